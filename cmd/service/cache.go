@@ -0,0 +1,29 @@
+package main
+
+import (
+	"github.com/iurikman/songs/internal/cache"
+	"github.com/iurikman/songs/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// newSongCache builds the configured cache backend, or returns nil when
+// caching is disabled. The returned func releases the backend.
+func newSongCache(cfg config.Config) (*cache.Cache, func()) {
+	switch cfg.CacheBackend {
+	case "memory":
+		return cache.New(cfg.CacheBackend, cache.NewMemory(cfg.CacheSize), cfg.CacheTTL), func() {}
+	case "redis":
+		backend := cache.NewRedis(cache.RedisConfig{
+			Addr:     cfg.CacheRedisAddr,
+			Password: cfg.CacheRedisPassword,
+		})
+
+		return cache.New(cfg.CacheBackend, backend, cfg.CacheTTL), func() {
+			if err := backend.Close(); err != nil {
+				log.Warnf("backend.Close() err: %v", err)
+			}
+		}
+	default:
+		return nil, func() {}
+	}
+}
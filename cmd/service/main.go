@@ -2,20 +2,31 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/iurikman/songs/internal/config"
+	"github.com/iurikman/songs/internal/events"
+	"github.com/iurikman/songs/internal/gql"
+	"github.com/iurikman/songs/internal/linkcheck"
+	"github.com/iurikman/songs/internal/logger"
+	"github.com/iurikman/songs/internal/models"
 	"github.com/iurikman/songs/internal/rest"
+	"github.com/iurikman/songs/internal/rpc"
 	"github.com/iurikman/songs/internal/service"
 	"github.com/iurikman/songs/internal/songdetails"
-	"github.com/iurikman/songs/internal/store"
+	"github.com/iurikman/songs/internal/telemetry"
 	_ "github.com/jackc/pgx/v5"
 	_ "github.com/jackc/pgx/v5/stdlib"
-	migrate "github.com/rubenv/sql-migrate"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
 )
 
+const tracingShutdownTimeout = 5 * time.Second
+
 // @title Songs API
 // @version 1.0
 // @description API for managing songs
@@ -24,49 +35,277 @@ import (
 // @BasePath /api/v1
 
 func main() {
-	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGQUIT, syscall.SIGHUP)
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM)
 	defer cancel()
 
-	cfg := config.NewConfig()
+	cfg, err := config.Load(os.Args[1:])
+
+	if cfg.PrintConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Panicf("cfg.Print(os.Stdout) err: %v", err)
+		}
+	}
+
+	if err != nil {
+		log.Panicf("config.Load err: %v", err)
+	}
+
+	if cfg.PrintConfig {
+		return
+	}
+
+	if err := logger.Setup(cfg.LogLevel, cfg.LogFormat); err != nil {
+		log.Panicf("logger.Setup(cfg.LogLevel, cfg.LogFormat) err: %v", err)
+	}
 
 	log.Debug("configuration initialized")
 
-	storeConfig := store.Config{
-		PGUser:     cfg.PostgresUser,
-		PGPassword: cfg.PostgresPassword,
-		PGHost:     cfg.PostgresHost,
-		PGPort:     cfg.PostgresPort,
-		PGDatabase: cfg.PostgresDatabase,
+	// Flags of the configuration come before the command, the command's own
+	// arguments after it. Without a command the server is started.
+	command, args := "serve", []string(nil)
+	if len(cfg.Command) > 0 {
+		command, args = cfg.Command[0], cfg.Command[1:]
 	}
 
-	db, err := store.New(ctx, storeConfig)
-	if err != nil {
-		log.Panicf("store.New(ctx, storeConfig) err: %v", err)
+	switch command {
+	case "serve":
+		if len(args) > 0 {
+			log.Panicf("serve takes no arguments, got %q; configuration flags go before the command", args)
+		}
+
+		serve(ctx, cfg)
+	case "migrate":
+		if err := runMigrate(ctx, cfg, args, os.Stdout); err != nil {
+			log.Panicf("migrate err: %v", err)
+		}
+	case "seed":
+		if err := runSeed(ctx, cfg, args, os.Stdout); err != nil {
+			log.Panicf("seed err: %v", err)
+		}
+	default:
+		log.Panicf("unknown command %q, expected serve, migrate or seed", command)
 	}
+}
 
-	if err := db.Migrate(migrate.Up); err != nil {
-		log.Panicf("db.Migrate(migrate.Up) err: %v", err)
+// serve runs the HTTP and gRPC servers and the background workers until ctx
+// is done.
+func serve(ctx context.Context, cfg config.Config) {
+	shutdownTracing, err := telemetry.Setup(ctx, telemetry.Config{
+		Exporter: cfg.TracingExporter,
+		Endpoint: cfg.TracingEndpoint,
+	})
+	if err != nil {
+		log.Panicf("telemetry.Setup(ctx, tracingConfig) err: %v", err)
 	}
 
-	log.Debug("successful migration")
+	defer func() {
+		ctxWithTimeout, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+		defer cancel()
+
+		if err := shutdownTracing(ctxWithTimeout); err != nil {
+			log.Warnf("shutdownTracing err: %v", err)
+		}
+	}()
+
+	db, postgres, closeStore := openStore(ctx, cfg)
+	defer closeStore()
 
-	songDetails := songdetails.NewSongDetails(cfg.APIUrl + cfg.APIPort)
+	prepareSchema(ctx, db, cfg.MigrationsOnStart)
 
-	svc := service.NewService(db, songDetails)
+	songDetails := songdetails.NewSongDetails(songDetailsConfig(cfg))
+
+	songCache, closeCache := newSongCache(cfg)
+	defer closeCache()
+
+	var (
+		svc      *service.Service
+		cachedDB *service.CachedDB
+	)
+
+	if songCache != nil {
+		cachedDB = service.NewCachedDB(db, songCache)
+		svc = service.NewService(cachedDB, songDetails)
+	} else {
+		svc = service.NewService(db, songDetails)
+	}
+
+	svc.SetDuplicateThreshold(cfg.DuplicateThreshold)
 
 	log.Debug("service initialized")
 
-	serverConfig := rest.SrvConfig{BindAddr: cfg.BindAddress}
+	serverConfig := rest.SrvConfig{
+		BindAddr:            cfg.BindAddress,
+		ReadHeaderTimeout:   cfg.ReadHeaderTimeout,
+		ReadTimeout:         cfg.ReadTimeout,
+		WriteTimeout:        cfg.WriteTimeout,
+		IdleTimeout:         cfg.IdleTimeout,
+		ShutdownTimeout:     cfg.ShutdownTimeout,
+		ReadinessDrainDelay: cfg.ReadinessDrainDelay,
+		DefaultPageSize:     cfg.DefaultPageSize,
+		MaxPageSize:         cfg.MaxPageSize,
+		RateLimit:           cfg.RateLimit,
+		RateBurst:           cfg.RateBurst,
+		SSEPollInterval:     cfg.SSEPollInterval,
+	}
+
+	// Writes only need tracking when reads may be served by a replica.
+	replicated := postgres != nil && len(cfg.PostgresReplicas()) > 0
+	if replicated {
+		serverConfig.StickyWindow = cfg.PostgresStickyWindow
+	}
+
+	reloader := config.NewReloader(cfg, os.Args[1:])
+
+	graphQL, err := gql.NewHandler(gql.Config{
+		DefaultPageSize: cfg.DefaultPageSize,
+		MaxPageSize:     cfg.MaxPageSize,
+		Limits: gql.Limits{
+			MaxDepth:      cfg.GraphQLMaxDepth,
+			MaxComplexity: cfg.GraphQLMaxComplexity,
+		},
+	}, svc)
+	if err != nil {
+		log.Panicf("gql.NewHandler(graphQLConfig, svc) err: %v", err)
+	}
+
+	deps := rest.Dependencies{
+		DB:          db,
+		SongDetails: songDetails,
+		Config:      reloader,
+		GraphQL:     graphQL,
+	}
+
+	if cachedDB != nil {
+		deps.Cache = cachedDB
+	}
+
+	if postgres != nil {
+		deps.Events = events.NewService(postgres)
+	}
+
+	if replicated {
+		deps.Replicas = postgres
+	}
+
+	if searchable, ok := db.(interface {
+		Search(ctx context.Context, query string, params models.Params) ([]*models.Song, error)
+	}); ok {
+		deps.Search = searchable
+	}
 
-	svr, err := rest.NewServer(serverConfig, svc)
+	svr, err := rest.NewServer(serverConfig, svc, deps)
 	if err != nil {
-		log.Panicf("rest.NewServer(serverConfig, svc) err: %v", err)
+		log.Panicf("rest.NewServer(serverConfig, svc, deps) err: %v", err)
 	}
 
 	log.Debug("rest server initialized")
 
-	if err := svr.Start(ctx); err != nil {
-		log.Panicf("svr.Start() err: %v", err)
+	reloader.Subscribe(func(cfg config.Config) {
+		if err := logger.Setup(cfg.LogLevel, cfg.LogFormat); err != nil {
+			log.Warnf("logger.Setup err: %v", err)
+		}
+
+		songDetails.Reconfigure(songDetailsConfig(cfg))
+		svr.SetPaging(cfg.DefaultPageSize, cfg.MaxPageSize)
+		graphQL.SetPaging(cfg.DefaultPageSize, cfg.MaxPageSize)
+		svr.SetRateLimit(cfg.RateLimit, cfg.RateBurst)
+		svc.SetDuplicateThreshold(cfg.DuplicateThreshold)
+	})
+
+	go reloadOnSIGHUP(ctx, reloader)
+
+	group, groupCtx := errgroup.WithContext(ctx)
+
+	group.Go(func() error {
+		if err := svr.Start(groupCtx); err != nil {
+			return fmt.Errorf("svr.Start() err: %w", err)
+		}
+
+		return nil
+	})
+
+	if postgres != nil {
+		listener, err := postgres.Listener()
+		if err != nil {
+			log.Panicf("postgres.Listener() err: %v", err)
+		}
+
+		if cachedDB != nil {
+			listener.Subscribe(cachedDB)
+		}
+
+		group.Go(func() error {
+			return listener.Run(groupCtx)
+		})
+
+		dispatcher := events.NewDispatcher(events.DispatcherConfig{
+			PollInterval: cfg.WebhookPollInterval,
+			MaxAttempts:  cfg.WebhookMaxAttempts,
+			BackoffBase:  cfg.WebhookBackoffBase,
+			BackoffMax:   cfg.WebhookBackoffMax,
+			Timeout:      cfg.WebhookTimeout,
+		}, postgres)
+
+		group.Go(func() error {
+			return dispatcher.Run(groupCtx)
+		})
+
+		group.Go(func() error {
+			return postgres.MonitorReplicas(groupCtx, cfg.PostgresReplicaCheckInterval)
+		})
+	}
+
+	if refresher, ok := db.(interface {
+		RefreshStatsEvery(ctx context.Context, interval time.Duration) error
+	}); ok && cfg.StatsRefreshInterval > 0 {
+		group.Go(func() error {
+			return refresher.RefreshStatsEvery(groupCtx, cfg.StatsRefreshInterval)
+		})
+	}
+
+	if cfg.LinkCheckInterval > 0 {
+		checker := linkcheck.NewChecker(linkcheck.Config{
+			Interval:        cfg.LinkCheckInterval,
+			PollInterval:    cfg.LinkCheckPollInterval,
+			MaxFailures:     cfg.LinkCheckMaxFailures,
+			Timeout:         cfg.LinkCheckTimeout,
+			Rate:            cfg.LinkCheckRate,
+			HostConcurrency: cfg.LinkCheckHostConcurrency,
+		}, db)
+
+		group.Go(func() error {
+			return checker.Run(groupCtx)
+		})
+	}
+
+	if cfg.GRPCBindAddress != "" {
+		grpcSvr, err := rpc.NewServer(rpc.SrvConfig{
+			BindAddr:        cfg.GRPCBindAddress,
+			ShutdownTimeout: cfg.ShutdownTimeout,
+			DefaultPageSize: cfg.DefaultPageSize,
+			MaxPageSize:     cfg.MaxPageSize,
+		}, svc)
+		if err != nil {
+			log.Panicf("rpc.NewServer(grpcConfig, svc) err: %v", err)
+		}
+
+		log.Debug("grpc server initialized")
+
+		reloader.Subscribe(func(cfg config.Config) {
+			grpcSvr.SetPaging(cfg.DefaultPageSize, cfg.MaxPageSize)
+		})
+
+		group.Go(func() error {
+			if err := grpcSvr.Start(groupCtx); err != nil {
+				return fmt.Errorf("grpcSvr.Start() err: %w", err)
+			}
+
+			return nil
+		})
+	}
+
+	if err := group.Wait(); err != nil {
+		log.Panicf("server err: %v", err)
 	}
 
 	log.Info("server stopped")
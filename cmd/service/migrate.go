@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/iurikman/songs/internal/config"
+	"github.com/iurikman/songs/internal/store"
+	migrate "github.com/rubenv/sql-migrate"
+)
+
+// migrationVersionLayout matches the names of the existing migrations.
+const migrationVersionLayout = "20060201150405"
+
+var (
+	errMigrateUsage = errors.New("usage: migrate up|down|redo [-n N] [--dry-run] | status | create [--dir DIR] <name>")
+	errNoMigrations = errors.New("the store has no migrations")
+	errBadName      = errors.New("migration name must consist of letters, digits, '-' and '_'")
+
+	migrationName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+)
+
+// runMigrate runs the migrate command: args are the action and its flags,
+// output goes to w.
+func runMigrate(ctx context.Context, cfg config.Config, args []string, w io.Writer) error {
+	if len(args) == 0 {
+		return errMigrateUsage
+	}
+
+	action := args[0]
+
+	fs := flag.NewFlagSet("migrate "+action, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	count := fs.Int("n", 0, "number of migrations, all for up and one for down and redo by default")
+	dryRun := fs.Bool("dry-run", false, "print the SQL instead of running it")
+	dir := fs.String("dir", "", "directory to create the migration in")
+
+	positional, err := parseInterspersed(fs, args[1:])
+	if err != nil {
+		return fmt.Errorf("%w: %w", errMigrateUsage, err)
+	}
+
+	if action == "create" {
+		if len(positional) != 1 {
+			return errMigrateUsage
+		}
+
+		return createMigration(cfg, *dir, positional[0], w)
+	}
+
+	if len(positional) > 0 || *count < 0 {
+		return errMigrateUsage
+	}
+
+	db, _, closeStore := openStore(ctx, cfg)
+	defer closeStore()
+
+	migrated, ok := db.(migratable)
+	if !ok {
+		return fmt.Errorf("%w: %s", errNoMigrations, db.Name())
+	}
+
+	migrator, err := migrated.Migrator()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = migrator.Close()
+	}()
+
+	switch action {
+	case "up":
+		return migrateStep(migrator, migrate.Up, *count, *dryRun, w)
+	case "down":
+		return migrateStep(migrator, migrate.Down, max(*count, 1), *dryRun, w)
+	case "redo":
+		return redoMigrations(migrator, max(*count, 1), *dryRun, w)
+	case "status":
+		return printMigrationStatus(migrator, w)
+	default:
+		return errMigrateUsage
+	}
+}
+
+// parseInterspersed parses fs from args allowing flags after positional
+// arguments, which the flag package stops at, and returns the positional ones.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+func migrateStep(migrator *store.Migrator, direction migrate.MigrationDirection, count int, dryRun bool, w io.Writer) error {
+	if dryRun {
+		planned, err := migrator.Plan(direction, count)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range planned {
+			printQueries(w, migration.Id, direction, migration.Queries)
+		}
+
+		return nil
+	}
+
+	applied, err := migrator.Exec(direction, count)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "applied %d migrations %s\n", applied, directionName(direction))
+
+	return err //nolint:wrapcheck
+}
+
+// redoMigrations rolls back the last count migrations and applies them again.
+func redoMigrations(migrator *store.Migrator, count int, dryRun bool, w io.Writer) error {
+	if !dryRun {
+		if err := migrateStep(migrator, migrate.Down, count, false, w); err != nil {
+			return err
+		}
+
+		return migrateStep(migrator, migrate.Up, count, false, w)
+	}
+
+	planned, err := migrator.Plan(migrate.Down, count)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range planned {
+		printQueries(w, migration.Id, migrate.Down, migration.Queries)
+	}
+
+	for i := len(planned) - 1; i >= 0; i-- {
+		printQueries(w, planned[i].Id, migrate.Up, planned[i].Up)
+	}
+
+	return nil
+}
+
+func printQueries(w io.Writer, id string, direction migrate.MigrationDirection, queries []string) {
+	fmt.Fprintf(w, "-- %s (%s)\n", id, directionName(direction))
+
+	for _, query := range queries {
+		fmt.Fprintln(w, strings.TrimSpace(query))
+	}
+
+	fmt.Fprintln(w)
+}
+
+func directionName(direction migrate.MigrationDirection) string {
+	if direction == migrate.Down {
+		return "down"
+	}
+
+	return "up"
+}
+
+func printMigrationStatus(migrator *store.Migrator, w io.Writer) error {
+	statuses, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "MIGRATION\tAPPLIED")
+
+	for _, status := range statuses {
+		applied := "pending"
+		if status.AppliedAt != nil {
+			applied = status.AppliedAt.Format(time.RFC3339)
+		}
+
+		fmt.Fprintf(table, "%s\t%s\n", status.ID, applied)
+	}
+
+	return table.Flush() //nolint:wrapcheck
+}
+
+// createMigration writes an empty migration named after the current time to
+// dir, by default the embedded migrations of the configured store. The
+// version always sorts after the existing migrations, so they keep being
+// applied in the order they were written.
+func createMigration(cfg config.Config, dir, name string, w io.Writer) error {
+	if !migrationName.MatchString(name) {
+		return errBadName
+	}
+
+	if dir == "" {
+		dir = filepath.Join("internal", "store", "migrations")
+		if backend, _ := cfg.StoreBackend(); backend == "sqlite" {
+			dir = filepath.Join("internal", "store", "sqlite_migrations")
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("os.ReadDir(%s) err: %w", dir, err)
+	}
+
+	version := time.Now().UTC().Format(migrationVersionLayout)
+
+	for _, entry := range entries {
+		existing, _, _ := strings.Cut(entry.Name(), "_")
+		if len(existing) == len(version) && existing >= version {
+			version = nextVersion(existing)
+		}
+	}
+
+	path := filepath.Join(dir, version+"_"+name+".sql")
+
+	//nolint:gosec
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("os.OpenFile(%s) err: %w", path, err)
+	}
+
+	if _, err := io.WriteString(file, "-- +migrate Up\n\n-- +migrate Down\n"); err != nil {
+		_ = file.Close()
+
+		return fmt.Errorf("writing %s err: %w", path, err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("closing %s err: %w", path, err)
+	}
+
+	_, err = fmt.Fprintln(w, path)
+
+	return err //nolint:wrapcheck
+}
+
+// nextVersion increments a version of digits by one.
+func nextVersion(version string) string {
+	digits := []byte(version)
+
+	for i := len(digits) - 1; i >= 0; i-- {
+		if digits[i] < '9' {
+			digits[i]++
+
+			return string(digits)
+		}
+
+		digits[i] = '0'
+	}
+
+	return "1" + string(digits)
+}
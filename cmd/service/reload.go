@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/iurikman/songs/internal/config"
+	"github.com/iurikman/songs/internal/songdetails"
+	log "github.com/sirupsen/logrus"
+)
+
+// reloadOnSIGHUP re-reads the configuration every time the process receives
+// SIGHUP until ctx is done. A failed reload keeps the running configuration.
+func reloadOnSIGHUP(ctx context.Context, reloader *config.Reloader) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			log.Info("SIGHUP received, reloading configuration")
+
+			if err := reloader.Reload(); err != nil {
+				log.Errorf("configuration reload rejected: %v", err)
+			}
+		}
+	}
+}
+
+func songDetailsConfig(cfg config.Config) songdetails.Config {
+	return songdetails.Config{
+		Host:             cfg.SongDetailsURL(),
+		Timeout:          cfg.APITimeout,
+		FailureThreshold: cfg.APIFailureThreshold,
+		OpenTimeout:      cfg.APIOpenTimeout,
+	}
+}
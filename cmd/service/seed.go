@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/iurikman/songs/internal/config"
+	"github.com/iurikman/songs/internal/seed"
+)
+
+var errSeedUsage = errors.New("usage: seed [--generate N] [--random-seed S] [fixture.yaml|fixture.json ...]")
+
+// runSeed runs the seed command: args are fixture files and flags, output
+// goes to w. The schema is prepared like the server does before loading.
+func runSeed(ctx context.Context, cfg config.Config, args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	generate := fs.Int("generate", 0, "number of synthetic songs to generate")
+	randomSeed := fs.Uint64("random-seed", 1, "seed of the synthetic songs, the same seed yields the same songs")
+
+	paths, err := parseInterspersed(fs, args)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errSeedUsage, err)
+	}
+
+	if *generate < 0 || (*generate == 0 && len(paths) == 0) {
+		return errSeedUsage
+	}
+
+	db, _, closeStore := openStore(ctx, cfg)
+	defer closeStore()
+
+	prepareSchema(ctx, db, cfg.MigrationsOnStart)
+
+	report, err := seed.LoadFiles(ctx, db, paths...)
+	if err != nil {
+		return err
+	}
+
+	if *generate > 0 {
+		generated, err := seed.Load(ctx, db, seed.Generate(*generate, *randomSeed))
+		if err != nil {
+			return err
+		}
+
+		report.Created += generated.Created
+		report.Existing += generated.Existing
+	}
+
+	_, err = fmt.Fprintf(w, "created %d songs, %d already existed, skipped %d translations\n",
+		report.Created, report.Existing, report.SkippedTranslations)
+
+	return err //nolint:wrapcheck
+}
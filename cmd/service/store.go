@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/config"
+	"github.com/iurikman/songs/internal/models"
+	"github.com/iurikman/songs/internal/store"
+	migrate "github.com/rubenv/sql-migrate"
+	log "github.com/sirupsen/logrus"
+)
+
+// songStore is what every store backend provides: the songs the service and
+// the link checker work with and the probes of the health endpoints.
+type songStore interface {
+	CreateSong(ctx context.Context, song models.Song) (*models.Song, error)
+	GetSongs(ctx context.Context, params models.Params) ([]*models.Song, error)
+	GetText(ctx context.Context, id uuid.UUID, verse int) (*string, error)
+	DeleteSong(ctx context.Context, id uuid.UUID) error
+	UpdateSong(ctx context.Context, id uuid.UUID, song models.Song) (*models.Song, error)
+	GetSongsByIDs(ctx context.Context, ids []uuid.UUID) ([]*models.Song, error)
+	GetSongsByGroups(ctx context.Context, groups []string) ([]*models.Song, error)
+	GetGroups(ctx context.Context, params models.Params) ([]string, error)
+	SimilarSongs(ctx context.Context, song models.Song, threshold float64, limit int) ([]*models.SimilarSong, error)
+	SimilarSongPairs(ctx context.Context, threshold float64) ([]models.SongPair, error)
+	MergeSongs(ctx context.Context, id uuid.UUID, song models.Song, sources []uuid.UUID) (*models.Song, error)
+	MergedInto(ctx context.Context, id uuid.UUID) (uuid.UUID, error)
+	GetLinks(ctx context.Context, songID uuid.UUID) ([]*models.SongLink, error)
+	CreateLink(ctx context.Context, link models.SongLink) (*models.SongLink, error)
+	UpdateLink(ctx context.Context, link models.SongLink) (*models.SongLink, error)
+	DeleteLink(ctx context.Context, songID, id uuid.UUID) error
+	ClaimLinks(ctx context.Context, limit int, interval time.Duration) ([]*models.SongLink, error)
+	RecordLinkCheck(ctx context.Context, id uuid.UUID, check models.LinkCheck, maxFailures int) (*models.LinkCheck, error)
+	BrokenLinks(ctx context.Context, params models.Params) ([]*models.BrokenLink, error)
+	GetTags(ctx context.Context) ([]*models.Tag, error)
+	CreateTag(ctx context.Context, tag models.Tag) (*models.Tag, error)
+	UpdateTag(ctx context.Context, tag models.Tag) (*models.Tag, error)
+	DeleteTag(ctx context.Context, id uuid.UUID) error
+	GetSongTags(ctx context.Context, songID uuid.UUID) ([]*models.Tag, error)
+	SetSongTags(ctx context.Context, songID uuid.UUID, slugs []string) ([]*models.Tag, error)
+	TagFacets(ctx context.Context, params models.Params) ([]*models.TagFacet, error)
+	RelatedSongs(ctx context.Context, id uuid.UUID, params models.Params) ([]*models.RelatedSong, error)
+	GetStats(ctx context.Context, params models.Params) (*models.Stats, error)
+	Name() string
+	Ping(ctx context.Context) error
+	PendingMigrations(ctx context.Context) (int, error)
+}
+
+// openStore opens the configured store, leaving its schema alone, and returns
+// a func closing it. The Postgres store is also returned on its own, it is the
+// only one backing the change feed, webhooks and cache invalidation across
+// instances; it is nil for the other backends.
+func openStore(ctx context.Context, cfg config.Config) (songStore, *store.Postgres, func()) {
+	backend, target := cfg.StoreBackend()
+
+	switch backend {
+	case "memory":
+		log.Warn("using the in-memory store, data is lost on restart")
+
+		return store.NewMemory(), nil, func() {}
+	case "sqlite":
+		db, err := store.NewSQLite(target)
+		if err != nil {
+			log.Panicf("store.NewSQLite(path) err: %v", err)
+		}
+
+		return db, nil, func() {
+			if err := db.Close(); err != nil {
+				log.Warnf("db.Close() err: %v", err)
+			}
+		}
+	}
+
+	db, err := store.New(ctx, store.Config{
+		DSN:        target,
+		PGUser:     cfg.PostgresUser,
+		PGPassword: cfg.PostgresPassword,
+		PGHost:     cfg.PostgresHost,
+		PGPort:     cfg.PostgresPort,
+		PGDatabase: cfg.PostgresDatabase,
+
+		MaxConns:       int32(cfg.PostgresMaxConns), //nolint:gosec
+		MinConns:       int32(cfg.PostgresMinConns), //nolint:gosec
+		ConnectTimeout: cfg.PostgresConnectTimeout,
+
+		ReplicaDSNs:   cfg.PostgresReplicas(),
+		ReplicaMaxLag: cfg.PostgresReplicaMaxLag,
+		StickyWindow:  cfg.PostgresStickyWindow,
+	})
+	if err != nil {
+		log.Panicf("store.New(ctx, storeConfig) err: %v", err)
+	}
+
+	return db, db, db.Close
+}
+
+// migratable is a store with embedded migrations.
+type migratable interface {
+	Migrate(direction migrate.MigrationDirection) error
+	Migrator() (*store.Migrator, error)
+}
+
+// prepareSchema handles the migrations before the store serves requests:
+// "auto" applies the pending ones, "check" refuses to start while any are
+// pending and "off" leaves the schema alone.
+func prepareSchema(ctx context.Context, db songStore, mode string) {
+	switch mode {
+	case "off":
+		return
+	case "check":
+		pending, err := db.PendingMigrations(ctx)
+		if err != nil {
+			log.Panicf("db.PendingMigrations(ctx) err: %v", err)
+		}
+
+		if pending > 0 {
+			log.Panicf("%d migrations are pending, apply them with the migrate up command", pending)
+		}
+	default:
+		migrated, ok := db.(migratable)
+		if !ok {
+			return
+		}
+
+		if err := migrated.Migrate(migrate.Up); err != nil {
+			log.Panicf("db.Migrate(migrate.Up) err: %v", err)
+		}
+
+		log.Debug("successful migration")
+	}
+}
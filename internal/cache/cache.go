@@ -0,0 +1,192 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/iurikman/songs/internal/logger"
+	"golang.org/x/sync/singleflight"
+)
+
+// Backend stores encoded values. Implementations must be safe for concurrent
+// use; a miss is reported as ok == false, not as an error.
+type Backend interface {
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+	DeletePrefix(ctx context.Context, prefix string) error
+	Purge(ctx context.Context) error
+}
+
+// Stats are the counters of a Cache since it was created.
+type Stats struct {
+	Backend       string `json:"backend"`
+	Hits          int64  `json:"hits"`
+	Misses        int64  `json:"misses"`
+	Coalesced     int64  `json:"coalesced"`
+	Errors        int64  `json:"errors"`
+	Invalidations int64  `json:"invalidations"`
+}
+
+// Cache is a read-through cache over a Backend. Concurrent loads of the same
+// key are coalesced into one, and backend failures degrade to loading from
+// the source instead of failing the request.
+//
+// Every invalidation starts a new generation. A load only caches its result
+// if no invalidation happened since it started, as it may have read what the
+// invalidating write changed, and loads are only coalesced within a
+// generation, so a read following a write never joins a load preceding it.
+type Cache struct {
+	backend Backend
+	name    string
+	ttl     time.Duration
+	group   singleflight.Group
+
+	// mu orders the invalidations with the results cached, generation counts
+	// the invalidations.
+	mu         sync.RWMutex
+	generation uint64
+
+	hits          atomic.Int64
+	misses        atomic.Int64
+	coalesced     atomic.Int64
+	errors        atomic.Int64
+	invalidations atomic.Int64
+}
+
+func New(name string, backend Backend, ttl time.Duration) *Cache {
+	return &Cache{backend: backend, name: name, ttl: ttl}
+}
+
+func (c *Cache) Stats() Stats {
+	return Stats{
+		Backend:       c.name,
+		Hits:          c.hits.Load(),
+		Misses:        c.misses.Load(),
+		Coalesced:     c.coalesced.Load(),
+		Errors:        c.errors.Load(),
+		Invalidations: c.invalidations.Load(),
+	}
+}
+
+// Fetch returns the value cached under key, calling load and caching its
+// result on a miss. Errors of load are returned and not cached.
+func Fetch[T any](ctx context.Context, c *Cache, key string, load func(ctx context.Context) (T, error)) (T, error) {
+	var value T
+
+	data, ok, err := c.backend.Get(ctx, key)
+	if err != nil {
+		c.backendFailed(ctx, "get", err)
+	}
+
+	if ok {
+		if err := json.Unmarshal(data, &value); err == nil {
+			c.hits.Add(1)
+
+			return value, nil
+		}
+
+		c.backendFailed(ctx, "decode", err)
+	}
+
+	c.misses.Add(1)
+
+	// The first caller loads for everybody waiting on the key, so its
+	// cancellation must not fail the others.
+	loadCtx := context.WithoutCancel(ctx)
+	generation := c.currentGeneration()
+
+	result, err, shared := c.group.Do(strconv.FormatUint(generation, 10)+":"+key, func() (any, error) {
+		loaded, err := load(loadCtx)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := json.Marshal(loaded)
+		if err != nil {
+			return nil, fmt.Errorf("json.Marshal err: %w", err)
+		}
+
+		c.set(loadCtx, key, data, generation)
+
+		return loaded, nil
+	})
+	if shared {
+		c.coalesced.Add(1)
+	}
+
+	if err != nil {
+		return value, err
+	}
+
+	return result.(T), nil //nolint:forcetypeassert
+}
+
+// set caches data under key unless the generation loading it is over.
+func (c *Cache) set(ctx context.Context, key string, data []byte, generation uint64) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.generation != generation {
+		return
+	}
+
+	if err := c.backend.Set(ctx, key, data, c.ttl); err != nil {
+		c.backendFailed(ctx, "set", err)
+	}
+}
+
+func (c *Cache) currentGeneration() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.generation
+}
+
+// invalidate starts a new generation. It comes before dropping the keys, so
+// that a load of the generation over either cached its result before, and
+// the result is dropped with the keys, or does not cache it at all.
+func (c *Cache) invalidate() {
+	c.invalidations.Add(1)
+
+	c.mu.Lock()
+	c.generation++
+	c.mu.Unlock()
+}
+
+// Delete drops the given keys.
+func (c *Cache) Delete(ctx context.Context, keys ...string) {
+	c.invalidate()
+
+	if err := c.backend.Delete(ctx, keys...); err != nil {
+		c.backendFailed(ctx, "delete", err)
+	}
+}
+
+// DeletePrefix drops every key starting with prefix.
+func (c *Cache) DeletePrefix(ctx context.Context, prefix string) {
+	c.invalidate()
+
+	if err := c.backend.DeletePrefix(ctx, prefix); err != nil {
+		c.backendFailed(ctx, "delete prefix", err)
+	}
+}
+
+// Purge drops everything.
+func (c *Cache) Purge(ctx context.Context) {
+	c.invalidate()
+
+	if err := c.backend.Purge(ctx); err != nil {
+		c.backendFailed(ctx, "purge", err)
+	}
+}
+
+func (c *Cache) backendFailed(ctx context.Context, op string, err error) {
+	c.errors.Add(1)
+	logger.FromContext(ctx).WithError(err).WithField("op", op).Warn("cache backend failed")
+}
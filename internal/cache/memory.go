@@ -0,0 +1,136 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultMemorySize = 10000
+
+// Memory is an LRU Backend holding at most size entries in process memory.
+type Memory struct {
+	mu    sync.Mutex
+	size  int
+	items map[string]*list.Element
+	order *list.List
+	now   func() time.Time
+}
+
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+func NewMemory(size int) *Memory {
+	if size <= 0 {
+		size = defaultMemorySize
+	}
+
+	return &Memory{
+		size:  size,
+		items: make(map[string]*list.Element, size),
+		order: list.New(),
+		now:   time.Now,
+	}
+}
+
+func (m *Memory) Get(_ context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	elem, ok := m.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+
+	entry := elem.Value.(*memoryEntry) //nolint:forcetypeassert
+
+	if !entry.expires.IsZero() && !m.now().Before(entry.expires) {
+		m.remove(elem)
+
+		return nil, false, nil
+	}
+
+	m.order.MoveToFront(elem)
+
+	return entry.value, true, nil
+}
+
+func (m *Memory) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var expires time.Time
+	if ttl > 0 {
+		expires = m.now().Add(ttl)
+	}
+
+	if elem, ok := m.items[key]; ok {
+		entry := elem.Value.(*memoryEntry) //nolint:forcetypeassert
+		entry.value, entry.expires = value, expires
+		m.order.MoveToFront(elem)
+
+		return nil
+	}
+
+	m.items[key] = m.order.PushFront(&memoryEntry{key: key, value: value, expires: expires})
+
+	for m.order.Len() > m.size {
+		m.remove(m.order.Back())
+	}
+
+	return nil
+}
+
+func (m *Memory) Delete(_ context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range keys {
+		if elem, ok := m.items[key]; ok {
+			m.remove(elem)
+		}
+	}
+
+	return nil
+}
+
+func (m *Memory) DeletePrefix(_ context.Context, prefix string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, elem := range m.items {
+		if strings.HasPrefix(key, prefix) {
+			m.remove(elem)
+		}
+	}
+
+	return nil
+}
+
+func (m *Memory) Purge(_ context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	clear(m.items)
+	m.order.Init()
+
+	return nil
+}
+
+// Len returns the number of entries, expired ones included.
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.order.Len()
+}
+
+func (m *Memory) remove(elem *list.Element) {
+	m.order.Remove(elem)
+	delete(m.items, elem.Value.(*memoryEntry).key) //nolint:forcetypeassert
+}
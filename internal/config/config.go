@@ -1,43 +1,256 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 	log "github.com/sirupsen/logrus"
 )
 
+// Config holds every tunable of the service. Values are layered, each source
+// overriding the previous one: defaults < config file < environment < flags.
+//
+// The yaml/toml tags name the key in a config file, env the environment
+// variable and flag the command line flag. Fields tagged secret are redacted
+// when the configuration is printed, and fields tagged reload may be changed
+// on a running process by Reloader.
 type Config struct {
-	BindAddress string
+	ConfigFile  string `yaml:"-" toml:"-" env:"CONFIG_FILE" flag:"config"`
+	PrintConfig bool   `yaml:"-" toml:"-" flag:"print-config"`
+	// Command holds the arguments after the flags: the subcommand to run and
+	// its own arguments.
+	Command []string `yaml:"-" toml:"-"`
 
-	PostgresHost     string
-	PostgresPort     string
-	PostgresDatabase string
-	PostgresUser     string
-	PostgresPassword string
+	BindAddress          string        `yaml:"bind_address" toml:"bind_address" env:"BIND_ADDRESS" flag:"bind-address"`
+	GRPCBindAddress      string        `yaml:"grpc_bind_address" toml:"grpc_bind_address" env:"GRPC_BIND_ADDRESS" flag:"grpc-bind-address"`
+	ReadHeaderTimeout    time.Duration `yaml:"read_header_timeout" toml:"read_header_timeout" env:"READ_HEADER_TIMEOUT" flag:"read-header-timeout"`
+	ReadTimeout          time.Duration `yaml:"read_timeout" toml:"read_timeout" env:"READ_TIMEOUT" flag:"read-timeout"`
+	WriteTimeout         time.Duration `yaml:"write_timeout" toml:"write_timeout" env:"WRITE_TIMEOUT" flag:"write-timeout"`
+	IdleTimeout          time.Duration `yaml:"idle_timeout" toml:"idle_timeout" env:"IDLE_TIMEOUT" flag:"idle-timeout"`
+	ShutdownTimeout      time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" flag:"shutdown-timeout"`
+	ReadinessDrainDelay  time.Duration `yaml:"readiness_drain_delay" toml:"readiness_drain_delay" env:"READINESS_DRAIN_DELAY" flag:"readiness-drain-delay"`
+	DefaultPageSize      int           `yaml:"default_page_size" toml:"default_page_size" env:"DEFAULT_PAGE_SIZE" flag:"default-page-size" reload:"true"`
+	MaxPageSize          int           `yaml:"max_page_size" toml:"max_page_size" env:"MAX_PAGE_SIZE" flag:"max-page-size" reload:"true"`
+	GraphQLMaxDepth      int           `yaml:"graphql_max_depth" toml:"graphql_max_depth" env:"GRAPHQL_MAX_DEPTH" flag:"graphql-max-depth"`
+	GraphQLMaxComplexity int           `yaml:"graphql_max_complexity" toml:"graphql_max_complexity" env:"GRAPHQL_MAX_COMPLEXITY" flag:"graphql-max-complexity"`
+	RateLimit            float64       `yaml:"rate_limit" toml:"rate_limit" env:"RATE_LIMIT" flag:"rate-limit" reload:"true"`
+	RateBurst            int           `yaml:"rate_burst" toml:"rate_burst" env:"RATE_BURST" flag:"rate-burst" reload:"true"`
+	SSEPollInterval      time.Duration `yaml:"sse_poll_interval" toml:"sse_poll_interval" env:"SSE_POLL_INTERVAL" flag:"sse-poll-interval"`
+	// DuplicateThreshold is the trigram similarity of names and groups from
+	// which a new song is rejected as a probable duplicate.
+	DuplicateThreshold float64 `yaml:"duplicate_threshold" toml:"duplicate_threshold" env:"DUPLICATE_THRESHOLD" flag:"duplicate-threshold" reload:"true"`
 
-	APIUrl  string
-	APIPort string
+	WebhookPollInterval time.Duration `yaml:"webhook_poll_interval" toml:"webhook_poll_interval" env:"WEBHOOK_POLL_INTERVAL" flag:"webhook-poll-interval"`
+	WebhookMaxAttempts  int           `yaml:"webhook_max_attempts" toml:"webhook_max_attempts" env:"WEBHOOK_MAX_ATTEMPTS" flag:"webhook-max-attempts"`
+	WebhookBackoffBase  time.Duration `yaml:"webhook_backoff_base" toml:"webhook_backoff_base" env:"WEBHOOK_BACKOFF_BASE" flag:"webhook-backoff-base"`
+	WebhookBackoffMax   time.Duration `yaml:"webhook_backoff_max" toml:"webhook_backoff_max" env:"WEBHOOK_BACKOFF_MAX" flag:"webhook-backoff-max"`
+	WebhookTimeout      time.Duration `yaml:"webhook_timeout" toml:"webhook_timeout" env:"WEBHOOK_TIMEOUT" flag:"webhook-timeout"`
+
+	// LinkCheckInterval is how often each song link is checked, 0 disables
+	// the checker.
+	LinkCheckInterval        time.Duration `yaml:"link_check_interval" toml:"link_check_interval" env:"LINK_CHECK_INTERVAL" flag:"link-check-interval"`
+	LinkCheckPollInterval    time.Duration `yaml:"link_check_poll_interval" toml:"link_check_poll_interval" env:"LINK_CHECK_POLL_INTERVAL" flag:"link-check-poll-interval"`
+	LinkCheckMaxFailures     int           `yaml:"link_check_max_failures" toml:"link_check_max_failures" env:"LINK_CHECK_MAX_FAILURES" flag:"link-check-max-failures"`
+	LinkCheckTimeout         time.Duration `yaml:"link_check_timeout" toml:"link_check_timeout" env:"LINK_CHECK_TIMEOUT" flag:"link-check-timeout"`
+	LinkCheckRate            float64       `yaml:"link_check_rate" toml:"link_check_rate" env:"LINK_CHECK_RATE" flag:"link-check-rate"`
+	LinkCheckHostConcurrency int           `yaml:"link_check_host_concurrency" toml:"link_check_host_concurrency" env:"LINK_CHECK_HOST_CONCURRENCY" flag:"link-check-host-concurrency"`
+
+	Store             string `yaml:"store" toml:"store" env:"STORE" flag:"store"`
+	StoreDSN          string `yaml:"store_dsn" toml:"store_dsn" env:"STORE_DSN" flag:"store-dsn" secret:"true"`
+	MigrationsOnStart string `yaml:"migrations_on_start" toml:"migrations_on_start" env:"MIGRATIONS_ON_START" flag:"migrations-on-start"`
+
+	PostgresHost           string        `yaml:"postgres_host" toml:"postgres_host" env:"POSTGRES_HOST" flag:"postgres-host"`
+	PostgresPort           string        `yaml:"postgres_port" toml:"postgres_port" env:"POSTGRES_PORT" flag:"postgres-port"`
+	PostgresDatabase       string        `yaml:"postgres_database" toml:"postgres_database" env:"POSTGRES_DATABASE" flag:"postgres-database"`
+	PostgresUser           string        `yaml:"postgres_user" toml:"postgres_user" env:"POSTGRES_USER" flag:"postgres-user"`
+	PostgresPassword       string        `yaml:"postgres_password" toml:"postgres_password" env:"POSTGRES_PASSWORD" flag:"postgres-password" secret:"true"`
+	PostgresMaxConns       int           `yaml:"postgres_max_conns" toml:"postgres_max_conns" env:"POSTGRES_MAX_CONNS" flag:"postgres-max-conns"`
+	PostgresMinConns       int           `yaml:"postgres_min_conns" toml:"postgres_min_conns" env:"POSTGRES_MIN_CONNS" flag:"postgres-min-conns"`
+	PostgresConnectTimeout time.Duration `yaml:"postgres_connect_timeout" toml:"postgres_connect_timeout" env:"POSTGRES_CONNECT_TIMEOUT" flag:"postgres-connect-timeout"`
+
+	PostgresReplicaDSNs          string        `yaml:"postgres_replica_dsns" toml:"postgres_replica_dsns" env:"POSTGRES_REPLICA_DSNS" flag:"postgres-replica-dsns" secret:"true"`
+	PostgresReplicaMaxLag        time.Duration `yaml:"postgres_replica_max_lag" toml:"postgres_replica_max_lag" env:"POSTGRES_REPLICA_MAX_LAG" flag:"postgres-replica-max-lag"`
+	PostgresReplicaCheckInterval time.Duration `yaml:"postgres_replica_check_interval" toml:"postgres_replica_check_interval" env:"POSTGRES_REPLICA_CHECK_INTERVAL" flag:"postgres-replica-check-interval"`
+	PostgresStickyWindow         time.Duration `yaml:"postgres_sticky_window" toml:"postgres_sticky_window" env:"POSTGRES_STICKY_WINDOW" flag:"postgres-sticky-window"`
+
+	// StatsRefreshInterval is how often the Postgres and SQLite stores refresh
+	// the statistics and the weights of the terms of lyrics, 0 disables the
+	// refresh.
+	StatsRefreshInterval time.Duration `yaml:"stats_refresh_interval" toml:"stats_refresh_interval" env:"STATS_REFRESH_INTERVAL" flag:"stats-refresh-interval"`
+
+	APIUrl              string        `yaml:"api_url" toml:"api_url" env:"API_URL" flag:"api-url" reload:"true"`
+	APIPort             string        `yaml:"api_port" toml:"api_port" env:"API_PORT" flag:"api-port" reload:"true"`
+	APITimeout          time.Duration `yaml:"api_timeout" toml:"api_timeout" env:"API_TIMEOUT" flag:"api-timeout" reload:"true"`
+	APIFailureThreshold int           `yaml:"api_failure_threshold" toml:"api_failure_threshold" env:"API_FAILURE_THRESHOLD" flag:"api-failure-threshold" reload:"true"`
+	APIOpenTimeout      time.Duration `yaml:"api_open_timeout" toml:"api_open_timeout" env:"API_OPEN_TIMEOUT" flag:"api-open-timeout" reload:"true"`
+
+	CacheBackend       string        `yaml:"cache_backend" toml:"cache_backend" env:"CACHE_BACKEND" flag:"cache-backend"`
+	CacheSize          int           `yaml:"cache_size" toml:"cache_size" env:"CACHE_SIZE" flag:"cache-size"`
+	CacheTTL           time.Duration `yaml:"cache_ttl" toml:"cache_ttl" env:"CACHE_TTL" flag:"cache-ttl"`
+	CacheRedisAddr     string        `yaml:"cache_redis_addr" toml:"cache_redis_addr" env:"CACHE_REDIS_ADDR" flag:"cache-redis-addr"`
+	CacheRedisPassword string        `yaml:"cache_redis_password" toml:"cache_redis_password" env:"CACHE_REDIS_PASSWORD" flag:"cache-redis-password" secret:"true"`
+
+	TracingExporter string `yaml:"tracing_exporter" toml:"tracing_exporter" env:"TRACING_EXPORTER" flag:"tracing-exporter"`
+	TracingEndpoint string `yaml:"tracing_endpoint" toml:"tracing_endpoint" env:"TRACING_ENDPOINT" flag:"tracing-endpoint"`
+
+	LogLevel  string `yaml:"log_level" toml:"log_level" env:"LOG_LEVEL" flag:"log-level" reload:"true"`
+	LogFormat string `yaml:"log_format" toml:"log_format" env:"LOG_FORMAT" flag:"log-format" reload:"true"`
+}
+
+// Default returns the configuration used when no source overrides a value.
+func Default() Config {
+	return Config{
+		BindAddress:          ":8080",
+		GRPCBindAddress:      ":9090",
+		ReadHeaderTimeout:    5 * time.Second,
+		ReadTimeout:          15 * time.Second,
+		WriteTimeout:         15 * time.Second,
+		IdleTimeout:          60 * time.Second,
+		ShutdownTimeout:      5 * time.Second,
+		ReadinessDrainDelay:  3 * time.Second,
+		DefaultPageSize:      10,
+		MaxPageSize:          100,
+		RateLimit:            0,
+		GraphQLMaxDepth:      8,
+		GraphQLMaxComplexity: 5000,
+		RateBurst:            50,
+		SSEPollInterval:      time.Second,
+		DuplicateThreshold:   0.7,
+
+		WebhookPollInterval: time.Second,
+		WebhookMaxAttempts:  8,
+		WebhookBackoffBase:  5 * time.Second,
+		WebhookBackoffMax:   time.Hour,
+		WebhookTimeout:      10 * time.Second,
+
+		LinkCheckInterval:        24 * time.Hour,
+		LinkCheckPollInterval:    time.Minute,
+		LinkCheckMaxFailures:     3,
+		LinkCheckTimeout:         10 * time.Second,
+		LinkCheckRate:            5,
+		LinkCheckHostConcurrency: 2,
+
+		Store:             "postgres",
+		MigrationsOnStart: "auto",
+
+		PostgresHost:           "localhost",
+		PostgresPort:           "5432",
+		PostgresDatabase:       "postgres",
+		PostgresMaxConns:       10,
+		PostgresMinConns:       0,
+		PostgresConnectTimeout: 5 * time.Second,
+
+		PostgresReplicaMaxLag:        5 * time.Second,
+		PostgresReplicaCheckInterval: 5 * time.Second,
+		PostgresStickyWindow:         5 * time.Second,
+
+		StatsRefreshInterval: 15 * time.Minute,
+
+		APITimeout:          10 * time.Second,
+		APIFailureThreshold: 5,
+		APIOpenTimeout:      30 * time.Second,
+
+		CacheBackend: "memory",
+		CacheSize:    10000,
+		CacheTTL:     30 * time.Second,
+
+		TracingExporter: "none",
+
+		LogLevel:  "info",
+		LogFormat: "json",
+	}
 }
 
-func NewConfig() Config {
-	err := godotenv.Load(".env")
+// Load builds the configuration from defaults, an optional config file, the
+// environment (including a .env file in the working directory, if present)
+// and the given command line arguments, then validates the result.
+func Load(args []string) (Config, error) {
+	// .env is read on every load rather than exported into the process
+	// environment, so that a reload picks up edits to it.
+	dotenv, err := godotenv.Read(".env")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return Config{}, fmt.Errorf("godotenv.Read(.env) err: %w", err)
+	}
+
+	env := func(name string) string {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+
+		return dotenv[name]
+	}
+
+	flags, err := parseFlags(args)
 	if err != nil {
-		log.Panicf("Error loading .env file")
+		return Config{}, err
+	}
+
+	cfg := Default()
+
+	// The config file path itself may come from the environment or a flag,
+	// so resolve it before reading the file.
+	path := lookupSource(flags, env, "config", "CONFIG_FILE")
+	if path != "" {
+		if err := loadFile(&cfg, path); err != nil {
+			return Config{}, err
+		}
+
+		log.Debugf("configuration file %s loaded", path)
+	}
+
+	if err := applyEnv(&cfg, env); err != nil {
+		return Config{}, err
 	}
 
-	log.Debug("environment variables loaded")
+	if err := applyFlags(&cfg, flags); err != nil {
+		return Config{}, err
+	}
+
+	cfg.Command = flags.Args()
+
+	// The populated configuration is returned alongside validation errors so
+	// that --print-config can still show what was loaded.
+	return cfg, cfg.Validate()
+}
+
+// StoreBackend tells which store to open and where. A STORE_DSN picks the
+// backend by its scheme: sqlite://<path> (or sqlite://:memory:) opens SQLite,
+// postgres:// connects to Postgres instead of the POSTGRES_* settings.
+// Without one, STORE decides and target is empty.
+func (c Config) StoreBackend() (backend, target string) {
+	scheme, rest, ok := strings.Cut(c.StoreDSN, "://")
 
-	config := Config{
-		BindAddress:      os.Getenv("BIND_ADDRESS"),
-		PostgresHost:     os.Getenv("POSTGRES_HOST"),
-		PostgresPort:     os.Getenv("POSTGRES_PORT"),
-		PostgresDatabase: os.Getenv("POSTGRES_DATABASE"),
-		PostgresUser:     os.Getenv("POSTGRES_USER"),
-		PostgresPassword: os.Getenv("POSTGRES_PASSWORD"),
-		APIUrl:           os.Getenv("API_URL"),
-		APIPort:          os.Getenv("API_PORT"),
+	switch {
+	case !ok:
+		return c.Store, ""
+	case scheme == "sqlite":
+		return "sqlite", rest
+	case scheme == "postgres" || scheme == "postgresql":
+		return "postgres", c.StoreDSN
+	default:
+		return scheme, c.StoreDSN
 	}
+}
+
+// PostgresReplicas splits the comma separated POSTGRES_REPLICA_DSNS.
+func (c Config) PostgresReplicas() []string {
+	var dsns []string
+
+	for _, dsn := range strings.Split(c.PostgresReplicaDSNs, ",") {
+		if dsn = strings.TrimSpace(dsn); dsn != "" {
+			dsns = append(dsns, dsn)
+		}
+	}
+
+	return dsns
+}
 
-	return config
+// SongDetailsURL joins the details API base URL and port.
+func (c Config) SongDetailsURL() string {
+	return joinHostPort(c.APIUrl, c.APIPort)
 }
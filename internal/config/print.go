@@ -0,0 +1,49 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "[REDACTED]"
+
+// Redacted returns the effective configuration keyed like a YAML config file,
+// with every secret field masked.
+func (c Config) Redacted() map[string]any {
+	out := make(map[string]any)
+
+	eachField(&c, func(field reflect.StructField, value reflect.Value) {
+		key := field.Tag.Get("yaml")
+		if key == "" || key == "-" {
+			return
+		}
+
+		if field.Tag.Get("secret") == "true" && value.String() != "" {
+			out[key] = redacted
+
+			return
+		}
+
+		if value.Type() == durationType {
+			out[key] = formatValue(value)
+
+			return
+		}
+
+		out[key] = value.Interface()
+	})
+
+	return out
+}
+
+// Print writes the redacted configuration as YAML.
+func (c Config) Print(w io.Writer) error {
+	if err := yaml.NewEncoder(w).Encode(c.Redacted()); err != nil {
+		return fmt.Errorf("yaml.Encode err: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const hashLength = 12
+
+// Reloader keeps the live configuration of a running process. Reload re-reads
+// every source with the original arguments, applies fields tagged reload and
+// keeps the old value of any other field that changed.
+type Reloader struct {
+	mu          sync.Mutex
+	args        []string
+	current     Config
+	hash        atomic.Value
+	reloads     atomic.Int64
+	failures    atomic.Int64
+	subscribers []func(Config)
+}
+
+func NewReloader(cfg Config, args []string) *Reloader {
+	r := &Reloader{
+		args:    args,
+		current: cfg,
+	}
+
+	r.hash.Store(cfg.Hash())
+
+	return r
+}
+
+// Subscribe registers fn to be called with the new configuration after every
+// successful reload.
+func (r *Reloader) Subscribe(fn func(Config)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.subscribers = append(r.subscribers, fn)
+}
+
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	loaded, err := Load(r.args)
+	if err != nil {
+		r.failures.Add(1)
+
+		return fmt.Errorf("config.Load err: %w", err)
+	}
+
+	next := r.current
+	applied, rejected := merge(&next, loaded)
+
+	for _, name := range rejected {
+		log.WithField("field", name).Warn("config change requires a restart, ignored")
+	}
+
+	r.current = next
+	r.hash.Store(next.Hash())
+	r.reloads.Add(1)
+
+	log.WithFields(log.Fields{
+		"applied":  applied,
+		"rejected": rejected,
+		"hash":     r.Hash(),
+	}).Info("configuration reloaded")
+
+	for _, fn := range r.subscribers {
+		fn(next)
+	}
+
+	return nil
+}
+
+func (r *Reloader) Current() Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.current
+}
+
+func (r *Reloader) Hash() string {
+	hash, _ := r.hash.Load().(string)
+
+	return hash
+}
+
+func (r *Reloader) Reloads() int64 {
+	return r.reloads.Load()
+}
+
+func (r *Reloader) Failures() int64 {
+	return r.failures.Load()
+}
+
+// Hash identifies the effective configuration, secrets included, without
+// revealing it.
+func (c Config) Hash() string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%+v", c)))
+
+	return hex.EncodeToString(sum[:])[:hashLength]
+}
+
+// merge copies the reloadable fields that differ from loaded into dst and
+// reports the names of the applied and rejected changes.
+func merge(dst *Config, loaded Config) ([]string, []string) {
+	src := reflect.ValueOf(loaded)
+
+	var applied, rejected []string
+
+	eachField(dst, func(field reflect.StructField, value reflect.Value) {
+		if field.Tag.Get("yaml") == "-" {
+			return
+		}
+
+		next := src.FieldByIndex(field.Index)
+		if reflect.DeepEqual(value.Interface(), next.Interface()) {
+			return
+		}
+
+		if field.Tag.Get("reload") != "true" {
+			rejected = append(rejected, field.Tag.Get("env"))
+
+			return
+		}
+
+		value.Set(next)
+		applied = append(applied, field.Tag.Get("env"))
+	})
+
+	return applied, rejected
+}
@@ -0,0 +1,218 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+var ErrUnsupportedFile = errors.New("unsupported config file extension")
+
+// fileConfig holds the raw keys of a config file. Values go through the same
+// conversion as environment variables, so durations are written as "5s".
+type fileConfig map[string]any
+
+// parseFlags registers a flag for every tagged field and parses args. Flags
+// are only applied after the file and environment layers.
+func parseFlags(args []string) (*flag.FlagSet, error) {
+	fs := flag.NewFlagSet("songs", flag.ContinueOnError)
+
+	defaults := Default()
+
+	eachField(&defaults, func(field reflect.StructField, value reflect.Value) {
+		name := field.Tag.Get("flag")
+		if name == "" {
+			return
+		}
+
+		if value.Kind() == reflect.Bool {
+			fs.Bool(name, value.Bool(), "")
+
+			return
+		}
+
+		fs.String(name, formatValue(value), "")
+	})
+
+	if err := fs.Parse(args); err != nil {
+		return nil, fmt.Errorf("parsing flags err: %w", err)
+	}
+
+	return fs, nil
+}
+
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("os.ReadFile(%s) err: %w", path, err)
+	}
+
+	values := fileConfig{}
+
+	var tag string
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		tag = "yaml"
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		tag = "toml"
+		err = toml.Unmarshal(data, &values)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedFile, path)
+	}
+
+	if err != nil {
+		return fmt.Errorf("decoding %s err: %w", path, err)
+	}
+
+	var errs []error
+
+	eachField(cfg, func(field reflect.StructField, value reflect.Value) {
+		key := field.Tag.Get(tag)
+		if key == "" || key == "-" {
+			return
+		}
+
+		raw, ok := values[key]
+		if !ok {
+			return
+		}
+
+		if err := setValue(value, fmt.Sprint(raw)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: %w", path, key, err))
+		}
+	})
+
+	return errors.Join(errs...)
+}
+
+func applyEnv(cfg *Config, env func(string) string) error {
+	var errs []error
+
+	eachField(cfg, func(field reflect.StructField, value reflect.Value) {
+		name := field.Tag.Get("env")
+		if name == "" {
+			return
+		}
+
+		// Empty variables are treated as unset so that a blank line in .env
+		// or docker-compose does not wipe out a default.
+		raw := env(name)
+		if raw == "" {
+			return
+		}
+
+		if err := setValue(value, raw); err != nil {
+			errs = append(errs, fmt.Errorf("env %s: %w", name, err))
+		}
+	})
+
+	return errors.Join(errs...)
+}
+
+func applyFlags(cfg *Config, fs *flag.FlagSet) error {
+	set := make(map[string]string)
+
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = f.Value.String()
+	})
+
+	var errs []error
+
+	eachField(cfg, func(field reflect.StructField, value reflect.Value) {
+		name := field.Tag.Get("flag")
+
+		raw, ok := set[name]
+		if name == "" || !ok {
+			return
+		}
+
+		if err := setValue(value, raw); err != nil {
+			errs = append(errs, fmt.Errorf("flag -%s: %w", name, err))
+		}
+	})
+
+	return errors.Join(errs...)
+}
+
+// lookupSource returns the value of a setting that has to be known before the
+// layers are applied, preferring the flag over the environment.
+func lookupSource(fs *flag.FlagSet, env func(string) string, flagName, envName string) string {
+	value := env(envName)
+
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == flagName {
+			value = f.Value.String()
+		}
+	})
+
+	return value
+}
+
+func eachField(cfg *Config, fn func(field reflect.StructField, value reflect.Value)) {
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+
+	for i := range t.NumField() {
+		fn(t.Field(i), v.Field(i))
+	}
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func setValue(value reflect.Value, raw string) error {
+	raw = strings.TrimSpace(raw)
+
+	switch {
+	case value.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q: %w", raw, err)
+		}
+
+		value.SetInt(int64(d))
+	case value.Kind() == reflect.String:
+		value.SetString(raw)
+	case value.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q: %w", raw, err)
+		}
+
+		value.SetInt(int64(n))
+	case value.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q: %w", raw, err)
+		}
+
+		value.SetFloat(f)
+	case value.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q: %w", raw, err)
+		}
+
+		value.SetBool(b)
+	}
+
+	return nil
+}
+
+func formatValue(value reflect.Value) string {
+	if value.Type() == durationType {
+		return time.Duration(value.Int()).String()
+	}
+
+	return fmt.Sprint(value.Interface())
+}
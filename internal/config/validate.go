@@ -0,0 +1,155 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var ErrInvalid = errors.New("invalid configuration")
+
+// Validate checks the whole configuration and reports every problem at once.
+func (c Config) Validate() error {
+	var errs []error
+
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	_, _, err := net.SplitHostPort(c.BindAddress)
+	check(err == nil, "BIND_ADDRESS %q must be host:port", c.BindAddress)
+
+	if c.GRPCBindAddress != "" {
+		_, _, err = net.SplitHostPort(c.GRPCBindAddress)
+		check(err == nil, "GRPC_BIND_ADDRESS %q must be host:port or empty to disable gRPC", c.GRPCBindAddress)
+	}
+
+	// The POSTGRES_* settings only matter to the Postgres store, and those
+	// locating the server not when a postgres:// STORE_DSN does.
+	if backend, target := c.StoreBackend(); backend == "postgres" {
+		if target == "" {
+			check(c.PostgresHost != "", "POSTGRES_HOST is required")
+			check(validPort(c.PostgresPort), "POSTGRES_PORT %q must be a port number", c.PostgresPort)
+			check(c.PostgresDatabase != "", "POSTGRES_DATABASE is required")
+			check(c.PostgresUser != "", "POSTGRES_USER is required")
+		}
+
+		check(c.PostgresMaxConns > 0, "POSTGRES_MAX_CONNS must be positive")
+		check(c.PostgresMinConns >= 0 && c.PostgresMinConns <= c.PostgresMaxConns,
+			"POSTGRES_MIN_CONNS must be between 0 and POSTGRES_MAX_CONNS")
+		check(c.PostgresConnectTimeout > 0, "POSTGRES_CONNECT_TIMEOUT must be positive")
+
+		for i, dsn := range c.PostgresReplicas() {
+			replica, err := url.Parse(dsn)
+			check(err == nil && oneOf(replica.Scheme, "postgres", "postgresql") && replica.Host != "",
+				"POSTGRES_REPLICA_DSNS entry %d must be a postgres:// URL", i+1)
+		}
+
+		check(c.PostgresReplicaMaxLag > 0, "POSTGRES_REPLICA_MAX_LAG must be positive")
+		check(c.PostgresReplicaCheckInterval > 0, "POSTGRES_REPLICA_CHECK_INTERVAL must be positive")
+		check(c.PostgresStickyWindow >= 0, "POSTGRES_STICKY_WINDOW must not be negative, 0 disables read-your-writes")
+	}
+
+	check(c.StatsRefreshInterval >= 0, "STATS_REFRESH_INTERVAL must not be negative, 0 disables it")
+
+	apiURL, err := url.Parse(c.APIUrl)
+	check(err == nil && (apiURL.Scheme == "http" || apiURL.Scheme == "https") && apiURL.Host != "",
+		"API_URL %q must be an absolute http(s) URL", c.APIUrl)
+	check(c.APIPort == "" || validPort(strings.TrimPrefix(c.APIPort, ":")),
+		"API_PORT %q must be a port number, optionally prefixed with ':'", c.APIPort)
+	check(c.APITimeout > 0, "API_TIMEOUT must be positive")
+	check(c.APIFailureThreshold > 0, "API_FAILURE_THRESHOLD must be positive")
+	check(c.APIOpenTimeout > 0, "API_OPEN_TIMEOUT must be positive")
+
+	check(c.ReadHeaderTimeout > 0, "READ_HEADER_TIMEOUT must be positive")
+	check(c.ReadTimeout >= 0, "READ_TIMEOUT must not be negative")
+	check(c.WriteTimeout >= 0, "WRITE_TIMEOUT must not be negative")
+	check(c.IdleTimeout >= 0, "IDLE_TIMEOUT must not be negative")
+	check(c.ShutdownTimeout > 0, "SHUTDOWN_TIMEOUT must be positive")
+	check(c.ReadinessDrainDelay >= 0, "READINESS_DRAIN_DELAY must not be negative")
+	check(c.DefaultPageSize > 0, "DEFAULT_PAGE_SIZE must be positive")
+	check(c.MaxPageSize >= c.DefaultPageSize, "MAX_PAGE_SIZE must not be less than DEFAULT_PAGE_SIZE")
+	check(c.GraphQLMaxDepth > 0, "GRAPHQL_MAX_DEPTH must be positive")
+	check(c.GraphQLMaxComplexity > 0, "GRAPHQL_MAX_COMPLEXITY must be positive")
+	check(c.RateLimit >= 0, "RATE_LIMIT must not be negative, 0 disables it")
+	check(c.RateBurst > 0, "RATE_BURST must be positive")
+	check(c.SSEPollInterval > 0, "SSE_POLL_INTERVAL must be positive")
+	check(c.DuplicateThreshold > 0 && c.DuplicateThreshold <= 1, "DUPLICATE_THRESHOLD must be greater than 0 and at most 1")
+
+	check(c.WebhookPollInterval > 0, "WEBHOOK_POLL_INTERVAL must be positive")
+	check(c.WebhookMaxAttempts > 0, "WEBHOOK_MAX_ATTEMPTS must be positive")
+	check(c.WebhookBackoffBase > 0, "WEBHOOK_BACKOFF_BASE must be positive")
+	check(c.WebhookBackoffMax >= c.WebhookBackoffBase, "WEBHOOK_BACKOFF_MAX must not be less than WEBHOOK_BACKOFF_BASE")
+	check(c.WebhookTimeout > 0, "WEBHOOK_TIMEOUT must be positive")
+
+	check(c.LinkCheckInterval >= 0, "LINK_CHECK_INTERVAL must not be negative, 0 disables it")
+	check(c.LinkCheckPollInterval > 0, "LINK_CHECK_POLL_INTERVAL must be positive")
+	check(c.LinkCheckMaxFailures > 0, "LINK_CHECK_MAX_FAILURES must be positive")
+	check(c.LinkCheckTimeout > 0, "LINK_CHECK_TIMEOUT must be positive")
+	check(c.LinkCheckRate >= 0, "LINK_CHECK_RATE must not be negative, 0 disables it")
+	check(c.LinkCheckHostConcurrency > 0, "LINK_CHECK_HOST_CONCURRENCY must be positive")
+
+	check(oneOf(c.Store, "postgres", "memory"), "STORE %q must be postgres or memory", c.Store)
+	check(oneOf(c.MigrationsOnStart, "auto", "check", "off"),
+		"MIGRATIONS_ON_START %q must be auto, check or off", c.MigrationsOnStart)
+
+	if c.StoreDSN != "" {
+		backend, target := c.StoreBackend()
+		check(oneOf(backend, "sqlite", "postgres") && target != "",
+			"STORE_DSN must start with sqlite:// or postgres://")
+		check(c.Store != "memory", "STORE_DSN can not be combined with STORE=memory")
+	}
+	check(oneOf(c.CacheBackend, "none", "memory", "redis"),
+		"CACHE_BACKEND %q must be none, memory or redis", c.CacheBackend)
+	check(c.CacheSize > 0, "CACHE_SIZE must be positive")
+	check(c.CacheTTL > 0, "CACHE_TTL must be positive")
+	check(c.CacheBackend != "redis" || c.CacheRedisAddr != "", "CACHE_REDIS_ADDR is required for the redis cache backend")
+
+	_, err = log.ParseLevel(c.LogLevel)
+	check(err == nil, "LOG_LEVEL %q is not a valid level", c.LogLevel)
+	check(c.LogFormat == "json" || c.LogFormat == "text", "LOG_FORMAT %q must be json or text", c.LogFormat)
+	check(oneOf(c.TracingExporter, "none", "stdout", "otlp"),
+		"TRACING_EXPORTER %q must be none, stdout or otlp", c.TracingExporter)
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w:\n%w", ErrInvalid, errors.Join(errs...))
+	}
+
+	return nil
+}
+
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+
+	return err == nil && n > 0 && n < 1<<16
+}
+
+func oneOf(value string, allowed ...string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+
+	return false
+}
+
+// joinHostPort appends port to base unless it is empty, tolerating a leading
+// colon on the port and a trailing slash on the base URL.
+func joinHostPort(base, port string) string {
+	base = strings.TrimSuffix(base, "/")
+	port = strings.TrimPrefix(port, ":")
+
+	if port == "" {
+		return base
+	}
+
+	return base + ":" + port
+}
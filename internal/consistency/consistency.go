@@ -0,0 +1,81 @@
+// Package consistency carries the read consistency a caller needs through a
+// context, so that the store can decide whether a read may be served by a
+// replica.
+package consistency
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+type ctxKey int
+
+const (
+	primaryKey ctxKey = iota
+	sessionKey
+)
+
+// WithPrimary marks every read made with the returned context as one that
+// must see the latest committed data.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey, true)
+}
+
+// Primary reports whether ctx asks for reads from the primary.
+func Primary(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryKey).(bool)
+
+	return primary
+}
+
+// Session remembers when a client last wrote, so that its reads can stick to
+// the primary until replicas have caught up with that write.
+type Session struct {
+	lastWrite atomic.Int64
+	onWrite   func(at time.Time)
+}
+
+// NewSession starts a session that last wrote at lastWrite, which is zero
+// for a client that has not written yet. onWrite, when set, is called after
+// every write made through the session.
+func NewSession(lastWrite time.Time, onWrite func(at time.Time)) *Session {
+	s := &Session{onWrite: onWrite}
+
+	if !lastWrite.IsZero() {
+		s.lastWrite.Store(lastWrite.UnixNano())
+	}
+
+	return s
+}
+
+// Wrote records a write committed at at.
+func (s *Session) Wrote(at time.Time) {
+	s.lastWrite.Store(at.UnixNano())
+
+	if s.onWrite != nil {
+		s.onWrite(at)
+	}
+}
+
+// LastWrite returns when the session last wrote, or the zero time.
+func (s *Session) LastWrite() time.Time {
+	nanos := s.lastWrite.Load()
+	if nanos == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, nanos)
+}
+
+// WithSession attaches session to ctx.
+func WithSession(ctx context.Context, session *Session) context.Context {
+	return context.WithValue(ctx, sessionKey, session)
+}
+
+// SessionFrom returns the session attached to ctx, or nil.
+func SessionFrom(ctx context.Context) *Session {
+	session, _ := ctx.Value(sessionKey).(*Session)
+
+	return session
+}
@@ -0,0 +1,224 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/iurikman/songs/internal/logger"
+	"github.com/iurikman/songs/internal/models"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+const (
+	defaultPollInterval = time.Second
+	defaultMaxAttempts  = 8
+	defaultBackoffBase  = 5 * time.Second
+	defaultBackoffMax   = time.Hour
+	defaultTimeout      = 10 * time.Second
+	defaultBatchSize    = 50
+	maxErrorLength      = 512
+)
+
+var errUnexpectedStatus = errors.New("unexpected status code")
+
+type DispatcherConfig struct {
+	PollInterval time.Duration
+	// MaxAttempts is the number of tries after which a delivery is dead.
+	MaxAttempts int
+	// BackoffBase and BackoffMax bound the exponential delay between tries.
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	Timeout     time.Duration
+	BatchSize   int
+}
+
+// Dispatcher sends queued webhook deliveries. Several instances can run
+// against the same database; each delivery is claimed by one of them.
+type Dispatcher struct {
+	config DispatcherConfig
+	db     store
+	client *http.Client
+}
+
+func NewDispatcher(cfg DispatcherConfig, db store) *Dispatcher {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultMaxAttempts
+	}
+
+	if cfg.BackoffBase <= 0 {
+		cfg.BackoffBase = defaultBackoffBase
+	}
+
+	if cfg.BackoffMax <= 0 {
+		cfg.BackoffMax = defaultBackoffMax
+	}
+
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+
+	return &Dispatcher{
+		config: cfg,
+		db:     db,
+		client: &http.Client{
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
+			Timeout:   cfg.Timeout,
+		},
+	}
+}
+
+// Run delivers due webhooks until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) error {
+	log.WithField("interval", d.config.PollInterval.String()).Info("webhook dispatcher started")
+
+	ticker := time.NewTicker(d.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Drain full batches right away, wait for the ticker otherwise.
+		for d.dispatch(ctx) == d.config.BatchSize {
+			if ctx.Err() != nil {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Info("webhook dispatcher stopped")
+
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatch sends one batch of due deliveries and reports its size.
+func (d *Dispatcher) dispatch(ctx context.Context) int {
+	// The lease must outlive a send so that no other instance picks the
+	// delivery up while this one is still waiting for the receiver.
+	deliveries, err := d.db.ClaimDeliveries(ctx, d.config.BatchSize, 2*d.config.Timeout)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.WithError(err).Error("claiming webhook deliveries failed")
+		}
+
+		return 0
+	}
+
+	for _, delivery := range deliveries {
+		d.deliver(ctx, delivery)
+	}
+
+	return len(deliveries)
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, delivery *models.PendingDelivery) {
+	entry := logger.FromContext(ctx).WithFields(log.Fields{
+		"delivery_id": delivery.ID,
+		"webhook_id":  delivery.WebhookID,
+		"event_id":    delivery.EventID,
+		"attempt":     delivery.Attempts,
+	})
+
+	status, err := d.send(ctx, delivery)
+	if err == nil {
+		if err := d.db.MarkDelivered(ctx, delivery.ID, status); err != nil {
+			entry.WithError(err).Error("marking delivery delivered failed")
+		}
+
+		entry.Debug("webhook delivered")
+
+		return
+	}
+
+	dead := delivery.Attempts >= d.config.MaxAttempts
+	next := time.Now().Add(Backoff(delivery.Attempts, d.config.BackoffBase, d.config.BackoffMax))
+
+	reason := err.Error()
+	if len(reason) > maxErrorLength {
+		reason = reason[:maxErrorLength]
+	}
+
+	if err := d.db.MarkFailed(ctx, delivery.ID, status, reason, next, dead); err != nil {
+		entry.WithError(err).Error("recording failed delivery attempt failed")
+	}
+
+	entry = entry.WithError(err).WithField("status", status)
+	if dead {
+		entry.Warn("webhook delivery is dead")
+
+		return
+	}
+
+	entry.WithField("next_attempt_at", next).Info("webhook delivery failed, will retry")
+}
+
+func (d *Dispatcher) send(ctx context.Context, delivery *models.PendingDelivery) (int, error) {
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		return 0, fmt.Errorf("json.Marshal(event) err: %w", err)
+	}
+
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("http.NewRequestWithContext err: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, delivery.Event.Type)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("client.Do(req) err: %w", err)
+	}
+	defer resp.Body.Close()
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorLength))
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return resp.StatusCode, fmt.Errorf("%w: %d", errUnexpectedStatus, resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// Backoff returns the delay before the next try after attempt failed tries:
+// base doubled per attempt, capped at maxDelay, with full jitter over the upper
+// half so that receivers coming back up are not hit by every sender at once.
+func Backoff(attempt int, base, maxDelay time.Duration) time.Duration {
+	delay := maxDelay
+
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	if shift := attempt - 1; shift < 32 && base<<shift > 0 && base<<shift < maxDelay {
+		delay = base << shift
+	}
+
+	half := delay / 2
+
+	return half + rand.N(half+1) //nolint:gosec
+}
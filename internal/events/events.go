@@ -0,0 +1,126 @@
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/logger"
+	"github.com/iurikman/songs/internal/models"
+	"github.com/iurikman/songs/internal/telemetry"
+	"github.com/iurikman/songs/internal/validation"
+)
+
+const secretBytes = 32
+
+// Types lists the event types a webhook can subscribe to.
+var Types = []string{models.EventSongCreated, models.EventSongUpdated, models.EventSongDeleted, models.EventSongMerged}
+
+type store interface {
+	GetEvents(ctx context.Context, afterID int64, limit int) ([]*models.Event, error)
+	CreateWebhook(ctx context.Context, webhook models.Webhook) (*models.Webhook, error)
+	GetWebhooks(ctx context.Context) ([]*models.Webhook, error)
+	DeleteWebhook(ctx context.Context, id uuid.UUID) error
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*models.PendingDelivery, error)
+	MarkDelivered(ctx context.Context, id int64, status int) error
+	MarkFailed(ctx context.Context, id int64, status int, reason string, next time.Time, dead bool) error
+	GetDeadDeliveries(ctx context.Context, params models.Params) ([]*models.Delivery, error)
+	RetryDelivery(ctx context.Context, id int64) error
+}
+
+// Service exposes the change feed and manages webhook subscriptions.
+type Service struct {
+	db store
+}
+
+func NewService(db store) *Service {
+	return &Service{db: db}
+}
+
+// Events returns up to limit events that happened after the event afterID.
+func (s *Service) Events(ctx context.Context, afterID int64, limit int) ([]*models.Event, error) {
+	events, err := s.db.GetEvents(ctx, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("s.db.GetEvents err: %w", err)
+	}
+
+	return events, nil
+}
+
+// CreateWebhook registers a webhook and returns it with its signing secret.
+// The secret is only ever returned here.
+func (s *Service) CreateWebhook(ctx context.Context, webhook models.Webhook) (*models.Webhook, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "Events.CreateWebhook")
+	defer span.End()
+
+	if err := validation.Webhook(webhook, Types); err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	secret := make([]byte, secretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, telemetry.RecordError(span, fmt.Errorf("rand.Read err: %w", err))
+	}
+
+	webhook.ID = uuid.New()
+	webhook.Secret = hex.EncodeToString(secret)
+	webhook.Active = true
+
+	created, err := s.db.CreateWebhook(ctx, webhook)
+	if err != nil {
+		return nil, telemetry.RecordError(span, fmt.Errorf("s.db.CreateWebhook err: %w", err))
+	}
+
+	logger.FromContext(ctx).WithField("webhook_id", created.ID).Info("webhook registered")
+
+	return created, nil
+}
+
+// Webhooks lists the registered webhooks without their secrets.
+func (s *Service) Webhooks(ctx context.Context) ([]*models.Webhook, error) {
+	webhooks, err := s.db.GetWebhooks(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("s.db.GetWebhooks err: %w", err)
+	}
+
+	for _, webhook := range webhooks {
+		webhook.Secret = ""
+	}
+
+	return webhooks, nil
+}
+
+func (s *Service) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	if err := s.db.DeleteWebhook(ctx, id); err != nil {
+		return fmt.Errorf("s.db.DeleteWebhook err: %w", err)
+	}
+
+	logger.FromContext(ctx).WithField("webhook_id", id).Info("webhook deleted")
+
+	return nil
+}
+
+// DeadDeliveries lists the deliveries that ran out of attempts, most recent
+// first.
+func (s *Service) DeadDeliveries(ctx context.Context, params models.Params) ([]*models.Delivery, error) {
+	deliveries, err := s.db.GetDeadDeliveries(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("s.db.GetDeadDeliveries err: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// RetryDelivery queues a dead delivery again.
+func (s *Service) RetryDelivery(ctx context.Context, id int64) error {
+	if err := s.db.RetryDelivery(ctx, id); err != nil {
+		return fmt.Errorf("s.db.RetryDelivery err: %w", err)
+	}
+
+	logger.FromContext(ctx).WithField("delivery_id", id).Info("dead delivery requeued")
+
+	return nil
+}
@@ -0,0 +1,155 @@
+package gql
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/iurikman/songs/internal/logger"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	standardPage = 10
+	maxPage      = 100
+
+	defaultMaxDepth      = 8
+	defaultMaxComplexity = 5000
+
+	maxBodyBytes = 1 << 20
+)
+
+type Config struct {
+	DefaultPageSize int
+	MaxPageSize     int
+	Limits          Limits
+}
+
+type request struct {
+	Query         string         `json:"query"`
+	Variables     map[string]any `json:"variables"`
+	OperationName string         `json:"operationName"`
+}
+
+// Handler serves GraphQL queries over HTTP, as POST with a JSON body or as
+// GET with query, variables and operationName in the query string.
+type Handler struct {
+	schema graphql.Schema
+	svc    service
+	paging *pageSizes
+	limits Limits
+}
+
+func NewHandler(cfg Config, svc service) (*Handler, error) {
+	p := new(pageSizes)
+
+	limits := cfg.Limits
+	if limits.MaxDepth <= 0 {
+		limits.MaxDepth = defaultMaxDepth
+	}
+
+	if limits.MaxComplexity <= 0 {
+		limits.MaxComplexity = defaultMaxComplexity
+	}
+
+	schema, err := newSchema(&resolver{svc: svc, paging: p})
+	if err != nil {
+		return nil, err
+	}
+
+	h := &Handler{
+		schema: schema,
+		svc:    svc,
+		paging: p,
+		limits: limits,
+	}
+
+	h.SetPaging(cfg.DefaultPageSize, cfg.MaxPageSize)
+
+	return h, nil
+}
+
+// SetPaging changes the page size used when a query has no limit and the
+// largest page a client may ask for.
+func (h *Handler) SetPaging(defaultSize, maxSize int) {
+	if defaultSize <= 0 {
+		defaultSize = standardPage
+	}
+
+	if maxSize <= 0 {
+		maxSize = maxPage
+	}
+
+	h.paging.defaultSize.Store(int64(defaultSize))
+	h.paging.maxSize.Store(int64(maxSize))
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req, err := decodeRequest(r)
+	if err != nil {
+		writeResult(w, http.StatusBadRequest, errorResult(err))
+
+		return
+	}
+
+	if err := checkLimits(req.Query, req.Variables, h.limits, h.paging.load()); err != nil {
+		logger.FromContext(r.Context()).WithError(err).Info("graphql query rejected")
+		writeResult(w, http.StatusBadRequest, errorResult(err))
+
+		return
+	}
+
+	ctx := withLoaders(r.Context(), newLoaders(h.svc))
+
+	result := graphql.Do(graphql.Params{
+		Schema:         h.schema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        ctx,
+	})
+
+	if result.HasErrors() {
+		logger.FromContext(ctx).WithField("errors", fmt.Sprint(result.Errors)).Debug("graphql query returned errors")
+	}
+
+	writeResult(w, http.StatusOK, result)
+}
+
+func decodeRequest(r *http.Request) (*request, error) {
+	req := new(request)
+
+	switch r.Method {
+	case http.MethodGet:
+		values := r.URL.Query()
+		req.Query = values.Get("query")
+		req.OperationName = values.Get("operationName")
+
+		if raw := values.Get("variables"); raw != "" {
+			if err := json.Unmarshal([]byte(raw), &req.Variables); err != nil {
+				return nil, fmt.Errorf("invalid variables: %w", err)
+			}
+		}
+	default:
+		if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxBodyBytes)).Decode(req); err != nil {
+			return nil, fmt.Errorf("invalid request body: %w", err)
+		}
+	}
+
+	return req, nil
+}
+
+func errorResult(err error) *graphql.Result {
+	return &graphql.Result{Errors: []gqlerrors.FormattedError{gqlerrors.NewFormattedError(err.Error())}}
+}
+
+func writeResult(w http.ResponseWriter, statusCode int, result *graphql.Result) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Warnf("json.NewEncoder(w).Encode(result) err: %v", err)
+	}
+}
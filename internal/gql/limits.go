@@ -0,0 +1,161 @@
+package gql
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+var (
+	ErrQueryTooDeep    = errors.New("query is too deep")
+	ErrQueryTooComplex = errors.New("query is too complex")
+)
+
+// listFields are the fields returning pages; their cost is multiplied by the
+// number of items they may return.
+var listFields = map[string]bool{
+	"songs":   true,
+	"groups":  true,
+	"verses":  true,
+	"related": true,
+}
+
+// Limits bound the shape of accepted queries. Depth counts nested selection
+// sets; complexity counts every field that can be resolved, multiplying list
+// fields by their limit argument (or the default page size).
+type Limits struct {
+	MaxDepth      int
+	MaxComplexity int
+}
+
+type analyzer struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]any
+	paging    paging
+	visiting  map[string]bool
+}
+
+// checkLimits parses the query and rejects it when it exceeds limits. Syntax
+// errors are left to the executor so they are reported the usual way.
+func checkLimits(query string, variables map[string]any, limits Limits, p paging) error {
+	doc, err := parser.Parse(parser.ParseParams{Source: query})
+	if err != nil {
+		return nil //nolint:nilerr
+	}
+
+	a := &analyzer{
+		fragments: make(map[string]*ast.FragmentDefinition),
+		variables: variables,
+		paging:    p,
+		visiting:  make(map[string]bool),
+	}
+
+	for _, def := range doc.Definitions {
+		if fragment, ok := def.(*ast.FragmentDefinition); ok {
+			a.fragments[fragment.Name.Value] = fragment
+		}
+	}
+
+	for _, def := range doc.Definitions {
+		operation, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+
+		depth, complexity := a.selectionSet(operation.SelectionSet)
+
+		if limits.MaxDepth > 0 && depth > limits.MaxDepth {
+			return fmt.Errorf("%w: depth %d, limit %d", ErrQueryTooDeep, depth, limits.MaxDepth)
+		}
+
+		if limits.MaxComplexity > 0 && complexity > limits.MaxComplexity {
+			return fmt.Errorf("%w: complexity %d, limit %d", ErrQueryTooComplex, complexity, limits.MaxComplexity)
+		}
+	}
+
+	return nil
+}
+
+func (a *analyzer) selectionSet(set *ast.SelectionSet) (int, int) {
+	if set == nil {
+		return 0, 0
+	}
+
+	maxDepth, total := 0, 0
+
+	for _, selection := range set.Selections {
+		var depth, complexity int
+
+		switch s := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(s.Name.Value, "__") {
+				continue
+			}
+
+			childDepth, childComplexity := a.selectionSet(s.SelectionSet)
+			depth = childDepth + 1
+			complexity = 1 + childComplexity*a.multiplier(s)
+		case *ast.InlineFragment:
+			depth, complexity = a.selectionSet(s.SelectionSet)
+		case *ast.FragmentSpread:
+			name := s.Name.Value
+
+			fragment, ok := a.fragments[name]
+			if !ok || a.visiting[name] {
+				continue
+			}
+
+			a.visiting[name] = true
+			depth, complexity = a.selectionSet(fragment.SelectionSet)
+			a.visiting[name] = false
+		}
+
+		maxDepth = max(maxDepth, depth)
+		total += complexity
+	}
+
+	return maxDepth, total
+}
+
+func (a *analyzer) multiplier(field *ast.Field) int {
+	if !listFields[field.Name.Value] {
+		return 1
+	}
+
+	limit := a.paging.defaultSize
+
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "limit" {
+			continue
+		}
+
+		switch v := arg.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(v.Value); err == nil && n > 0 {
+				limit = n
+			}
+		case *ast.Variable:
+			if n, ok := toInt(a.variables[v.Name.Value]); ok && n > 0 {
+				limit = n
+			}
+		}
+	}
+
+	return min(limit, a.paging.maxSize)
+}
+
+// toInt accepts the numeric types variables may be decoded into.
+func toInt(v any) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case float64:
+		return int(n), true
+	default:
+		return 0, false
+	}
+}
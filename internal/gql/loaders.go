@@ -0,0 +1,99 @@
+package gql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/graph-gophers/dataloader/v7"
+	"github.com/iurikman/songs/internal/models"
+)
+
+// loaderWait is how long a loader collects keys before running a batch. It
+// only has to cover the resolvers of one level of the query.
+const loaderWait = 2 * time.Millisecond
+
+type loadersKey struct{}
+
+// loaders batch the per-song and per-group lookups of a single request, so a
+// list of N songs asking for their group costs one query instead of N.
+type loaders struct {
+	songByID     *dataloader.Loader[uuid.UUID, *models.Song]
+	songsByGroup *dataloader.Loader[string, []*models.Song]
+}
+
+func newLoaders(svc service) *loaders {
+	return &loaders{
+		songByID: dataloader.NewBatchedLoader(
+			songsByIDBatch(svc),
+			dataloader.WithWait[uuid.UUID, *models.Song](loaderWait),
+		),
+		songsByGroup: dataloader.NewBatchedLoader(
+			songsByGroupBatch(svc),
+			dataloader.WithWait[string, []*models.Song](loaderWait),
+		),
+	}
+}
+
+func withLoaders(ctx context.Context, l *loaders) context.Context {
+	return context.WithValue(ctx, loadersKey{}, l)
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	l, _ := ctx.Value(loadersKey{}).(*loaders)
+
+	return l
+}
+
+func songsByIDBatch(svc service) dataloader.BatchFunc[uuid.UUID, *models.Song] {
+	return func(ctx context.Context, ids []uuid.UUID) []*dataloader.Result[*models.Song] {
+		results := make([]*dataloader.Result[*models.Song], len(ids))
+
+		songs, err := svc.GetSongsByIDs(ctx, ids)
+		if err != nil {
+			return fillError(results, fmt.Errorf("loading songs err: %w", err))
+		}
+
+		byID := make(map[uuid.UUID]*models.Song, len(songs))
+		for _, song := range songs {
+			byID[song.ID] = song
+		}
+
+		for i, id := range ids {
+			results[i] = &dataloader.Result[*models.Song]{Data: byID[id]}
+		}
+
+		return results
+	}
+}
+
+func songsByGroupBatch(svc service) dataloader.BatchFunc[string, []*models.Song] {
+	return func(ctx context.Context, groups []string) []*dataloader.Result[[]*models.Song] {
+		results := make([]*dataloader.Result[[]*models.Song], len(groups))
+
+		songs, err := svc.GetSongsByGroups(ctx, groups)
+		if err != nil {
+			return fillError(results, fmt.Errorf("loading songs by group err: %w", err))
+		}
+
+		byGroup := make(map[string][]*models.Song, len(groups))
+		for _, song := range songs {
+			byGroup[song.Group] = append(byGroup[song.Group], song)
+		}
+
+		for i, group := range groups {
+			results[i] = &dataloader.Result[[]*models.Song]{Data: byGroup[group]}
+		}
+
+		return results
+	}
+}
+
+func fillError[V any](results []*dataloader.Result[V], err error) []*dataloader.Result[V] {
+	for i := range results {
+		results[i] = &dataloader.Result[V]{Error: err}
+	}
+
+	return results
+}
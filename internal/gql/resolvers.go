@@ -0,0 +1,205 @@
+package gql
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/graphql-go/graphql"
+	"github.com/iurikman/songs/internal/logger"
+	"github.com/iurikman/songs/internal/models"
+)
+
+var (
+	errNegativePaging = errors.New("offset and limit must not be negative")
+	errInvalidID      = errors.New("invalid id")
+	errInternal       = errors.New("internal server error")
+)
+
+func (r *resolver) songs(p graphql.ResolveParams) (any, error) {
+	params, err := r.paging.load().params(p.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	songs, err := r.svc.GetSongs(p.Context, params)
+	if err != nil {
+		return nil, publicError(p.Context, err)
+	}
+
+	return songs, nil
+}
+
+func (r *resolver) song(p graphql.ResolveParams) (any, error) {
+	raw, _ := p.Args["id"].(string)
+
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		return nil, errInvalidID
+	}
+
+	thunk := loadersFrom(p.Context).songByID.Load(p.Context, id)
+
+	return func() (any, error) {
+		song, err := thunk()
+		if err != nil {
+			return nil, publicError(p.Context, err)
+		}
+
+		if song == nil {
+			// graphql-go treats a typed nil as a value, return an untyped one.
+			return nil, nil
+		}
+
+		return song, nil
+	}, nil
+}
+
+func (r *resolver) groups(p graphql.ResolveParams) (any, error) {
+	params, err := r.paging.load().params(p.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := r.svc.GetGroups(p.Context, params)
+	if err != nil {
+		return nil, publicError(p.Context, err)
+	}
+
+	groups := make([]group, 0, len(names))
+	for _, name := range names {
+		groups = append(groups, group{name: name})
+	}
+
+	return groups, nil
+}
+
+func (r *resolver) group(p graphql.ResolveParams) (any, error) {
+	name, _ := p.Args["name"].(string)
+
+	thunk := loadersFrom(p.Context).songsByGroup.Load(p.Context, name)
+
+	return func() (any, error) {
+		songs, err := thunk()
+		if err != nil {
+			return nil, publicError(p.Context, err)
+		}
+
+		if len(songs) == 0 {
+			return nil, nil
+		}
+
+		return group{name: name}, nil
+	}, nil
+}
+
+func (r *resolver) groupSongs(p graphql.ResolveParams) (any, error) {
+	params, err := r.paging.load().params(p.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	name := p.Source.(group).name
+	thunk := loadersFrom(p.Context).songsByGroup.Load(p.Context, name)
+
+	return func() (any, error) {
+		songs, err := thunk()
+		if err != nil {
+			return nil, publicError(p.Context, err)
+		}
+
+		return page(songs, params), nil
+	}, nil
+}
+
+func (r *resolver) groupSongCount(p graphql.ResolveParams) (any, error) {
+	name := p.Source.(group).name
+	thunk := loadersFrom(p.Context).songsByGroup.Load(p.Context, name)
+
+	return func() (any, error) {
+		songs, err := thunk()
+		if err != nil {
+			return nil, publicError(p.Context, err)
+		}
+
+		return len(songs), nil
+	}, nil
+}
+
+func (r *resolver) related(p graphql.ResolveParams) (any, error) {
+	params, err := r.paging.load().params(p.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	song := p.Source.(*models.Song)
+	thunk := loadersFrom(p.Context).songsByGroup.Load(p.Context, song.Group)
+
+	return func() (any, error) {
+		songs, err := thunk()
+		if err != nil {
+			return nil, publicError(p.Context, err)
+		}
+
+		related := make([]*models.Song, 0, len(songs))
+
+		for _, s := range songs {
+			if s.ID != song.ID {
+				related = append(related, s)
+			}
+		}
+
+		return page(related, params), nil
+	}, nil
+}
+
+func (r *resolver) verses(p graphql.ResolveParams) (any, error) {
+	params, err := r.paging.load().params(p.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	texts := models.SplitVerses(p.Source.(*models.Song).Text)
+
+	verses := make([]verse, 0, len(texts))
+	for i, text := range texts {
+		verses = append(verses, verse{number: i + 1, text: text})
+	}
+
+	return page(verses, params), nil
+}
+
+func (r *resolver) verse(p graphql.ResolveParams) (any, error) {
+	number, _ := p.Args["number"].(int)
+	texts := models.SplitVerses(p.Source.(*models.Song).Text)
+
+	if number < 1 || number > len(texts) {
+		return nil, nil //nolint:nilnil
+	}
+
+	return verse{number: number, text: texts[number-1]}, nil
+}
+
+// publicError hides internal failures from clients; domain errors are
+// reported as they are.
+func publicError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, models.ErrInvalidSorting):
+		return models.ErrInvalidSorting
+	default:
+		logger.FromContext(ctx).WithError(err).Error("graphql resolver failed")
+
+		return errInternal
+	}
+}
+
+// page applies offset and limit to an already loaded slice.
+func page[T any](items []T, params models.Params) []T {
+	if params.Offset >= len(items) {
+		return []T{}
+	}
+
+	end := min(params.Offset+params.Limit, len(items))
+
+	return items[params.Offset:end]
+}
@@ -0,0 +1,218 @@
+package gql
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+	"github.com/graphql-go/graphql"
+	"github.com/iurikman/songs/internal/models"
+)
+
+type service interface {
+	GetSongs(ctx context.Context, params models.Params) ([]*models.Song, error)
+	GetSongsByIDs(ctx context.Context, ids []uuid.UUID) ([]*models.Song, error)
+	GetSongsByGroups(ctx context.Context, groups []string) ([]*models.Song, error)
+	GetGroups(ctx context.Context, params models.Params) ([]string, error)
+}
+
+// group is the resolved value of the Group type. Groups are not stored on
+// their own, they are the distinct musicGroup values of the songs.
+type group struct {
+	name string
+}
+
+type verse struct {
+	number int
+	text   string
+}
+
+type resolver struct {
+	svc    service
+	paging *pageSizes
+}
+
+// paging holds the page size defaults shared with the REST API.
+type paging struct {
+	defaultSize int
+	maxSize     int
+}
+
+// pageSizes holds the paging of a Handler, which the resolver shares so that
+// SetPaging applies to queries and to their complexity alike.
+type pageSizes struct {
+	defaultSize atomic.Int64
+	maxSize     atomic.Int64
+}
+
+func (s *pageSizes) load() paging {
+	return paging{defaultSize: int(s.defaultSize.Load()), maxSize: int(s.maxSize.Load())}
+}
+
+// params builds models.Params from the pagination arguments of a field with
+// the same defaults and limits as the query string of GET /songs.
+func (p paging) params(args map[string]any) (models.Params, error) {
+	params := models.Params{Limit: p.defaultSize}
+
+	if v, ok := args["offset"].(int); ok {
+		params.Offset = v
+	}
+
+	if v, ok := args["limit"].(int); ok && v != 0 {
+		params.Limit = v
+	}
+
+	if params.Offset < 0 || params.Limit < 0 {
+		return models.Params{}, errNegativePaging
+	}
+
+	params.Limit = min(params.Limit, p.maxSize)
+
+	if v, ok := args["sorting"].(string); ok {
+		params.Sorting = v
+	}
+
+	if v, ok := args["descending"].(bool); ok {
+		params.Descending = v
+	}
+
+	if v, ok := args["filter"].(string); ok {
+		params.Filter = v
+	}
+
+	return params, nil
+}
+
+func newSchema(r *resolver) (graphql.Schema, error) {
+	pageArgs := func(withSorting bool) graphql.FieldConfigArgument {
+		args := graphql.FieldConfigArgument{
+			"offset": &graphql.ArgumentConfig{Type: graphql.Int},
+			"limit":  &graphql.ArgumentConfig{Type: graphql.Int},
+		}
+
+		if withSorting {
+			args["sorting"] = &graphql.ArgumentConfig{Type: graphql.String}
+			args["descending"] = &graphql.ArgumentConfig{Type: graphql.Boolean}
+		}
+
+		return args
+	}
+
+	verseType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Verse",
+		Fields: graphql.Fields{
+			"number": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.Int),
+				Resolve: func(p graphql.ResolveParams) (any, error) { return p.Source.(verse).number, nil },
+			},
+			"text": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (any, error) { return p.Source.(verse).text, nil },
+			},
+		},
+	})
+
+	groupType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Group",
+		Fields: graphql.Fields{
+			"name": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (any, error) { return p.Source.(group).name, nil },
+			},
+		},
+	})
+
+	songType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Song",
+		Fields: graphql.Fields{
+			"id":          songField(graphql.ID, func(s *models.Song) any { return s.ID.String() }),
+			"name":        songField(graphql.String, func(s *models.Song) any { return s.Name }),
+			"releaseDate": songField(graphql.String, func(s *models.Song) any { return s.ReleaseDate.String() }),
+			"link":        songField(graphql.String, func(s *models.Song) any { return s.Link }),
+			"text":        songField(graphql.String, func(s *models.Song) any { return s.Text }),
+			"group": &graphql.Field{
+				Type:    graphql.NewNonNull(groupType),
+				Resolve: func(p graphql.ResolveParams) (any, error) { return group{name: p.Source.(*models.Song).Group}, nil },
+			},
+			"verses": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(verseType))),
+				Args:    pageArgs(false),
+				Resolve: r.verses,
+			},
+			"verse": &graphql.Field{
+				Type: verseType,
+				Args: graphql.FieldConfigArgument{
+					"number": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: r.verse,
+			},
+		},
+	})
+
+	songList := graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(songType)))
+
+	// Fields referring back to Song are added after the type exists.
+	songType.AddFieldConfig("related", &graphql.Field{
+		Type:        songList,
+		Description: "Other songs of the same group.",
+		Args:        pageArgs(false),
+		Resolve:     r.related,
+	})
+	groupType.AddFieldConfig("songs", &graphql.Field{
+		Type:    songList,
+		Args:    pageArgs(false),
+		Resolve: r.groupSongs,
+	})
+	groupType.AddFieldConfig("songCount", &graphql.Field{
+		Type:    graphql.NewNonNull(graphql.Int),
+		Resolve: r.groupSongCount,
+	})
+
+	songsArgs := pageArgs(true)
+	songsArgs["filter"] = &graphql.ArgumentConfig{Type: graphql.String, Description: "Substring of the song name."}
+
+	groupsArgs := pageArgs(false)
+	groupsArgs["filter"] = &graphql.ArgumentConfig{Type: graphql.String, Description: "Substring of the group name."}
+	groupsArgs["descending"] = &graphql.ArgumentConfig{Type: graphql.Boolean}
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"songs": &graphql.Field{
+				Type:    songList,
+				Args:    songsArgs,
+				Resolve: r.songs,
+			},
+			"song": &graphql.Field{
+				Type:    songType,
+				Args:    graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)}},
+				Resolve: r.song,
+			},
+			"groups": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(groupType))),
+				Args:    groupsArgs,
+				Resolve: r.groups,
+			},
+			"group": &graphql.Field{
+				Type:    groupType,
+				Args:    graphql.FieldConfigArgument{"name": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)}},
+				Resolve: r.group,
+			},
+		},
+	})
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: query})
+	if err != nil {
+		return graphql.Schema{}, fmt.Errorf("graphql.NewSchema err: %w", err)
+	}
+
+	return schema, nil
+}
+
+func songField(t graphql.Output, get func(*models.Song) any) *graphql.Field {
+	return &graphql.Field{
+		Type:    graphql.NewNonNull(t),
+		Resolve: func(p graphql.ResolveParams) (any, error) { return get(p.Source.(*models.Song)), nil },
+	}
+}
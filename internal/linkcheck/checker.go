@@ -0,0 +1,269 @@
+// Package linkcheck checks the external links of songs in the background and
+// marks those that keep failing as broken.
+package linkcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/models"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultInterval        = 24 * time.Hour
+	defaultPollInterval    = time.Minute
+	defaultBatchSize       = 50
+	defaultMaxFailures     = 3
+	defaultTimeout         = 10 * time.Second
+	defaultHostConcurrency = 2
+	maxErrorLength         = 512
+	maxBodyLength          = 64 << 10
+	userAgent              = "songs-link-checker/1.0"
+)
+
+type store interface {
+	ClaimLinks(ctx context.Context, limit int, interval time.Duration) ([]*models.SongLink, error)
+	RecordLinkCheck(ctx context.Context, id uuid.UUID, check models.LinkCheck, maxFailures int) (*models.LinkCheck, error)
+}
+
+type Config struct {
+	// Interval is how often each link is checked.
+	Interval     time.Duration
+	PollInterval time.Duration
+	BatchSize    int
+	// MaxFailures is the number of failed checks in a row after which a link
+	// is broken.
+	MaxFailures int
+	Timeout     time.Duration
+	// Rate bounds the requests per second to all hosts together, 0 leaves
+	// them unbounded.
+	Rate float64
+	// HostConcurrency bounds the requests in flight to a single host.
+	HostConcurrency int
+}
+
+// Checker periodically sends HEAD requests to song links, falling back to
+// GET for servers that refuse HEAD, and records the outcome. Several
+// instances can run against the same database; each link is claimed by one
+// of them.
+type Checker struct {
+	config  Config
+	db      store
+	client  *http.Client
+	limiter *rate.Limiter
+}
+
+func NewChecker(cfg Config, db store) *Checker {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+
+	if cfg.MaxFailures <= 0 {
+		cfg.MaxFailures = defaultMaxFailures
+	}
+
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+
+	if cfg.HostConcurrency <= 0 {
+		cfg.HostConcurrency = defaultHostConcurrency
+	}
+
+	limit := rate.Inf
+	if cfg.Rate > 0 {
+		limit = rate.Limit(cfg.Rate)
+	}
+
+	return &Checker{
+		config: cfg,
+		db:     db,
+		client: &http.Client{
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
+			Timeout:   cfg.Timeout,
+		},
+		limiter: rate.NewLimiter(limit, 1),
+	}
+}
+
+// Run checks due links until ctx is done.
+func (c *Checker) Run(ctx context.Context) error {
+	log.WithFields(log.Fields{
+		"interval":      c.config.Interval.String(),
+		"poll_interval": c.config.PollInterval.String(),
+	}).Info("link checker started")
+
+	ticker := time.NewTicker(c.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Drain full batches right away, wait for the ticker otherwise.
+		for c.CheckDue(ctx) == c.config.BatchSize {
+			if ctx.Err() != nil {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Info("link checker stopped")
+
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckDue checks one batch of due links and reports its size.
+func (c *Checker) CheckDue(ctx context.Context) int {
+	links, err := c.db.ClaimLinks(ctx, c.config.BatchSize, c.config.Interval)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.WithError(err).Error("claiming links to check failed")
+		}
+
+		return 0
+	}
+
+	// Links of a batch are checked at once but for those to the same host,
+	// of which at most HostConcurrency are in flight.
+	hosts := make(map[string]chan struct{})
+
+	var wg sync.WaitGroup
+
+	for _, link := range links {
+		host := hostOf(link.URL)
+
+		slots, ok := hosts[host]
+		if !ok {
+			slots = make(chan struct{}, c.config.HostConcurrency)
+			hosts[host] = slots
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+
+			check := c.check(ctx, link.URL)
+
+			<-slots
+
+			if ctx.Err() == nil {
+				c.record(ctx, link, check)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return len(links)
+}
+
+func (c *Checker) record(ctx context.Context, link *models.SongLink, check models.LinkCheck) {
+	entry := log.WithFields(log.Fields{
+		"link_id": link.ID,
+		"song_id": link.SongID,
+		"url":     link.URL,
+		"status":  check.Status,
+	})
+
+	recorded, err := c.db.RecordLinkCheck(ctx, link.ID, check, c.config.MaxFailures)
+
+	switch {
+	case errors.Is(err, models.ErrLinkNotFound):
+		entry.Debug("link deleted while being checked")
+	case err != nil:
+		entry.WithError(err).Error("recording link check failed")
+	case recorded.Broken && recorded.Failures == c.config.MaxFailures:
+		entry.WithField("error", recorded.Error).Warn("link is broken")
+	case link.Check != nil && link.Check.Broken && !recorded.Broken:
+		entry.Info("broken link works again")
+	default:
+		entry.WithField("failures", recorded.Failures).Debug("link checked")
+	}
+}
+
+// check requests url with HEAD, or with GET when HEAD fails with a status,
+// and returns the outcome.
+func (c *Checker) check(ctx context.Context, rawURL string) models.LinkCheck {
+	status, final, err := c.request(ctx, http.MethodHead, rawURL)
+	if err == nil && status >= http.StatusBadRequest {
+		status, final, err = c.request(ctx, http.MethodGet, rawURL)
+	}
+
+	check := models.LinkCheck{Status: status, CheckedAt: time.Now().UTC()}
+
+	if err != nil {
+		check.Error = err.Error()
+		if len(check.Error) > maxErrorLength {
+			check.Error = check.Error[:maxErrorLength]
+		}
+	}
+
+	if final != "" && final != rawURL {
+		check.RedirectURL = final
+	}
+
+	return check
+}
+
+// request sends one request and returns the status and the URL of the
+// response, which differs from rawURL after redirects.
+func (c *Checker) request(ctx context.Context, method, rawURL string) (int, string, error) {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return 0, "", fmt.Errorf("limiter.Wait(ctx) err: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return 0, "", fmt.Errorf("http.NewRequestWithContext err: %w", err)
+	}
+
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, "", fmt.Errorf("client.Do(req) err: %w", err)
+	}
+	defer resp.Body.Close()
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxBodyLength))
+
+	return resp.StatusCode, resp.Request.URL.String(), nil
+}
+
+// hostOf returns the host requests to rawURL count against.
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	return strings.ToLower(u.Host)
+}
@@ -0,0 +1,109 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+
+	"github.com/iurikman/songs/internal/models"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+
+	FieldRequestID = "request_id"
+	FieldTraceID   = "trace_id"
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown log format")
+
+	hookOnce sync.Once
+)
+
+type (
+	ctxKey       struct{}
+	requestIDKey struct{}
+)
+
+// Setup configures the global logger that request-scoped loggers derive from.
+func Setup(level, format string) error {
+	if level == "" {
+		level = log.InfoLevel.String()
+	}
+
+	lvl, err := log.ParseLevel(level)
+	if err != nil {
+		return fmt.Errorf("log.ParseLevel(%q) err: %w", level, err)
+	}
+
+	log.SetLevel(lvl)
+
+	switch format {
+	case "", FormatJSON:
+		log.SetFormatter(&log.JSONFormatter{})
+	case FormatText:
+		log.SetFormatter(&log.TextFormatter{FullTimestamp: true})
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+
+	hookOnce.Do(func() { log.AddHook(redactHook{}) })
+
+	return nil
+}
+
+// WithLogger returns a copy of ctx carrying entry.
+func WithLogger(ctx context.Context, entry *log.Entry) context.Context {
+	return context.WithValue(ctx, ctxKey{}, entry)
+}
+
+// FromContext returns the logger stored in ctx, or the global logger when the
+// context carries none.
+func FromContext(ctx context.Context) *log.Entry {
+	if entry, ok := ctx.Value(ctxKey{}).(*log.Entry); ok {
+		return entry
+	}
+
+	return log.NewEntry(log.StandardLogger())
+}
+
+// WithRequestID returns a copy of ctx carrying the correlation ID of the request.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the correlation ID stored in ctx, if any.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+
+	return id
+}
+
+// SongFields describes a song for logging without dumping its lyrics.
+func SongFields(song *models.Song) log.Fields {
+	if song == nil {
+		return log.Fields{}
+	}
+
+	return log.Fields{
+		"song_id":     song.ID,
+		"song_name":   song.Name,
+		"music_group": song.Group,
+		"text_length": len(song.Text),
+	}
+}
+
+// RedactDSN hides the password of a connection URL.
+func RedactDSN(dsn string) string {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return redacted
+	}
+
+	return u.Redacted()
+}
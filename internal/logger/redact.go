@@ -0,0 +1,67 @@
+package logger
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	redacted       = "[REDACTED]"
+	maxFieldLength = 256
+)
+
+var secretKeys = []string{"password", "secret", "token", "authorization", "dsn"}
+
+// redactHook masks fields whose names look like secrets and truncates long
+// string values so a stray lyric or payload cannot flood the log.
+type redactHook struct{}
+
+func (redactHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (redactHook) Fire(entry *log.Entry) error {
+	for key, value := range entry.Data {
+		if isSecret(key) {
+			entry.Data[key] = redacted
+
+			continue
+		}
+
+		if str, ok := value.(string); ok {
+			entry.Data[key] = truncate(str, maxFieldLength)
+		}
+	}
+
+	entry.Message = truncate(entry.Message, maxFieldLength*4)
+
+	return nil
+}
+
+func truncate(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+
+	// Cut on a rune boundary so multi-byte lyrics stay valid UTF-8.
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+
+	return s[:cut] + "...(truncated)"
+}
+
+func isSecret(key string) bool {
+	key = strings.ToLower(key)
+
+	for _, secret := range secretKeys {
+		if strings.Contains(key, secret) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,9 +1,55 @@
 package models
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
 
 var (
-	ErrSongNotFound    = errors.New("song not found")
-	ErrVerseIsNotValid = errors.New("verse is not valid")
-	ErrDuplicateSong   = errors.New("duplicate song")
+	ErrSongNotFound       = errors.New("song not found")
+	ErrVerseIsNotValid    = errors.New("verse is not valid")
+	ErrDuplicateSong      = errors.New("duplicate song")
+	ErrProbableDuplicate  = errors.New("probable duplicate song")
+	ErrSongMerged         = errors.New("song merged")
+	ErrInvalidSorting     = errors.New("invalid sorting")
+	ErrInvalidReleaseDate = errors.New("invalid release date")
+	ErrWebhookNotFound    = errors.New("webhook not found")
+	ErrDeliveryNotFound   = errors.New("delivery not found")
+	ErrLinkNotFound       = errors.New("link not found")
+	ErrDuplicateLink      = errors.New("duplicate link")
+	ErrInvalidLink        = errors.New("invalid link")
+	ErrTagNotFound        = errors.New("tag not found")
+	ErrDuplicateTag       = errors.New("duplicate tag")
+	ErrUnknownTag         = errors.New("unknown tag")
+	ErrTagCycle           = errors.New("tag cannot descend from itself")
 )
+
+// ProbableDuplicateError rejects a song similar to songs already stored. It
+// matches ErrProbableDuplicate with errors.Is.
+type ProbableDuplicateError struct {
+	Candidates []*SimilarSong
+}
+
+func (e *ProbableDuplicateError) Error() string {
+	return fmt.Sprintf("%s: %d similar songs", ErrProbableDuplicate, len(e.Candidates))
+}
+
+func (e *ProbableDuplicateError) Unwrap() error {
+	return ErrProbableDuplicate
+}
+
+// MergedSongError reports that the song ID was merged into the song Into. It
+// matches ErrSongMerged with errors.Is.
+type MergedSongError struct {
+	ID, Into uuid.UUID
+}
+
+func (e *MergedSongError) Error() string {
+	return fmt.Sprintf("song %s merged into %s", e.ID, e.Into)
+}
+
+func (e *MergedSongError) Unwrap() error {
+	return ErrSongMerged
+}
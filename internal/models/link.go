@@ -0,0 +1,264 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Platforms of a song link.
+const (
+	PlatformYouTube    = "youtube"
+	PlatformSpotify    = "spotify"
+	PlatformAppleMusic = "apple_music"
+	PlatformLyrics     = "lyrics"
+	PlatformOther      = "other"
+)
+
+// Platforms lists the platforms of song links.
+var Platforms = []string{PlatformYouTube, PlatformSpotify, PlatformAppleMusic, PlatformLyrics, PlatformOther}
+
+// SongLink is an external link of a song. URL is normalized: links to the same
+// track on a platform are the same URL, and ExternalID is the id of the track
+// on the platform. A song has at most one primary link, which is its Link.
+type SongLink struct {
+	ID         uuid.UUID `json:"id"`
+	SongID     uuid.UUID `json:"songId"`
+	Platform   string    `json:"platform"`
+	URL        string    `json:"url"`
+	ExternalID string    `json:"externalId,omitempty"`
+	Region     string    `json:"region,omitempty"`
+	Primary    bool      `json:"primary"`
+	CreatedAt  time.Time `json:"createdAt"`
+	// Check is the outcome of the last health check, nil until the link is
+	// checked.
+	Check *LinkCheck `json:"check,omitempty"`
+}
+
+// LinkCheck is the outcome of a health check of a link. Status is the HTTP
+// status of the response, 0 when there was none, and RedirectURL where the
+// link ended up when it redirected. Failures counts the failed checks in a
+// row; a link is Broken once they reach the limit of the checker.
+type LinkCheck struct {
+	Status      int       `json:"status,omitempty"`
+	RedirectURL string    `json:"redirectUrl,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	CheckedAt   time.Time `json:"checkedAt"`
+	Failures    int       `json:"failures"`
+	Broken      bool      `json:"broken"`
+}
+
+// OK reports whether the link answered without an error status.
+func (c LinkCheck) OK() bool {
+	return c.Error == "" && c.Status > 0 && c.Status < 400
+}
+
+// BrokenLink is a broken link with the song it belongs to.
+type BrokenLink struct {
+	SongLink
+	Name  string `json:"name"`
+	Group string `json:"musicGroup"`
+}
+
+// LinkError rejects a URL that is on a known platform but does not link to a
+// track there. It matches ErrInvalidLink with errors.Is.
+type LinkError struct {
+	Reason string
+}
+
+func (e *LinkError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrInvalidLink, e.Reason)
+}
+
+func (e *LinkError) Unwrap() error {
+	return ErrInvalidLink
+}
+
+var (
+	youTubeID    = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+	spotifyID    = regexp.MustCompile(`^[A-Za-z0-9]{22}$`)
+	appleMusicID = regexp.MustCompile(`^[0-9]+$`)
+	storefront   = regexp.MustCompile(`^[a-z]{2}$`)
+	lyricsSlug   = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+)
+
+// platformNormalizers recognize the URLs of a platform by host and return the
+// canonical URL, the id and the region of the track they link to.
+var platformNormalizers = []struct {
+	platform string
+	hosts    []string
+	reason   string
+	match    func(u *url.URL) (canonical, id, region string, ok bool)
+}{
+	{
+		platform: PlatformYouTube,
+		hosts:    []string{"youtube.com", "www.youtube.com", "m.youtube.com", "music.youtube.com", "youtu.be"},
+		reason:   "must link to a YouTube video",
+		match:    matchYouTube,
+	},
+	{
+		platform: PlatformSpotify,
+		hosts:    []string{"open.spotify.com"},
+		reason:   "must link to a Spotify track",
+		match:    matchSpotify,
+	},
+	{
+		platform: PlatformAppleMusic,
+		hosts:    []string{"music.apple.com"},
+		reason:   "must link to an Apple Music song",
+		match:    matchAppleMusic,
+	},
+	{
+		platform: PlatformLyrics,
+		hosts:    []string{"genius.com", "www.genius.com"},
+		reason:   "must link to lyrics on Genius",
+		match:    matchGenius,
+	},
+	{
+		platform: PlatformLyrics,
+		hosts:    []string{"musixmatch.com", "www.musixmatch.com"},
+		reason:   "must link to lyrics on Musixmatch",
+		match:    matchMusixmatch,
+	},
+}
+
+// NormalizeLink recognizes the platform of an http or https URL and returns
+// the link it makes, with its canonical URL and, on a known platform, the id
+// and the region of the track. Other URLs are kept as they are but for the
+// case of the host and the fragment. Spotify URIs are accepted too.
+func NormalizeLink(raw string) (SongLink, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return SongLink{}, &LinkError{Reason: "must be an absolute http or https URL"}
+	}
+
+	if u.Scheme == "spotify" {
+		parts := strings.Split(u.Opaque, ":")
+		if len(parts) != 2 || parts[0] != "track" || !spotifyID.MatchString(parts[1]) {
+			return SongLink{}, &LinkError{Reason: "must link to a Spotify track"}
+		}
+
+		return SongLink{Platform: PlatformSpotify, URL: spotifyTrackURL(parts[1]), ExternalID: parts[1]}, nil
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return SongLink{}, &LinkError{Reason: "must be an absolute http or https URL"}
+	}
+
+	u.Host = strings.ToLower(u.Host)
+	u.Fragment = ""
+
+	for _, normalizer := range platformNormalizers {
+		if !slices.Contains(normalizer.hosts, u.Hostname()) {
+			continue
+		}
+
+		canonical, id, region, ok := normalizer.match(u)
+		if !ok {
+			return SongLink{}, &LinkError{Reason: normalizer.reason}
+		}
+
+		return SongLink{Platform: normalizer.platform, URL: canonical, ExternalID: id, Region: region}, nil
+	}
+
+	return SongLink{Platform: PlatformOther, URL: u.String()}, nil
+}
+
+// pathSegments splits the path of u, ignoring empty segments.
+func pathSegments(u *url.URL) []string {
+	return strings.FieldsFunc(u.Path, func(r rune) bool { return r == '/' })
+}
+
+// matchYouTube accepts youtu.be/ID, /watch?v=ID and /shorts, /embed, /live
+// and /v followed by ID.
+func matchYouTube(u *url.URL) (string, string, string, bool) {
+	segments := pathSegments(u)
+
+	var id string
+
+	switch {
+	case u.Hostname() == "youtu.be" && len(segments) == 1:
+		id = segments[0]
+	case len(segments) == 1 && segments[0] == "watch":
+		id = u.Query().Get("v")
+	case len(segments) == 2 && (segments[0] == "shorts" || segments[0] == "embed" ||
+		segments[0] == "live" || segments[0] == "v"):
+		id = segments[1]
+	}
+
+	if !youTubeID.MatchString(id) {
+		return "", "", "", false
+	}
+
+	return "https://www.youtube.com/watch?v=" + id, id, "", true
+}
+
+// matchSpotify accepts /track/ID, optionally after a localized /intl-xx.
+func matchSpotify(u *url.URL) (string, string, string, bool) {
+	segments := pathSegments(u)
+	if len(segments) > 0 && strings.HasPrefix(segments[0], "intl-") {
+		segments = segments[1:]
+	}
+
+	if len(segments) != 2 || segments[0] != "track" || !spotifyID.MatchString(segments[1]) {
+		return "", "", "", false
+	}
+
+	return spotifyTrackURL(segments[1]), segments[1], "", true
+}
+
+func spotifyTrackURL(id string) string {
+	return "https://open.spotify.com/track/" + id
+}
+
+// matchAppleMusic accepts /cc/song/[slug/]ID and /cc/album/slug/ID?i=ID, the
+// storefront cc being the region of the link.
+func matchAppleMusic(u *url.URL) (string, string, string, bool) {
+	segments := pathSegments(u)
+	if len(segments) < 3 || !storefront.MatchString(segments[0]) {
+		return "", "", "", false
+	}
+
+	var id string
+
+	switch segments[1] {
+	case "song":
+		id = segments[len(segments)-1]
+	case "album":
+		id = u.Query().Get("i")
+	}
+
+	if !appleMusicID.MatchString(id) {
+		return "", "", "", false
+	}
+
+	return "https://music.apple.com/" + segments[0] + "/song/" + id, id, strings.ToUpper(segments[0]), true
+}
+
+// matchGenius accepts /artist-title-lyrics, the slug being the id.
+func matchGenius(u *url.URL) (string, string, string, bool) {
+	segments := pathSegments(u)
+	if len(segments) != 1 || !strings.HasSuffix(segments[0], "-lyrics") || !lyricsSlug.MatchString(segments[0]) {
+		return "", "", "", false
+	}
+
+	return "https://genius.com/" + segments[0], segments[0], "", true
+}
+
+// matchMusixmatch accepts /lyrics/artist/title, "artist/title" being the id.
+func matchMusixmatch(u *url.URL) (string, string, string, bool) {
+	segments := pathSegments(u)
+	if len(segments) != 3 || segments[0] != "lyrics" ||
+		!lyricsSlug.MatchString(segments[1]) || !lyricsSlug.MatchString(segments[2]) {
+		return "", "", "", false
+	}
+
+	id := segments[1] + "/" + segments[2]
+
+	return "https://www.musixmatch.com/lyrics/" + id, id, "", true
+}
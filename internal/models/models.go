@@ -1,15 +1,20 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type Song struct {
-	ID          uuid.UUID `json:"id"`
-	ReleaseDate string    `json:"releaseDate"`
-	Name        string    `json:"name"`
-	Group       string    `json:"musicGroup"`
-	Text        string    `json:"text"`
-	Link        string    `json:"link"`
-	Deleted     bool      `json:"deleted"`
+	ID          uuid.UUID   `json:"id"`
+	ReleaseDate ReleaseDate `json:"releaseDate" swaggertype:"string"`
+	Name        string      `json:"name"`
+	Group       string      `json:"musicGroup"`
+	Text        string      `json:"text"`
+	Link        string      `json:"link"`
+	Deleted     bool        `json:"deleted"`
 }
 
 type SongDetails struct {
@@ -24,4 +29,141 @@ type Params struct {
 	Sorting    string `schema:"sorting"`
 	Descending bool   `schema:"descending"`
 	Filter     string `schema:"filter"`
+	// ReleasedFrom and ReleasedTo keep songs released within the periods
+	// they span, "releasedFrom=1990&releasedTo=1999" being the 1990s.
+	ReleasedFrom ReleaseDate `schema:"releasedFrom"`
+	ReleasedTo   ReleaseDate `schema:"releasedTo"`
+	// Tag keeps the songs with the tag of this slug, and with its descendants
+	// as well when Descendants is set.
+	Tag         string `schema:"tag"`
+	Descendants bool   `schema:"descendants"`
+	// Facets asks for the tag facets of the songs listed along with them.
+	Facets bool `schema:"facets"`
+	// GroupBoost and TagBoost are added to the score of related songs by the
+	// same group and with a tag in common.
+	GroupBoost float64 `schema:"groupBoost"`
+	TagBoost   float64 `schema:"tagBoost"`
+}
+
+// verseSeparator separates verses in song lyrics.
+const verseSeparator = "\n\n"
+
+// SplitVerses splits lyrics into verses, the same way verse numbers are
+// resolved everywhere in the API.
+func SplitVerses(text string) []string {
+	return strings.Split(text, verseSeparator)
+}
+
+const (
+	EventSongCreated = "song.created"
+	EventSongUpdated = "song.updated"
+	EventSongDeleted = "song.deleted"
+	// EventSongMerged is recorded for every song merged into another one.
+	EventSongMerged = "song.merged"
+)
+
+// Event is an entry of the change feed, written in the same transaction as
+// the change it describes.
+type Event struct {
+	ID        int64     `json:"id"`
+	Type      string    `json:"type"`
+	SongID    uuid.UUID `json:"songId"`
+	Song      *Song     `json:"song"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type Webhook struct {
+	ID         uuid.UUID `json:"id"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"secret,omitempty"`
+	EventTypes []string  `json:"eventTypes"`
+	Active     bool      `json:"active"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+// Delivery tracks sending one event to one webhook.
+type Delivery struct {
+	ID            int64     `json:"id"`
+	WebhookID     uuid.UUID `json:"webhookId"`
+	EventID       int64     `json:"eventId"`
+	Status        string    `json:"status"`
+	Attempts      int       `json:"attempts"`
+	LastStatus    int       `json:"lastStatus,omitempty"`
+	LastError     string    `json:"lastError,omitempty"`
+	NextAttemptAt time.Time `json:"nextAttemptAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// PendingDelivery is a delivery claimed for sending, together with what is
+// needed to send it.
+type PendingDelivery struct {
+	Delivery
+	URL    string
+	Secret string
+	Event  Event
+}
+
+// Change announces that a song was written, so that instances holding copies
+// of it can drop them.
+type Change struct {
+	Type   string    `json:"type"`
+	SongID uuid.UUID `json:"songId"`
+}
+
+// ChangeTags is the type of the changes to tags, which may move songs in or
+// out of lists filtered by tag. They are not events; SongID is the song whose
+// tags changed, if any.
+const ChangeTags = "tags"
+
+// ReplicaStatus is the last health check of a read replica. Reads are only
+// routed to healthy replicas.
+type ReplicaStatus struct {
+	Name    string    `json:"name"`
+	Healthy bool      `json:"healthy"`
+	Lag     string    `json:"lag"`
+	Error   string    `json:"error,omitempty"`
+	Checked time.Time `json:"checked"`
+}
+
+// SimilarSong is a song that is probably a duplicate of another one.
+// Similarity, from 0 to 1, is the trigram similarity of their names.
+type SimilarSong struct {
+	Song
+	Similarity float64 `json:"similarity"`
+}
+
+// RelatedSong is a song whose lyrics are like those of another one.
+// Similarity, from 0 to 1, is the cosine similarity of the TF-IDF vectors of
+// their lyrics; Score adds the boosts asked for to it.
+type RelatedSong struct {
+	Song
+	Similarity float64 `json:"similarity"`
+	Score      float64 `json:"score"`
+}
+
+// Merge merges the sources into a song. Fields maps the JSON names of song
+// fields to the song, among the merged one and the sources, whose value
+// wins; the merged song keeps its own values otherwise.
+type Merge struct {
+	Sources []uuid.UUID          `json:"sources"`
+	Fields  map[string]uuid.UUID `json:"fields"`
+}
+
+// SongPair links two songs that are probably duplicates of each other.
+type SongPair struct {
+	A, B       uuid.UUID
+	Similarity float64
+}
+
+// DuplicateCluster is a group of songs linked by probable duplicate pairs.
+// Similarity is the lowest similarity of the pairs linking them.
+type DuplicateCluster struct {
+	Songs      []*Song `json:"songs"`
+	Similarity float64 `json:"similarity"`
 }
@@ -0,0 +1,110 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Precisions of a release date.
+const (
+	PrecisionYear  = "year"
+	PrecisionMonth = "month"
+	PrecisionDay   = "day"
+)
+
+// releaseDateLayouts are the formats ParseReleaseDate accepts by precision:
+// the API format, the one of the details API, and ISO 8601.
+var releaseDateLayouts = []struct {
+	layout    string
+	precision string
+}{
+	{"2.1.2006", PrecisionDay},
+	{"2006-01-02", PrecisionDay},
+	{"1.2006", PrecisionMonth},
+	{"2006-01", PrecisionMonth},
+	{"2006", PrecisionYear},
+}
+
+// ReleaseDate is the day, month or year a song was released. Date is the
+// first day of that period in UTC; the zero value is an unknown date. It is
+// written as "16.07.2006", "07.2006" or "2006" depending on the precision.
+type ReleaseDate struct {
+	Date      time.Time
+	Precision string
+}
+
+// NewReleaseDate truncates t to precision.
+func NewReleaseDate(t time.Time, precision string) ReleaseDate {
+	year, month, day := t.Date()
+
+	switch precision {
+	case PrecisionYear:
+		month, day = time.January, 1
+	case PrecisionMonth:
+		day = 1
+	}
+
+	return ReleaseDate{Date: time.Date(year, month, day, 0, 0, 0, 0, time.UTC), Precision: precision}
+}
+
+// ParseReleaseDate parses "16.07.2006", "07.2006", "2006" and their ISO 8601
+// forms. An empty string is an unknown date.
+func ParseReleaseDate(s string) (ReleaseDate, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return ReleaseDate{}, nil
+	}
+
+	for _, format := range releaseDateLayouts {
+		if t, err := time.Parse(format.layout, s); err == nil {
+			return NewReleaseDate(t, format.precision), nil
+		}
+	}
+
+	return ReleaseDate{}, fmt.Errorf("%w: %q", ErrInvalidReleaseDate, s)
+}
+
+func (d ReleaseDate) IsZero() bool {
+	return d.Precision == ""
+}
+
+// End is the last day of the period of the date.
+func (d ReleaseDate) End() time.Time {
+	switch d.Precision {
+	case PrecisionYear:
+		return d.Date.AddDate(1, 0, -1)
+	case PrecisionMonth:
+		return d.Date.AddDate(0, 1, -1)
+	default:
+		return d.Date
+	}
+}
+
+func (d ReleaseDate) String() string {
+	switch d.Precision {
+	case PrecisionYear:
+		return d.Date.Format("2006")
+	case PrecisionMonth:
+		return d.Date.Format("01.2006")
+	case PrecisionDay:
+		return d.Date.Format("02.01.2006")
+	default:
+		return ""
+	}
+}
+
+func (d ReleaseDate) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *ReleaseDate) UnmarshalText(text []byte) error {
+	parsed, err := ParseReleaseDate(string(text))
+	if err != nil {
+		return err
+	}
+
+	*d = parsed
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+)
+
+// Stats are aggregates over the songs that are not deleted. The Postgres
+// store serves them from materialized views, so they may be as old as
+// RefreshedAt; the other stores compute them on every request.
+type Stats struct {
+	Songs  int `json:"songs"`
+	Groups int `json:"groups"`
+	// ByGroup counts the songs of every group, the largest groups first. It
+	// is paged like song lists.
+	ByGroup []StatsCount `json:"byGroup"`
+	// ByReleaseYear and ByCreationMonth, "2024-10" in UTC, leave out songs
+	// whose release date or creation time is unknown.
+	ByReleaseYear   []StatsCount `json:"byReleaseYear"`
+	ByCreationMonth []StatsCount `json:"byCreationMonth"`
+	Lyrics          LyricStats   `json:"lyrics"`
+	Completeness    Completeness `json:"completeness"`
+	RefreshedAt     *time.Time   `json:"refreshedAt,omitempty"`
+}
+
+// StatsCount is the number of songs of a group, year or month.
+type StatsCount struct {
+	Key   string `json:"key"`
+	Songs int    `json:"songs"`
+}
+
+// LyricStats measure the lyrics of the songs that have any. Verses are split
+// like GetText splits them, words are separated by white space.
+type LyricStats struct {
+	Songs         int     `json:"songs"`
+	AverageVerses float64 `json:"averageVerses"`
+	MaxVerses     int     `json:"maxVerses"`
+	AverageWords  float64 `json:"averageWords"`
+	TotalWords    int     `json:"totalWords"`
+}
+
+// Completeness are the shares of songs, from 0 to 1, that have a release
+// date, lyrics, a primary link and tags.
+type Completeness struct {
+	ReleaseDate float64 `json:"releaseDate"`
+	Text        float64 `json:"text"`
+	Link        float64 `json:"link"`
+	Tags        float64 `json:"tags"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"github.com/google/uuid"
+)
+
+// Tag classifies songs, by genre, mood or era. Tags form trees, rock > punk,
+// and a song tagged punk is a rock song too when descendants are asked for.
+// Slug names the tag in the API, in the tag filter of song lists for one.
+type Tag struct {
+	ID       uuid.UUID  `json:"id"`
+	Slug     string     `json:"slug"`
+	Name     string     `json:"name"`
+	ParentID *uuid.UUID `json:"parentId,omitempty"`
+}
+
+// TagFacet counts the songs of a list that have a tag or one of its
+// descendants.
+type TagFacet struct {
+	Tag
+	Count int `json:"count"`
+}
+
+// SongTags are the slugs of the tags of a song.
+type SongTags struct {
+	Tags []string `json:"tags"`
+}
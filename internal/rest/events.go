@@ -0,0 +1,292 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/logger"
+	"github.com/iurikman/songs/internal/models"
+)
+
+const (
+	defaultSSEPollInterval = time.Second
+	sseHeartbeatInterval   = 15 * time.Second
+	sseBatchSize           = 100
+)
+
+type eventFeed interface {
+	Events(ctx context.Context, afterID int64, limit int) ([]*models.Event, error)
+	CreateWebhook(ctx context.Context, webhook models.Webhook) (*models.Webhook, error)
+	Webhooks(ctx context.Context) ([]*models.Webhook, error)
+	DeleteWebhook(ctx context.Context, id uuid.UUID) error
+	DeadDeliveries(ctx context.Context, params models.Params) ([]*models.Delivery, error)
+	RetryDelivery(ctx context.Context, id int64) error
+}
+
+// songEvents godoc
+// @Summary Stream song changes
+// @Description Server-Sent Events stream of song.created, song.updated and
+// @Description song.deleted events. Reconnecting clients resume after the
+// @Description Last-Event-ID header or the lastEventId query parameter.
+// @Tags events
+// @Produce text/event-stream
+// @Param Last-Event-ID header int false "Id of the last event received"
+// @Success 200 {object} models.Event
+// @Failure 400 {object} HTTPResponse
+// @Router /songs/events [get].
+func (s *Server) songEvents(w http.ResponseWriter, r *http.Request) {
+	entry := logger.FromContext(r.Context())
+
+	lastID, err := lastEventID(r)
+	if err != nil {
+		writeError(w, r, errInvalidLastEventID)
+
+		return
+	}
+
+	// The stream outlives any write timeout configured for regular requests.
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		entry.WithError(err).Debug("clearing write deadline not supported")
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if err := rc.Flush(); err != nil {
+		entry.WithError(err).Error("response does not support streaming")
+
+		return
+	}
+
+	entry.WithField("last_event_id", lastID).Debug("event stream opened")
+
+	poll := time.NewTicker(s.ssePollInterval())
+	defer poll.Stop()
+
+	lastWrite := time.Now()
+
+	for {
+		batch, err := s.deps.Events.Events(r.Context(), lastID, sseBatchSize)
+		if err != nil {
+			if r.Context().Err() == nil {
+				entry.WithError(err).Error("reading events failed")
+			}
+
+			return
+		}
+
+		for _, event := range batch {
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+
+			lastID = event.ID
+		}
+
+		if len(batch) == 0 && time.Since(lastWrite) >= sseHeartbeatInterval {
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+
+			lastWrite = time.Now()
+		}
+
+		if len(batch) > 0 {
+			lastWrite = time.Now()
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		if len(batch) == sseBatchSize {
+			continue
+		}
+
+		select {
+		case <-r.Context().Done():
+			entry.Debug("event stream closed")
+
+			return
+		case <-poll.C:
+		}
+	}
+}
+
+func (s *Server) ssePollInterval() time.Duration {
+	if s.config.SSEPollInterval > 0 {
+		return s.config.SSEPollInterval
+	}
+
+	return defaultSSEPollInterval
+}
+
+func lastEventID(r *http.Request) (int64, error) {
+	raw := r.Header.Get("Last-Event-ID")
+	if raw == "" {
+		raw = r.URL.Query().Get("lastEventId")
+	}
+
+	if raw == "" {
+		return 0, nil
+	}
+
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || id < 0 {
+		return 0, fmt.Errorf("invalid event id %q", raw) //nolint:err113
+	}
+
+	return id, nil
+}
+
+func writeEvent(w http.ResponseWriter, event *models.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("json.Marshal(event) err: %w", err)
+	}
+
+	if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data); err != nil {
+		return fmt.Errorf("writing event err: %w", err)
+	}
+
+	return nil
+}
+
+// createWebhook godoc
+// @Summary Register a webhook
+// @Description Register a URL to receive signed song events. The secret used
+// @Description for the X-Songs-Signature header is only returned here.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param webhook body models.Webhook true "Webhook url and event types"
+// @Success 201 {object} models.Webhook
+// @Failure 400 {object} HTTPResponse
+// @Failure 422 {object} HTTPResponse
+// @Failure 500 {object} HTTPResponse
+// @Router /webhooks [post].
+func (s *Server) createWebhook(w http.ResponseWriter, r *http.Request) {
+	var webhook models.Webhook
+
+	if !decodeBody(w, r, &webhook) {
+		return
+	}
+
+	created, err := s.deps.Events.CreateWebhook(r.Context(), webhook)
+	if err != nil {
+		writeError(w, r, err)
+
+		return
+	}
+
+	writeOKResponse(w, http.StatusCreated, created)
+}
+
+// getWebhooks godoc
+// @Summary List webhooks
+// @Tags webhooks
+// @Produce json
+// @Success 200 {array} models.Webhook
+// @Failure 500 {object} HTTPResponse
+// @Router /webhooks [get].
+func (s *Server) getWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := s.deps.Events.Webhooks(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+
+		return
+	}
+
+	writeOKResponse(w, http.StatusOK, webhooks)
+}
+
+// deleteWebhook godoc
+// @Summary Delete a webhook
+// @Tags webhooks
+// @Param id path string true "Webhook ID"
+// @Success 204
+// @Failure 400 {object} HTTPResponse
+// @Failure 404 {object} HTTPResponse
+// @Failure 500 {object} HTTPResponse
+// @Router /webhooks/{id} [delete].
+func (s *Server) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, errInvalidID)
+
+		return
+	}
+
+	if err := s.deps.Events.DeleteWebhook(r.Context(), id); err != nil {
+		writeError(w, r, err)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getDeadDeliveries godoc
+// @Summary List dead webhook deliveries
+// @Description Deliveries that failed every attempt, most recent first
+// @Tags webhooks
+// @Produce json
+// @Param offset query int false "Offset for pagination"
+// @Param limit query int false "Limit number of deliveries"
+// @Success 200 {array} models.Delivery
+// @Failure 400 {object} HTTPResponse
+// @Failure 500 {object} HTTPResponse
+// @Router /webhooks/deliveries/dead [get].
+func (s *Server) getDeadDeliveries(w http.ResponseWriter, r *http.Request) {
+	params, err := s.parseParams(r.URL.Query())
+	if err != nil {
+		writeError(w, r, err)
+
+		return
+	}
+
+	deliveries, err := s.deps.Events.DeadDeliveries(r.Context(), *params)
+	if err != nil {
+		writeError(w, r, err)
+
+		return
+	}
+
+	writeOKResponse(w, http.StatusOK, deliveries)
+}
+
+// retryDelivery godoc
+// @Summary Retry a dead webhook delivery
+// @Tags webhooks
+// @Param id path int true "Delivery ID"
+// @Success 202
+// @Failure 400 {object} HTTPResponse
+// @Failure 404 {object} HTTPResponse
+// @Failure 500 {object} HTTPResponse
+// @Router /webhooks/deliveries/{id}/retry [post].
+func (s *Server) retryDelivery(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, r, errInvalidID)
+
+		return
+	}
+
+	if err := s.deps.Events.RetryDelivery(r.Context(), id); err != nil {
+		writeError(w, r, err)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
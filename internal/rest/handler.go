@@ -7,66 +7,100 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"path"
 	"strconv"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/gorilla/schema"
+	"github.com/iurikman/songs/internal/logger"
 	"github.com/iurikman/songs/internal/models"
+	"github.com/iurikman/songs/internal/validation"
 	log "github.com/sirupsen/logrus"
 )
 
 const (
 	standardPage = 10
+	// maxBodyBytes leaves room for lyrics of the maximum length escaped in
+	// JSON.
+	maxBodyBytes = 1 << 20
 )
 
+var errNegativePaging = errors.New("offset and limit must not be negative")
+
+// HTTPResponse is the v1 envelope. Code, set with Error, is the stable code
+// of the error; see Problem.
 type HTTPResponse struct {
 	Data  any    `json:"data"`
 	Error string `json:"error"`
+	Code  string `json:"code,omitempty"`
+	// Errors lists the violations of a request that failed validation.
+	Errors []validation.FieldError `json:"errors,omitempty"`
+	// Facets count the songs of a list per tag, when asked for.
+	Facets []*models.TagFacet `json:"facets,omitempty"`
 }
 
 type service interface {
-	CreateSong(ctx context.Context, song models.Song) (*models.Song, error)
+	CreateSong(ctx context.Context, song models.Song, force bool) (*models.Song, error)
 	GetSongs(ctx context.Context, params models.Params) ([]*models.Song, error)
 	GetText(ctx context.Context, id uuid.UUID, verse int) (*string, error)
 	DeleteSong(ctx context.Context, id uuid.UUID) error
 	UpdateSong(ctx context.Context, id uuid.UUID, song models.Song) (*models.Song, error)
+	DuplicateClusters(ctx context.Context, params models.Params) ([]*models.DuplicateCluster, error)
+	MergeSongs(ctx context.Context, id uuid.UUID, merge models.Merge) (*models.Song, error)
+	GetLinks(ctx context.Context, songID uuid.UUID) ([]*models.SongLink, error)
+	CreateLink(ctx context.Context, songID uuid.UUID, link models.SongLink) (*models.SongLink, error)
+	UpdateLink(ctx context.Context, songID, id uuid.UUID, link models.SongLink) (*models.SongLink, error)
+	DeleteLink(ctx context.Context, songID, id uuid.UUID) error
+	GetBrokenLinks(ctx context.Context, params models.Params) ([]*models.BrokenLink, error)
+	GetTags(ctx context.Context) ([]*models.Tag, error)
+	CreateTag(ctx context.Context, tag models.Tag) (*models.Tag, error)
+	UpdateTag(ctx context.Context, id uuid.UUID, tag models.Tag) (*models.Tag, error)
+	DeleteTag(ctx context.Context, id uuid.UUID) error
+	GetSongTags(ctx context.Context, songID uuid.UUID) ([]*models.Tag, error)
+	SetSongTags(ctx context.Context, songID uuid.UUID, tags models.SongTags) ([]*models.Tag, error)
+	TagFacets(ctx context.Context, params models.Params) ([]*models.TagFacet, error)
+	RelatedSongs(ctx context.Context, id uuid.UUID, params models.Params) ([]*models.RelatedSong, error)
+	GetStats(ctx context.Context, params models.Params) (*models.Stats, error)
 }
 
 // createSong godoc
 // @Summary Create a new song
-// @Description Create a new song with the provided details
+// @Description Create a new song with the provided details. A song similar to
+// @Description songs already stored is rejected with them as candidates.
 // @Tags songs
 // @Accept json
 // @Produce json
 // @Param song body models.Song true "Song Data"
+// @Param force query bool false "Create the song even if it is probably a duplicate"
 // @Success 201 {object} models.Song
 // @Failure 400 {object} HTTPResponse
 // @Failure 409 {object} HTTPResponse
+// @Failure 413 {object} HTTPResponse
+// @Failure 422 {object} HTTPResponse
 // @Failure 500 {object} HTTPResponse
 // @Router /songs [post].
 func (s *Server) createSong(w http.ResponseWriter, r *http.Request) {
-	log.Debug("createSong: handler invoked")
+	logger.FromContext(r.Context()).Debug("createSong: handler invoked")
 
 	var song models.Song
 
-	if err := json.NewDecoder(r.Body).Decode(&song); err != nil {
-		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+	force, err := parseBool(r.URL.Query().Get("force"))
+	if err != nil {
+		writeError(w, r, err)
 
 		return
 	}
 
-	log.Debugf("Attempting to create song: %+v", song)
-
-	createSong, err := s.svc.CreateSong(r.Context(), song)
+	if !decodeBody(w, r, &song) {
+		return
+	}
 
-	switch {
-	case errors.Is(err, models.ErrDuplicateSong):
-		writeErrorResponse(w, http.StatusConflict, err.Error())
+	logger.FromContext(r.Context()).WithFields(logger.SongFields(&song)).Debug("Attempting to create song")
 
-		return
-	case err != nil:
-		writeErrorResponse(w, http.StatusInternalServerError, "internal server error")
+	createSong, err := s.svc.CreateSong(r.Context(), song, force)
+	if err != nil {
+		writeError(w, r, err)
 
 		return
 	}
@@ -82,6 +116,11 @@ func (s *Server) createSong(w http.ResponseWriter, r *http.Request) {
 // @Param filter query string false "Filter by song name"
 // @Param sorting query string false "Sort by field (e.g., name)"
 // @Param descending query bool false "Sort in descending order"
+// @Param releasedFrom query string false "Released in or after this day, month or year (e.g., 1990)"
+// @Param releasedTo query string false "Released in or before this day, month or year (e.g., 1999)"
+// @Param tag query string false "Tagged with the tag of this slug"
+// @Param descendants query bool false "Tagged with a descendant of the tag as well"
+// @Param facets query bool false "Count the songs listed per tag, on every page"
 // @Param offset query int false "Offset for pagination"
 // @Param limit query int false "Limit number of songs"
 // @Success 200 {array} models.Song
@@ -89,70 +128,114 @@ func (s *Server) createSong(w http.ResponseWriter, r *http.Request) {
 // @Failure 500 {object} HTTPResponse
 // @Router /songs [get].
 func (s *Server) getSongs(w http.ResponseWriter, r *http.Request) {
-	log.Debug("getSongs: handler invoked")
+	logger.FromContext(r.Context()).Debug("getSongs: handler invoked")
 
-	params, err := parseParams(r.URL.Query())
+	params, err := s.parseParams(r.URL.Query())
 	if err != nil {
-		writeErrorResponse(w, http.StatusBadRequest, "invalid query parameters")
+		writeError(w, r, err)
 
 		return
 	}
 
-	log.Debugf("Fetching songs with params: %+v", params)
+	logger.FromContext(r.Context()).WithField("params", *params).Debug("Fetching songs")
 
 	songs, err := s.svc.GetSongs(r.Context(), *params)
 	if err != nil {
-		writeErrorResponse(w, http.StatusInternalServerError, "internal server error")
+		writeError(w, r, err)
 
 		return
 	}
 
-	writeOKResponse(w, http.StatusOK, songs)
+	if !params.Facets {
+		writeOKResponse(w, http.StatusOK, songs)
+
+		return
+	}
+
+	facets, err := s.svc.TagFacets(r.Context(), *params)
+	if err != nil {
+		writeError(w, r, err)
+
+		return
+	}
+
+	writeResponse(w, http.StatusOK, HTTPResponse{Data: songs, Facets: facets})
+}
+
+// getDuplicates godoc
+// @Summary List probable duplicates
+// @Description Clusters of songs whose names and groups are similar and whose
+// @Description release dates overlap, largest clusters first
+// @Tags songs
+// @Produce json
+// @Param offset query int false "Offset for pagination"
+// @Param limit query int false "Limit number of clusters"
+// @Success 200 {array} models.DuplicateCluster
+// @Failure 400 {object} HTTPResponse
+// @Failure 500 {object} HTTPResponse
+// @Router /songs/duplicates [get].
+func (s *Server) getDuplicates(w http.ResponseWriter, r *http.Request) {
+	params, err := s.parseParams(r.URL.Query())
+	if err != nil {
+		writeError(w, r, err)
+
+		return
+	}
+
+	clusters, err := s.svc.DuplicateClusters(r.Context(), *params)
+	if err != nil {
+		writeError(w, r, err)
+
+		return
+	}
+
+	writeOKResponse(w, http.StatusOK, clusters)
 }
 
 // getText godoc
 // @Summary Get song text
-// @Description Retrieve the text of a song by ID and verse
+// @Description Retrieve the text of a song by ID and verse. A song merged into
+// @Description another one redirects to it.
 // @Tags songs
 // @Produce json
 // @Param id path string true "Song ID"
 // @Param offset query int true "Verse offset"
 // @Success 200 {string} string "Song text"
+// @Success 301
 // @Failure 400 {object} HTTPResponse
 // @Failure 404 {object} HTTPResponse
 // @Failure 500 {object} HTTPResponse
 // @Router /songs/{id} [get].
 func (s *Server) getText(w http.ResponseWriter, r *http.Request) {
-	log.Debug("getText: handler invoked")
+	logger.FromContext(r.Context()).Debug("getText: handler invoked")
 
 	id, err := uuid.Parse(chi.URLParam(r, "id"))
 	if err != nil {
-		writeErrorResponse(w, http.StatusBadRequest, "invalid id")
+		writeError(w, r, errInvalidID)
 
 		return
 	}
 
 	verse, err := strconv.Atoi(r.URL.Query().Get("offset"))
 	if err != nil {
-		writeErrorResponse(w, http.StatusBadRequest, "invalid verse")
+		writeError(w, r, errInvalidVerse)
 
 		return
 	}
 
-	log.Debugf("Retrieving text for song ID: %s, verse offset: %d", id, verse)
+	logger.FromContext(r.Context()).Debugf("Retrieving text for song ID: %s, verse offset: %d", id, verse)
+
 	text, err := s.svc.GetText(r.Context(), id, verse)
 
-	switch {
-	case errors.Is(err, models.ErrSongNotFound):
-		writeErrorResponse(w, http.StatusNotFound, err.Error())
+	var merged *models.MergedSongError
+	if errors.As(err, &merged) {
+		redirectToSong(w, r, merged.Into)
 
 		return
-	case errors.Is(err, models.ErrVerseIsNotValid):
-		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+	}
 
-		return
-	case err != nil:
-		writeErrorResponse(w, http.StatusInternalServerError, "internal server error")
+	if err != nil {
+		writeError(w, r, err)
 
 		return
 	}
@@ -171,26 +254,19 @@ func (s *Server) getText(w http.ResponseWriter, r *http.Request) {
 // @Failure 500 {object} HTTPResponse
 // @Router /songs/{id} [delete].
 func (s *Server) deleteSong(w http.ResponseWriter, r *http.Request) {
-	log.Debug("deleteSong: handler invoked")
+	logger.FromContext(r.Context()).Debug("deleteSong: handler invoked")
 
 	id, err := uuid.Parse(chi.URLParam(r, "id"))
 	if err != nil {
-		writeErrorResponse(w, http.StatusBadRequest, "invalid id")
+		writeError(w, r, errInvalidID)
 
 		return
 	}
 
-	log.Debugf("Attempting to delete song with ID: %s", id)
-	err = s.svc.DeleteSong(r.Context(), id)
+	logger.FromContext(r.Context()).Debugf("Attempting to delete song with ID: %s", id)
 
-	switch {
-	case errors.Is(err, models.ErrSongNotFound):
-		writeErrorResponse(w, http.StatusNotFound, err.Error())
-
-		return
-
-	case err != nil:
-		writeErrorResponse(w, http.StatusInternalServerError, "internal server error")
+	if err := s.svc.DeleteSong(r.Context(), id); err != nil {
+		writeError(w, r, err)
 
 		return
 	}
@@ -200,37 +276,51 @@ func (s *Server) deleteSong(w http.ResponseWriter, r *http.Request) {
 
 // updateSong godoc
 // @Summary Update a song
-// @Description Update details of a song by ID
+// @Description Update details of a song by ID. A song merged into another one
+// @Description redirects to it.
 // @Tags songs
 // @Accept json
 // @Produce json
 // @Param id path string true "Song ID"
 // @Param song body models.Song true "Song Data"
 // @Success 200 {object} models.Song
+// @Success 308
 // @Failure 400 {object} HTTPResponse
+// @Failure 404 {object} HTTPResponse
+// @Failure 409 {object} HTTPResponse
+// @Failure 413 {object} HTTPResponse
+// @Failure 422 {object} HTTPResponse
 // @Failure 500 {object} HTTPResponse
-// @Router /songs/{id} [put].
+// @Router /songs/{id} [patch].
 func (s *Server) updateSong(w http.ResponseWriter, r *http.Request) {
-	log.Debug("updateSong: handler invoked")
+	logger.FromContext(r.Context()).Debug("updateSong: handler invoked")
 
 	var song models.Song
 
 	id, err := uuid.Parse(chi.URLParam(r, "id"))
 	if err != nil {
-		writeErrorResponse(w, http.StatusBadRequest, "invalid id")
+		writeError(w, r, errInvalidID)
 
 		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&song); err != nil {
-		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+	if !decodeBody(w, r, &song) {
+		return
 	}
 
-	log.Debugf("Attempting to update song with ID: %s to: %+v", id, song)
+	logger.FromContext(r.Context()).WithFields(logger.SongFields(&song)).Debugf("Attempting to update song with ID: %s", id)
 
 	updatedSong, err := s.svc.UpdateSong(r.Context(), id, song)
+
+	var merged *models.MergedSongError
+	if errors.As(err, &merged) {
+		redirectToSong(w, r, merged.Into)
+
+		return
+	}
+
 	if err != nil {
-		writeErrorResponse(w, http.StatusInternalServerError, "internal server error")
+		writeError(w, r, err)
 
 		return
 	}
@@ -238,40 +328,129 @@ func (s *Server) updateSong(w http.ResponseWriter, r *http.Request) {
 	writeOKResponse(w, http.StatusOK, updatedSong)
 }
 
-func parseParams(values url.Values) (*models.Params, error) {
+// mergeSongs godoc
+// @Summary Merge songs
+// @Description Merge the source songs into a song, which takes the values of
+// @Description the fields picked from them. The sources are deleted and their
+// @Description IDs redirect to the song.
+// @Tags songs
+// @Accept json
+// @Produce json
+// @Param id path string true "Song ID"
+// @Param merge body models.Merge true "Sources and the song each field is taken from"
+// @Success 200 {object} models.Song
+// @Failure 400 {object} HTTPResponse
+// @Failure 404 {object} HTTPResponse
+// @Failure 409 {object} HTTPResponse
+// @Failure 413 {object} HTTPResponse
+// @Failure 422 {object} HTTPResponse
+// @Failure 500 {object} HTTPResponse
+// @Router /songs/{id}/merge [post].
+func (s *Server) mergeSongs(w http.ResponseWriter, r *http.Request) {
+	logger.FromContext(r.Context()).Debug("mergeSongs: handler invoked")
+
+	var merge models.Merge
+
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, errInvalidID)
+
+		return
+	}
+
+	if !decodeBody(w, r, &merge) {
+		return
+	}
+
+	mergedSong, err := s.svc.MergeSongs(r.Context(), id, merge)
+	if err != nil {
+		writeError(w, r, err)
+
+		return
+	}
+
+	writeOKResponse(w, http.StatusOK, mergedSong)
+}
+
+// redirectToSong permanently redirects a request for a merged song to the
+// same resource of the song it was merged into. Writes are redirected with
+// 308, which keeps their method and body where 301 lets clients send a GET.
+func redirectToSong(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
+	target := url.URL{Path: path.Join(path.Dir(r.URL.Path), id.String()), RawQuery: r.URL.RawQuery}
+
+	code := http.StatusMovedPermanently
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		code = http.StatusPermanentRedirect
+	}
+
+	http.Redirect(w, r, target.String(), code)
+}
+
+func (s *Server) parseParams(values url.Values) (*models.Params, error) {
 	decoder := schema.NewDecoder()
 	params := &models.Params{}
 
-	log.Debug("Parsing query parameters")
-
 	err := decoder.Decode(params, values)
 	if err != nil {
-		return nil, fmt.Errorf("decoder.Decode(params, values): %w", err)
+		return nil, fmt.Errorf("%w: %w", errInvalidQuery, err)
+	}
+
+	if params.Offset < 0 || params.Limit < 0 {
+		return nil, fmt.Errorf("%w: %w", errInvalidQuery, errNegativePaging)
 	}
 
 	if params.Limit == 0 {
-		params.Limit = standardPage
+		params.Limit = int(s.defaultPage.Load())
 	}
 
-	log.Infof("Parsed parameters: %+v", params)
+	if maxLimit := int(s.maxPage.Load()); params.Limit > maxLimit {
+		params.Limit = maxLimit
+	}
 
 	return params, nil
 }
 
-func writeErrorResponse(w http.ResponseWriter, statusCode int, description string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
+// parseBool parses a boolean query parameter, false when it is empty.
+func parseBool(value string) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", errInvalidQuery, err)
+	}
+
+	return b, nil
+}
 
-	if err := json.NewEncoder(w).Encode(HTTPResponse{Error: description}); err != nil {
-		log.Warnf("json.NewEncoder(w).Encode(HTTPResponse{Error: description}) err: %v", err)
+// decodeBody strictly decodes the JSON body of r into v. When it cannot, it
+// writes the error response and returns false.
+func decodeBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	err := validation.DecodeJSON(http.MaxBytesReader(w, r.Body, maxBodyBytes), v)
+	if err == nil {
+		return true
 	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		err = fmt.Errorf("%w: must be at most %d bytes", errBodyTooLarge, tooLarge.Limit)
+	}
+
+	writeError(w, r, err)
+
+	return false
 }
 
 func writeOKResponse(w http.ResponseWriter, statusCode int, respData any) {
+	writeResponse(w, statusCode, HTTPResponse{Data: respData})
+}
+
+func writeResponse(w http.ResponseWriter, statusCode int, resp HTTPResponse) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
 
-	if err := json.NewEncoder(w).Encode(HTTPResponse{Data: respData}); err != nil {
-		log.Warnf("json.NewEncoder(w).Encode(HTTPResponse{Data: respData}) err: %v", err)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Warnf("json.NewEncoder(w).Encode(resp) err: %v", err)
 	}
 }
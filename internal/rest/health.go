@@ -0,0 +1,226 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/iurikman/songs/internal/cache"
+	"github.com/iurikman/songs/internal/models"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	statusUp       = "up"
+	statusDown     = "down"
+	statusOK       = "ok"
+	statusDegraded = "degraded"
+
+	circuitOpen = "open"
+
+	healthCheckTimeout = 2 * time.Second
+)
+
+var (
+	errNotReady          = errors.New("server is shutting down")
+	errPendingMigrations = errors.New("migrations are pending")
+	errCircuitOpen       = errors.New("song details circuit is open")
+)
+
+type database interface {
+	// Name labels the store in the health report.
+	Name() string
+	Ping(ctx context.Context) error
+	PendingMigrations(ctx context.Context) (int, error)
+}
+
+type songDetails interface {
+	CircuitState() string
+}
+
+type cacheStats interface {
+	Stats() cache.Stats
+}
+
+type replicaStatus interface {
+	Replicas() []models.ReplicaStatus
+}
+
+type configInfo interface {
+	Hash() string
+	Reloads() int64
+	Failures() int64
+}
+
+// Dependencies are the optional collaborators of the server. DB and
+// SongDetails are probed by the health endpoints, Config exposes the hot
+// reload state and GraphQL, when set, is served at /graphql. Events, when
+// set, enables the change feed and the webhook endpoints. Cache, when set,
+// has its hit and miss counters reported by /health. Search, when set, is
+// served at /songs/search. Replicas, when set, has the state of the read
+// replicas reported by /health; an ejected replica does not fail readiness,
+// reads just skip it.
+type Dependencies struct {
+	DB          database
+	SongDetails songDetails
+	Config      configInfo
+	GraphQL     http.Handler
+	Events      eventFeed
+	Cache       cacheStats
+	Search      searcher
+	Replicas    replicaStatus
+}
+
+type dependencyHealth struct {
+	Status  string `json:"status"`
+	Latency string `json:"latency"`
+	Error   string `json:"error,omitempty"`
+	Pending *int   `json:"pending,omitempty"`
+	Circuit string `json:"circuit,omitempty"`
+}
+
+type configReport struct {
+	Hash     string `json:"hash"`
+	Reloads  int64  `json:"reloads"`
+	Failures int64  `json:"failures"`
+}
+
+type healthReport struct {
+	Status   string                      `json:"status"`
+	Ready    bool                        `json:"ready"`
+	Checks   map[string]dependencyHealth `json:"checks"`
+	Config   *configReport               `json:"config,omitempty"`
+	Cache    *cache.Stats                `json:"cache,omitempty"`
+	Replicas []models.ReplicaStatus      `json:"replicas,omitempty"`
+}
+
+// healthz only reports that the process is able to serve HTTP.
+func (s *Server) healthz(w http.ResponseWriter, _ *http.Request) {
+	writeHealth(w, http.StatusOK, healthReport{Status: statusOK, Ready: s.ready.Load()})
+}
+
+// readyz fails as soon as shutdown begins or any dependency is unhealthy.
+func (s *Server) readyz(w http.ResponseWriter, r *http.Request) {
+	report := s.checkDependencies(r.Context())
+
+	if !report.Ready {
+		writeHealth(w, http.StatusServiceUnavailable, report)
+
+		return
+	}
+
+	writeHealth(w, http.StatusOK, report)
+}
+
+// health returns the per-dependency report regardless of the overall state.
+func (s *Server) health(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, s.checkDependencies(r.Context()))
+}
+
+func (s *Server) checkDependencies(ctx context.Context) healthReport {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	report := healthReport{
+		Status: statusOK,
+		Ready:  s.ready.Load(),
+		Checks: make(map[string]dependencyHealth),
+	}
+
+	if !report.Ready {
+		report.Status = statusDown
+		report.Checks["server"] = dependencyHealth{Status: statusDown, Error: errNotReady.Error()}
+	}
+
+	if s.deps.DB != nil {
+		report.Checks[s.deps.DB.Name()] = probe(func() error { return s.deps.DB.Ping(ctx) })
+
+		var pending int
+
+		migrations := probe(func() error {
+			var err error
+
+			pending, err = s.deps.DB.PendingMigrations(ctx)
+			if err == nil && pending > 0 {
+				err = fmt.Errorf("%w: %d", errPendingMigrations, pending)
+			}
+
+			return err
+		})
+		migrations.Pending = &pending
+		report.Checks["migrations"] = migrations
+	}
+
+	if s.deps.SongDetails != nil {
+		circuit := s.deps.SongDetails.CircuitState()
+
+		details := probe(func() error {
+			if circuit == circuitOpen {
+				return errCircuitOpen
+			}
+
+			return nil
+		})
+		details.Circuit = circuit
+		report.Checks["songDetails"] = details
+	}
+
+	if s.deps.Config != nil {
+		report.Config = &configReport{
+			Hash:     s.deps.Config.Hash(),
+			Reloads:  s.deps.Config.Reloads(),
+			Failures: s.deps.Config.Failures(),
+		}
+	}
+
+	if s.deps.Cache != nil {
+		stats := s.deps.Cache.Stats()
+		report.Cache = &stats
+	}
+
+	if s.deps.Replicas != nil {
+		report.Replicas = s.deps.Replicas.Replicas()
+	}
+
+	for _, check := range report.Checks {
+		if check.Status != statusUp {
+			report.Ready = false
+
+			if report.Status == statusOK {
+				report.Status = statusDegraded
+			}
+		}
+	}
+
+	return report
+}
+
+func probe(check func() error) dependencyHealth {
+	start := time.Now()
+	err := check()
+
+	result := dependencyHealth{
+		Status:  statusUp,
+		Latency: time.Since(start).String(),
+	}
+
+	if err != nil {
+		result.Status = statusDown
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+func writeHealth(w http.ResponseWriter, statusCode int, report healthReport) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Warnf("json.NewEncoder(w).Encode(report) err: %v", err)
+	}
+}
@@ -0,0 +1,207 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/logger"
+	"github.com/iurikman/songs/internal/models"
+)
+
+// getLinks godoc
+// @Summary Get the links of a song
+// @Description List the external links of a song, the primary one first
+// @Tags links
+// @Produce json
+// @Param id path string true "Song ID"
+// @Success 200 {array} models.SongLink
+// @Failure 400 {object} HTTPResponse
+// @Failure 404 {object} HTTPResponse
+// @Failure 500 {object} HTTPResponse
+// @Router /songs/{id}/links [get].
+func (s *Server) getLinks(w http.ResponseWriter, r *http.Request) {
+	logger.FromContext(r.Context()).Debug("getLinks: handler invoked")
+
+	songID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, errInvalidID)
+
+		return
+	}
+
+	links, err := s.svc.GetLinks(r.Context(), songID)
+	if err != nil {
+		writeError(w, r, err)
+
+		return
+	}
+
+	writeOKResponse(w, http.StatusOK, links)
+}
+
+// createLink godoc
+// @Summary Add a link to a song
+// @Description Add an external link to a song. The URL is normalized and its
+// @Description platform and the ID of the track on it are recognized. A
+// @Description primary link becomes the link of the song.
+// @Tags links
+// @Accept json
+// @Produce json
+// @Param id path string true "Song ID"
+// @Param link body models.SongLink true "Link Data"
+// @Success 201 {object} models.SongLink
+// @Failure 400 {object} HTTPResponse
+// @Failure 404 {object} HTTPResponse
+// @Failure 409 {object} HTTPResponse
+// @Failure 413 {object} HTTPResponse
+// @Failure 422 {object} HTTPResponse
+// @Failure 500 {object} HTTPResponse
+// @Router /songs/{id}/links [post].
+func (s *Server) createLink(w http.ResponseWriter, r *http.Request) {
+	logger.FromContext(r.Context()).Debug("createLink: handler invoked")
+
+	var link models.SongLink
+
+	songID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, errInvalidID)
+
+		return
+	}
+
+	if !decodeBody(w, r, &link) {
+		return
+	}
+
+	created, err := s.svc.CreateLink(r.Context(), songID, link)
+	if err != nil {
+		writeError(w, r, err)
+
+		return
+	}
+
+	writeOKResponse(w, http.StatusCreated, created)
+}
+
+// updateLink godoc
+// @Summary Update a link of a song
+// @Description Replace the URL, region and primary flag of a link of a song
+// @Tags links
+// @Accept json
+// @Produce json
+// @Param id path string true "Song ID"
+// @Param linkID path string true "Link ID"
+// @Param link body models.SongLink true "Link Data"
+// @Success 200 {object} models.SongLink
+// @Failure 400 {object} HTTPResponse
+// @Failure 404 {object} HTTPResponse
+// @Failure 409 {object} HTTPResponse
+// @Failure 413 {object} HTTPResponse
+// @Failure 422 {object} HTTPResponse
+// @Failure 500 {object} HTTPResponse
+// @Router /songs/{id}/links/{linkID} [patch].
+func (s *Server) updateLink(w http.ResponseWriter, r *http.Request) {
+	logger.FromContext(r.Context()).Debug("updateLink: handler invoked")
+
+	var link models.SongLink
+
+	songID, id, ok := linkIDs(w, r)
+	if !ok {
+		return
+	}
+
+	if !decodeBody(w, r, &link) {
+		return
+	}
+
+	updated, err := s.svc.UpdateLink(r.Context(), songID, id, link)
+	if err != nil {
+		writeError(w, r, err)
+
+		return
+	}
+
+	writeOKResponse(w, http.StatusOK, updated)
+}
+
+// deleteLink godoc
+// @Summary Delete a link of a song
+// @Description Delete a link of a song. Deleting the primary link leaves the
+// @Description song without a link.
+// @Tags links
+// @Param id path string true "Song ID"
+// @Param linkID path string true "Link ID"
+// @Success 204
+// @Failure 400 {object} HTTPResponse
+// @Failure 404 {object} HTTPResponse
+// @Failure 500 {object} HTTPResponse
+// @Router /songs/{id}/links/{linkID} [delete].
+func (s *Server) deleteLink(w http.ResponseWriter, r *http.Request) {
+	logger.FromContext(r.Context()).Debug("deleteLink: handler invoked")
+
+	songID, id, ok := linkIDs(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.svc.DeleteLink(r.Context(), songID, id); err != nil {
+		writeError(w, r, err)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getBrokenLinks godoc
+// @Summary Get broken links
+// @Description List the song links that failed their health checks too many
+// @Description times in a row, the most recently checked first
+// @Tags links
+// @Produce json
+// @Param offset query int false "Offset for pagination"
+// @Param limit query int false "Limit number of links"
+// @Success 200 {array} models.BrokenLink
+// @Failure 400 {object} HTTPResponse
+// @Failure 500 {object} HTTPResponse
+// @Router /links/broken [get].
+func (s *Server) getBrokenLinks(w http.ResponseWriter, r *http.Request) {
+	logger.FromContext(r.Context()).Debug("getBrokenLinks: handler invoked")
+
+	params, err := s.parseParams(r.URL.Query())
+	if err != nil {
+		writeError(w, r, err)
+
+		return
+	}
+
+	links, err := s.svc.GetBrokenLinks(r.Context(), *params)
+	if err != nil {
+		writeError(w, r, err)
+
+		return
+	}
+
+	writeOKResponse(w, http.StatusOK, links)
+}
+
+// linkIDs parses the song and the link IDs of the path, writing an error
+// when either is invalid.
+func linkIDs(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	songID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, errInvalidID)
+
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	id, err := uuid.Parse(chi.URLParam(r, "linkID"))
+	if err != nil {
+		writeError(w, r, errInvalidID)
+
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return songID, id, true
+}
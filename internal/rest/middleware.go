@@ -0,0 +1,144 @@
+package rest
+
+import (
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/consistency"
+	"github.com/iurikman/songs/internal/logger"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracing starts a server span for every request, continuing the trace from an
+// incoming traceparent header. The span is renamed after routing so that it
+// carries the matched route pattern instead of the raw path.
+func tracing(next http.Handler) http.Handler {
+	named := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			trace.SpanFromContext(r.Context()).SetName(r.Method + " " + rctx.RoutePattern())
+		}
+	})
+
+	return otelhttp.NewHandler(named, "http.server")
+}
+
+const requestIDHeader = "X-Request-ID"
+
+// validRequestID bounds what a client may send as its request ID so arbitrary
+// input does not end up in logs and response headers.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// requestID accepts a well-formed X-Request-ID from the client or generates a
+// new one, echoes it back and stores a logger tagged with it in the context.
+func requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = uuid.NewString()
+		}
+
+		w.Header().Set(requestIDHeader, id)
+
+		fields := log.Fields{
+			logger.FieldRequestID: id,
+			"method":              r.Method,
+			"path":                r.URL.Path,
+		}
+
+		if spanContext := trace.SpanContextFromContext(r.Context()); spanContext.HasTraceID() {
+			fields[logger.FieldTraceID] = spanContext.TraceID().String()
+		}
+
+		ctx := logger.WithRequestID(r.Context(), id)
+		ctx = logger.WithLogger(ctx, logger.FromContext(ctx).WithFields(fields))
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// rateLimit rejects requests above the configured rate with 429.
+func (s *Server) rateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.limiter.Allow() {
+			w.Header().Set("Retry-After", "1")
+			writeError(w, r, errRateLimited)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+const (
+	// consistencyHeader set to primary has every read of a request served by
+	// the primary database.
+	consistencyHeader = "X-Read-Consistency"
+	// lastWriteHeader holds the Unix time in milliseconds of the last write
+	// made by a client. Responses to writes carry it, and a client sending it
+	// back has its reads served by the primary within the sticky window.
+	lastWriteHeader = "X-Last-Write"
+	// lastWriteCookie is lastWriteHeader for browsers, which send it back on
+	// their own. The header wins when a request has both.
+	lastWriteCookie = "songs_last_write"
+)
+
+// readYourWrites routes the reads of a request to the primary when the client
+// asks for it in the X-Read-Consistency header, or when it wrote within the
+// sticky window, so that it sees its own writes even when replicas lag. The
+// time of the last write travels with the client, in the X-Last-Write header
+// or the cookie, since its requests may be served by different instances.
+func (s *Server) readYourWrites(next http.Handler) http.Handler {
+	maxAge := int(math.Ceil(s.config.StickyWindow.Seconds()))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		if strings.EqualFold(r.Header.Get(consistencyHeader), "primary") {
+			ctx = consistency.WithPrimary(ctx)
+		}
+
+		if s.config.StickyWindow <= 0 {
+			next.ServeHTTP(w, r.WithContext(ctx))
+
+			return
+		}
+
+		lastWrite := r.Header.Get(lastWriteHeader)
+		if cookie, err := r.Cookie(lastWriteCookie); err == nil && lastWrite == "" {
+			lastWrite = cookie.Value
+		}
+
+		var lastWriteAt time.Time
+
+		if millis, err := strconv.ParseInt(lastWrite, 10, 64); err == nil {
+			lastWriteAt = time.UnixMilli(millis)
+		}
+
+		session := consistency.NewSession(lastWriteAt, func(at time.Time) {
+			millis := strconv.FormatInt(at.UnixMilli(), 10)
+
+			w.Header().Set(lastWriteHeader, millis)
+			http.SetCookie(w, &http.Cookie{
+				Name:     lastWriteCookie,
+				Value:    millis,
+				Path:     "/",
+				MaxAge:   maxAge,
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+		})
+
+		next.ServeHTTP(w, r.WithContext(consistency.WithSession(ctx, session)))
+	})
+}
@@ -0,0 +1,218 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/iurikman/songs/internal/logger"
+	"github.com/iurikman/songs/internal/models"
+	"github.com/iurikman/songs/internal/songdetails"
+	"github.com/iurikman/songs/internal/validation"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	problemContentType = "application/problem+json"
+	// problemTypeBase is followed by the error code in the type of a problem.
+	problemTypeBase = "urn:songs:error:"
+)
+
+var (
+	errInvalidID          = errors.New("invalid id")
+	errInvalidQuery       = errors.New("invalid query parameters")
+	errInvalidVerse       = errors.New("invalid verse")
+	errMissingSearchQuery = errors.New("missing search query")
+	errInvalidLastEventID = errors.New("invalid last event id")
+	errBodyTooLarge       = errors.New("body is too large")
+	errRateLimited        = errors.New("rate limit exceeded")
+	errRouteNotFound      = errors.New("route not found")
+	errMethodNotAllowed   = errors.New("method not allowed")
+	errInternal           = errors.New("internal server error")
+)
+
+// Problem is an RFC 7807 problem detail. Code is the stable error code the
+// type is made of, meant for clients to match on.
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code"`
+	RequestID string `json:"requestId,omitempty"`
+	// Errors lists the violations of a request that failed validation.
+	Errors []validation.FieldError `json:"errors,omitempty"`
+	// Candidates lists the songs a rejected song probably duplicates.
+	Candidates []*models.SimilarSong `json:"candidates,omitempty"`
+}
+
+// errorCode is an entry of the error catalog.
+type errorCode struct {
+	code   string
+	status int
+	title  string
+}
+
+var (
+	codeSongNotFound        = errorCode{"SONG_NOT_FOUND", http.StatusNotFound, "Song not found"}
+	codeVerseOutOfRange     = errorCode{"VERSE_OUT_OF_RANGE", http.StatusBadRequest, "Verse out of range"}
+	codeDuplicateSong       = errorCode{"DUPLICATE_SONG", http.StatusConflict, "Duplicate song"}
+	codeProbableDuplicate   = errorCode{"PROBABLE_DUPLICATE", http.StatusConflict, "Probable duplicate song"}
+	codeLinkNotFound        = errorCode{"LINK_NOT_FOUND", http.StatusNotFound, "Link not found"}
+	codeDuplicateLink       = errorCode{"DUPLICATE_LINK", http.StatusConflict, "Duplicate link"}
+	codeTagNotFound         = errorCode{"TAG_NOT_FOUND", http.StatusNotFound, "Tag not found"}
+	codeDuplicateTag        = errorCode{"DUPLICATE_TAG", http.StatusConflict, "Duplicate tag"}
+	codeUnknownTag          = errorCode{"UNKNOWN_TAG", http.StatusUnprocessableEntity, "Unknown tag"}
+	codeTagCycle            = errorCode{"TAG_CYCLE", http.StatusUnprocessableEntity, "Tag cycle"}
+	codeInvalidSorting      = errorCode{"INVALID_SORTING", http.StatusBadRequest, "Invalid sorting"}
+	codeWebhookNotFound     = errorCode{"WEBHOOK_NOT_FOUND", http.StatusNotFound, "Webhook not found"}
+	codeDeliveryNotFound    = errorCode{"DELIVERY_NOT_FOUND", http.StatusNotFound, "Delivery not found"}
+	codeValidation          = errorCode{"VALIDATION_FAILED", http.StatusUnprocessableEntity, "Validation failed"}
+	codeMalformedBody       = errorCode{"MALFORMED_BODY", http.StatusBadRequest, "Malformed body"}
+	codeBodyTooLarge        = errorCode{"BODY_TOO_LARGE", http.StatusRequestEntityTooLarge, "Body too large"}
+	codeInvalidID           = errorCode{"INVALID_ID", http.StatusBadRequest, "Invalid id"}
+	codeInvalidParameter    = errorCode{"INVALID_PARAMETER", http.StatusBadRequest, "Invalid parameter"}
+	codeRateLimited         = errorCode{"RATE_LIMITED", http.StatusTooManyRequests, "Rate limit exceeded"}
+	codeRouteNotFound       = errorCode{"ROUTE_NOT_FOUND", http.StatusNotFound, "Route not found"}
+	codeMethodNotAllowed    = errorCode{"METHOD_NOT_ALLOWED", http.StatusMethodNotAllowed, "Method not allowed"}
+	codeUpstreamUnavailable = errorCode{"UPSTREAM_UNAVAILABLE", http.StatusServiceUnavailable, "Upstream unavailable"}
+	codeInternal            = errorCode{"INTERNAL", http.StatusInternalServerError, "Internal server error"}
+)
+
+// errorCatalog maps errors to their codes, matched with errors.Is in order.
+// The detail written to clients is the message of the matched error, not of
+// the error chain, unless public is set: then the chain was built for clients.
+var errorCatalog = []struct {
+	err    error
+	code   errorCode
+	public bool
+}{
+	{err: models.ErrSongNotFound, code: codeSongNotFound},
+	{err: models.ErrVerseIsNotValid, code: codeVerseOutOfRange},
+	{err: models.ErrDuplicateSong, code: codeDuplicateSong},
+	{err: models.ErrProbableDuplicate, code: codeProbableDuplicate},
+	{err: models.ErrLinkNotFound, code: codeLinkNotFound},
+	{err: models.ErrDuplicateLink, code: codeDuplicateLink},
+	{err: models.ErrTagNotFound, code: codeTagNotFound},
+	{err: models.ErrDuplicateTag, code: codeDuplicateTag},
+	{err: models.ErrUnknownTag, code: codeUnknownTag},
+	{err: models.ErrTagCycle, code: codeTagCycle},
+	{err: models.ErrInvalidSorting, code: codeInvalidSorting},
+	{err: models.ErrWebhookNotFound, code: codeWebhookNotFound},
+	{err: models.ErrDeliveryNotFound, code: codeDeliveryNotFound},
+	{err: validation.ErrInvalid, code: codeValidation},
+	{err: validation.ErrMalformed, code: codeMalformedBody, public: true},
+	{err: errBodyTooLarge, code: codeBodyTooLarge, public: true},
+	{err: errInvalidID, code: codeInvalidID},
+	{err: errInvalidQuery, code: codeInvalidParameter},
+	{err: errInvalidVerse, code: codeInvalidParameter},
+	{err: errMissingSearchQuery, code: codeInvalidParameter},
+	{err: errInvalidLastEventID, code: codeInvalidParameter},
+	{err: errRateLimited, code: codeRateLimited},
+	{err: errRouteNotFound, code: codeRouteNotFound},
+	{err: errMethodNotAllowed, code: codeMethodNotAllowed},
+	{err: songdetails.ErrUnavailable, code: codeUpstreamUnavailable},
+}
+
+// problemFor finds err in the catalog. Errors outside of it are internal and
+// their message is never written to clients.
+func problemFor(err error) (errorCode, string) {
+	for _, entry := range errorCatalog {
+		if !errors.Is(err, entry.err) {
+			continue
+		}
+
+		if entry.public {
+			return entry.code, err.Error()
+		}
+
+		return entry.code, entry.err.Error()
+	}
+
+	return codeInternal, errInternal.Error()
+}
+
+// writeError writes err as a problem when the client accepts
+// application/problem+json and in the v1 envelope otherwise, which carries
+// the same code. Internal errors are logged with their whole chain. Failures
+// of the details API tell when to retry if the circuit knows.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	code, detail := problemFor(err)
+
+	entry := logger.FromContext(r.Context()).WithError(err).WithField("code", code.code)
+	if code == codeInternal {
+		entry.Error("request failed")
+	} else {
+		entry.Debug("request rejected")
+	}
+
+	var (
+		violations  validation.Errors
+		duplicate   *models.ProbableDuplicateError
+		unavailable *songdetails.UnavailableError
+		candidates  []*models.SimilarSong
+	)
+
+	errors.As(err, &violations)
+
+	if errors.As(err, &duplicate) {
+		candidates = duplicate.Candidates
+	}
+
+	if errors.As(err, &unavailable) && unavailable.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(unavailable.RetryAfter.Seconds()))))
+	}
+
+	if !acceptsProblem(r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code.status)
+
+		// The v1 envelope has the candidates of a probable duplicate as data.
+		response := HTTPResponse{Error: detail, Code: code.code, Errors: violations}
+		if candidates != nil {
+			response.Data = candidates
+		}
+
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			log.Warnf("json.NewEncoder(w).Encode(response) err: %v", err)
+		}
+
+		return
+	}
+
+	problem := Problem{
+		Type:       problemTypeBase + code.code,
+		Title:      code.title,
+		Status:     code.status,
+		Detail:     detail,
+		Instance:   r.URL.Path,
+		Code:       code.code,
+		RequestID:  logger.RequestID(r.Context()),
+		Errors:     violations,
+		Candidates: candidates,
+	}
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(code.status)
+
+	if err := json.NewEncoder(w).Encode(problem); err != nil {
+		log.Warnf("json.NewEncoder(w).Encode(problem) err: %v", err)
+	}
+}
+
+// acceptsProblem tells whether the client asked for problem details.
+func acceptsProblem(r *http.Request) bool {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(accepted)
+		if err == nil && mediaType == problemContentType {
+			return true
+		}
+	}
+
+	return false
+}
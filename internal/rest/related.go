@@ -0,0 +1,63 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/logger"
+)
+
+var errBoostRange = errors.New("groupBoost and tagBoost must be between 0 and 1")
+
+// getRelated godoc
+// @Summary Get related songs
+// @Description Songs whose lyrics are like those of the song, by the cosine
+// @Description similarity of their TF-IDF vectors, the highest scores first.
+// @Description Songs by the same group and with a tag in common can be boosted.
+// @Tags songs
+// @Produce json
+// @Param id path string true "Song ID"
+// @Param groupBoost query number false "Added to the score of songs by the same group, 0 to 1"
+// @Param tagBoost query number false "Added to the score of songs with a tag in common, 0 to 1"
+// @Param offset query int false "Offset for pagination"
+// @Param limit query int false "Limit number of songs"
+// @Success 200 {array} models.RelatedSong
+// @Failure 400 {object} HTTPResponse
+// @Failure 404 {object} HTTPResponse
+// @Failure 500 {object} HTTPResponse
+// @Router /songs/{id}/related [get].
+func (s *Server) getRelated(w http.ResponseWriter, r *http.Request) {
+	logger.FromContext(r.Context()).Debug("getRelated: handler invoked")
+
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, errInvalidID)
+
+		return
+	}
+
+	params, err := s.parseParams(r.URL.Query())
+	if err != nil {
+		writeError(w, r, err)
+
+		return
+	}
+
+	if params.GroupBoost < 0 || params.GroupBoost > 1 || params.TagBoost < 0 || params.TagBoost > 1 {
+		writeError(w, r, fmt.Errorf("%w: %w", errInvalidQuery, errBoostRange))
+
+		return
+	}
+
+	related, err := s.svc.RelatedSongs(r.Context(), id, *params)
+	if err != nil {
+		writeError(w, r, err)
+
+		return
+	}
+
+	writeOKResponse(w, http.StatusOK, related)
+}
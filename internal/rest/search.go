@@ -0,0 +1,53 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/iurikman/songs/internal/models"
+)
+
+type searcher interface {
+	Search(ctx context.Context, query string, params models.Params) ([]*models.Song, error)
+}
+
+// searchSongs godoc
+// @Summary Search songs
+// @Description Full-text search over names, groups and lyrics. Only served by
+// @Description stores that support it.
+// @Tags songs
+// @Produce json
+// @Param q query string true "Words to search for"
+// @Param offset query int false "Offset for pagination"
+// @Param limit query int false "Limit number of songs"
+// @Success 200 {array} models.Song
+// @Failure 400 {object} HTTPResponse
+// @Failure 500 {object} HTTPResponse
+// @Router /songs/search [get].
+func (s *Server) searchSongs(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	query := values.Get("q")
+	values.Del("q")
+
+	if query == "" {
+		writeError(w, r, errMissingSearchQuery)
+
+		return
+	}
+
+	params, err := s.parseParams(values)
+	if err != nil {
+		writeError(w, r, err)
+
+		return
+	}
+
+	songs, err := s.deps.Search.Search(r.Context(), query, *params)
+	if err != nil {
+		writeError(w, r, err)
+
+		return
+	}
+
+	writeOKResponse(w, http.StatusOK, songs)
+}
@@ -5,20 +5,43 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
 )
 
 const (
 	gracefulShutdownTimeout = 5 * time.Second
 	readHeaderTimeout       = 5 * time.Second
 	maxHeaderBytes          = 1 << 20
+	maxPage                 = 100
 )
 
 type SrvConfig struct {
-	BindAddr string
+	BindAddr          string
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	ShutdownTimeout   time.Duration
+	// ReadinessDrainDelay is how long /readyz reports failure before the
+	// server stops accepting connections, giving load balancers time to react.
+	ReadinessDrainDelay time.Duration
+	DefaultPageSize     int
+	MaxPageSize         int
+	// RateLimit is the number of API requests per second the server accepts,
+	// with bursts of up to RateBurst. Zero disables limiting.
+	RateLimit float64
+	RateBurst int
+	// SSEPollInterval is how often an open event stream looks for new events.
+	SSEPollInterval time.Duration
+	// StickyWindow is how long after a write the reads of the same client go
+	// to the primary database. Zero disables tracking writes; clients can
+	// still ask for the primary with the X-Read-Consistency header.
+	StickyWindow time.Duration
 }
 
 type Server struct {
@@ -26,26 +49,77 @@ type Server struct {
 	router *chi.Mux
 	server *http.Server
 	svc    service
+	deps   Dependencies
+	ready  atomic.Bool
+
+	defaultPage atomic.Int64
+	maxPage     atomic.Int64
+	limiter     *rate.Limiter
 }
 
-func NewServer(cfg SrvConfig, svc service) (*Server, error) {
+func NewServer(cfg SrvConfig, svc service, deps Dependencies) (*Server, error) {
 	router := chi.NewRouter()
 
+	if cfg.ReadHeaderTimeout <= 0 {
+		cfg.ReadHeaderTimeout = readHeaderTimeout
+	}
+
+	if cfg.ShutdownTimeout <= 0 {
+		cfg.ShutdownTimeout = gracefulShutdownTimeout
+	}
+
 	srv := &http.Server{
 		Addr:              cfg.BindAddr,
 		Handler:           router,
-		ReadHeaderTimeout: readHeaderTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
 		MaxHeaderBytes:    maxHeaderBytes,
 	}
 
 	log.Debug("Initializing server")
 
-	return &Server{
-		config: cfg,
-		router: router,
-		server: srv,
-		svc:    svc,
-	}, nil
+	s := &Server{
+		config:  cfg,
+		router:  router,
+		server:  srv,
+		svc:     svc,
+		deps:    deps,
+		limiter: rate.NewLimiter(rate.Inf, cfg.RateBurst),
+	}
+
+	s.SetPaging(cfg.DefaultPageSize, cfg.MaxPageSize)
+	s.SetRateLimit(cfg.RateLimit, cfg.RateBurst)
+
+	return s, nil
+}
+
+// SetPaging changes the page size used when a request has no limit and the
+// largest page a client may ask for.
+func (s *Server) SetPaging(defaultSize, maxSize int) {
+	if defaultSize <= 0 {
+		defaultSize = standardPage
+	}
+
+	if maxSize <= 0 {
+		maxSize = maxPage
+	}
+
+	s.defaultPage.Store(int64(defaultSize))
+	s.maxPage.Store(int64(maxSize))
+}
+
+// SetRateLimit changes the API rate limit; a zero limit disables it.
+func (s *Server) SetRateLimit(limit float64, burst int) {
+	if limit <= 0 {
+		s.limiter.SetLimit(rate.Inf)
+
+		return
+	}
+
+	s.limiter.SetLimit(rate.Limit(limit))
+	s.limiter.SetBurst(max(burst, 1))
 }
 
 //nolint:contextcheck
@@ -58,7 +132,11 @@ func (s *Server) Start(ctx context.Context) error {
 	go func() {
 		<-ctx.Done()
 
-		ctxWithTimeout, cancel := context.WithTimeout(context.Background(), gracefulShutdownTimeout)
+		s.ready.Store(false)
+		log.Debug("readiness set to failing, draining")
+		time.Sleep(s.config.ReadinessDrainDelay)
+
+		ctxWithTimeout, cancel := context.WithTimeout(context.Background(), s.config.ShutdownTimeout)
 		defer cancel()
 
 		if err := s.server.Shutdown(ctxWithTimeout); err != nil {
@@ -66,6 +144,8 @@ func (s *Server) Start(ctx context.Context) error {
 		}
 	}()
 
+	s.ready.Store(true)
+
 	err := s.server.ListenAndServe()
 	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		return fmt.Errorf("s.server.ListenAndServe() err: %w", err)
@@ -75,15 +155,77 @@ func (s *Server) Start(ctx context.Context) error {
 }
 
 func (s *Server) configRouter() {
+	s.router.Use(tracing)
+	s.router.Use(requestID)
+
+	s.router.Use(s.readYourWrites)
+
+	s.router.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, r, errRouteNotFound)
+	})
+	s.router.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, r, errMethodNotAllowed)
+	})
+
+	s.router.Get("/healthz", s.healthz)
+	s.router.Get("/readyz", s.readyz)
+	s.router.Get("/health", s.health)
+
+	if s.deps.GraphQL != nil {
+		s.router.With(s.rateLimit).Handle("/graphql", s.deps.GraphQL)
+	}
+
 	s.router.Route("/api", func(r chi.Router) {
+		r.Use(s.rateLimit)
+
 		r.Route("/v1", func(r chi.Router) {
 			r.Route("/songs", func(r chi.Router) {
 				r.Post("/", s.createSong)
 				r.Get("/", s.getSongs)
+
+				if s.deps.Events != nil {
+					r.Get("/events", s.songEvents)
+				}
+
+				if s.deps.Search != nil {
+					r.Get("/search", s.searchSongs)
+				}
+
+				r.Get("/duplicates", s.getDuplicates)
+
 				r.Get("/{id}", s.getText)
 				r.Patch("/{id}", s.updateSong)
 				r.Delete("/{id}", s.deleteSong)
+				r.Post("/{id}/merge", s.mergeSongs)
+				r.Get("/{id}/links", s.getLinks)
+				r.Post("/{id}/links", s.createLink)
+				r.Patch("/{id}/links/{linkID}", s.updateLink)
+				r.Delete("/{id}/links/{linkID}", s.deleteLink)
+				r.Get("/{id}/tags", s.getSongTags)
+				r.Put("/{id}/tags", s.setSongTags)
+				r.Get("/{id}/related", s.getRelated)
 			})
+
+			r.Get("/links/broken", s.getBrokenLinks)
+
+			r.Route("/tags", func(r chi.Router) {
+				r.Post("/", s.createTag)
+				r.Get("/", s.getTags)
+				r.Patch("/{id}", s.updateTag)
+				r.Delete("/{id}", s.deleteTag)
+			})
+
+			r.Get("/stats", s.getStats)
+
+			if s.deps.Events != nil {
+				r.Route("/webhooks", func(r chi.Router) {
+					r.Post("/", s.createWebhook)
+					r.Get("/", s.getWebhooks)
+					r.Delete("/{id}", s.deleteWebhook)
+					r.Get("/deliveries/dead", s.getDeadDeliveries)
+					r.Post("/deliveries/{id}/retry", s.retryDelivery)
+				})
+			}
 		})
 	})
 
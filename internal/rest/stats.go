@@ -0,0 +1,41 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/iurikman/songs/internal/logger"
+)
+
+// getStats godoc
+// @Summary Get catalog statistics
+// @Description Songs per group, release year and creation month, lyric
+// @Description metrics and the shares of songs with a release date, lyrics,
+// @Description a link and tags. With Postgres they are refreshed on a
+// @Description schedule, as of refreshedAt.
+// @Tags stats
+// @Produce json
+// @Param offset query int false "Offset for pagination of the groups"
+// @Param limit query int false "Limit number of groups"
+// @Success 200 {object} models.Stats
+// @Failure 400 {object} HTTPResponse
+// @Failure 500 {object} HTTPResponse
+// @Router /stats [get].
+func (s *Server) getStats(w http.ResponseWriter, r *http.Request) {
+	logger.FromContext(r.Context()).Debug("getStats: handler invoked")
+
+	params, err := s.parseParams(r.URL.Query())
+	if err != nil {
+		writeError(w, r, err)
+
+		return
+	}
+
+	stats, err := s.svc.GetStats(r.Context(), *params)
+	if err != nil {
+		writeError(w, r, err)
+
+		return
+	}
+
+	writeOKResponse(w, http.StatusOK, stats)
+}
@@ -0,0 +1,210 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/logger"
+	"github.com/iurikman/songs/internal/models"
+)
+
+// getTags godoc
+// @Summary Get tags
+// @Description List every tag, ordered by slug. The parent of a tag is given
+// @Description by its ID.
+// @Tags tags
+// @Produce json
+// @Success 200 {array} models.Tag
+// @Failure 500 {object} HTTPResponse
+// @Router /tags [get].
+func (s *Server) getTags(w http.ResponseWriter, r *http.Request) {
+	logger.FromContext(r.Context()).Debug("getTags: handler invoked")
+
+	tags, err := s.svc.GetTags(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+
+		return
+	}
+
+	writeOKResponse(w, http.StatusOK, tags)
+}
+
+// createTag godoc
+// @Summary Create a tag
+// @Description Create a tag, under a parent tag or as the root of a tree
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Param tag body models.Tag true "Tag Data"
+// @Success 201 {object} models.Tag
+// @Failure 400 {object} HTTPResponse
+// @Failure 409 {object} HTTPResponse
+// @Failure 413 {object} HTTPResponse
+// @Failure 422 {object} HTTPResponse
+// @Failure 500 {object} HTTPResponse
+// @Router /tags [post].
+func (s *Server) createTag(w http.ResponseWriter, r *http.Request) {
+	logger.FromContext(r.Context()).Debug("createTag: handler invoked")
+
+	var tag models.Tag
+
+	if !decodeBody(w, r, &tag) {
+		return
+	}
+
+	created, err := s.svc.CreateTag(r.Context(), tag)
+	if err != nil {
+		writeError(w, r, err)
+
+		return
+	}
+
+	writeOKResponse(w, http.StatusCreated, created)
+}
+
+// updateTag godoc
+// @Summary Update a tag
+// @Description Replace the slug, name and parent of a tag. Its descendants
+// @Description move along with it.
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Param id path string true "Tag ID"
+// @Param tag body models.Tag true "Tag Data"
+// @Success 200 {object} models.Tag
+// @Failure 400 {object} HTTPResponse
+// @Failure 404 {object} HTTPResponse
+// @Failure 409 {object} HTTPResponse
+// @Failure 413 {object} HTTPResponse
+// @Failure 422 {object} HTTPResponse
+// @Failure 500 {object} HTTPResponse
+// @Router /tags/{id} [patch].
+func (s *Server) updateTag(w http.ResponseWriter, r *http.Request) {
+	logger.FromContext(r.Context()).Debug("updateTag: handler invoked")
+
+	var tag models.Tag
+
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, errInvalidID)
+
+		return
+	}
+
+	if !decodeBody(w, r, &tag) {
+		return
+	}
+
+	updated, err := s.svc.UpdateTag(r.Context(), id, tag)
+	if err != nil {
+		writeError(w, r, err)
+
+		return
+	}
+
+	writeOKResponse(w, http.StatusOK, updated)
+}
+
+// deleteTag godoc
+// @Summary Delete a tag
+// @Description Delete a tag and remove it from the songs that have it. Its
+// @Description children move up to its parent.
+// @Tags tags
+// @Param id path string true "Tag ID"
+// @Success 204
+// @Failure 400 {object} HTTPResponse
+// @Failure 404 {object} HTTPResponse
+// @Failure 500 {object} HTTPResponse
+// @Router /tags/{id} [delete].
+func (s *Server) deleteTag(w http.ResponseWriter, r *http.Request) {
+	logger.FromContext(r.Context()).Debug("deleteTag: handler invoked")
+
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, errInvalidID)
+
+		return
+	}
+
+	if err := s.svc.DeleteTag(r.Context(), id); err != nil {
+		writeError(w, r, err)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getSongTags godoc
+// @Summary Get the tags of a song
+// @Description List the tags of a song, ordered by slug
+// @Tags tags
+// @Produce json
+// @Param id path string true "Song ID"
+// @Success 200 {array} models.Tag
+// @Failure 400 {object} HTTPResponse
+// @Failure 404 {object} HTTPResponse
+// @Failure 500 {object} HTTPResponse
+// @Router /songs/{id}/tags [get].
+func (s *Server) getSongTags(w http.ResponseWriter, r *http.Request) {
+	logger.FromContext(r.Context()).Debug("getSongTags: handler invoked")
+
+	songID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, errInvalidID)
+
+		return
+	}
+
+	tags, err := s.svc.GetSongTags(r.Context(), songID)
+	if err != nil {
+		writeError(w, r, err)
+
+		return
+	}
+
+	writeOKResponse(w, http.StatusOK, tags)
+}
+
+// setSongTags godoc
+// @Summary Set the tags of a song
+// @Description Replace the tags of a song with the tags of the slugs given
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Param id path string true "Song ID"
+// @Param tags body models.SongTags true "Tag slugs"
+// @Success 200 {array} models.Tag
+// @Failure 400 {object} HTTPResponse
+// @Failure 404 {object} HTTPResponse
+// @Failure 413 {object} HTTPResponse
+// @Failure 422 {object} HTTPResponse
+// @Failure 500 {object} HTTPResponse
+// @Router /songs/{id}/tags [put].
+func (s *Server) setSongTags(w http.ResponseWriter, r *http.Request) {
+	logger.FromContext(r.Context()).Debug("setSongTags: handler invoked")
+
+	var tags models.SongTags
+
+	songID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, errInvalidID)
+
+		return
+	}
+
+	if !decodeBody(w, r, &tags) {
+		return
+	}
+
+	set, err := s.svc.SetSongTags(r.Context(), songID, tags)
+	if err != nil {
+		writeError(w, r, err)
+
+		return
+	}
+
+	writeOKResponse(w, http.StatusOK, set)
+}
@@ -0,0 +1,112 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/iurikman/songs/internal/logger"
+	"github.com/iurikman/songs/internal/models"
+	"github.com/iurikman/songs/internal/songdetails"
+	"github.com/iurikman/songs/internal/validation"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// toStatus maps domain errors to gRPC status codes. Anything unknown is
+// logged and reported as Internal without leaking the error chain.
+func toStatus(ctx context.Context, err error) error {
+	var (
+		violations validation.Errors
+		duplicate  *models.ProbableDuplicateError
+		merged     *models.MergedSongError
+	)
+
+	switch {
+	case errors.As(err, &violations):
+		return invalidArgument(violations)
+	case errors.As(err, &duplicate):
+		return probableDuplicate(duplicate)
+	case errors.As(err, &merged):
+		return mergedSong(merged)
+	case errors.Is(err, models.ErrSongNotFound):
+		return status.Error(codes.NotFound, models.ErrSongNotFound.Error())
+	case errors.Is(err, models.ErrVerseIsNotValid):
+		return status.Error(codes.OutOfRange, models.ErrVerseIsNotValid.Error())
+	case errors.Is(err, models.ErrInvalidSorting):
+		return status.Error(codes.InvalidArgument, models.ErrInvalidSorting.Error())
+	case errors.Is(err, models.ErrDuplicateSong):
+		return status.Error(codes.AlreadyExists, models.ErrDuplicateSong.Error())
+	case errors.Is(err, songdetails.ErrUnavailable):
+		return status.Error(codes.Unavailable, "song details are temporarily unavailable")
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		logger.FromContext(ctx).WithError(err).Error("request failed")
+
+		return status.Error(codes.Internal, "internal server error")
+	}
+}
+
+// invalidArgument reports violations as field violations of a BadRequest.
+func invalidArgument(violations validation.Errors) error {
+	st := status.New(codes.InvalidArgument, violations.Error())
+
+	details := &errdetails.BadRequest{}
+	for _, violation := range violations {
+		details.FieldViolations = append(details.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       violation.Field,
+			Description: violation.Message,
+		})
+	}
+
+	withDetails, err := st.WithDetails(details)
+	if err != nil {
+		return st.Err()
+	}
+
+	return withDetails.Err()
+}
+
+// probableDuplicate reports the songs a new song probably duplicates as
+// resource infos of an AlreadyExists status.
+func probableDuplicate(duplicate *models.ProbableDuplicateError) error {
+	st := status.New(codes.AlreadyExists, models.ErrProbableDuplicate.Error())
+
+	details := make([]protoadapt.MessageV1, 0, len(duplicate.Candidates))
+	for _, candidate := range duplicate.Candidates {
+		details = append(details, &errdetails.ResourceInfo{
+			ResourceType: "song",
+			ResourceName: candidate.ID.String(),
+			Description:  fmt.Sprintf("%s - %s, similarity %.2f", candidate.Group, candidate.Name, candidate.Similarity),
+		})
+	}
+
+	withDetails, err := st.WithDetails(details...)
+	if err != nil {
+		return st.Err()
+	}
+
+	return withDetails.Err()
+}
+
+// mergedSong reports a song merged into another one as NotFound, with the
+// song it was merged into as resource info.
+func mergedSong(merged *models.MergedSongError) error {
+	st := status.New(codes.NotFound, models.ErrSongMerged.Error())
+
+	withDetails, err := st.WithDetails(&errdetails.ResourceInfo{
+		ResourceType: "song",
+		ResourceName: merged.Into.String(),
+		Description:  "the song was merged into this one",
+	})
+	if err != nil {
+		return st.Err()
+	}
+
+	return withDetails.Err()
+}
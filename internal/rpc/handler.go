@@ -0,0 +1,192 @@
+package rpc
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+	songsv1 "github.com/iurikman/songs/internal/gen/songs/v1"
+	"github.com/iurikman/songs/internal/logger"
+	"github.com/iurikman/songs/internal/models"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+const (
+	standardPage = 10
+	maxPage      = 100
+)
+
+type service interface {
+	CreateSong(ctx context.Context, song models.Song, force bool) (*models.Song, error)
+	GetSongs(ctx context.Context, params models.Params) ([]*models.Song, error)
+	GetText(ctx context.Context, id uuid.UUID, verse int) (*string, error)
+	DeleteSong(ctx context.Context, id uuid.UUID) error
+	UpdateSong(ctx context.Context, id uuid.UUID, song models.Song) (*models.Song, error)
+}
+
+type songService struct {
+	songsv1.UnimplementedSongServiceServer
+
+	svc service
+
+	defaultPage atomic.Int64
+	maxPage     atomic.Int64
+}
+
+func (s *songService) CreateSong(ctx context.Context, req *songsv1.CreateSongRequest) (*songsv1.Song, error) {
+	song, err := fromProto(req.GetSong())
+	if err != nil {
+		return nil, err
+	}
+
+	logger.FromContext(ctx).WithFields(logger.SongFields(&song)).Debug("Attempting to create song")
+
+	createdSong, err := s.svc.CreateSong(ctx, song, req.GetForce())
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	return toProto(createdSong), nil
+}
+
+func (s *songService) ListSongs(req *songsv1.ListSongsRequest, stream grpc.ServerStreamingServer[songsv1.Song]) error {
+	ctx := stream.Context()
+
+	if req.GetOffset() < 0 || req.GetLimit() < 0 {
+		return status.Error(codes.InvalidArgument, "offset and limit must not be negative")
+	}
+
+	params := models.Params{
+		Offset:     int(req.GetOffset()),
+		Limit:      int(req.GetLimit()),
+		Sorting:    req.GetSorting(),
+		Descending: req.GetDescending(),
+		Filter:     req.GetFilter(),
+	}
+
+	if params.Limit == 0 {
+		params.Limit = s.pageSize()
+	}
+
+	params.Limit = min(params.Limit, s.maxPageSize())
+
+	songs, err := s.svc.GetSongs(ctx, params)
+	if err != nil {
+		return toStatus(ctx, err)
+	}
+
+	for _, song := range songs {
+		if err := stream.Send(toProto(song)); err != nil {
+			return err //nolint:wrapcheck
+		}
+	}
+
+	return nil
+}
+
+func (s *songService) GetText(ctx context.Context, req *songsv1.GetTextRequest) (*songsv1.GetTextResponse, error) {
+	id, err := parseID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := s.svc.GetText(ctx, id, int(req.GetVerse()))
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	return &songsv1.GetTextResponse{Text: *text}, nil
+}
+
+func (s *songService) UpdateSong(ctx context.Context, req *songsv1.UpdateSongRequest) (*songsv1.Song, error) {
+	id, err := parseID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	song, err := fromProto(req.GetSong())
+	if err != nil {
+		return nil, err
+	}
+
+	updatedSong, err := s.svc.UpdateSong(ctx, id, song)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	return toProto(updatedSong), nil
+}
+
+func (s *songService) DeleteSong(ctx context.Context, req *songsv1.DeleteSongRequest) (*emptypb.Empty, error) {
+	id, err := parseID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.svc.DeleteSong(ctx, id); err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *songService) pageSize() int {
+	return int(s.defaultPage.Load())
+}
+
+func (s *songService) maxPageSize() int {
+	return int(s.maxPage.Load())
+}
+
+func parseID(raw string) (uuid.UUID, error) {
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		return uuid.Nil, status.Error(codes.InvalidArgument, "invalid id")
+	}
+
+	return id, nil
+}
+
+func fromProto(song *songsv1.Song) (models.Song, error) {
+	if song == nil {
+		return models.Song{}, status.Error(codes.InvalidArgument, "song is required")
+	}
+
+	releaseDate, err := models.ParseReleaseDate(song.GetReleaseDate())
+	if err != nil {
+		return models.Song{}, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	result := models.Song{
+		ReleaseDate: releaseDate,
+		Name:        song.GetName(),
+		Group:       song.GetMusicGroup(),
+		Text:        song.GetText(),
+		Link:        song.GetLink(),
+	}
+
+	if song.GetId() != "" {
+		id, err := parseID(song.GetId())
+		if err != nil {
+			return models.Song{}, err
+		}
+
+		result.ID = id
+	}
+
+	return result, nil
+}
+
+func toProto(song *models.Song) *songsv1.Song {
+	return &songsv1.Song{
+		Id:          song.ID.String(),
+		ReleaseDate: song.ReleaseDate.String(),
+		Name:        song.Name,
+		MusicGroup:  song.Group,
+		Text:        song.Text,
+		Link:        song.Link,
+	}
+}
@@ -0,0 +1,66 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/logger"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const requestIDKey = "x-request-id"
+
+// withRequestID mirrors the REST request ID middleware: it reuses the
+// x-request-id metadata sent by the client or generates one, returns it in
+// the response header and tags the context logger with it.
+func withRequestID(ctx context.Context, method string) context.Context {
+	id := ""
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(requestIDKey); len(values) > 0 && len(values[0]) <= 128 {
+			id = values[0]
+		}
+	}
+
+	if id == "" {
+		id = uuid.NewString()
+	}
+
+	if err := grpc.SetHeader(ctx, metadata.Pairs(requestIDKey, id)); err != nil {
+		log.Debugf("grpc.SetHeader err: %v", err)
+	}
+
+	ctx = logger.WithRequestID(ctx, id)
+
+	return logger.WithLogger(ctx, logger.FromContext(ctx).WithFields(log.Fields{
+		logger.FieldRequestID: id,
+		"grpc_method":         method,
+	}))
+}
+
+func unaryRequestID(
+	ctx context.Context,
+	req any,
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	return handler(withRequestID(ctx, info.FullMethod), req)
+}
+
+type requestIDStream struct {
+	grpc.ServerStream
+	ctx context.Context //nolint:containedctx
+}
+
+func (s *requestIDStream) Context() context.Context {
+	return s.ctx
+}
+
+func streamRequestID(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &requestIDStream{
+		ServerStream: stream,
+		ctx:          withRequestID(stream.Context(), info.FullMethod),
+	})
+}
@@ -0,0 +1,123 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	songsv1 "github.com/iurikman/songs/internal/gen/songs/v1"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+const gracefulShutdownTimeout = 5 * time.Second
+
+type SrvConfig struct {
+	BindAddr        string
+	ShutdownTimeout time.Duration
+	DefaultPageSize int
+	MaxPageSize     int
+}
+
+type Server struct {
+	config SrvConfig
+	server *grpc.Server
+	health *health.Server
+	songs  *songService
+}
+
+func NewServer(cfg SrvConfig, svc service) (*Server, error) {
+	if cfg.ShutdownTimeout <= 0 {
+		cfg.ShutdownTimeout = gracefulShutdownTimeout
+	}
+
+	srv := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(unaryRequestID),
+		grpc.ChainStreamInterceptor(streamRequestID),
+	)
+
+	healthServer := health.NewServer()
+
+	songs := &songService{svc: svc}
+
+	songsv1.RegisterSongServiceServer(srv, songs)
+	healthpb.RegisterHealthServer(srv, healthServer)
+	reflection.Register(srv)
+
+	log.Debug("Initializing grpc server")
+
+	s := &Server{
+		config: cfg,
+		server: srv,
+		health: healthServer,
+		songs:  songs,
+	}
+
+	s.SetPaging(cfg.DefaultPageSize, cfg.MaxPageSize)
+
+	return s, nil
+}
+
+// SetPaging changes the page size used when a request has no limit and the
+// largest page a client may ask for.
+func (s *Server) SetPaging(defaultSize, maxSize int) {
+	if defaultSize <= 0 {
+		defaultSize = standardPage
+	}
+
+	if maxSize <= 0 {
+		maxSize = maxPage
+	}
+
+	s.songs.defaultPage.Store(int64(defaultSize))
+	s.songs.maxPage.Store(int64(maxSize))
+}
+
+// Start serves gRPC until ctx is done, then reports NOT_SERVING through the
+// health service and stops gracefully, forcing the stop after the timeout.
+//
+//nolint:contextcheck
+func (s *Server) Start(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.config.BindAddr)
+	if err != nil {
+		return fmt.Errorf("net.Listen(tcp, %s) err: %w", s.config.BindAddr, err)
+	}
+
+	log.Debug("Starting grpc server at ", s.config.BindAddr)
+
+	go func() {
+		<-ctx.Done()
+
+		s.health.Shutdown()
+
+		stopped := make(chan struct{})
+
+		go func() {
+			s.server.GracefulStop()
+			close(stopped)
+		}()
+
+		select {
+		case <-stopped:
+		case <-time.After(s.config.ShutdownTimeout):
+			log.Warn("grpc server did not stop gracefully in time, forcing")
+			s.server.Stop()
+		}
+	}()
+
+	s.health.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	s.health.SetServingStatus(songsv1.SongService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+
+	if err := s.server.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return fmt.Errorf("s.server.Serve(listener) err: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package seed
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/iurikman/songs/internal/models"
+)
+
+var (
+	adjectives = []string{
+		"electric", "silent", "golden", "broken", "endless", "midnight", "wild", "restless", "neon", "faded",
+		"burning", "hollow", "velvet", "crimson", "distant", "lonely", "shining", "frozen", "secret", "wandering",
+	}
+	nouns = []string{
+		"heart", "river", "city", "highway", "summer", "shadow", "ocean", "fire", "dream", "garden",
+		"window", "thunder", "mirror", "season", "signal", "harbor", "skyline", "echo", "horizon", "letter",
+	}
+	verbs = []string{
+		"chase", "follow", "remember", "carry", "hold", "light", "burn", "find", "lose", "call",
+		"break", "keep", "leave", "cross", "dance with", "sing to", "wait for", "run from", "believe in", "dream of",
+	}
+	places = []string{
+		"tonight", "in the rain", "after dark", "till the morning", "on the radio", "across the water",
+		"down the line", "under the stars", "all the way home", "one more time",
+	}
+	lineTemplates = []func(r *rand.Rand) string{
+		func(r *rand.Rand) string {
+			return fmt.Sprintf("I %s the %s %s", pick(r, verbs), pick(r, adjectives), pick(r, nouns))
+		},
+		func(r *rand.Rand) string {
+			return fmt.Sprintf("we %s your %s %s", pick(r, verbs), pick(r, nouns), pick(r, places))
+		},
+		func(r *rand.Rand) string {
+			return fmt.Sprintf("%s %s, %s %s", pick(r, adjectives), pick(r, nouns), pick(r, adjectives), pick(r, nouns))
+		},
+		func(r *rand.Rand) string {
+			return fmt.Sprintf("you %s me %s", pick(r, verbs), pick(r, places))
+		},
+		func(r *rand.Rand) string {
+			return fmt.Sprintf("there's a %s in the %s %s", pick(r, nouns), pick(r, adjectives), pick(r, nouns))
+		},
+	}
+)
+
+const (
+	linesPerVerse = 4
+	songsPerGroup = 10
+	firstYear     = 1960
+	lastYear      = 2024
+)
+
+// Generate makes n synthetic songs with lyrics of two to four verses and a
+// repeated chorus. The same seed always yields the same songs, ids included,
+// so generated songs can be loaded again without duplicates.
+func Generate(n int, seed uint64) []models.Song {
+	r := rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15)) //nolint:gosec
+
+	groups := make([]string, max(n/songsPerGroup, 1))
+	for i := range groups {
+		groups[i] = title("the " + pick(r, adjectives) + " " + pick(r, nouns) + "s")
+	}
+
+	songs := make([]models.Song, 0, n)
+	seen := make(map[string]bool, n)
+
+	for len(songs) < n {
+		song := models.Song{
+			Name:        title(pick(r, adjectives) + " " + pick(r, nouns)),
+			Group:       pick(r, groups),
+			ReleaseDate: randomDate(r),
+			Text:        lyrics(r),
+		}
+
+		// Stores compare release dates by their first day.
+		key := song.ReleaseDate.Date.String() + song.Name + song.Group
+		if seen[key] {
+			continue
+		}
+
+		seen[key] = true
+
+		song.ID = KeyID(song.ReleaseDate, song.Name, song.Group)
+		song.Link = "https://example.com/songs/" + song.ID.String()
+
+		songs = append(songs, song)
+	}
+
+	return songs
+}
+
+// lyrics alternates verses with the same chorus, separated like verses are
+// everywhere in the API.
+func lyrics(r *rand.Rand) string {
+	chorus := verse(r)
+	verses := 2 + r.IntN(3)
+
+	parts := make([]string, 0, 2*verses)
+	for range verses {
+		parts = append(parts, verse(r), chorus)
+	}
+
+	return strings.Join(parts, "\n\n")
+}
+
+func verse(r *rand.Rand) string {
+	lines := make([]string, linesPerVerse)
+	for i := range lines {
+		lines[i] = capitalize(pick(r, lineTemplates)(r))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// randomDate is mostly a day, sometimes only a month or a year, like dates
+// coming from the details API.
+func randomDate(r *rand.Rand) models.ReleaseDate {
+	start := time.Date(firstYear, time.January, 1, 0, 0, 0, 0, time.UTC)
+	days := int(time.Date(lastYear, time.December, 31, 0, 0, 0, 0, time.UTC).Sub(start).Hours() / 24)
+	date := start.AddDate(0, 0, r.IntN(days+1))
+
+	switch n := r.IntN(10); {
+	case n == 0:
+		return models.NewReleaseDate(date, models.PrecisionYear)
+	case n == 1:
+		return models.NewReleaseDate(date, models.PrecisionMonth)
+	default:
+		return models.NewReleaseDate(date, models.PrecisionDay)
+	}
+}
+
+func pick[T any](r *rand.Rand, items []T) T {
+	return items[r.IntN(len(items))]
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+func title(s string) string {
+	words := strings.Fields(s)
+	for i, word := range words {
+		words[i] = capitalize(word)
+	}
+
+	return strings.Join(words, " ")
+}
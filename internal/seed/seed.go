@@ -0,0 +1,221 @@
+// Package seed loads songs from fixture files, or generates synthetic ones,
+// into a store.
+package seed
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/logger"
+	"github.com/iurikman/songs/internal/models"
+	"github.com/iurikman/songs/internal/validation"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrUnsupportedFile = errors.New("unsupported fixture file extension")
+	ErrIncompleteSong  = errors.New("song needs a name and a music group")
+)
+
+// namespace derives the ids of fixture songs without one from their unique
+// key, so that loading a fixture twice yields the same songs.
+var namespace = uuid.MustParse("5d0b3f4e-8a55-4c1f-9b5e-4d1f3c0a9e21")
+
+// Store is what seeding needs from a store; every store backend and
+// service.CachedDB implement it.
+type Store interface {
+	CreateSong(ctx context.Context, song models.Song) (*models.Song, error)
+}
+
+// Song is a song in a fixture file. Group may be left out for songs listed
+// under a group.
+type Song struct {
+	ID          uuid.UUID `json:"id" yaml:"id"`
+	Group       string    `json:"musicGroup" yaml:"musicGroup"`
+	Name        string    `json:"name" yaml:"name"`
+	ReleaseDate string    `json:"releaseDate" yaml:"releaseDate"`
+	Text        string    `json:"text" yaml:"text"`
+	Link        string    `json:"link" yaml:"link"`
+	// Translations of the lyrics by language. No store keeps them yet, they
+	// are counted as skipped.
+	Translations map[string]string `json:"translations" yaml:"translations"`
+}
+
+// Group lists songs of one music group.
+type Group struct {
+	Name  string `json:"name" yaml:"name"`
+	Songs []Song `json:"songs" yaml:"songs"`
+}
+
+// Fixture is the content of a fixture file: songs that name their group,
+// songs listed by group, or both.
+type Fixture struct {
+	Songs  []Song  `json:"songs" yaml:"songs"`
+	Groups []Group `json:"groups" yaml:"groups"`
+}
+
+// Report counts what a load did.
+type Report struct {
+	Created int
+	// Existing songs already had the unique key or id of a loaded song.
+	Existing int
+	// SkippedTranslations are the translations that were not loaded.
+	SkippedTranslations int
+}
+
+func (r *Report) add(other Report) {
+	r.Created += other.Created
+	r.Existing += other.Existing
+	r.SkippedTranslations += other.SkippedTranslations
+}
+
+// ReadFixture reads a YAML (.yaml, .yml) or JSON (.json) fixture file.
+func ReadFixture(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile(%s) err: %w", path, err)
+	}
+
+	fixture := new(Fixture)
+
+	// Fields are decoded strictly, so that a misspelt one is not silently
+	// left empty.
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(fixture)
+	case ".json":
+		err = validation.DecodeJSON(bytes.NewReader(data), fixture)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFile, path)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s err: %w", path, err)
+	}
+
+	return fixture, nil
+}
+
+// Flatten turns the fixture into songs ready to be created and counts their
+// translations. Songs without an id get one derived from their unique key.
+func (f *Fixture) Flatten() ([]models.Song, int, error) {
+	fixtureSongs := append([]Song(nil), f.Songs...)
+
+	for _, group := range f.Groups {
+		for _, song := range group.Songs {
+			if song.Group == "" {
+				song.Group = group.Name
+			}
+
+			fixtureSongs = append(fixtureSongs, song)
+		}
+	}
+
+	songs := make([]models.Song, 0, len(fixtureSongs))
+	translations := 0
+
+	for i, song := range fixtureSongs {
+		if song.Name == "" || song.Group == "" {
+			return nil, 0, fmt.Errorf("song %d: %w", i+1, ErrIncompleteSong)
+		}
+
+		releaseDate, err := models.ParseReleaseDate(song.ReleaseDate)
+		if err != nil {
+			return nil, 0, fmt.Errorf("song %d: %w", i+1, err)
+		}
+
+		if song.ID == uuid.Nil {
+			song.ID = KeyID(releaseDate, song.Name, song.Group)
+		}
+
+		converted := models.Song{
+			ID:          song.ID,
+			ReleaseDate: releaseDate,
+			Name:        song.Name,
+			Group:       song.Group,
+			Text:        strings.TrimSpace(song.Text),
+			Link:        song.Link,
+		}
+
+		// Seeding writes to the store directly, it is held to what the API
+		// accepts all the same.
+		if err := validation.Song(converted); err != nil {
+			return nil, 0, fmt.Errorf("song %d: %w", i+1, err)
+		}
+
+		translations += len(song.Translations)
+		songs = append(songs, converted)
+	}
+
+	return songs, translations, nil
+}
+
+// KeyID derives a song id from the unique key of the song.
+func KeyID(releaseDate models.ReleaseDate, name, group string) uuid.UUID {
+	return uuid.NewSHA1(namespace, []byte(releaseDate.String()+"\x00"+name+"\x00"+group))
+}
+
+// LoadFiles loads every fixture file into store.
+func LoadFiles(ctx context.Context, store Store, paths ...string) (Report, error) {
+	var report Report
+
+	for _, path := range paths {
+		fixture, err := ReadFixture(path)
+		if err != nil {
+			return report, err
+		}
+
+		songs, translations, err := fixture.Flatten()
+		if err != nil {
+			return report, fmt.Errorf("%s: %w", path, err)
+		}
+
+		loaded, err := Load(ctx, store, songs)
+		loaded.SkippedTranslations = translations
+		report.add(loaded)
+
+		if err != nil {
+			return report, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	return report, nil
+}
+
+// progressEvery is how often a long load logs its progress.
+const progressEvery = 1000
+
+// Load creates songs in store. Songs clashing with existing ones are counted
+// and skipped, so loading the same songs again changes nothing.
+func Load(ctx context.Context, store Store, songs []models.Song) (Report, error) {
+	var report Report
+
+	entry := logger.FromContext(ctx)
+
+	for i, song := range songs {
+		_, err := store.CreateSong(ctx, song)
+
+		switch {
+		case errors.Is(err, models.ErrDuplicateSong):
+			report.Existing++
+		case err != nil:
+			return report, fmt.Errorf("creating song %q of %q err: %w", song.Name, song.Group, err)
+		default:
+			report.Created++
+		}
+
+		if (i+1)%progressEvery == 0 {
+			entry.WithField("loaded", i+1).WithField("total", len(songs)).Info("seeding songs")
+		}
+	}
+
+	return report, nil
+}
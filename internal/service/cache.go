@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/cache"
+	"github.com/iurikman/songs/internal/logger"
+	"github.com/iurikman/songs/internal/models"
+)
+
+const (
+	listKeyPrefix   = "songs:list:"
+	versesKeyPrefix = "songs:verses:"
+)
+
+// CachedDB is a read-through cache in front of the song store. Song lists are
+// cached per query and lyrics are cached already split into verses; every
+// write drops what it may have made stale. It also implements
+// store.Invalidator so writes made by other instances are dropped as well.
+type CachedDB struct {
+	db
+	cache *cache.Cache
+}
+
+func NewCachedDB(db db, c *cache.Cache) *CachedDB {
+	return &CachedDB{db: db, cache: c}
+}
+
+func (c *CachedDB) GetSongs(ctx context.Context, params models.Params) ([]*models.Song, error) {
+	key, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("json.Marshal(params) err: %w", err)
+	}
+
+	return cache.Fetch(ctx, c.cache, listKeyPrefix+string(key), func(ctx context.Context) ([]*models.Song, error) {
+		return c.db.GetSongs(ctx, params)
+	})
+}
+
+// GetText serves a verse from the cached verses of the song, with the same
+// errors as the store.
+func (c *CachedDB) GetText(ctx context.Context, id uuid.UUID, verse int) (*string, error) {
+	verses, err := cache.Fetch(ctx, c.cache, versesKeyPrefix+id.String(), func(ctx context.Context) ([]string, error) {
+		songs, err := c.db.GetSongsByIDs(ctx, []uuid.UUID{id})
+		if err != nil {
+			return nil, fmt.Errorf("c.db.GetSongsByIDs err: %w", err)
+		}
+
+		if len(songs) == 0 {
+			return nil, models.ErrSongNotFound
+		}
+
+		return models.SplitVerses(songs[0].Text), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if verse < 1 || verse > len(verses) {
+		logger.FromContext(ctx).Debugf("verse %d out of range, song has %d verses", verse, len(verses))
+
+		return nil, models.ErrVerseIsNotValid
+	}
+
+	text := verses[verse-1]
+
+	return &text, nil
+}
+
+func (c *CachedDB) CreateSong(ctx context.Context, song models.Song) (*models.Song, error) {
+	created, err := c.db.CreateSong(ctx, song)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	c.invalidate(ctx, created.ID)
+
+	return created, nil
+}
+
+func (c *CachedDB) UpdateSong(ctx context.Context, id uuid.UUID, song models.Song) (*models.Song, error) {
+	updated, err := c.db.UpdateSong(ctx, id, song)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	c.invalidate(ctx, id)
+
+	return updated, nil
+}
+
+func (c *CachedDB) DeleteSong(ctx context.Context, id uuid.UUID) error {
+	if err := c.db.DeleteSong(ctx, id); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	c.invalidate(ctx, id)
+
+	return nil
+}
+
+func (c *CachedDB) MergeSongs(ctx context.Context, id uuid.UUID, song models.Song, sources []uuid.UUID) (*models.Song, error) {
+	merged, err := c.db.MergeSongs(ctx, id, song, sources)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	c.invalidate(ctx, id)
+
+	for _, source := range sources {
+		c.cache.Delete(ctx, versesKeyPrefix+source.String())
+	}
+
+	return merged, nil
+}
+
+// CreateLink, UpdateLink and DeleteLink may change the primary link of the
+// song, so they drop it like the other writes do.
+func (c *CachedDB) CreateLink(ctx context.Context, link models.SongLink) (*models.SongLink, error) {
+	created, err := c.db.CreateLink(ctx, link)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	c.invalidate(ctx, link.SongID)
+
+	return created, nil
+}
+
+func (c *CachedDB) UpdateLink(ctx context.Context, link models.SongLink) (*models.SongLink, error) {
+	updated, err := c.db.UpdateLink(ctx, link)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	c.invalidate(ctx, link.SongID)
+
+	return updated, nil
+}
+
+func (c *CachedDB) DeleteLink(ctx context.Context, songID, id uuid.UUID) error {
+	if err := c.db.DeleteLink(ctx, songID, id); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	c.invalidate(ctx, songID)
+
+	return nil
+}
+
+// UpdateTag, DeleteTag and SetSongTags may move songs in or out of lists
+// filtered by tag.
+func (c *CachedDB) UpdateTag(ctx context.Context, tag models.Tag) (*models.Tag, error) {
+	updated, err := c.db.UpdateTag(ctx, tag)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	c.cache.DeletePrefix(ctx, listKeyPrefix)
+
+	return updated, nil
+}
+
+func (c *CachedDB) DeleteTag(ctx context.Context, id uuid.UUID) error {
+	if err := c.db.DeleteTag(ctx, id); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	c.cache.DeletePrefix(ctx, listKeyPrefix)
+
+	return nil
+}
+
+func (c *CachedDB) SetSongTags(ctx context.Context, songID uuid.UUID, slugs []string) ([]*models.Tag, error) {
+	tags, err := c.db.SetSongTags(ctx, songID, slugs)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	c.cache.DeletePrefix(ctx, listKeyPrefix)
+
+	return tags, nil
+}
+
+// Invalidate drops what a change notified by another instance made stale.
+func (c *CachedDB) Invalidate(change models.Change) {
+	c.invalidate(context.Background(), change.SongID)
+}
+
+func (c *CachedDB) InvalidateAll() {
+	c.cache.Purge(context.Background())
+}
+
+func (c *CachedDB) Stats() cache.Stats {
+	return c.cache.Stats()
+}
+
+// invalidate drops the verses of the song and every cached list, since any
+// write can move a song in or out of any page.
+func (c *CachedDB) invalidate(ctx context.Context, id uuid.UUID) {
+	c.cache.Delete(ctx, versesKeyPrefix+id.String())
+	c.cache.DeletePrefix(ctx, listKeyPrefix)
+}
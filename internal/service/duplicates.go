@@ -0,0 +1,179 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/logger"
+	"github.com/iurikman/songs/internal/models"
+	"github.com/iurikman/songs/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// DefaultDuplicateThreshold is the trigram similarity of names and groups
+	// from which songs are probable duplicates.
+	DefaultDuplicateThreshold = 0.7
+	// maxDuplicateCandidates bounds the similar songs a rejected song is
+	// reported with.
+	maxDuplicateCandidates = 5
+)
+
+// SetDuplicateThreshold changes the similarity, from 0 to 1, from which songs
+// are probable duplicates.
+func (s *Service) SetDuplicateThreshold(threshold float64) {
+	s.duplicateThreshold.Store(math.Float64bits(threshold))
+}
+
+func (s *Service) threshold() float64 {
+	return math.Float64frombits(s.duplicateThreshold.Load())
+}
+
+// checkDuplicates returns a *models.ProbableDuplicateError when song is
+// probably a duplicate of stored songs.
+func (s *Service) checkDuplicates(ctx context.Context, song models.Song) error {
+	similar, err := s.db.SimilarSongs(ctx, song, s.threshold(), maxDuplicateCandidates)
+	if err != nil {
+		return fmt.Errorf("s.db.SimilarSongs(ctx, song) err: %w", err)
+	}
+
+	if len(similar) == 0 {
+		return nil
+	}
+
+	logger.FromContext(ctx).WithField("candidates", len(similar)).Info("Song rejected as a probable duplicate")
+
+	return &models.ProbableDuplicateError{Candidates: similar}
+}
+
+// DuplicateClusters groups the songs of the catalog linked by probable
+// duplicate pairs, largest clusters first, and pages them like params.
+func (s *Service) DuplicateClusters(ctx context.Context, params models.Params) ([]*models.DuplicateCluster, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "Service.DuplicateClusters")
+	defer span.End()
+
+	pairs, err := s.db.SimilarSongPairs(ctx, s.threshold())
+	if err != nil {
+		return nil, telemetry.RecordError(span, fmt.Errorf("s.db.SimilarSongPairs(ctx, threshold) err: %w", err))
+	}
+
+	clusters := clusterPairs(pairs)
+
+	span.SetAttributes(attribute.Int("duplicates.pairs", len(pairs)), attribute.Int("duplicates.clusters", len(clusters)))
+
+	clusters = clusters[min(params.Offset, len(clusters)):min(params.Offset+params.Limit, len(clusters))]
+
+	ids := make([]uuid.UUID, 0)
+	for _, cluster := range clusters {
+		ids = append(ids, cluster.ids...)
+	}
+
+	songs, err := s.db.GetSongsByIDs(ctx, ids)
+	if err != nil {
+		return nil, telemetry.RecordError(span, fmt.Errorf("s.db.GetSongsByIDs(ctx, ids) err: %w", err))
+	}
+
+	byID := make(map[uuid.UUID]*models.Song, len(songs))
+	for _, song := range songs {
+		byID[song.ID] = song
+	}
+
+	result := make([]*models.DuplicateCluster, 0, len(clusters))
+
+	for _, cluster := range clusters {
+		duplicates := &models.DuplicateCluster{Songs: make([]*models.Song, 0, len(cluster.ids)), Similarity: cluster.similarity}
+
+		// A song deleted since the pairs were read drops out of its cluster.
+		for _, id := range cluster.ids {
+			if song, ok := byID[id]; ok {
+				duplicates.Songs = append(duplicates.Songs, song)
+			}
+		}
+
+		if len(duplicates.Songs) > 1 {
+			result = append(result, duplicates)
+		}
+	}
+
+	return result, nil
+}
+
+type idCluster struct {
+	ids        []uuid.UUID
+	similarity float64
+}
+
+// clusterPairs joins the songs of pairs into connected clusters, ordered by
+// size, then by similarity, then by their lowest id so that pages are stable.
+func clusterPairs(pairs []models.SongPair) []idCluster {
+	parent := make(map[uuid.UUID]uuid.UUID)
+
+	var find func(id uuid.UUID) uuid.UUID
+
+	find = func(id uuid.UUID) uuid.UUID {
+		root, ok := parent[id]
+		if !ok {
+			parent[id] = id
+
+			return id
+		}
+
+		if root != id {
+			root = find(root)
+			parent[id] = root
+		}
+
+		return root
+	}
+
+	for _, pair := range pairs {
+		a, b := find(pair.A), find(pair.B)
+		if a != b {
+			parent[a] = b
+		}
+	}
+
+	byRoot := make(map[uuid.UUID]*idCluster)
+
+	for _, pair := range pairs {
+		root := find(pair.A)
+
+		cluster, ok := byRoot[root]
+		if !ok {
+			cluster = &idCluster{similarity: 1}
+			byRoot[root] = cluster
+		}
+
+		cluster.similarity = min(cluster.similarity, pair.Similarity)
+	}
+
+	for id := range parent {
+		cluster := byRoot[find(id)]
+		cluster.ids = append(cluster.ids, id)
+	}
+
+	clusters := make([]idCluster, 0, len(byRoot))
+
+	for _, cluster := range byRoot {
+		slices.SortFunc(cluster.ids, compareIDs)
+		clusters = append(clusters, *cluster)
+	}
+
+	slices.SortFunc(clusters, func(a, b idCluster) int {
+		return cmp.Or(
+			cmp.Compare(len(b.ids), len(a.ids)),
+			cmp.Compare(b.similarity, a.similarity),
+			compareIDs(a.ids[0], b.ids[0]),
+		)
+	})
+
+	return clusters
+}
+
+func compareIDs(a, b uuid.UUID) int {
+	return cmp.Compare(a.String(), b.String())
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/logger"
+	"github.com/iurikman/songs/internal/models"
+	"github.com/iurikman/songs/internal/telemetry"
+	"github.com/iurikman/songs/internal/validation"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func (s *Service) GetLinks(ctx context.Context, songID uuid.UUID) ([]*models.SongLink, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "Service.GetLinks", trace.WithAttributes(attribute.String("song.id", songID.String())))
+	defer span.End()
+
+	links, err := s.db.GetLinks(ctx, songID)
+	if err != nil {
+		return nil, telemetry.RecordError(span, fmt.Errorf("s.db.GetLinks(ctx, songID) err: %w", err))
+	}
+
+	return links, nil
+}
+
+// CreateLink adds a link to the song songID. Its URL is normalized, which
+// tells its platform and the id of the track; the region defaults to the one
+// of the URL.
+func (s *Service) CreateLink(ctx context.Context, songID uuid.UUID, link models.SongLink) (*models.SongLink, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "Service.CreateLink", trace.WithAttributes(attribute.String("song.id", songID.String())))
+	defer span.End()
+
+	if err := validation.Link(link); err != nil {
+		return nil, telemetry.RecordError(span, err)
+	}
+
+	link = normalizeLink(link)
+	link.ID = uuid.New()
+	link.SongID = songID
+
+	created, err := s.db.CreateLink(ctx, link)
+	if err != nil {
+		return nil, telemetry.RecordError(span, fmt.Errorf("s.db.CreateLink(ctx, link) err: %w", err))
+	}
+
+	logger.FromContext(ctx).WithField("link", created.URL).Infof("Link successfully added to song %s", songID)
+
+	return created, nil
+}
+
+// UpdateLink replaces a link of the song songID like CreateLink adds one.
+func (s *Service) UpdateLink(ctx context.Context, songID, id uuid.UUID, link models.SongLink) (*models.SongLink, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "Service.UpdateLink", trace.WithAttributes(
+		attribute.String("song.id", songID.String()), attribute.String("link.id", id.String()),
+	))
+	defer span.End()
+
+	if err := validation.Link(link); err != nil {
+		return nil, telemetry.RecordError(span, err)
+	}
+
+	link = normalizeLink(link)
+	link.ID = id
+	link.SongID = songID
+
+	updated, err := s.db.UpdateLink(ctx, link)
+	if err != nil {
+		return nil, telemetry.RecordError(span, fmt.Errorf("s.db.UpdateLink(ctx, link) err: %w", err))
+	}
+
+	logger.FromContext(ctx).WithField("link", updated.URL).Infof("Link successfully updated for song %s", songID)
+
+	return updated, nil
+}
+
+func (s *Service) DeleteLink(ctx context.Context, songID, id uuid.UUID) error {
+	ctx, span := telemetry.Tracer().Start(ctx, "Service.DeleteLink", trace.WithAttributes(
+		attribute.String("song.id", songID.String()), attribute.String("link.id", id.String()),
+	))
+	defer span.End()
+
+	if err := s.db.DeleteLink(ctx, songID, id); err != nil {
+		return telemetry.RecordError(span, fmt.Errorf("s.db.DeleteLink(ctx, songID, id) err: %w", err))
+	}
+
+	logger.FromContext(ctx).Infof("Link %s successfully deleted from song %s", id, songID)
+
+	return nil
+}
+
+// GetBrokenLinks returns the links the checker found broken, the most
+// recently checked first.
+func (s *Service) GetBrokenLinks(ctx context.Context, params models.Params) ([]*models.BrokenLink, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "Service.GetBrokenLinks")
+	defer span.End()
+
+	links, err := s.db.BrokenLinks(ctx, params)
+	if err != nil {
+		return nil, telemetry.RecordError(span, fmt.Errorf("s.db.BrokenLinks(ctx, params) err: %w", err))
+	}
+
+	return links, nil
+}
+
+// normalizeLink returns link with its normalized URL, platform and id on the
+// platform. link must be valid.
+func normalizeLink(link models.SongLink) models.SongLink {
+	normalized, _ := models.NormalizeLink(link.URL)
+
+	link.URL = normalized.URL
+	link.Platform = normalized.Platform
+	link.ExternalID = normalized.ExternalID
+
+	if link.Region == "" {
+		link.Region = normalized.Region
+	}
+
+	return link
+}
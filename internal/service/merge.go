@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/logger"
+	"github.com/iurikman/songs/internal/models"
+	"github.com/iurikman/songs/internal/telemetry"
+	"github.com/iurikman/songs/internal/validation"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// MergeSongs merges the sources of merge into the song id, which takes the
+// values of the fields merge picks from them. The sources are deleted and
+// their ids lead to the merged song from then on.
+func (s *Service) MergeSongs(ctx context.Context, id uuid.UUID, merge models.Merge) (*models.Song, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "Service.MergeSongs", trace.WithAttributes(
+		attribute.String("song.id", id.String()), attribute.Int("merge.sources", len(merge.Sources)),
+	))
+	defer span.End()
+
+	if err := validation.Merge(id, merge); err != nil {
+		return nil, telemetry.RecordError(span, err)
+	}
+
+	songs, err := s.db.GetSongsByIDs(ctx, append([]uuid.UUID{id}, merge.Sources...))
+	if err != nil {
+		return nil, telemetry.RecordError(span, fmt.Errorf("s.db.GetSongsByIDs(ctx, ids) err: %w", err))
+	}
+
+	byID := make(map[uuid.UUID]*models.Song, len(songs))
+	for _, song := range songs {
+		byID[song.ID] = song
+	}
+
+	if len(byID) != len(merge.Sources)+1 {
+		return nil, telemetry.RecordError(span, models.ErrSongNotFound)
+	}
+
+	merged := mergeFields(*byID[id], byID, merge.Fields)
+
+	if err := validation.Song(merged); err != nil {
+		return nil, telemetry.RecordError(span, err)
+	}
+
+	mergedSong, err := s.db.MergeSongs(ctx, id, merged, merge.Sources)
+	if err != nil {
+		return nil, telemetry.RecordError(span, fmt.Errorf("s.db.MergeSongs(ctx, id, song, sources) err: %w", err))
+	}
+
+	logger.FromContext(ctx).WithFields(logger.SongFields(mergedSong)).
+		WithField("sources", merge.Sources).Info("Songs successfully merged")
+
+	return mergedSong, nil
+}
+
+// mergeFields returns song with the fields picked from the songs by id.
+func mergeFields(song models.Song, byID map[uuid.UUID]*models.Song, fields map[string]uuid.UUID) models.Song {
+	for field, winner := range fields {
+		from := byID[winner]
+
+		switch field {
+		case "releaseDate":
+			song.ReleaseDate = from.ReleaseDate
+		case "name":
+			song.Name = from.Name
+		case "musicGroup":
+			song.Group = from.Group
+		case "text":
+			song.Text = from.Text
+		case "link":
+			song.Link = from.Link
+		}
+	}
+
+	return song
+}
+
+// mergedSong returns a *models.MergedSongError when the song id was merged
+// into another one, and notFound otherwise.
+func (s *Service) mergedSong(ctx context.Context, id uuid.UUID, notFound error) error {
+	into, err := s.db.MergedInto(ctx, id)
+
+	switch {
+	case errors.Is(err, models.ErrSongNotFound):
+		return notFound
+	case err != nil:
+		return fmt.Errorf("s.db.MergedInto(ctx, id) err: %w", err)
+	}
+
+	return &models.MergedSongError{ID: id, Into: into}
+}
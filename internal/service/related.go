@@ -0,0 +1,26 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/models"
+	"github.com/iurikman/songs/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RelatedSongs returns the songs whose lyrics are most like those of the song
+// id, boosted by params.
+func (s *Service) RelatedSongs(ctx context.Context, id uuid.UUID, params models.Params) ([]*models.RelatedSong, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "Service.RelatedSongs", trace.WithAttributes(attribute.String("song.id", id.String())))
+	defer span.End()
+
+	related, err := s.db.RelatedSongs(ctx, id, params)
+	if err != nil {
+		return nil, telemetry.RecordError(span, fmt.Errorf("s.db.RelatedSongs(ctx, id, params) err: %w", err))
+	}
+
+	return related, nil
+}
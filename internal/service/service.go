@@ -2,25 +2,39 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync/atomic"
 
 	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/logger"
 	"github.com/iurikman/songs/internal/models"
+	"github.com/iurikman/songs/internal/telemetry"
+	"github.com/iurikman/songs/internal/validation"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Service struct {
 	db          db
 	songDetails songDetailsClient
+	// duplicateThreshold holds the bits of the float64 similarity from which
+	// songs are probable duplicates.
+	duplicateThreshold atomic.Uint64
 }
 
 func NewService(db db, songDetailsServer songDetailsClient) *Service {
 	log.Debug("Initializing new service")
 
-	return &Service{
+	s := &Service{
 		db:          db,
 		songDetails: songDetailsServer,
 	}
+
+	s.SetDuplicateThreshold(DefaultDuplicateThreshold)
+
+	return s
 }
 
 type songDetailsClient interface {
@@ -33,73 +47,179 @@ type db interface {
 	GetText(ctx context.Context, id uuid.UUID, verse int) (*string, error)
 	DeleteSong(ctx context.Context, id uuid.UUID) error
 	UpdateSong(ctx context.Context, id uuid.UUID, song models.Song) (*models.Song, error)
+	GetSongsByIDs(ctx context.Context, ids []uuid.UUID) ([]*models.Song, error)
+	GetSongsByGroups(ctx context.Context, groups []string) ([]*models.Song, error)
+	GetGroups(ctx context.Context, params models.Params) ([]string, error)
+	SimilarSongs(ctx context.Context, song models.Song, threshold float64, limit int) ([]*models.SimilarSong, error)
+	SimilarSongPairs(ctx context.Context, threshold float64) ([]models.SongPair, error)
+	MergeSongs(ctx context.Context, id uuid.UUID, song models.Song, sources []uuid.UUID) (*models.Song, error)
+	MergedInto(ctx context.Context, id uuid.UUID) (uuid.UUID, error)
+	GetLinks(ctx context.Context, songID uuid.UUID) ([]*models.SongLink, error)
+	CreateLink(ctx context.Context, link models.SongLink) (*models.SongLink, error)
+	UpdateLink(ctx context.Context, link models.SongLink) (*models.SongLink, error)
+	DeleteLink(ctx context.Context, songID, id uuid.UUID) error
+	BrokenLinks(ctx context.Context, params models.Params) ([]*models.BrokenLink, error)
+	GetTags(ctx context.Context) ([]*models.Tag, error)
+	CreateTag(ctx context.Context, tag models.Tag) (*models.Tag, error)
+	UpdateTag(ctx context.Context, tag models.Tag) (*models.Tag, error)
+	DeleteTag(ctx context.Context, id uuid.UUID) error
+	GetSongTags(ctx context.Context, songID uuid.UUID) ([]*models.Tag, error)
+	SetSongTags(ctx context.Context, songID uuid.UUID, slugs []string) ([]*models.Tag, error)
+	TagFacets(ctx context.Context, params models.Params) ([]*models.TagFacet, error)
+	RelatedSongs(ctx context.Context, id uuid.UUID, params models.Params) ([]*models.RelatedSong, error)
+	GetStats(ctx context.Context, params models.Params) (*models.Stats, error)
 }
 
-func (s *Service) CreateSong(ctx context.Context, song models.Song) (*models.Song, error) {
-	log.Debugf("Creating song, getting song details from songdetails: %+v", song)
+// CreateSong creates a song unless it is probably a duplicate of a song
+// already stored, which force skips checking.
+func (s *Service) CreateSong(ctx context.Context, song models.Song, force bool) (*models.Song, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "Service.CreateSong")
+	defer span.End()
+
+	entry := logger.FromContext(ctx).WithFields(logger.SongFields(&song))
+
+	if err := validation.Song(song); err != nil {
+		return nil, telemetry.RecordError(span, err)
+	}
+
+	entry.Debug("Creating song, getting song details from songdetails")
 
 	songWithDetails, err := s.songDetails.Get(ctx, song)
 	if err != nil {
-		return nil, fmt.Errorf("getDetails(ctx, song) err: %w", err)
+		return nil, telemetry.RecordError(span, fmt.Errorf("getDetails(ctx, song) err: %w", err))
 	}
 
-	log.Debug("Details retrieved and assigned to song, creating new song")
+	entry.Debug("Details retrieved and assigned to song, creating new song")
+
+	if !force {
+		if err := s.checkDuplicates(ctx, *songWithDetails); err != nil {
+			return nil, telemetry.RecordError(span, err)
+		}
+	}
 
 	createdSong, err := s.db.CreateSong(ctx, *songWithDetails)
 	if err != nil {
-		return nil, fmt.Errorf("s.db.createSong(ctx, song) err: %w", err)
+		return nil, telemetry.RecordError(span, fmt.Errorf("s.db.createSong(ctx, song) err: %w", err))
 	}
 
-	log.Infof("Song successfully created: %+v", createdSong)
+	entry.WithFields(logger.SongFields(createdSong)).Info("Song successfully created")
 
 	return createdSong, nil
 }
 
 func (s *Service) GetSongs(ctx context.Context, params models.Params) ([]*models.Song, error) {
-	log.Debugf("Retrieving songs with params: %+v", params)
+	ctx, span := telemetry.Tracer().Start(ctx, "Service.GetSongs")
+	defer span.End()
+
+	entry := logger.FromContext(ctx)
+
+	entry.WithField("params", params).Debug("Retrieving songs")
 
 	songs, err := s.db.GetSongs(ctx, params)
 	if err != nil {
-		return nil, fmt.Errorf("s.db.createSong(ctx, params) err: %w", err)
+		return nil, telemetry.RecordError(span, fmt.Errorf("s.db.createSong(ctx, params) err: %w", err))
 	}
 
-	log.Infof("Successfully retrieved %d songs", len(songs))
+	entry.Infof("Successfully retrieved %d songs", len(songs))
 
 	return songs, nil
 }
 
 func (s *Service) GetText(ctx context.Context, id uuid.UUID, verse int) (*string, error) {
-	log.Debugf("Retrieving text for song ID: %s, verse: %d", id, verse)
+	ctx, span := telemetry.Tracer().Start(ctx, "Service.GetText",
+		trace.WithAttributes(attribute.String("song.id", id.String()), attribute.Int("song.verse", verse)),
+	)
+	defer span.End()
+
+	logger.FromContext(ctx).Debugf("Retrieving text for song ID: %s, verse: %d", id, verse)
 
 	textOfVerse, err := s.db.GetText(ctx, id, verse)
+	if errors.Is(err, models.ErrSongNotFound) {
+		err = s.mergedSong(ctx, id, err)
+	}
+
 	if err != nil {
-		return nil, fmt.Errorf("s.db.getText(ctx, id, verse) err: %w", err)
+		return nil, telemetry.RecordError(span, fmt.Errorf("s.db.getText(ctx, id, verse) err: %w", err))
 	}
 
 	return textOfVerse, nil
 }
 
 func (s *Service) DeleteSong(ctx context.Context, id uuid.UUID) error {
-	log.Debugf("Deleting song with ID: %s", id)
+	ctx, span := telemetry.Tracer().Start(ctx, "Service.DeleteSong", trace.WithAttributes(attribute.String("song.id", id.String())))
+	defer span.End()
+
+	entry := logger.FromContext(ctx)
+
+	entry.Debugf("Deleting song with ID: %s", id)
 
 	if err := s.db.DeleteSong(ctx, id); err != nil {
-		return fmt.Errorf("s.db.deleteSong(ctx, id) err: %w", err)
+		return telemetry.RecordError(span, fmt.Errorf("s.db.deleteSong(ctx, id) err: %w", err))
 	}
 
-	log.Infof("Song successfully deleted with ID: %s", id)
+	entry.Infof("Song successfully deleted with ID: %s", id)
 
 	return nil
 }
 
 func (s *Service) UpdateSong(ctx context.Context, id uuid.UUID, song models.Song) (*models.Song, error) {
-	log.Debugf("Updating song with ID: %s", id)
+	ctx, span := telemetry.Tracer().Start(ctx, "Service.UpdateSong", trace.WithAttributes(attribute.String("song.id", id.String())))
+	defer span.End()
+
+	entry := logger.FromContext(ctx)
+
+	entry.Debugf("Updating song with ID: %s", id)
+
+	if err := validation.Song(song); err != nil {
+		return nil, telemetry.RecordError(span, err)
+	}
 
 	updatedSong, err := s.db.UpdateSong(ctx, id, song)
+	if errors.Is(err, models.ErrSongNotFound) {
+		err = s.mergedSong(ctx, id, err)
+	}
+
 	if err != nil {
-		return nil, fmt.Errorf("s.db.UpdateSong(ctx, id, song) err: %w", err)
+		return nil, telemetry.RecordError(span, fmt.Errorf("s.db.UpdateSong(ctx, id, song) err: %w", err))
 	}
 
-	log.Infof("Song successfully updated: %+v", updatedSong)
+	entry.WithFields(logger.SongFields(updatedSong)).Info("Song successfully updated")
 
 	return updatedSong, nil
 }
+
+func (s *Service) GetSongsByIDs(ctx context.Context, ids []uuid.UUID) ([]*models.Song, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "Service.GetSongsByIDs", trace.WithAttributes(attribute.Int("songs.ids", len(ids))))
+	defer span.End()
+
+	songs, err := s.db.GetSongsByIDs(ctx, ids)
+	if err != nil {
+		return nil, telemetry.RecordError(span, fmt.Errorf("s.db.GetSongsByIDs(ctx, ids) err: %w", err))
+	}
+
+	return songs, nil
+}
+
+func (s *Service) GetSongsByGroups(ctx context.Context, groups []string) ([]*models.Song, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "Service.GetSongsByGroups", trace.WithAttributes(attribute.Int("songs.groups", len(groups))))
+	defer span.End()
+
+	songs, err := s.db.GetSongsByGroups(ctx, groups)
+	if err != nil {
+		return nil, telemetry.RecordError(span, fmt.Errorf("s.db.GetSongsByGroups(ctx, groups) err: %w", err))
+	}
+
+	return songs, nil
+}
+
+func (s *Service) GetGroups(ctx context.Context, params models.Params) ([]string, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "Service.GetGroups")
+	defer span.End()
+
+	groups, err := s.db.GetGroups(ctx, params)
+	if err != nil {
+		return nil, telemetry.RecordError(span, fmt.Errorf("s.db.GetGroups(ctx, params) err: %w", err))
+	}
+
+	return groups, nil
+}
@@ -0,0 +1,22 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/iurikman/songs/internal/models"
+	"github.com/iurikman/songs/internal/telemetry"
+)
+
+// GetStats returns the statistics of the catalog, the groups paged by params.
+func (s *Service) GetStats(ctx context.Context, params models.Params) (*models.Stats, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "Service.GetStats")
+	defer span.End()
+
+	stats, err := s.db.GetStats(ctx, params)
+	if err != nil {
+		return nil, telemetry.RecordError(span, fmt.Errorf("s.db.GetStats(ctx, params) err: %w", err))
+	}
+
+	return stats, nil
+}
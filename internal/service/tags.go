@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/logger"
+	"github.com/iurikman/songs/internal/models"
+	"github.com/iurikman/songs/internal/telemetry"
+	"github.com/iurikman/songs/internal/validation"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func (s *Service) GetTags(ctx context.Context) ([]*models.Tag, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "Service.GetTags")
+	defer span.End()
+
+	tags, err := s.db.GetTags(ctx)
+	if err != nil {
+		return nil, telemetry.RecordError(span, fmt.Errorf("s.db.GetTags(ctx) err: %w", err))
+	}
+
+	return tags, nil
+}
+
+func (s *Service) CreateTag(ctx context.Context, tag models.Tag) (*models.Tag, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "Service.CreateTag")
+	defer span.End()
+
+	tag.ID = uuid.New()
+
+	if err := validation.Tag(tag); err != nil {
+		return nil, telemetry.RecordError(span, err)
+	}
+
+	created, err := s.db.CreateTag(ctx, tag)
+	if err != nil {
+		return nil, telemetry.RecordError(span, fmt.Errorf("s.db.CreateTag(ctx, tag) err: %w", err))
+	}
+
+	logger.FromContext(ctx).WithField("tag", created.Slug).Info("Tag successfully created")
+
+	return created, nil
+}
+
+// UpdateTag replaces the slug, name and parent of the tag id; moving it under
+// another tag moves its descendants along.
+func (s *Service) UpdateTag(ctx context.Context, id uuid.UUID, tag models.Tag) (*models.Tag, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "Service.UpdateTag", trace.WithAttributes(attribute.String("tag.id", id.String())))
+	defer span.End()
+
+	tag.ID = id
+
+	if err := validation.Tag(tag); err != nil {
+		return nil, telemetry.RecordError(span, err)
+	}
+
+	updated, err := s.db.UpdateTag(ctx, tag)
+	if err != nil {
+		return nil, telemetry.RecordError(span, fmt.Errorf("s.db.UpdateTag(ctx, tag) err: %w", err))
+	}
+
+	logger.FromContext(ctx).WithField("tag", updated.Slug).Info("Tag successfully updated")
+
+	return updated, nil
+}
+
+// DeleteTag removes the tag id from every song; its children take its place
+// under its parent.
+func (s *Service) DeleteTag(ctx context.Context, id uuid.UUID) error {
+	ctx, span := telemetry.Tracer().Start(ctx, "Service.DeleteTag", trace.WithAttributes(attribute.String("tag.id", id.String())))
+	defer span.End()
+
+	if err := s.db.DeleteTag(ctx, id); err != nil {
+		return telemetry.RecordError(span, fmt.Errorf("s.db.DeleteTag(ctx, id) err: %w", err))
+	}
+
+	logger.FromContext(ctx).Infof("Tag %s successfully deleted", id)
+
+	return nil
+}
+
+func (s *Service) GetSongTags(ctx context.Context, songID uuid.UUID) ([]*models.Tag, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "Service.GetSongTags", trace.WithAttributes(attribute.String("song.id", songID.String())))
+	defer span.End()
+
+	tags, err := s.db.GetSongTags(ctx, songID)
+	if err != nil {
+		return nil, telemetry.RecordError(span, fmt.Errorf("s.db.GetSongTags(ctx, songID) err: %w", err))
+	}
+
+	return tags, nil
+}
+
+// SetSongTags replaces the tags of the song songID with those of the slugs.
+func (s *Service) SetSongTags(ctx context.Context, songID uuid.UUID, tags models.SongTags) ([]*models.Tag, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "Service.SetSongTags", trace.WithAttributes(attribute.String("song.id", songID.String())))
+	defer span.End()
+
+	if err := validation.SongTags(tags); err != nil {
+		return nil, telemetry.RecordError(span, err)
+	}
+
+	set, err := s.db.SetSongTags(ctx, songID, tags.Tags)
+	if err != nil {
+		return nil, telemetry.RecordError(span, fmt.Errorf("s.db.SetSongTags(ctx, songID, slugs) err: %w", err))
+	}
+
+	logger.FromContext(ctx).Infof("Song %s successfully tagged with %d tags", songID, len(set))
+
+	return set, nil
+}
+
+// TagFacets counts per tag the songs GetSongs lists with params, on every
+// page. A song counts for a tag when it has the tag or one of its descendants.
+func (s *Service) TagFacets(ctx context.Context, params models.Params) ([]*models.TagFacet, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "Service.TagFacets")
+	defer span.End()
+
+	facets, err := s.db.TagFacets(ctx, params)
+	if err != nil {
+		return nil, telemetry.RecordError(span, fmt.Errorf("s.db.TagFacets(ctx, params) err: %w", err))
+	}
+
+	return facets, nil
+}
@@ -0,0 +1,113 @@
+package songdetails
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	StateClosed   = "closed"
+	StateOpen     = "open"
+	StateHalfOpen = "half-open"
+
+	defaultFailureThreshold = 5
+	defaultOpenTimeout      = 30 * time.Second
+)
+
+var ErrCircuitOpen = errors.New("song details circuit is open")
+
+// breaker is a consecutive-failure circuit breaker. After threshold failures
+// in a row it rejects calls for openTimeout, then lets a single trial request
+// through; the trial result decides whether the circuit closes again.
+type breaker struct {
+	mu          sync.Mutex
+	threshold   int
+	openTimeout time.Duration
+	failures    int
+	openedAt    time.Time
+	trial       bool
+	now         func() time.Time
+}
+
+func newBreaker(threshold int, openTimeout time.Duration) *breaker {
+	return &breaker{
+		threshold:   threshold,
+		openTimeout: openTimeout,
+		now:         time.Now,
+	}
+}
+
+func (b *breaker) configure(threshold int, openTimeout time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.threshold = threshold
+	b.openTimeout = openTimeout
+}
+
+func (b *breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state() {
+	case StateOpen:
+		return ErrCircuitOpen
+	case StateHalfOpen:
+		if b.trial {
+			return ErrCircuitOpen
+		}
+
+		b.trial = true
+	}
+
+	return nil
+}
+
+func (b *breaker) report(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+
+	if err == nil {
+		b.failures = 0
+
+		return
+	}
+
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openedAt = b.now()
+	}
+}
+
+// retryAfter is how long the circuit stays open, zero unless it is open.
+func (b *breaker) retryAfter() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state() != StateOpen {
+		return 0
+	}
+
+	return b.openedAt.Add(b.openTimeout).Sub(b.now())
+}
+
+func (b *breaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state()
+}
+
+func (b *breaker) state() string {
+	switch {
+	case b.failures < b.threshold:
+		return StateClosed
+	case b.now().Sub(b.openedAt) < b.openTimeout:
+		return StateOpen
+	default:
+		return StateHalfOpen
+	}
+}
@@ -3,49 +3,141 @@ package songdetails
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/iurikman/songs/internal/logger"
 	"github.com/iurikman/songs/internal/models"
+	"github.com/iurikman/songs/internal/telemetry"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
+var (
+	ErrUnexpectedStatus = errors.New("unexpected status code")
+	// ErrUnavailable matches every failure of the details API, the open
+	// circuit included, but those of requests cancelled by their caller.
+	ErrUnavailable = errors.New("song details are unavailable")
+)
+
+// UnavailableError reports a failed call of the details API. It matches
+// ErrUnavailable and its cause with errors.Is. RetryAfter is how long the
+// circuit stays open, zero when it is not known.
+type UnavailableError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrUnavailable, e.Err)
+}
+
+func (e *UnavailableError) Unwrap() []error {
+	return []error{ErrUnavailable, e.Err}
+}
+
+type Config struct {
+	Host             string
+	Timeout          time.Duration
+	FailureThreshold int
+	OpenTimeout      time.Duration
+}
+
 type SongDetails struct {
-	host string
+	mu      sync.RWMutex
+	host    string
+	timeout time.Duration
+	client  *http.Client
+	breaker *breaker
 }
 
-func NewSongDetails(host string) *SongDetails {
+func NewSongDetails(cfg Config) *SongDetails {
+	cfg = withDefaults(cfg)
+
 	return &SongDetails{
-		host: host,
+		host:    strings.TrimSuffix(cfg.Host, "/"),
+		timeout: cfg.Timeout,
+		// otelhttp.Transport creates a client span per request and injects
+		// the W3C traceparent header so the upstream can join the trace.
+		client:  &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)},
+		breaker: newBreaker(cfg.FailureThreshold, cfg.OpenTimeout),
 	}
 }
 
+// Reconfigure swaps the upstream address, timeout and breaker settings.
+// Requests already in flight finish with the settings they started with.
+func (s *SongDetails) Reconfigure(cfg Config) {
+	cfg = withDefaults(cfg)
+
+	s.mu.Lock()
+	s.host = strings.TrimSuffix(cfg.Host, "/")
+	s.timeout = cfg.Timeout
+	s.mu.Unlock()
+
+	s.breaker.configure(cfg.FailureThreshold, cfg.OpenTimeout)
+}
+
+func withDefaults(cfg Config) Config {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = defaultFailureThreshold
+	}
+
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = defaultOpenTimeout
+	}
+
+	return cfg
+}
+
+// CircuitState reports the state of the circuit breaker guarding the details API.
+func (s *SongDetails) CircuitState() string {
+	return s.breaker.State()
+}
+
 func (s *SongDetails) Get(ctx context.Context, song models.Song) (*models.Song, error) {
-	reqURLstring := s.host + "/info" + "?song=" + song.Name + "&group=" + song.Group
+	ctx, span := telemetry.Tracer().Start(ctx, "SongDetails.Get")
+	defer span.End()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURLstring, nil)
-	if err != nil {
-		return nil, fmt.Errorf("http.NewRequestWithContext(ctx, \"GET\", reqURLstring, nil) err: %w", err)
+	if err := s.breaker.allow(); err != nil {
+		return nil, telemetry.RecordError(span, &UnavailableError{Err: err, RetryAfter: s.breaker.retryAfter()})
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	start := time.Now()
+	songDetails, err := s.fetch(ctx, song)
+	s.breaker.report(err)
 
-	client := &http.Client{}
+	entry := logger.FromContext(ctx).WithFields(log.Fields{
+		"duration": time.Since(start).String(),
+		"circuit":  s.breaker.State(),
+	})
 
-	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("client.Do(req) err: %w", err)
+		entry.WithError(err).Warn("song details request failed")
+
+		if ctx.Err() == nil {
+			err = &UnavailableError{Err: err}
+		}
+
+		return nil, telemetry.RecordError(span, err)
 	}
-	defer resp.Body.Close()
 
-	var songDetails models.SongDetails
+	entry.Debug("song details retrieved")
 
-	if err := json.NewDecoder(resp.Body).Decode(&songDetails); err != nil {
-		return nil, fmt.Errorf("json.Decode() err: %w", err)
+	// A release date the details API formats unexpectedly is left unknown
+	// rather than failing the song.
+	releaseDate, err := models.ParseReleaseDate(songDetails.ReleaseDate)
+	if err != nil {
+		entry.WithError(err).Warn("song details have an invalid release date")
 	}
 
 	songWithDetails := &models.Song{
 		ID:          song.ID,
-		ReleaseDate: songDetails.ReleaseDate,
+		ReleaseDate: releaseDate,
 		Text:        songDetails.Text,
 		Link:        songDetails.Link,
 		Name:        song.Name,
@@ -54,3 +146,44 @@ func (s *SongDetails) Get(ctx context.Context, song models.Song) (*models.Song,
 
 	return songWithDetails, nil
 }
+
+func (s *SongDetails) fetch(ctx context.Context, song models.Song) (*models.SongDetails, error) {
+	s.mu.RLock()
+	host, timeout := s.host, s.timeout
+	s.mu.RUnlock()
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	query := url.Values{"song": []string{song.Name}, "group": []string{song.Group}}
+	reqURLstring := host + "/info?" + query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURLstring, nil)
+	if err != nil {
+		return nil, fmt.Errorf("http.NewRequestWithContext(ctx, \"GET\", reqURLstring, nil) err: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("client.Do(req) err: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %d", ErrUnexpectedStatus, resp.StatusCode)
+	}
+
+	var songDetails models.SongDetails
+
+	if err := json.NewDecoder(resp.Body).Decode(&songDetails); err != nil {
+		return nil, fmt.Errorf("json.Decode() err: %w", err)
+	}
+
+	return &songDetails, nil
+}
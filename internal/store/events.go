@@ -0,0 +1,373 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// recordEvent appends a change to the outbox, notifies the listeners of
+// ChannelSongsChanged and queues a delivery for every active webhook
+// subscribed to it. It runs in the transaction of the change so the feed never
+// misses or invents one.
+func recordEvent(ctx context.Context, tx pgx.Tx, eventType string, song *models.Song) error {
+	return recordSongEvent(ctx, tx, eventType, song.ID, song)
+}
+
+// recordSongEvent records an event of the song songID carrying song, which
+// is another song for the songs merged into it.
+func recordSongEvent(ctx context.Context, tx pgx.Tx, eventType string, songID uuid.UUID, song *models.Song) error {
+	payload, err := json.Marshal(song)
+	if err != nil {
+		return fmt.Errorf("json.Marshal(song) err: %w", err)
+	}
+
+	var eventID int64
+
+	err = tx.QueryRow(
+		ctx,
+		`INSERT INTO song_events (song_id, type, payload) VALUES ($1, $2, $3) RETURNING id`,
+		songID,
+		eventType,
+		payload,
+	).Scan(&eventID)
+	if err != nil {
+		return fmt.Errorf("inserting song event err: %w", err)
+	}
+
+	if err := notifyChange(ctx, tx, models.Change{Type: eventType, SongID: songID}); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		`	INSERT INTO webhook_deliveries (webhook_id, event_id)
+			SELECT id, $1 FROM webhooks
+			WHERE active and (event_types = '{}' or $2::varchar = ANY(event_types))
+		`,
+		eventID,
+		eventType,
+	)
+	if err != nil {
+		return fmt.Errorf("queueing webhook deliveries err: %w", err)
+	}
+
+	return nil
+}
+
+// notifyChange notifies ChannelSongsChanged of change. Notifications are sent
+// on commit and dropped on rollback.
+func notifyChange(ctx context.Context, tx pgx.Tx, change models.Change) error {
+	payload, err := json.Marshal(change)
+	if err != nil {
+		return fmt.Errorf("json.Marshal(change) err: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `SELECT pg_notify($1, $2)`, ChannelSongsChanged, string(payload)); err != nil {
+		return fmt.Errorf("notifying %s err: %w", ChannelSongsChanged, err)
+	}
+
+	return nil
+}
+
+// GetEvents returns up to limit events with an id greater than afterID, oldest
+// first.
+func (p *Postgres) GetEvents(ctx context.Context, afterID int64, limit int) ([]*models.Event, error) {
+	query := `
+				SELECT id, type, song_id, payload, created_at
+				FROM song_events
+				WHERE id > $1
+				ORDER BY id
+				LIMIT $2
+			`
+
+	rows, err := p.db.Query(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("getting events err: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]*models.Event, 0, limit)
+
+	for rows.Next() {
+		event := new(models.Event)
+
+		if err := rows.Scan(&event.ID, &event.Type, &event.SongID, &event.Song, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning event err: %w", err)
+		}
+
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading events err: %w", err)
+	}
+
+	return events, nil
+}
+
+func (p *Postgres) CreateWebhook(ctx context.Context, webhook models.Webhook) (*models.Webhook, error) {
+	query := `	INSERT INTO webhooks (id, url, secret, event_types, active)
+				VALUES ($1, $2, $3, $4, $5)
+				RETURNING id, url, secret, event_types, active, created_at
+				`
+
+	if webhook.EventTypes == nil {
+		webhook.EventTypes = []string{}
+	}
+
+	created, err := scanWebhook(p.db.QueryRow(
+		ctx,
+		query,
+		webhook.ID,
+		webhook.URL,
+		webhook.Secret,
+		webhook.EventTypes,
+		webhook.Active,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("creating webhook err: %w", err)
+	}
+
+	return created, nil
+}
+
+func (p *Postgres) GetWebhooks(ctx context.Context) ([]*models.Webhook, error) {
+	query := `
+				SELECT id, url, secret, event_types, active, created_at
+				FROM webhooks
+				ORDER BY created_at
+			`
+
+	rows, err := p.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("getting webhooks err: %w", err)
+	}
+	defer rows.Close()
+
+	webhooks := make([]*models.Webhook, 0, 1)
+
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning webhook err: %w", err)
+		}
+
+		webhooks = append(webhooks, webhook)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading webhooks err: %w", err)
+	}
+
+	return webhooks, nil
+}
+
+func (p *Postgres) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	result, err := p.db.Exec(ctx, `DELETE FROM webhooks WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("deleting webhook err: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return models.ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+func scanWebhook(row pgx.Row) (*models.Webhook, error) {
+	webhook := new(models.Webhook)
+
+	err := row.Scan(
+		&webhook.ID,
+		&webhook.URL,
+		&webhook.Secret,
+		&webhook.EventTypes,
+		&webhook.Active,
+		&webhook.CreatedAt,
+	)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	return webhook, nil
+}
+
+// ClaimDeliveries picks up to limit due deliveries, counts the attempt and
+// pushes their next attempt lease into the future so that other instances
+// skip them while they are being sent. A delivery whose sender dies is
+// retried once the lease expires.
+func (p *Postgres) ClaimDeliveries(
+	ctx context.Context,
+	limit int,
+	lease time.Duration,
+) ([]*models.PendingDelivery, error) {
+	query := `
+				WITH due AS (
+					SELECT id FROM webhook_deliveries
+					WHERE status = 'pending' and next_attempt_at <= now()
+					ORDER BY next_attempt_at
+					LIMIT $1
+					FOR UPDATE SKIP LOCKED
+				)
+				UPDATE webhook_deliveries d
+				SET attempts = d.attempts + 1,
+					next_attempt_at = now() + make_interval(secs => $2::float8),
+					updated_at = now()
+				FROM due, webhooks w, song_events e
+				WHERE d.id = due.id and w.id = d.webhook_id and e.id = d.event_id
+				RETURNING d.id, d.webhook_id, d.event_id, d.status, d.attempts, d.next_attempt_at, d.updated_at,
+					w.url, w.secret, e.type, e.song_id, e.payload, e.created_at
+			`
+
+	rows, err := p.db.Query(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("claiming deliveries err: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := make([]*models.PendingDelivery, 0, limit)
+
+	for rows.Next() {
+		d := new(models.PendingDelivery)
+
+		err := rows.Scan(
+			&d.ID,
+			&d.WebhookID,
+			&d.EventID,
+			&d.Status,
+			&d.Attempts,
+			&d.NextAttemptAt,
+			&d.UpdatedAt,
+			&d.URL,
+			&d.Secret,
+			&d.Event.Type,
+			&d.Event.SongID,
+			&d.Event.Song,
+			&d.Event.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scanning delivery err: %w", err)
+		}
+
+		d.Event.ID = d.EventID
+		deliveries = append(deliveries, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading deliveries err: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+func (p *Postgres) MarkDelivered(ctx context.Context, id int64, status int) error {
+	query := `
+				UPDATE webhook_deliveries
+				SET status = 'delivered', last_status = $2, last_error = NULL, updated_at = now()
+				WHERE id = $1
+			`
+
+	if _, err := p.db.Exec(ctx, query, id, status); err != nil {
+		return fmt.Errorf("marking delivery delivered err: %w", err)
+	}
+
+	return nil
+}
+
+// MarkFailed records a failed attempt. The delivery is retried at next unless
+// dead is set, in which case it moves to the dead letter view.
+func (p *Postgres) MarkFailed(
+	ctx context.Context,
+	id int64,
+	status int,
+	reason string,
+	next time.Time,
+	dead bool,
+) error {
+	query := `
+				UPDATE webhook_deliveries
+				SET status = CASE WHEN $5::bool THEN 'dead' ELSE 'pending' END,
+					last_status = NULLIF($2::int, 0), last_error = $3, next_attempt_at = $4, updated_at = now()
+				WHERE id = $1
+			`
+
+	if _, err := p.db.Exec(ctx, query, id, status, reason, next, dead); err != nil {
+		return fmt.Errorf("marking delivery failed err: %w", err)
+	}
+
+	return nil
+}
+
+func (p *Postgres) GetDeadDeliveries(ctx context.Context, params models.Params) ([]*models.Delivery, error) {
+	query := `
+				SELECT id, webhook_id, event_id, status, attempts, COALESCE(last_status, 0), COALESCE(last_error, ''),
+					next_attempt_at, updated_at
+				FROM webhook_deliveries
+				WHERE status = 'dead'
+				ORDER BY updated_at DESC
+				OFFSET $1 LIMIT $2
+			`
+
+	rows, err := p.db.Query(ctx, query, params.Offset, params.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("getting dead deliveries err: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := make([]*models.Delivery, 0, params.Limit)
+
+	for rows.Next() {
+		d := new(models.Delivery)
+
+		err := rows.Scan(
+			&d.ID,
+			&d.WebhookID,
+			&d.EventID,
+			&d.Status,
+			&d.Attempts,
+			&d.LastStatus,
+			&d.LastError,
+			&d.NextAttemptAt,
+			&d.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scanning delivery err: %w", err)
+		}
+
+		deliveries = append(deliveries, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading deliveries err: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// RetryDelivery moves a dead delivery back to the queue with a fresh attempt
+// budget.
+func (p *Postgres) RetryDelivery(ctx context.Context, id int64) error {
+	query := `
+				UPDATE webhook_deliveries
+				SET status = 'pending', attempts = 0, next_attempt_at = now(), updated_at = now()
+				WHERE id = $1 and status = 'dead'
+			`
+
+	result, err := p.db.Exec(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("retrying delivery err: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return models.ErrDeliveryNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// ClaimLinks picks up to limit links of songs that are not deleted and are
+// due for a check, and pushes their next check interval into the future so
+// that other instances skip them.
+func (p *Postgres) ClaimLinks(ctx context.Context, limit int, interval time.Duration) ([]*models.SongLink, error) {
+	query := `
+				WITH due AS (
+					SELECT l.id FROM song_links l JOIN songs s ON s.id = l.song_id
+					WHERE s.deleted = false and l.next_check_at <= now()
+					ORDER BY l.next_check_at
+					LIMIT $1
+					FOR UPDATE OF l SKIP LOCKED
+				)
+				UPDATE song_links SET next_check_at = now() + make_interval(secs => $2::float8)
+				FROM due
+				WHERE song_links.id = due.id
+				RETURNING ` + linkColumns
+
+	rows, err := p.db.Query(ctx, query, limit, interval.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("claiming links err: %w", err)
+	}
+
+	links, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.SongLink, error) {
+		return scanLink(row)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading claimed links err: %w", err)
+	}
+
+	return links, nil
+}
+
+// RecordLinkCheck stores the outcome of a check of the link id and returns
+// it with the failures in a row counted; the link is broken once they reach
+// maxFailures and is no longer once a check succeeds.
+func (p *Postgres) RecordLinkCheck(
+	ctx context.Context,
+	id uuid.UUID,
+	check models.LinkCheck,
+	maxFailures int,
+) (*models.LinkCheck, error) {
+	query := `	UPDATE song_links
+				SET check_status = $2, redirect_url = $3, check_error = $4, checked_at = $5,
+					check_failures = CASE WHEN $6 THEN 0 ELSE check_failures + 1 END,
+					broken = NOT $6 and check_failures + 1 >= $7
+				WHERE id = $1
+				RETURNING check_status, redirect_url, check_error, checked_at, check_failures, broken`
+
+	var recorded models.LinkCheck
+
+	err := p.db.QueryRow(ctx, query, id, check.Status, check.RedirectURL, check.Error, check.CheckedAt,
+		check.OK(), maxFailures).Scan(
+		&recorded.Status,
+		&recorded.RedirectURL,
+		&recorded.Error,
+		&recorded.CheckedAt,
+		&recorded.Failures,
+		&recorded.Broken,
+	)
+
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return nil, models.ErrLinkNotFound
+	case err != nil:
+		return nil, fmt.Errorf("recording link check err: %w", err)
+	}
+
+	return &recorded, nil
+}
+
+// BrokenLinks returns the broken links of songs that are not deleted, the
+// most recently checked first.
+func (p *Postgres) BrokenLinks(ctx context.Context, params models.Params) ([]*models.BrokenLink, error) {
+	rows, err := p.reader(ctx).Query(ctx, `
+		SELECT `+linkColumnsOf("l")+`, s.name, s.music_group
+		FROM song_links l JOIN songs s ON s.id = l.song_id
+		WHERE l.broken and s.deleted = false
+		ORDER BY l.checked_at DESC, l.id
+		OFFSET $1 LIMIT $2`, params.Offset, params.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("getting broken links err: %w", err)
+	}
+
+	links, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.BrokenLink, error) {
+		var broken models.BrokenLink
+
+		link, err := scanLink(row, &broken.Name, &broken.Group)
+		if err != nil {
+			return nil, err
+		}
+
+		broken.SongLink = *link
+
+		return &broken, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading broken links err: %w", err)
+	}
+
+	return links, nil
+}
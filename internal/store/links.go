@@ -0,0 +1,458 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/logger"
+	"github.com/iurikman/songs/internal/models"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	log "github.com/sirupsen/logrus"
+)
+
+var linkColumns = linkColumnsOf("song_links")
+
+// linkColumnsOf returns the columns of the links in table, the outcome of
+// their last check included.
+func linkColumnsOf(table string) string {
+	return fmt.Sprintf(`%[1]s.id, %[1]s.song_id, %[1]s.platform::text, %[1]s.url, %[1]s.external_id, %[1]s.region,
+		%[1]s.is_primary, %[1]s.created_at, %[1]s.check_status, %[1]s.redirect_url, %[1]s.check_error,
+		%[1]s.checked_at, %[1]s.check_failures, %[1]s.broken`, table)
+}
+
+// GetLinks returns the links of a song that is not deleted, the primary one
+// first and the others in the order they were added.
+func (p *Postgres) GetLinks(ctx context.Context, songID uuid.UUID) ([]*models.SongLink, error) {
+	if err := songExists(ctx, p.reader(ctx), songID); err != nil {
+		return nil, err
+	}
+
+	rows, err := p.reader(ctx).Query(ctx, `SELECT `+linkColumns+` FROM song_links
+		WHERE song_id = $1 ORDER BY is_primary DESC, created_at, id`, songID)
+	if err != nil {
+		return nil, fmt.Errorf("getting links err: %w", err)
+	}
+
+	links, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.SongLink, error) {
+		return scanLink(row)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading links err: %w", err)
+	}
+
+	return links, nil
+}
+
+// CreateLink adds a link to a song that is not deleted. A primary link
+// replaces the primary link of the song.
+func (p *Postgres) CreateLink(ctx context.Context, link models.SongLink) (*models.SongLink, error) {
+	query := `	INSERT INTO song_links (id, song_id, platform, url, external_id, region, is_primary)
+				VALUES ($1, $2, $3::link_platform, $4, $5, $6, $7)
+				RETURNING ` + linkColumns
+
+	var created *models.SongLink
+
+	err := p.inTx(ctx, func(tx pgx.Tx) error {
+		if err := songExists(ctx, tx, link.SongID); err != nil {
+			return err
+		}
+
+		if err := clearPrimaryLink(ctx, tx, link); err != nil {
+			return err
+		}
+
+		var err error
+
+		created, err = scanLink(tx.QueryRow(ctx, query,
+			link.ID, link.SongID, link.Platform, link.URL, link.ExternalID, link.Region, link.Primary))
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		return recordLinkChange(ctx, tx, link.SongID)
+	})
+	if err != nil {
+		return nil, linkError(err, "creating link")
+	}
+
+	return created, nil
+}
+
+// UpdateLink replaces every field of a link of a song that is not deleted but
+// its id and song. A new URL is checked anew.
+func (p *Postgres) UpdateLink(ctx context.Context, link models.SongLink) (*models.SongLink, error) {
+	query := `	UPDATE song_links
+				SET platform = $3::link_platform, url = $4, external_id = $5, region = $6, is_primary = $7
+				WHERE id = $1 and song_id = $2
+				RETURNING ` + linkColumns
+
+	var updated *models.SongLink
+
+	err := p.inTx(ctx, func(tx pgx.Tx) error {
+		if err := songExists(ctx, tx, link.SongID); err != nil {
+			return err
+		}
+
+		if err := clearPrimaryLink(ctx, tx, link); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(ctx, `
+			UPDATE song_links
+			SET check_status = 0, redirect_url = '', check_error = '', checked_at = NULL, check_failures = 0,
+				broken = false, next_check_at = now()
+			WHERE id = $1 and song_id = $2 and url <> $3`, link.ID, link.SongID, link.URL)
+		if err != nil {
+			return fmt.Errorf("resetting link check err: %w", err)
+		}
+
+		updated, err = scanLink(tx.QueryRow(ctx, query,
+			link.ID, link.SongID, link.Platform, link.URL, link.ExternalID, link.Region, link.Primary))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.ErrLinkNotFound
+		}
+
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		return recordLinkChange(ctx, tx, link.SongID)
+	})
+	if err != nil {
+		return nil, linkError(err, "updating link")
+	}
+
+	return updated, nil
+}
+
+// DeleteLink removes a link of a song that is not deleted. Removing the primary link leaves the
+// song without a link.
+func (p *Postgres) DeleteLink(ctx context.Context, songID, id uuid.UUID) error {
+	err := p.inTx(ctx, func(tx pgx.Tx) error {
+		if err := songExists(ctx, tx, songID); err != nil {
+			return err
+		}
+
+		tag, err := tx.Exec(ctx, `DELETE FROM song_links WHERE id = $1 and song_id = $2`, id, songID)
+		if err != nil {
+			return fmt.Errorf("deleting link err: %w", err)
+		}
+
+		if tag.RowsAffected() == 0 {
+			return models.ErrLinkNotFound
+		}
+
+		return recordLinkChange(ctx, tx, songID)
+	})
+	if err != nil {
+		return linkError(err, "deleting link")
+	}
+
+	return nil
+}
+
+// rowQuerier is a pool or a transaction.
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// songExists returns models.ErrSongNotFound unless the song id exists and is
+// not deleted.
+func songExists(ctx context.Context, q rowQuerier, id uuid.UUID) error {
+	var exists bool
+
+	err := q.QueryRow(ctx, `SELECT exists (SELECT 1 FROM songs WHERE id = $1 and deleted = false)`, id).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("checking song err: %w", err)
+	}
+
+	if !exists {
+		return models.ErrSongNotFound
+	}
+
+	return nil
+}
+
+// clearPrimaryLink makes the primary link of the song of link, other than
+// link itself, a secondary one when link is to become the primary one.
+func clearPrimaryLink(ctx context.Context, tx pgx.Tx, link models.SongLink) error {
+	if !link.Primary {
+		return nil
+	}
+
+	_, err := tx.Exec(ctx, `UPDATE song_links SET is_primary = false WHERE song_id = $1 and is_primary and id <> $2`,
+		link.SongID, link.ID)
+	if err != nil {
+		return fmt.Errorf("clearing primary link err: %w", err)
+	}
+
+	return nil
+}
+
+// setPrimaryLink makes raw the primary link of a song, adding it when the
+// song has no such link yet, and returns its normalized URL. An empty raw
+// leaves the song without a primary link.
+func setPrimaryLink(ctx context.Context, tx pgx.Tx, songID uuid.UUID, raw string) (string, error) {
+	if raw == "" {
+		if _, err := tx.Exec(ctx, `UPDATE song_links SET is_primary = false WHERE song_id = $1 and is_primary`, songID); err != nil {
+			return "", fmt.Errorf("clearing primary link err: %w", err)
+		}
+
+		return "", nil
+	}
+
+	link, ok := primaryLink(ctx, raw)
+	if !ok {
+		return setPrimaryLink(ctx, tx, songID, "")
+	}
+
+	_, err := tx.Exec(ctx, `UPDATE song_links SET is_primary = false WHERE song_id = $1 and is_primary and url <> $2`,
+		songID, link.URL)
+	if err != nil {
+		return "", fmt.Errorf("clearing primary link err: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `	INSERT INTO song_links (id, song_id, platform, url, external_id, region, is_primary)
+							VALUES ($1, $2, $3::link_platform, $4, $5, $6, true)
+							ON CONFLICT (song_id, url) DO UPDATE SET is_primary = true`,
+		uuid.New(), songID, link.Platform, link.URL, link.ExternalID, link.Region)
+	if err != nil {
+		return "", fmt.Errorf("setting primary link err: %w", err)
+	}
+
+	return link.URL, nil
+}
+
+// primaryLink normalizes raw, the link a song is written with. It may come
+// from the details API rather than from a client, so a link the normalizer
+// rejects does not fail the write: an http or https URL is kept as a link to
+// another platform, anything else is left out and ok is false.
+func primaryLink(ctx context.Context, raw string) (link models.SongLink, ok bool) {
+	link, err := models.NormalizeLink(raw)
+	if err == nil {
+		return link, true
+	}
+
+	entry := logger.FromContext(ctx).WithError(err).WithField("link", raw)
+
+	u, parseErr := url.Parse(strings.TrimSpace(raw))
+	if parseErr != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		entry.Warn("song link left out, it is not an http or https URL")
+
+		return models.SongLink{}, false
+	}
+
+	entry.Warn("song link kept as a link to another platform")
+
+	return models.SongLink{Platform: models.PlatformOther, URL: u.String()}, true
+}
+
+// normalizeMigratedLinks normalizes the links waiting in
+// song_links_to_normalize, those moved from songs.link as they were.
+// tableExists tells whether the migration queueing them was applied, bind
+// turns the ? placeholders of a query into those of the database. A link
+// that turns out to be another link of its song is dropped for it.
+func normalizeMigratedLinks(db *sql.DB, tableExists string, bind func(query string) string) error {
+	var exists bool
+
+	if err := db.QueryRow(tableExists).Scan(&exists); err != nil {
+		return fmt.Errorf("checking song_links_to_normalize err: %w", err)
+	}
+
+	if !exists {
+		return nil
+	}
+
+	ctx := context.Background()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("db.BeginTx() err: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	rows, err := tx.QueryContext(ctx, `SELECT l.id, l.song_id, l.url, l.is_primary
+		FROM song_links_to_normalize q JOIN song_links l ON l.id = q.link_id`)
+	if err != nil {
+		return fmt.Errorf("getting links to normalize err: %w", err)
+	}
+
+	type migratedLink struct {
+		id, songID, url string
+		primary         bool
+	}
+
+	var migrated []migratedLink
+
+	for rows.Next() {
+		var link migratedLink
+
+		if err := rows.Scan(&link.id, &link.songID, &link.url, &link.primary); err != nil {
+			rows.Close()
+
+			return fmt.Errorf("scanning link err: %w", err)
+		}
+
+		migrated = append(migrated, link)
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("reading links to normalize err: %w", err)
+	}
+
+	for _, link := range migrated {
+		normalized, ok := primaryLink(ctx, link.url)
+		if !ok {
+			continue
+		}
+
+		var duplicate string
+
+		err := tx.QueryRowContext(ctx, bind(`SELECT id FROM song_links WHERE song_id = ? and url = ? and id <> ?`),
+			link.songID, normalized.URL, link.id).Scan(&duplicate)
+
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			_, err = tx.ExecContext(ctx, bind(`UPDATE song_links SET platform = ?, url = ?, external_id = ?, region = ?
+				WHERE id = ?`), normalized.Platform, normalized.URL, normalized.ExternalID, normalized.Region, link.id)
+		case err == nil:
+			_, err = tx.ExecContext(ctx, bind(`DELETE FROM song_links WHERE id = ?`), link.id)
+			if err == nil && link.primary {
+				_, err = tx.ExecContext(ctx, bind(`UPDATE song_links SET is_primary = true WHERE id = ?`), duplicate)
+			}
+		}
+
+		if err != nil {
+			return fmt.Errorf("normalizing link err: %w", err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM song_links_to_normalize`); err != nil {
+		return fmt.Errorf("clearing links to normalize err: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("tx.Commit() err: %w", err)
+	}
+
+	if len(migrated) > 0 {
+		log.WithField("links", len(migrated)).Info("Normalized the links moved from songs")
+	}
+
+	return nil
+}
+
+// numbered turns the ? placeholders of query into the $1, $2... of Postgres.
+func numbered(query string) string {
+	var (
+		b strings.Builder
+		n int
+	)
+
+	for _, r := range query {
+		if r != '?' {
+			b.WriteRune(r)
+
+			continue
+		}
+
+		n++
+		b.WriteString("$" + strconv.Itoa(n))
+	}
+
+	return b.String()
+}
+
+// moveLinks moves the links of the sources to the song id as secondary
+// links, dropping those with a URL the song or an earlier source already has.
+func moveLinks(ctx context.Context, tx pgx.Tx, id uuid.UUID, sources []uuid.UUID) error {
+	_, err := tx.Exec(ctx, `
+		DELETE FROM song_links l
+		WHERE l.song_id = ANY($2) and exists (
+			SELECT 1 FROM song_links other
+			WHERE other.url = l.url and (other.song_id = $1 or (other.song_id = ANY($2) and other.id < l.id))
+		)`, id, sources)
+	if err != nil {
+		return fmt.Errorf("dropping duplicate links err: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE song_links SET song_id = $1, is_primary = false WHERE song_id = ANY($2)`, id, sources)
+	if err != nil {
+		return fmt.Errorf("moving links err: %w", err)
+	}
+
+	return nil
+}
+
+// recordLinkChange records that the song songID was updated, since its link
+// may have changed.
+func recordLinkChange(ctx context.Context, tx pgx.Tx, songID uuid.UUID) error {
+	song, err := scanSong(tx.QueryRow(ctx, `
+		SELECT id, release_date, release_precision, name, music_group, text, primary_link(id), deleted
+		FROM songs WHERE id = $1`, songID))
+	if err != nil {
+		return fmt.Errorf("getting song err: %w", err)
+	}
+
+	return recordEvent(ctx, tx, models.EventSongUpdated, song)
+}
+
+// linkError maps the errors of link writes.
+func linkError(err error, action string) error {
+	var pgErr *pgconn.PgError
+
+	switch {
+	case errors.Is(err, models.ErrSongNotFound), errors.Is(err, models.ErrLinkNotFound):
+		return err
+	case errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation:
+		return models.ErrDuplicateLink
+	default:
+		return fmt.Errorf("%s err: %w", action, err)
+	}
+}
+
+func scanLink(row pgx.Row, extra ...any) (*models.SongLink, error) {
+	var (
+		link      models.SongLink
+		check     models.LinkCheck
+		checkedAt *time.Time
+	)
+
+	err := row.Scan(append([]any{
+		&link.ID,
+		&link.SongID,
+		&link.Platform,
+		&link.URL,
+		&link.ExternalID,
+		&link.Region,
+		&link.Primary,
+		&link.CreatedAt,
+		&check.Status,
+		&check.RedirectURL,
+		&check.Error,
+		&checkedAt,
+		&check.Failures,
+		&check.Broken,
+	}, extra...)...)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	if checkedAt != nil {
+		check.CheckedAt = *checkedAt
+		link.Check = &check
+	}
+
+	return &link, nil
+}
@@ -0,0 +1,164 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/iurikman/songs/internal/models"
+	"github.com/jackc/pgx/v5"
+	log "github.com/sirupsen/logrus"
+)
+
+// ChannelSongsChanged is notified with a JSON models.Change in the transaction
+// of every song write.
+const ChannelSongsChanged = "songs_changed"
+
+const (
+	listenerMinBackoff = 500 * time.Millisecond
+	listenerMaxBackoff = 30 * time.Second
+	listenerCloseWait  = 5 * time.Second
+)
+
+// Invalidator is implemented by local caches that must forget songs written
+// by any instance.
+type Invalidator interface {
+	Invalidate(change models.Change)
+	// InvalidateAll is called when notifications may have been missed, after
+	// the listener (re)connects.
+	InvalidateAll()
+}
+
+// Listener receives ChannelSongsChanged notifications on a dedicated
+// connection, outside of the pool, and hands them to its subscribers.
+type Listener struct {
+	connConfig *pgx.ConnConfig
+
+	mu          sync.RWMutex
+	subscribers []Invalidator
+	connected   atomic.Bool
+}
+
+func (p *Postgres) Listener() (*Listener, error) {
+	connConfig, err := pgx.ParseConfig(p.dsn)
+	if err != nil {
+		return nil, fmt.Errorf("pgx.ParseConfig(dsn) err: %w", err)
+	}
+
+	connConfig.Tracer = queryTracer{}
+
+	return &Listener{connConfig: connConfig}, nil
+}
+
+func (l *Listener) Subscribe(inv Invalidator) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.subscribers = append(l.subscribers, inv)
+}
+
+// Connected reports whether the listener currently receives notifications.
+func (l *Listener) Connected() bool {
+	return l.connected.Load()
+}
+
+// Run listens until ctx is done, reconnecting with exponential backoff when
+// the connection is lost.
+func (l *Listener) Run(ctx context.Context) error {
+	backoff := listenerMinBackoff
+
+	for {
+		err := l.listen(ctx, func() { backoff = listenerMinBackoff })
+
+		l.connected.Store(false)
+
+		if ctx.Err() != nil {
+			log.Info("songs listener stopped")
+
+			return nil
+		}
+
+		log.WithError(err).WithField("retry_in", backoff.String()).Warn("songs listener disconnected")
+
+		select {
+		case <-ctx.Done():
+			log.Info("songs listener stopped")
+
+			return nil
+		case <-time.After(backoff):
+		}
+
+		backoff = min(2*backoff, listenerMaxBackoff)
+	}
+}
+
+// listen runs one connection until it fails; onListening is called once the
+// LISTEN is in place.
+func (l *Listener) listen(ctx context.Context, onListening func()) error {
+	conn, err := pgx.ConnectConfig(ctx, l.connConfig)
+	if err != nil {
+		return fmt.Errorf("pgx.ConnectConfig err: %w", err)
+	}
+
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), listenerCloseWait)
+		defer cancel()
+
+		if err := conn.Close(closeCtx); err != nil {
+			log.Warnf("listener conn.Close err: %v", err)
+		}
+	}()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+ChannelSongsChanged); err != nil {
+		return fmt.Errorf("LISTEN %s err: %w", ChannelSongsChanged, err)
+	}
+
+	l.connected.Store(true)
+	onListening()
+
+	log.WithField("channel", ChannelSongsChanged).Info("songs listener connected")
+
+	// Anything written while we were not listening is unknown.
+	l.notifyAll()
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("conn.WaitForNotification err: %w", err)
+		}
+
+		var change models.Change
+
+		if err := json.Unmarshal([]byte(notification.Payload), &change); err != nil {
+			log.WithError(err).WithField("payload", notification.Payload).Warn("malformed songs notification")
+			l.notifyAll()
+
+			continue
+		}
+
+		log.WithFields(log.Fields{"type": change.Type, "song_id": change.SongID}).Debug("song changed")
+
+		l.notify(change)
+	}
+}
+
+func (l *Listener) notify(change models.Change) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	for _, inv := range l.subscribers {
+		inv.Invalidate(change)
+	}
+}
+
+func (l *Listener) notifyAll() {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	for _, inv := range l.subscribers {
+		inv.InvalidateAll()
+	}
+}
@@ -0,0 +1,445 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/logger"
+	"github.com/iurikman/songs/internal/models"
+)
+
+// Memory keeps songs in process memory with the semantics of Postgres: songs
+// are soft deleted, (release_date, name, music_group) and id stay unique
+// across deleted songs too, and listing filters, sorts and pages the same
+// way. Songs without an explicit sorting are listed in insertion order.
+// Songs merged into another one no longer hold their key.
+type Memory struct {
+	mu    sync.RWMutex
+	songs []*models.Song
+	byID  map[uuid.UUID]*models.Song
+	// mergedInto maps merged songs to the song they were merged into.
+	mergedInto map[uuid.UUID]uuid.UUID
+	// links are the links of songs in the order they were added; the Link of
+	// a stored song is the URL of its primary link.
+	links map[uuid.UUID][]*models.SongLink
+	// nextCheck is when links are due for a check; links missing are due.
+	nextCheck map[uuid.UUID]time.Time
+	tags      map[uuid.UUID]*models.Tag
+	// songTags are the ids of the tags of songs.
+	songTags map[uuid.UUID][]uuid.UUID
+	// createdAt is when songs were created.
+	createdAt map[uuid.UUID]time.Time
+	// terms count the terms of the lyrics of songs.
+	terms map[uuid.UUID]map[string]int
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		byID:       make(map[uuid.UUID]*models.Song),
+		mergedInto: make(map[uuid.UUID]uuid.UUID),
+		links:      make(map[uuid.UUID][]*models.SongLink),
+		nextCheck:  make(map[uuid.UUID]time.Time),
+		tags:       make(map[uuid.UUID]*models.Tag),
+		songTags:   make(map[uuid.UUID][]uuid.UUID),
+		createdAt:  make(map[uuid.UUID]time.Time),
+		terms:      make(map[uuid.UUID]map[string]int),
+	}
+}
+
+// songKey is the unique key of a song. Like in the SQL stores a release date
+// is compared by its first day only.
+type songKey struct {
+	releaseDate time.Time
+	name, group string
+}
+
+func keyOf(song *models.Song) songKey {
+	return songKey{releaseDate: song.ReleaseDate.Date, name: song.Name, group: song.Group}
+}
+
+func (m *Memory) CreateSong(ctx context.Context, song models.Song) (*models.Song, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.conflicts(&song) {
+		return nil, models.ErrDuplicateSong
+	}
+
+	if _, ok := m.byID[song.ID]; ok {
+		return nil, models.ErrDuplicateSong
+	}
+
+	stored := song
+	stored.Link = ""
+	m.songs = append(m.songs, &stored)
+	m.byID[song.ID] = &stored
+	m.createdAt[song.ID] = time.Now().UTC()
+	m.terms[song.ID] = lyricTerms(song.Text)
+
+	m.setPrimaryLink(ctx, song.ID, song.Link)
+
+	created := stored
+
+	return &created, nil
+}
+
+func (m *Memory) GetSongs(ctx context.Context, params models.Params) ([]*models.Song, error) {
+	var column string
+
+	if params.Sorting != "" {
+		var ok bool
+
+		column, ok = sortColumns[params.Sorting]
+		if !ok {
+			return nil, models.ErrInvalidSorting
+		}
+	}
+
+	logger.FromContext(ctx).WithField("params", params).Debug("listing songs")
+
+	m.mu.RLock()
+	tagged := m.tagFilter(params)
+	songs := m.alive(func(song *models.Song) bool {
+		return strings.Contains(song.Name, params.Filter) && releasedWithin(song.ReleaseDate, params) && tagged(song.ID)
+	})
+	m.mu.RUnlock()
+
+	if column != "" {
+		slices.SortStableFunc(songs, func(a, b *models.Song) int {
+			return compareColumn(a, b, column, params.Descending)
+		})
+	}
+
+	return page(songs, params), nil
+}
+
+func (m *Memory) GetSongsByIDs(_ context.Context, ids []uuid.UUID) ([]*models.Song, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.alive(func(song *models.Song) bool {
+		return slices.Contains(ids, song.ID)
+	}), nil
+}
+
+func (m *Memory) GetSongsByGroups(_ context.Context, groups []string) ([]*models.Song, error) {
+	m.mu.RLock()
+	songs := m.alive(func(song *models.Song) bool {
+		return slices.Contains(groups, song.Group)
+	})
+	m.mu.RUnlock()
+
+	slices.SortStableFunc(songs, func(a, b *models.Song) int {
+		return cmp.Or(cmp.Compare(a.Group, b.Group), cmp.Compare(a.Name, b.Name))
+	})
+
+	return songs, nil
+}
+
+func (m *Memory) GetGroups(_ context.Context, params models.Params) ([]string, error) {
+	m.mu.RLock()
+
+	var groups []string
+
+	for _, song := range m.songs {
+		if !song.Deleted && strings.Contains(song.Group, params.Filter) && !slices.Contains(groups, song.Group) {
+			groups = append(groups, song.Group)
+		}
+	}
+
+	m.mu.RUnlock()
+
+	slices.Sort(groups)
+
+	if params.Descending {
+		slices.Reverse(groups)
+	}
+
+	return page(groups, params), nil
+}
+
+func (m *Memory) GetText(ctx context.Context, id uuid.UUID, verse int) (*string, error) {
+	m.mu.RLock()
+	song, ok := m.byID[id]
+	ok = ok && !song.Deleted
+
+	var text string
+	if ok {
+		text = song.Text
+	}
+	m.mu.RUnlock()
+
+	if !ok {
+		return nil, models.ErrSongNotFound
+	}
+
+	verses := models.SplitVerses(text)
+
+	if verse < 1 || verse > len(verses) {
+		logger.FromContext(ctx).Debugf("verse %d out of range, song has %d verses", verse, len(verses))
+
+		return nil, models.ErrVerseIsNotValid
+	}
+
+	return &verses[verse-1], nil
+}
+
+func (m *Memory) DeleteSong(_ context.Context, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	song, ok := m.byID[id]
+	if !ok || song.Deleted {
+		return models.ErrSongNotFound
+	}
+
+	song.Deleted = true
+
+	return nil
+}
+
+// UpdateSong replaces every field but id and deleted, like the Postgres
+// store does. Deleted songs, merged ones included, are not found.
+func (m *Memory) UpdateSong(ctx context.Context, id uuid.UUID, song models.Song) (*models.Song, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.byID[id]
+	if !ok || stored.Deleted {
+		return nil, models.ErrSongNotFound
+	}
+
+	if m.conflicts(&song, id) {
+		return nil, models.ErrDuplicateSong
+	}
+
+	stored.ReleaseDate = song.ReleaseDate
+	stored.Name = song.Name
+	stored.Group = song.Group
+	stored.Text = song.Text
+	m.terms[id] = lyricTerms(song.Text)
+
+	m.setPrimaryLink(ctx, id, song.Link)
+
+	updated := *stored
+
+	return &updated, nil
+}
+
+// MergeSongs replaces the song id with song and merges the sources into it:
+// they are deleted and point at it, as do the songs merged into them before.
+// The links of the sources move to the song, but for those it already has, and
+// so do their tags.
+func (m *Memory) MergeSongs(ctx context.Context, id uuid.UUID, song models.Song, sources []uuid.UUID) (*models.Song, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, songID := range append([]uuid.UUID{id}, sources...) {
+		if stored, ok := m.byID[songID]; !ok || stored.Deleted {
+			return nil, models.ErrSongNotFound
+		}
+	}
+
+	if m.conflicts(&song, append([]uuid.UUID{id}, sources...)...) {
+		return nil, models.ErrDuplicateSong
+	}
+
+	for _, source := range sources {
+		m.byID[source].Deleted = true
+		m.mergedInto[source] = id
+		m.moveLinks(id, source)
+		m.moveTags(id, source)
+	}
+
+	for merged, into := range m.mergedInto {
+		if slices.Contains(sources, into) {
+			m.mergedInto[merged] = id
+		}
+	}
+
+	stored := m.byID[id]
+	stored.ReleaseDate = song.ReleaseDate
+	stored.Name = song.Name
+	stored.Group = song.Group
+	stored.Text = song.Text
+	m.terms[id] = lyricTerms(song.Text)
+
+	m.setPrimaryLink(ctx, id, song.Link)
+
+	merged := *stored
+
+	return &merged, nil
+}
+
+// MergedInto returns the song the song id was merged into.
+func (m *Memory) MergedInto(_ context.Context, id uuid.UUID) (uuid.UUID, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	into, ok := m.mergedInto[id]
+	if !ok {
+		return uuid.Nil, models.ErrSongNotFound
+	}
+
+	return into, nil
+}
+
+// SimilarSongs returns up to limit songs that are probably duplicates of
+// song, most similar first.
+func (m *Memory) SimilarSongs(_ context.Context, song models.Song, threshold float64, limit int) ([]*models.SimilarSong, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	similar := make([]*models.SimilarSong, 0, 1)
+
+	for _, other := range m.songs {
+		if other.Deleted || other.ID == song.ID {
+			continue
+		}
+
+		if score, ok := probableDuplicate(&song, other, threshold); ok {
+			similar = append(similar, &models.SimilarSong{Song: *other, Similarity: score})
+		}
+	}
+
+	slices.SortStableFunc(similar, func(a, b *models.SimilarSong) int {
+		return cmp.Or(cmp.Compare(b.Similarity, a.Similarity), cmp.Compare(a.Name, b.Name))
+	})
+
+	return similar[:min(limit, len(similar))], nil
+}
+
+// SimilarSongPairs returns every pair of songs that are probably duplicates.
+func (m *Memory) SimilarSongPairs(_ context.Context, threshold float64) ([]models.SongPair, error) {
+	m.mu.RLock()
+	songs := m.alive(func(*models.Song) bool { return true })
+	m.mu.RUnlock()
+
+	pairs := make([]models.SongPair, 0)
+
+	for i, a := range songs {
+		for _, b := range songs[i+1:] {
+			if score, ok := probableDuplicate(a, b, threshold); ok {
+				pairs = append(pairs, models.SongPair{A: a.ID, B: b.ID, Similarity: score})
+			}
+		}
+	}
+
+	return pairs, nil
+}
+
+// Name, Ping and PendingMigrations let the health endpoints probe Memory like
+// a database; it is always up and has no schema.
+func (m *Memory) Name() string {
+	return "memory"
+}
+
+func (m *Memory) Ping(context.Context) error {
+	return nil
+}
+
+func (m *Memory) PendingMigrations(context.Context) (int, error) {
+	return 0, nil
+}
+
+// conflicts reports whether a song not merged and other than except already
+// uses the unique key of song. It must be called with m.mu held.
+func (m *Memory) conflicts(song *models.Song, except ...uuid.UUID) bool {
+	key := keyOf(song)
+
+	for _, other := range m.songs {
+		if _, merged := m.mergedInto[other.ID]; merged || slices.Contains(except, other.ID) {
+			continue
+		}
+
+		if keyOf(other) == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// alive returns copies of the songs that are not deleted and match keep, in
+// insertion order. It must be called with m.mu held.
+func (m *Memory) alive(keep func(song *models.Song) bool) []*models.Song {
+	songs := make([]*models.Song, 0, 1)
+
+	for _, song := range m.songs {
+		if song.Deleted || !keep(song) {
+			continue
+		}
+
+		c := *song
+		songs = append(songs, &c)
+	}
+
+	return songs
+}
+
+// releasedWithin reports whether date falls in the release date range of
+// params; unknown dates never do.
+func releasedWithin(date models.ReleaseDate, params models.Params) bool {
+	if !params.ReleasedFrom.IsZero() && (date.IsZero() || date.Date.Before(params.ReleasedFrom.Date)) {
+		return false
+	}
+
+	if !params.ReleasedTo.IsZero() && (date.IsZero() || date.Date.After(params.ReleasedTo.End())) {
+		return false
+	}
+
+	return true
+}
+
+// compareColumn orders songs by column like the SQL stores: release dates
+// chronologically, unknown ones last in either direction.
+func compareColumn(a, b *models.Song, column string, descending bool) int {
+	var c int
+
+	switch column {
+	case "id":
+		c = cmp.Compare(a.ID.String(), b.ID.String())
+	case "name":
+		c = cmp.Compare(a.Name, b.Name)
+	case "music_group":
+		c = cmp.Compare(a.Group, b.Group)
+	default:
+		if a.ReleaseDate.IsZero() || b.ReleaseDate.IsZero() {
+			return compareBool(a.ReleaseDate.IsZero(), b.ReleaseDate.IsZero())
+		}
+
+		c = a.ReleaseDate.Date.Compare(b.ReleaseDate.Date)
+	}
+
+	if descending {
+		return -c
+	}
+
+	return c
+}
+
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	default:
+		return -1
+	}
+}
+
+func page[T any](items []T, params models.Params) []T {
+	if params.Offset >= len(items) {
+		return []T{}
+	}
+
+	end := min(params.Offset+params.Limit, len(items))
+
+	return items[params.Offset:end]
+}
@@ -0,0 +1,137 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/models"
+)
+
+// ClaimLinks picks up to limit links of songs that are not deleted and are
+// due for a check, and makes them due again after interval.
+func (m *Memory) ClaimLinks(_ context.Context, limit int, interval time.Duration) ([]*models.SongLink, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+
+	var due []*models.SongLink
+
+	for _, song := range m.songs {
+		if song.Deleted {
+			continue
+		}
+
+		for _, link := range m.links[song.ID] {
+			if !m.nextCheck[link.ID].After(now) {
+				due = append(due, link)
+			}
+		}
+	}
+
+	slices.SortStableFunc(due, func(a, b *models.SongLink) int {
+		return m.nextCheck[a.ID].Compare(m.nextCheck[b.ID])
+	})
+
+	links := make([]*models.SongLink, 0, min(limit, len(due)))
+
+	for _, link := range due[:min(limit, len(due))] {
+		m.nextCheck[link.ID] = now.Add(interval)
+
+		links = append(links, copyLink(link))
+	}
+
+	return links, nil
+}
+
+// RecordLinkCheck stores the outcome of a check of the link id and returns
+// it with the failures in a row counted; the link is broken once they reach
+// maxFailures and is no longer once a check succeeds.
+func (m *Memory) RecordLinkCheck(
+	_ context.Context,
+	id uuid.UUID,
+	check models.LinkCheck,
+	maxFailures int,
+) (*models.LinkCheck, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	link := m.link(id)
+	if link == nil {
+		return nil, models.ErrLinkNotFound
+	}
+
+	recorded := check
+	recorded.Failures = 0
+
+	if !check.OK() {
+		if link.Check != nil {
+			recorded.Failures = link.Check.Failures
+		}
+
+		recorded.Failures++
+	}
+
+	recorded.Broken = recorded.Failures >= maxFailures && recorded.Failures > 0
+	link.Check = &recorded
+
+	c := recorded
+
+	return &c, nil
+}
+
+// BrokenLinks returns the broken links of songs that are not deleted, the
+// most recently checked first.
+func (m *Memory) BrokenLinks(_ context.Context, params models.Params) ([]*models.BrokenLink, error) {
+	m.mu.RLock()
+
+	links := make([]*models.BrokenLink, 0, 1)
+
+	for _, song := range m.songs {
+		if song.Deleted {
+			continue
+		}
+
+		for _, link := range m.links[song.ID] {
+			if link.Check != nil && link.Check.Broken {
+				links = append(links, &models.BrokenLink{SongLink: *copyLink(link), Name: song.Name, Group: song.Group})
+			}
+		}
+	}
+
+	m.mu.RUnlock()
+
+	slices.SortStableFunc(links, func(a, b *models.BrokenLink) int {
+		return cmp.Compare(b.Check.CheckedAt.UnixNano(), a.Check.CheckedAt.UnixNano())
+	})
+
+	return page(links, params), nil
+}
+
+// link returns the link id of any song. It must be called with m.mu held.
+func (m *Memory) link(id uuid.UUID) *models.SongLink {
+	for _, links := range m.links {
+		for _, link := range links {
+			if link.ID == id {
+				return link
+			}
+		}
+	}
+
+	return nil
+}
+
+// copyLink copies a stored link, its check included.
+func copyLink(link *models.SongLink) *models.SongLink {
+	c := *link
+
+	if link.Check != nil {
+		check := *link.Check
+		c.Check = &check
+	}
+
+	return &c
+}
@@ -0,0 +1,201 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/models"
+)
+
+// GetLinks returns the links of a song that is not deleted, the primary one
+// first and the others in the order they were added.
+func (m *Memory) GetLinks(_ context.Context, songID uuid.UUID) ([]*models.SongLink, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if !m.exists(songID) {
+		return nil, models.ErrSongNotFound
+	}
+
+	links := make([]*models.SongLink, 0, len(m.links[songID]))
+
+	for _, link := range m.links[songID] {
+		links = append(links, copyLink(link))
+	}
+
+	slices.SortStableFunc(links, func(a, b *models.SongLink) int {
+		return compareBool(!a.Primary, !b.Primary)
+	})
+
+	return links, nil
+}
+
+// CreateLink adds a link to a song that is not deleted. A primary link
+// replaces the primary link of the song.
+func (m *Memory) CreateLink(_ context.Context, link models.SongLink) (*models.SongLink, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.exists(link.SongID) {
+		return nil, models.ErrSongNotFound
+	}
+
+	for _, other := range m.links[link.SongID] {
+		if other.ID == link.ID || other.URL == link.URL {
+			return nil, models.ErrDuplicateLink
+		}
+	}
+
+	stored := link
+	stored.CreatedAt = time.Now().UTC()
+	stored.Check = nil
+	m.links[link.SongID] = append(m.links[link.SongID], &stored)
+	m.syncPrimaryLink(&stored)
+
+	created := stored
+
+	return &created, nil
+}
+
+// UpdateLink replaces every field of a link of a song that is not deleted but
+// its id, song and creation time. A new URL is checked anew.
+func (m *Memory) UpdateLink(_ context.Context, link models.SongLink) (*models.SongLink, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.exists(link.SongID) {
+		return nil, models.ErrSongNotFound
+	}
+
+	links := m.links[link.SongID]
+
+	i := slices.IndexFunc(links, func(other *models.SongLink) bool { return other.ID == link.ID })
+	if i < 0 {
+		return nil, models.ErrLinkNotFound
+	}
+
+	if slices.ContainsFunc(links, func(other *models.SongLink) bool { return other.ID != link.ID && other.URL == link.URL }) {
+		return nil, models.ErrDuplicateLink
+	}
+
+	stored := links[i]
+
+	if stored.URL != link.URL {
+		stored.Check = nil
+		delete(m.nextCheck, stored.ID)
+	}
+
+	stored.Platform = link.Platform
+	stored.URL = link.URL
+	stored.ExternalID = link.ExternalID
+	stored.Region = link.Region
+	stored.Primary = link.Primary
+	m.syncPrimaryLink(stored)
+
+	return copyLink(stored), nil
+}
+
+// DeleteLink removes a link of a song that is not deleted. Removing the
+// primary link leaves the song without a link.
+func (m *Memory) DeleteLink(_ context.Context, songID, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.exists(songID) {
+		return models.ErrSongNotFound
+	}
+
+	i := slices.IndexFunc(m.links[songID], func(link *models.SongLink) bool { return link.ID == id })
+	if i < 0 {
+		return models.ErrLinkNotFound
+	}
+
+	if m.links[songID][i].Primary {
+		m.byID[songID].Link = ""
+	}
+
+	m.links[songID] = slices.Delete(m.links[songID], i, i+1)
+
+	return nil
+}
+
+// exists reports whether the song id exists and is not deleted. It must be
+// called with m.mu held.
+func (m *Memory) exists(id uuid.UUID) bool {
+	song, ok := m.byID[id]
+
+	return ok && !song.Deleted
+}
+
+// syncPrimaryLink makes the other links of the song of link secondary ones
+// when link is primary and keeps the Link of the song the URL of its primary
+// link. It must be called with m.mu held.
+func (m *Memory) syncPrimaryLink(link *models.SongLink) {
+	song := m.byID[link.SongID]
+	song.Link = ""
+
+	for _, other := range m.links[link.SongID] {
+		if link.Primary && other != link {
+			other.Primary = false
+		}
+
+		if other.Primary {
+			song.Link = other.URL
+		}
+	}
+}
+
+// setPrimaryLink makes raw the primary link of the song id, adding it when
+// the song has no such link yet. An empty raw, or one primaryLink leaves out,
+// leaves the song without a primary link. It must be called with m.mu held.
+func (m *Memory) setPrimaryLink(ctx context.Context, id uuid.UUID, raw string) {
+	var (
+		normalized models.SongLink
+		ok         bool
+	)
+
+	if raw != "" {
+		normalized, ok = primaryLink(ctx, raw)
+	}
+
+	if !ok {
+		for _, link := range m.links[id] {
+			link.Primary = false
+		}
+
+		m.byID[id].Link = ""
+
+		return
+	}
+
+	i := slices.IndexFunc(m.links[id], func(link *models.SongLink) bool { return link.URL == normalized.URL })
+	if i < 0 {
+		normalized.ID = uuid.New()
+		normalized.SongID = id
+		normalized.CreatedAt = time.Now().UTC()
+		m.links[id] = append(m.links[id], &normalized)
+		i = len(m.links[id]) - 1
+	}
+
+	m.links[id][i].Primary = true
+	m.syncPrimaryLink(m.links[id][i])
+}
+
+// moveLinks moves the links of source to the song id as secondary links,
+// dropping those with a URL the song already has. It must be called with
+// m.mu held.
+func (m *Memory) moveLinks(id, source uuid.UUID) {
+	for _, link := range m.links[source] {
+		if slices.ContainsFunc(m.links[id], func(other *models.SongLink) bool { return other.URL == link.URL }) {
+			continue
+		}
+
+		link.SongID = id
+		link.Primary = false
+		m.links[id] = append(m.links[id], link)
+	}
+
+	delete(m.links, source)
+}
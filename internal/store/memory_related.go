@@ -0,0 +1,47 @@
+package store
+
+import (
+	"context"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/models"
+)
+
+// RelatedSongs returns the songs whose lyrics are like those of the song id,
+// the highest scores first and paged by params. Songs without a term in
+// common are left out.
+func (m *Memory) RelatedSongs(_ context.Context, id uuid.UUID, params models.Params) ([]*models.RelatedSong, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if !m.exists(id) {
+		return nil, models.ErrSongNotFound
+	}
+
+	songs := m.alive(func(*models.Song) bool { return true })
+	docs := make([]relatedDoc, 0, len(songs))
+	byID := make(map[uuid.UUID]*models.Song, len(songs))
+
+	for _, song := range songs {
+		byID[song.ID] = song
+		docs = append(docs, relatedDoc{
+			id:    song.ID,
+			name:  song.Name,
+			group: song.Group,
+			terms: m.terms[song.ID],
+			sharesTag: slices.ContainsFunc(m.songTags[song.ID], func(tagID uuid.UUID) bool {
+				return slices.Contains(m.songTags[id], tagID)
+			}),
+		})
+	}
+
+	ranked := rankRelated(id, docs, params)
+	related := make([]*models.RelatedSong, 0, len(ranked))
+
+	for _, scored := range ranked {
+		related = append(related, &models.RelatedSong{Song: *byID[scored.id], Similarity: scored.similarity, Score: scored.score})
+	}
+
+	return related, nil
+}
@@ -0,0 +1,28 @@
+package store
+
+import (
+	"context"
+
+	"github.com/iurikman/songs/internal/models"
+)
+
+// GetStats computes the statistics of the songs, ByGroup paged by params.
+func (m *Memory) GetStats(_ context.Context, params models.Params) (*models.Stats, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	builder := newStatsBuilder()
+
+	for _, song := range m.alive(func(*models.Song) bool { return true }) {
+		builder.add(songStats{
+			group:       song.Group,
+			releaseDate: song.ReleaseDate,
+			createdAt:   m.createdAt[song.ID],
+			text:        song.Text,
+			hasLink:     song.Link != "",
+			hasTags:     len(m.songTags[song.ID]) > 0,
+		})
+	}
+
+	return builder.build(params), nil
+}
@@ -0,0 +1,260 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/models"
+)
+
+// GetTags returns every tag, ordered by slug.
+func (m *Memory) GetTags(_ context.Context) ([]*models.Tag, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.sortedTags(func(*models.Tag) bool { return true }), nil
+}
+
+// CreateTag adds a tag under its parent, models.ErrUnknownTag when there is no
+// such tag.
+func (m *Memory) CreateTag(_ context.Context, tag models.Tag) (*models.Tag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.tags[tag.ID]; ok || m.tagBySlug(tag.Slug) != nil {
+		return nil, models.ErrDuplicateTag
+	}
+
+	if tag.ParentID != nil && m.tags[*tag.ParentID] == nil {
+		return nil, models.ErrUnknownTag
+	}
+
+	m.tags[tag.ID] = copyTag(&tag)
+
+	return copyTag(&tag), nil
+}
+
+// UpdateTag replaces the slug, name and parent of a tag. A tag cannot move
+// under itself or one of its descendants.
+func (m *Memory) UpdateTag(_ context.Context, tag models.Tag) (*models.Tag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.tags[tag.ID] == nil {
+		return nil, models.ErrTagNotFound
+	}
+
+	if tag.ParentID != nil {
+		if slices.Contains(m.subtree(tag.ID), *tag.ParentID) {
+			return nil, models.ErrTagCycle
+		}
+
+		if m.tags[*tag.ParentID] == nil {
+			return nil, models.ErrUnknownTag
+		}
+	}
+
+	if other := m.tagBySlug(tag.Slug); other != nil && other.ID != tag.ID {
+		return nil, models.ErrDuplicateTag
+	}
+
+	m.tags[tag.ID] = copyTag(&tag)
+
+	return copyTag(&tag), nil
+}
+
+// DeleteTag removes a tag from the songs that have it. Its children move up
+// to its parent.
+func (m *Memory) DeleteTag(_ context.Context, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tag, ok := m.tags[id]
+	if !ok {
+		return models.ErrTagNotFound
+	}
+
+	for _, child := range m.tags {
+		if child.ParentID != nil && *child.ParentID == id {
+			child.ParentID = tag.ParentID
+		}
+	}
+
+	delete(m.tags, id)
+
+	for songID, tags := range m.songTags {
+		m.songTags[songID] = slices.DeleteFunc(tags, func(tagID uuid.UUID) bool { return tagID == id })
+	}
+
+	return nil
+}
+
+// GetSongTags returns the tags of a song that is not deleted, ordered by slug.
+func (m *Memory) GetSongTags(_ context.Context, songID uuid.UUID) ([]*models.Tag, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if !m.exists(songID) {
+		return nil, models.ErrSongNotFound
+	}
+
+	return m.sortedTags(func(tag *models.Tag) bool { return slices.Contains(m.songTags[songID], tag.ID) }), nil
+}
+
+// SetSongTags replaces the tags of a song that is not deleted with those of
+// the slugs, models.ErrUnknownTag when one of them is not a tag.
+func (m *Memory) SetSongTags(_ context.Context, songID uuid.UUID, slugs []string) ([]*models.Tag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.exists(songID) {
+		return nil, models.ErrSongNotFound
+	}
+
+	ids := make([]uuid.UUID, 0, len(slugs))
+
+	for _, slug := range slugs {
+		tag := m.tagBySlug(slug)
+		if tag == nil {
+			return nil, models.ErrUnknownTag
+		}
+
+		if !slices.Contains(ids, tag.ID) {
+			ids = append(ids, tag.ID)
+		}
+	}
+
+	m.songTags[songID] = ids
+
+	return m.sortedTags(func(tag *models.Tag) bool { return slices.Contains(ids, tag.ID) }), nil
+}
+
+// TagFacets counts per tag the songs params list, ignoring paging and sorting.
+func (m *Memory) TagFacets(_ context.Context, params models.Params) ([]*models.TagFacet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tagged := m.tagFilter(params)
+	songs := m.alive(func(song *models.Song) bool {
+		return strings.Contains(song.Name, params.Filter) && releasedWithin(song.ReleaseDate, params) && tagged(song.ID)
+	})
+
+	facets := make([]*models.TagFacet, 0, 1)
+
+	for _, tag := range m.tags {
+		subtree := m.subtree(tag.ID)
+
+		var count int
+
+		for _, song := range songs {
+			if slices.ContainsFunc(m.songTags[song.ID], func(id uuid.UUID) bool { return slices.Contains(subtree, id) }) {
+				count++
+			}
+		}
+
+		if count > 0 {
+			facets = append(facets, &models.TagFacet{Tag: *copyTag(tag), Count: count})
+		}
+	}
+
+	slices.SortFunc(facets, func(a, b *models.TagFacet) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Slug, b.Slug))
+	})
+
+	return facets, nil
+}
+
+// tagFilter returns whether a song has the tag params filter songs by, or one
+// of its descendants when asked for. It must be called with m.mu held.
+func (m *Memory) tagFilter(params models.Params) func(songID uuid.UUID) bool {
+	if params.Tag == "" {
+		return func(uuid.UUID) bool { return true }
+	}
+
+	tag := m.tagBySlug(params.Tag)
+	if tag == nil {
+		return func(uuid.UUID) bool { return false }
+	}
+
+	wanted := []uuid.UUID{tag.ID}
+	if params.Descendants {
+		wanted = m.subtree(tag.ID)
+	}
+
+	return func(songID uuid.UUID) bool {
+		return slices.ContainsFunc(m.songTags[songID], func(id uuid.UUID) bool { return slices.Contains(wanted, id) })
+	}
+}
+
+// subtree returns the tag id and its descendants. It must be called with m.mu
+// held.
+func (m *Memory) subtree(id uuid.UUID) []uuid.UUID {
+	subtree := []uuid.UUID{id}
+
+	for i := 0; i < len(subtree); i++ {
+		for _, tag := range m.tags {
+			if tag.ParentID != nil && *tag.ParentID == subtree[i] {
+				subtree = append(subtree, tag.ID)
+			}
+		}
+	}
+
+	return subtree
+}
+
+// moveTags gives the song id the tags of the source, which loses them. It must
+// be called with m.mu held.
+func (m *Memory) moveTags(id, source uuid.UUID) {
+	for _, tagID := range m.songTags[source] {
+		if !slices.Contains(m.songTags[id], tagID) {
+			m.songTags[id] = append(m.songTags[id], tagID)
+		}
+	}
+
+	delete(m.songTags, source)
+}
+
+// tagBySlug returns the tag of the slug, nil when there is none. It must be
+// called with m.mu held.
+func (m *Memory) tagBySlug(slug string) *models.Tag {
+	for _, tag := range m.tags {
+		if tag.Slug == slug {
+			return tag
+		}
+	}
+
+	return nil
+}
+
+// sortedTags returns copies of the tags that match keep, ordered by slug. It
+// must be called with m.mu held.
+func (m *Memory) sortedTags(keep func(tag *models.Tag) bool) []*models.Tag {
+	tags := make([]*models.Tag, 0, 1)
+
+	for _, tag := range m.tags {
+		if keep(tag) {
+			tags = append(tags, copyTag(tag))
+		}
+	}
+
+	slices.SortFunc(tags, func(a, b *models.Tag) int {
+		return cmp.Compare(a.Slug, b.Slug)
+	})
+
+	return tags
+}
+
+// copyTag copies a tag, its parent id included.
+func copyTag(tag *models.Tag) *models.Tag {
+	c := *tag
+
+	if tag.ParentID != nil {
+		parentID := *tag.ParentID
+		c.ParentID = &parentID
+	}
+
+	return &c
+}
@@ -0,0 +1,42 @@
+-- +migrate Up
+
+CREATE TABLE song_events (
+    id bigserial primary key,
+    song_id uuid not null,
+    type varchar not null,
+    payload jsonb not null,
+    created_at timestamptz not null default now()
+);
+
+CREATE INDEX song_events_song_id_idx ON song_events (song_id);
+
+CREATE TABLE webhooks (
+    id uuid primary key,
+    url varchar not null,
+    secret varchar not null,
+    event_types varchar[] not null default '{}',
+    active bool not null default true,
+    created_at timestamptz not null default now()
+);
+
+CREATE TABLE webhook_deliveries (
+    id bigserial primary key,
+    webhook_id uuid not null references webhooks (id) ON DELETE CASCADE,
+    event_id bigint not null references song_events (id) ON DELETE CASCADE,
+    status varchar not null default 'pending',
+    attempts int not null default 0,
+    last_status int,
+    last_error varchar,
+    next_attempt_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+
+    CONSTRAINT unique_delivery UNIQUE (webhook_id, event_id)
+);
+
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+
+-- +migrate Down
+
+DROP TABLE webhook_deliveries;
+DROP TABLE webhooks;
+DROP TABLE song_events;
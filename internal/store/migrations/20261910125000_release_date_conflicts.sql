@@ -0,0 +1,96 @@
+-- +migrate Up
+
+-- The unique key of songs uses NULLS NOT DISTINCT from the release dates
+-- migration on.
+-- +migrate StatementBegin
+DO $$
+BEGIN
+    IF current_setting('server_version_num')::int < 150000 THEN
+        RAISE EXCEPTION 'PostgreSQL 15 or later is required for unique keys with NULLS NOT DISTINCT, found %',
+            current_setting('server_version');
+    END IF;
+END
+$$;
+-- +migrate StatementEnd
+
+-- Release dates that differ as text but not as dates, like "16.07.2006" and
+-- "2006-07-16", or that are both unknown, like "" and one that cannot be
+-- parsed, make duplicates of songs with the same name and group once the
+-- release dates migration parses them. They are kept here, to be merged or
+-- fixed by hand: the first of them, songs that are not deleted first, keeps
+-- the song and the others are deleted and renamed after their ids, out of the
+-- way of the unique key until the merge of conflicts migration merges them.
+CREATE TABLE release_date_conflicts (
+    song_id uuid primary key references songs (id) ON DELETE CASCADE,
+    duplicate_of uuid references songs (id) ON DELETE CASCADE,
+    release_date varchar,
+    name varchar not null,
+    deleted bool not null
+);
+
+-- Parses release dates as the release dates migration does.
+-- +migrate StatementBegin
+CREATE FUNCTION pg_temp.parse_release_date(raw varchar) RETURNS date AS $$
+BEGIN
+    raw := trim(raw);
+
+    IF raw ~ '^[0-9]{1,2}\.[0-9]{1,2}\.[0-9]{4}$' THEN
+        RETURN to_date(raw, 'DD.MM.YYYY');
+    ELSIF raw ~ '^[0-9]{4}-[0-9]{2}-[0-9]{2}$' THEN
+        RETURN to_date(raw, 'YYYY-MM-DD');
+    ELSIF raw ~ '^[0-9]{1,2}\.[0-9]{4}$' THEN
+        RETURN to_date(raw, 'MM.YYYY');
+    ELSIF raw ~ '^[0-9]{4}-[0-9]{2}$' THEN
+        RETURN to_date(raw, 'YYYY-MM');
+    ELSIF raw ~ '^[0-9]{4}$' THEN
+        RETURN make_date(raw::int, 1, 1);
+    END IF;
+
+    RETURN NULL;
+EXCEPTION WHEN others THEN
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+-- +migrate StatementEnd
+
+-- Databases that applied the release dates migration already have no
+-- conflicts, it would have failed otherwise.
+-- +migrate StatementBegin
+DO $$
+BEGIN
+    IF (
+        SELECT data_type FROM information_schema.columns
+        WHERE table_schema = current_schema() and table_name = 'songs' and column_name = 'release_date'
+    ) <> 'character varying' THEN
+        RETURN;
+    END IF;
+
+    INSERT INTO release_date_conflicts (song_id, duplicate_of, release_date, name, deleted)
+    SELECT id, CASE WHEN rank > 1 THEN kept END, release_date, name, deleted
+    FROM (
+        SELECT id, release_date, name, coalesce(deleted, false) AS deleted,
+            first_value(id) OVER keys AS kept, row_number() OVER keys AS rank, count(*) OVER same AS songs
+        FROM songs
+        WINDOW same AS (PARTITION BY pg_temp.parse_release_date(release_date), name, music_group),
+            keys AS (same ORDER BY coalesce(deleted, false), id)
+    ) ranked
+    WHERE songs > 1;
+
+    UPDATE songs SET deleted = true, name = conflict.name || ' (' || conflict.song_id || ')'
+    FROM release_date_conflicts conflict
+    WHERE songs.id = conflict.song_id and conflict.duplicate_of IS NOT NULL;
+END
+$$;
+-- +migrate StatementEnd
+
+DROP FUNCTION pg_temp.parse_release_date(varchar);
+
+-- +migrate Down
+
+-- The release dates migration formats the dates it parsed when it goes down,
+-- which would make the songs in conflict duplicates again.
+UPDATE songs SET release_date = conflict.release_date, name = conflict.name, deleted = conflict.deleted
+FROM release_date_conflicts conflict
+WHERE songs.id = conflict.song_id;
+
+DROP TABLE release_date_conflicts;
@@ -0,0 +1,83 @@
+-- +migrate Up
+
+-- Release dates that could not be parsed are kept here, and left unknown on
+-- the songs, so that they can be fixed by hand.
+CREATE TABLE unparsed_release_dates (
+    song_id uuid primary key references songs (id) ON DELETE CASCADE,
+    release_date varchar not null
+);
+
+ALTER TABLE songs
+    ADD COLUMN release_on date,
+    ADD COLUMN release_precision varchar CHECK (release_precision IN ('year', 'month', 'day'));
+
+-- +migrate StatementBegin
+DO $$
+DECLARE
+    song record;
+    parsed date;
+    parsed_precision varchar;
+BEGIN
+    FOR song IN SELECT id, trim(release_date) AS raw FROM songs WHERE coalesce(trim(release_date), '') <> '' LOOP
+        parsed := NULL;
+        parsed_precision := NULL;
+
+        BEGIN
+            IF song.raw ~ '^[0-9]{1,2}\.[0-9]{1,2}\.[0-9]{4}$' THEN
+                parsed := to_date(song.raw, 'DD.MM.YYYY');
+                parsed_precision := 'day';
+            ELSIF song.raw ~ '^[0-9]{4}-[0-9]{2}-[0-9]{2}$' THEN
+                parsed := to_date(song.raw, 'YYYY-MM-DD');
+                parsed_precision := 'day';
+            ELSIF song.raw ~ '^[0-9]{1,2}\.[0-9]{4}$' THEN
+                parsed := to_date(song.raw, 'MM.YYYY');
+                parsed_precision := 'month';
+            ELSIF song.raw ~ '^[0-9]{4}-[0-9]{2}$' THEN
+                parsed := to_date(song.raw, 'YYYY-MM');
+                parsed_precision := 'month';
+            ELSIF song.raw ~ '^[0-9]{4}$' THEN
+                parsed := make_date(song.raw::int, 1, 1);
+                parsed_precision := 'year';
+            END IF;
+        EXCEPTION WHEN others THEN
+            parsed := NULL;
+        END;
+
+        IF parsed IS NULL THEN
+            INSERT INTO unparsed_release_dates (song_id, release_date) VALUES (song.id, song.raw);
+            RAISE WARNING 'song %: cannot parse release date "%"', song.id, song.raw;
+        ELSE
+            UPDATE songs SET release_on = parsed, release_precision = parsed_precision WHERE id = song.id;
+        END IF;
+    END LOOP;
+END
+$$;
+-- +migrate StatementEnd
+
+-- Songs without a release date still conflict on name and group.
+ALTER TABLE songs DROP CONSTRAINT unique_song;
+ALTER TABLE songs DROP COLUMN release_date;
+ALTER TABLE songs RENAME COLUMN release_on TO release_date;
+ALTER TABLE songs ADD CONSTRAINT unique_song UNIQUE NULLS NOT DISTINCT (release_date, name, music_group);
+
+-- +migrate Down
+
+ALTER TABLE songs DROP CONSTRAINT unique_song;
+ALTER TABLE songs RENAME COLUMN release_date TO release_on;
+ALTER TABLE songs ADD COLUMN release_date varchar;
+
+UPDATE songs SET release_date = CASE release_precision
+    WHEN 'year' THEN to_char(release_on, 'YYYY')
+    WHEN 'month' THEN to_char(release_on, 'MM.YYYY')
+    WHEN 'day' THEN to_char(release_on, 'DD.MM.YYYY')
+    ELSE ''
+END;
+
+UPDATE songs SET release_date = unparsed.release_date
+FROM unparsed_release_dates unparsed
+WHERE songs.id = unparsed.song_id;
+
+ALTER TABLE songs DROP COLUMN release_on, DROP COLUMN release_precision;
+ALTER TABLE songs ADD CONSTRAINT unique_song UNIQUE (release_date, name, music_group);
+
+DROP TABLE unparsed_release_dates;
@@ -0,0 +1,25 @@
+-- +migrate Up
+
+-- pg_trgm finds songs with similar names, which are probably duplicates.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX songs_name_trgm ON songs USING gin (name gin_trgm_ops) WHERE deleted = false;
+
+-- release_end is the last day of the release period starting on release_date.
+-- +migrate StatementBegin
+CREATE FUNCTION release_end(release_date date, release_precision text) RETURNS date
+LANGUAGE sql IMMUTABLE AS $$
+    SELECT (release_date + CASE release_precision
+        WHEN 'year' THEN interval '1 year'
+        WHEN 'month' THEN interval '1 month'
+        ELSE interval '1 day'
+    END - interval '1 day')::date
+$$;
+-- +migrate StatementEnd
+
+-- +migrate Down
+
+DROP FUNCTION release_end(date, text);
+
+-- The extension is left installed, other database objects may use it.
+DROP INDEX songs_name_trgm;
@@ -0,0 +1,19 @@
+-- +migrate Up
+
+-- Songs merged into another one are deleted and point at it. They no longer
+-- hold their unique key, so that the merged song can take their values.
+ALTER TABLE songs ADD COLUMN merged_into uuid references songs (id);
+
+ALTER TABLE songs DROP CONSTRAINT unique_song;
+CREATE UNIQUE INDEX unique_song ON songs (release_date, name, music_group) NULLS NOT DISTINCT WHERE merged_into IS NULL;
+
+CREATE INDEX songs_merged_into_idx ON songs (merged_into) WHERE merged_into IS NOT NULL;
+
+-- +migrate Down
+
+-- Fails when a merged song shares its key with another song.
+DROP INDEX songs_merged_into_idx;
+DROP INDEX unique_song;
+ALTER TABLE songs ADD CONSTRAINT unique_song UNIQUE NULLS NOT DISTINCT (release_date, name, music_group);
+
+ALTER TABLE songs DROP COLUMN merged_into;
@@ -0,0 +1,13 @@
+-- +migrate Up
+
+-- Songs that duplicated others once their release dates were parsed are
+-- merged into them and take their names back, which merged songs can share.
+UPDATE songs SET name = conflict.name, merged_into = conflict.duplicate_of
+FROM release_date_conflicts conflict
+WHERE songs.id = conflict.song_id and conflict.duplicate_of IS NOT NULL;
+
+-- +migrate Down
+
+UPDATE songs SET name = conflict.name || ' (' || conflict.song_id || ')', merged_into = NULL
+FROM release_date_conflicts conflict
+WHERE songs.id = conflict.song_id and conflict.duplicate_of IS NOT NULL;
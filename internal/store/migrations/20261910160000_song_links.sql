@@ -0,0 +1,52 @@
+-- +migrate Up
+
+CREATE TYPE link_platform AS ENUM ('youtube', 'spotify', 'apple_music', 'lyrics', 'other');
+
+-- A song has at most one primary link, which the API shows as its link.
+CREATE TABLE song_links (
+    id uuid primary key,
+    song_id uuid not null references songs (id) ON DELETE CASCADE,
+    platform link_platform not null,
+    url varchar not null,
+    external_id varchar not null default '',
+    region varchar not null default '',
+    is_primary bool not null default false,
+    created_at timestamptz not null default now(),
+
+    CONSTRAINT unique_song_link UNIQUE (song_id, url)
+);
+
+CREATE UNIQUE INDEX song_links_primary_idx ON song_links (song_id) WHERE is_primary;
+CREATE INDEX song_links_external_id_idx ON song_links (platform, external_id) WHERE external_id <> '';
+
+-- Links are moved as they are, the platform is told by the host only. They
+-- are normalized when they are written again.
+INSERT INTO song_links (id, song_id, platform, url, is_primary)
+SELECT gen_random_uuid(), id, CASE
+        WHEN link ~* '^https?://(www\.|m\.|music\.)?(youtube\.com|youtu\.be)/' THEN 'youtube'
+        WHEN link ~* '^https?://open\.spotify\.com/' THEN 'spotify'
+        WHEN link ~* '^https?://music\.apple\.com/' THEN 'apple_music'
+        WHEN link ~* '^https?://(www\.)?(genius\.com|musixmatch\.com)/' THEN 'lyrics'
+        ELSE 'other'
+    END::link_platform, trim(link), true
+FROM songs
+WHERE trim(coalesce(link, '')) <> '';
+
+ALTER TABLE songs DROP COLUMN link;
+
+-- +migrate StatementBegin
+CREATE FUNCTION primary_link(song_id uuid) RETURNS varchar
+LANGUAGE sql STABLE AS $$
+    SELECT coalesce((SELECT url FROM song_links WHERE song_links.song_id = primary_link.song_id and is_primary), '')
+$$;
+-- +migrate StatementEnd
+
+-- +migrate Down
+
+ALTER TABLE songs ADD COLUMN link varchar;
+
+UPDATE songs SET link = primary_link(id);
+
+DROP FUNCTION primary_link(uuid);
+DROP TABLE song_links;
+DROP TYPE link_platform;
@@ -0,0 +1,31 @@
+-- +migrate Up
+
+-- The outcome of the last health check of a link: check_status is the HTTP
+-- status, 0 when there was no response, and redirect_url where the link
+-- ended up when it redirected. A link is broken after check_failures failed
+-- checks in a row.
+ALTER TABLE song_links
+    ADD COLUMN check_status integer not null default 0,
+    ADD COLUMN redirect_url varchar not null default '',
+    ADD COLUMN check_error varchar not null default '',
+    ADD COLUMN checked_at timestamptz,
+    ADD COLUMN check_failures integer not null default 0,
+    ADD COLUMN broken boolean not null default false,
+    ADD COLUMN next_check_at timestamptz not null default now();
+
+CREATE INDEX song_links_next_check_at_idx ON song_links (next_check_at);
+CREATE INDEX song_links_broken_idx ON song_links (checked_at DESC) WHERE broken;
+
+-- +migrate Down
+
+DROP INDEX song_links_broken_idx;
+DROP INDEX song_links_next_check_at_idx;
+
+ALTER TABLE song_links
+    DROP COLUMN check_status,
+    DROP COLUMN redirect_url,
+    DROP COLUMN check_error,
+    DROP COLUMN checked_at,
+    DROP COLUMN check_failures,
+    DROP COLUMN broken,
+    DROP COLUMN next_check_at;
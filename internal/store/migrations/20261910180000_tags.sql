@@ -0,0 +1,26 @@
+-- +migrate Up
+
+-- Tags form trees; a tag is removed with its songs, not with its children.
+CREATE TABLE tags (
+    id uuid primary key,
+    slug varchar not null unique,
+    name varchar not null,
+    parent_id uuid references tags (id),
+    created_at timestamptz not null default now()
+);
+
+CREATE INDEX tags_parent_id_idx ON tags (parent_id);
+
+CREATE TABLE song_tags (
+    song_id uuid not null references songs (id) ON DELETE CASCADE,
+    tag_id uuid not null references tags (id) ON DELETE CASCADE,
+
+    primary key (song_id, tag_id)
+);
+
+CREATE INDEX song_tags_tag_id_idx ON song_tags (tag_id);
+
+-- +migrate Down
+
+DROP TABLE song_tags;
+DROP TABLE tags;
@@ -0,0 +1,73 @@
+-- +migrate Up
+
+-- Songs created before this migration get the time of their song.created
+-- event when there is one and stay unknown otherwise.
+ALTER TABLE songs ADD COLUMN created_at timestamptz;
+
+UPDATE songs s SET created_at = e.created_at
+FROM (SELECT song_id, min(created_at) AS created_at FROM song_events WHERE type = 'song.created' GROUP BY song_id) e
+WHERE e.song_id = s.id;
+
+ALTER TABLE songs ALTER COLUMN created_at SET DEFAULT now();
+
+-- The statistics are served from these views, refreshed on a schedule. Each
+-- has a unique index so that it can be refreshed concurrently.
+CREATE MATERIALIZED VIEW stats_by_group AS
+SELECT music_group, count(*) AS songs
+FROM songs
+WHERE deleted = false
+GROUP BY music_group;
+
+CREATE UNIQUE INDEX stats_by_group_idx ON stats_by_group (music_group);
+
+CREATE MATERIALIZED VIEW stats_by_release_year AS
+SELECT extract(year FROM release_date)::int AS year, count(*) AS songs
+FROM songs
+WHERE deleted = false AND release_date IS NOT NULL
+GROUP BY 1;
+
+CREATE UNIQUE INDEX stats_by_release_year_idx ON stats_by_release_year (year);
+
+CREATE MATERIALIZED VIEW stats_by_creation_month AS
+SELECT to_char(created_at AT TIME ZONE 'UTC', 'YYYY-MM') AS month, count(*) AS songs
+FROM songs
+WHERE deleted = false AND created_at IS NOT NULL
+GROUP BY 1;
+
+CREATE UNIQUE INDEX stats_by_creation_month_idx ON stats_by_creation_month (month);
+
+-- Verses are split on blank lines like the API splits them.
+CREATE MATERIALIZED VIEW stats_summary AS
+SELECT 1 AS id,
+    count(*) AS songs,
+    count(DISTINCT music_group) AS groups,
+    count(*) FILTER (WHERE has_text) AS lyrics,
+    coalesce(avg(verses) FILTER (WHERE has_text), 0)::float8 AS average_verses,
+    coalesce(max(verses) FILTER (WHERE has_text), 0) AS max_verses,
+    coalesce(avg(words) FILTER (WHERE has_text), 0)::float8 AS average_words,
+    coalesce(sum(words), 0) AS total_words,
+    count(*) FILTER (WHERE release_date IS NOT NULL) AS with_release_date,
+    count(*) FILTER (WHERE has_link) AS with_link,
+    count(*) FILTER (WHERE has_tags) AS with_tags,
+    now() AS refreshed_at
+FROM (
+    SELECT s.music_group, s.release_date,
+        s.text ~ '\S' AS has_text,
+        (length(s.text) - length(replace(s.text, E'\n\n', ''))) / 2 + 1 AS verses,
+        (SELECT count(*) FROM regexp_split_to_table(s.text, '\s+') w WHERE w <> '') AS words,
+        exists (SELECT 1 FROM song_links l WHERE l.song_id = s.id AND l.is_primary) AS has_link,
+        exists (SELECT 1 FROM song_tags t WHERE t.song_id = s.id) AS has_tags
+    FROM songs s
+    WHERE s.deleted = false
+) metrics;
+
+CREATE UNIQUE INDEX stats_summary_idx ON stats_summary (id);
+
+-- +migrate Down
+
+DROP MATERIALIZED VIEW stats_summary;
+DROP MATERIALIZED VIEW stats_by_creation_month;
+DROP MATERIALIZED VIEW stats_by_release_year;
+DROP MATERIALIZED VIEW stats_by_group;
+
+ALTER TABLE songs DROP COLUMN created_at;
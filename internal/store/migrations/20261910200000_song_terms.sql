@@ -0,0 +1,19 @@
+-- +migrate Up
+
+-- The terms of the lyrics of songs and how often they occur, the term
+-- frequencies of the TF-IDF vectors related songs are found by. They are
+-- written along with the songs; the songs stored before are indexed once the
+-- migrations are applied.
+CREATE TABLE song_terms (
+    song_id uuid not null references songs (id) ON DELETE CASCADE,
+    term varchar not null,
+    frequency int not null,
+
+    primary key (song_id, term)
+);
+
+CREATE INDEX song_terms_term_idx ON song_terms (term);
+
+-- +migrate Down
+
+DROP TABLE song_terms;
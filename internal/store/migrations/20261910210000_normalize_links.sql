@@ -0,0 +1,15 @@
+-- +migrate Up
+
+-- Links moved from songs.link were classified by host only and kept as they
+-- were. They wait here to be normalized, with their ids extracted, by the
+-- store once the migrations are applied.
+CREATE TABLE song_links_to_normalize (
+    link_id uuid primary key references song_links (id) ON DELETE CASCADE
+);
+
+INSERT INTO song_links_to_normalize (link_id)
+SELECT id FROM song_links WHERE external_id = '';
+
+-- +migrate Down
+
+DROP TABLE song_links_to_normalize;
@@ -0,0 +1,26 @@
+-- +migrate Up
+
+-- The TF-IDF weights of the terms, the norms of the vectors of the lyrics and
+-- the number of songs that are not deleted with each term, the document
+-- frequency the weights are computed with. Document frequencies are kept up to
+-- date on write; a song is weighed when it is written, and every song again
+-- when the statistics are refreshed, as its weights change with the songs
+-- written after it. The terms stored before are weighed once the migrations
+-- are applied.
+ALTER TABLE song_terms ADD COLUMN weight float8 not null default 0;
+
+CREATE TABLE term_documents (
+    term varchar primary key,
+    songs int not null
+);
+
+CREATE TABLE song_norms (
+    song_id uuid primary key references songs (id) ON DELETE CASCADE,
+    norm float8 not null
+);
+
+-- +migrate Down
+
+DROP TABLE song_norms;
+DROP TABLE term_documents;
+ALTER TABLE song_terms DROP COLUMN weight;
@@ -0,0 +1,236 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"time"
+
+	migrate "github.com/rubenv/sql-migrate"
+	log "github.com/sirupsen/logrus"
+)
+
+// Migrator runs the embedded migrations of a store. It backs both the
+// migrations applied at startup and the migrate subcommand.
+type Migrator struct {
+	db      *sql.DB
+	dialect string
+	source  migrate.MigrationSource
+	// afterUp runs after migrations were applied upwards.
+	afterUp func() error
+	closer  io.Closer
+}
+
+// MigrationStatus tells whether an embedded migration has been applied.
+type MigrationStatus struct {
+	ID        string
+	AppliedAt *time.Time
+}
+
+// Migrator opens a database/sql connection for running migrations. It must
+// be closed after use.
+func (p *Postgres) Migrator() (*Migrator, error) {
+	conn, err := sql.Open("pgx", p.dsn)
+	if err != nil {
+		return nil, fmt.Errorf("sql.Open: %w", err)
+	}
+
+	return &Migrator{
+		db:      conn,
+		dialect: "postgres",
+		source:  migrationSource(),
+		afterUp: func() error {
+			err := normalizeMigratedLinks(conn, `SELECT to_regclass('song_links_to_normalize') IS NOT NULL`, numbered)
+			if err != nil {
+				return err
+			}
+
+			err = indexMissingTerms(conn, `SELECT to_regclass('song_terms') IS NOT NULL`,
+				`INSERT INTO song_terms (song_id, term, frequency) VALUES ($1, $2, $3)`)
+			if err != nil {
+				return err
+			}
+
+			err = weighMissingTerms(conn, `SELECT to_regclass('term_documents') IS NOT NULL`,
+				countTermDocuments, reweighTerms)
+			if err != nil {
+				return err
+			}
+
+			return warnUnparsedReleaseDates(conn, `SELECT to_regclass($1) IS NOT NULL`)
+		},
+		closer: conn,
+	}, nil
+}
+
+// Migrator runs migrations over the connection of the store, closing it is a
+// no-op.
+func (s *SQLite) Migrator() (*Migrator, error) {
+	return &Migrator{
+		db:      s.db,
+		dialect: "sqlite3",
+		source:  sqliteMigrationSource(),
+		afterUp: func() error {
+			if err := s.setupSearch(context.Background()); err != nil {
+				return err
+			}
+
+			err := normalizeMigratedLinks(s.db,
+				`SELECT count(*) > 0 FROM sqlite_master WHERE type = 'table' and name = 'song_links_to_normalize'`,
+				func(query string) string { return query })
+			if err != nil {
+				return err
+			}
+
+			err = indexMissingTerms(s.db, `SELECT count(*) > 0 FROM sqlite_master WHERE type = 'table' and name = 'song_terms'`,
+				`INSERT INTO song_terms (song_id, term, frequency) VALUES (?, ?, ?)`)
+			if err != nil {
+				return err
+			}
+
+			err = weighMissingTerms(s.db, `SELECT count(*) > 0 FROM sqlite_master WHERE type = 'table' and name = 'term_documents'`,
+				sqliteCountTermDocuments, sqliteReweighTerms)
+			if err != nil {
+				return err
+			}
+
+			return warnUnparsedReleaseDates(s.db, `SELECT count(*) > 0 FROM sqlite_master WHERE type = 'table' and name = ?`)
+		},
+	}, nil
+}
+
+func (m *Migrator) Close() error {
+	if m.closer == nil {
+		return nil
+	}
+
+	if err := m.closer.Close(); err != nil {
+		return fmt.Errorf("closing migrations connection err: %w", err)
+	}
+
+	return nil
+}
+
+// Plan returns the migrations Exec would run, max limiting their number when
+// positive.
+func (m *Migrator) Plan(direction migrate.MigrationDirection, max int) ([]*migrate.PlannedMigration, error) {
+	planned, _, err := migrate.PlanMigration(m.db, m.dialect, m.source, direction, max)
+	if err != nil {
+		return nil, fmt.Errorf("migrate.PlanMigration: %w", err)
+	}
+
+	return planned, nil
+}
+
+// Exec applies up to max migrations in direction, all of them when max is not
+// positive, and returns how many were applied.
+func (m *Migrator) Exec(direction migrate.MigrationDirection, max int) (int, error) {
+	applied, err := migrate.ExecMax(m.db, m.dialect, m.source, direction, max)
+	if err != nil {
+		return applied, fmt.Errorf("migrate.ExecMax: %w", err)
+	}
+
+	if direction == migrate.Up && m.afterUp != nil {
+		if err := m.afterUp(); err != nil {
+			return applied, err
+		}
+	}
+
+	return applied, nil
+}
+
+// Status lists every embedded migration in order, with the time it was
+// applied if it was.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	migrations, err := m.source.FindMigrations()
+	if err != nil {
+		return nil, fmt.Errorf("finding migrations err: %w", err)
+	}
+
+	records, err := migrate.GetMigrationRecords(m.db, m.dialect)
+	if err != nil {
+		return nil, fmt.Errorf("migrate.GetMigrationRecords: %w", err)
+	}
+
+	applied := make(map[string]time.Time, len(records))
+	for _, record := range records {
+		applied[record.Id] = record.AppliedAt
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+
+	for _, migration := range migrations {
+		status := MigrationStatus{ID: migration.Id}
+
+		if at, ok := applied[migration.Id]; ok {
+			status.AppliedAt = &at
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// migrationsTable is where sql-migrate records the applied migrations.
+const migrationsTable = "gorp_migrations"
+
+// countPending returns how many migrations of source are not applied.
+func countPending(source migrate.MigrationSource, applied map[string]bool) (int, error) {
+	migrations, err := source.FindMigrations()
+	if err != nil {
+		return 0, fmt.Errorf("finding migrations err: %w", err)
+	}
+
+	pending := 0
+
+	for _, migration := range migrations {
+		if !applied[migration.Id] {
+			pending++
+		}
+	}
+
+	return pending, nil
+}
+
+// warnUnparsedReleaseDates reminds of the release dates the migration to
+// typed dates could not parse, and of the duplicate songs it found, for as
+// long as they are left in their tables. tableExists tells whether a table,
+// and so the migration, exists.
+func warnUnparsedReleaseDates(db *sql.DB, tableExists string) error {
+	warnings := []struct{ table, message string }{
+		{"unparsed_release_dates", "release dates could not be parsed and are unknown, see the unparsed_release_dates table"},
+		{"release_date_conflicts", "songs were duplicates once their release dates were parsed, see the release_date_conflicts table"},
+	}
+
+	for _, warning := range warnings {
+		var exists bool
+
+		if err := db.QueryRow(tableExists, warning.table).Scan(&exists); err != nil {
+			return fmt.Errorf("checking %s err: %w", warning.table, err)
+		}
+
+		if !exists {
+			continue
+		}
+
+		var songs int
+
+		if err := db.QueryRow(`SELECT count(*) FROM ` + warning.table).Scan(&songs); err != nil {
+			return fmt.Errorf("counting %s err: %w", warning.table, err)
+		}
+
+		if songs > 0 {
+			log.WithField("songs", songs).Warn(warning.message)
+		}
+	}
+
+	return nil
+}
+
+func closeMigrator(m *Migrator) {
+	if err := m.Close(); err != nil {
+		log.Warnf("m.Close: %v", err)
+	}
+}
@@ -0,0 +1,421 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/models"
+	"github.com/jackc/pgx/v5"
+	log "github.com/sirupsen/logrus"
+)
+
+// relatedQuery scores the songs that are not deleted against the song $1 by
+// the cosine similarity of the TF-IDF vectors of their lyrics, from the weights
+// and norms stored with the songs. Only the songs sharing a term with it are
+// read. Songs by the same group get $2 added to their score, songs with a tag
+// in common $3.
+const relatedQuery = `
+	WITH dots AS (
+		SELECT b.song_id, sum(a.weight * b.weight) AS dot
+		FROM song_terms a JOIN song_terms b ON b.term = a.term
+		WHERE a.song_id = $1 and b.song_id <> $1
+		GROUP BY b.song_id
+	),
+	related AS (
+		SELECT d.song_id, d.dot / (n.norm * t.norm) AS similarity
+		FROM dots d JOIN song_norms n ON n.song_id = d.song_id JOIN song_norms t ON t.song_id = $1
+	)
+	SELECT s.id, s.release_date, s.release_precision, s.name, s.music_group, s.text, primary_link(s.id), s.deleted,
+		r.similarity,
+		r.similarity
+			+ CASE WHEN s.music_group = (SELECT music_group FROM songs WHERE id = $1) THEN $2::float8 ELSE 0 END
+			+ CASE WHEN exists (
+				SELECT 1 FROM song_tags a JOIN song_tags b ON b.tag_id = a.tag_id
+				WHERE a.song_id = $1 and b.song_id = s.id
+			) THEN $3::float8 ELSE 0 END AS score
+	FROM related r JOIN songs s ON s.id = r.song_id
+	WHERE s.deleted = false
+	ORDER BY score DESC, s.name, s.id
+	OFFSET $4 LIMIT $5`
+
+// termWeight is the TF-IDF weight of the song term t, with the term_documents
+// row d of its term: the frequency of the term in the lyrics times the smoothed
+// IDF ln((1 + songs) / (1 + songs with the term)) + 1 over the songs that are
+// not deleted.
+const termWeight = `t.frequency * (ln((1 + (SELECT count(*) FROM songs WHERE deleted = false))::float8
+	/ (1 + coalesce(d.songs, 0))) + 1)`
+
+// reweighTerms weighs the terms of the songs that are not deleted with the
+// document frequencies as they are now, those of a song being weighed when it
+// was written, and computes the norms of their vectors. Only the weights and
+// norms that changed are written.
+var reweighTerms = []string{
+	`UPDATE song_terms t SET weight = ` + termWeight + `
+	FROM term_documents d, songs s
+	WHERE d.term = t.term and s.id = t.song_id and s.deleted = false and t.weight <> ` + termWeight,
+	`INSERT INTO song_norms (song_id, norm)
+	SELECT t.song_id, sqrt(sum(t.weight * t.weight)) FROM song_terms t JOIN songs s ON s.id = t.song_id
+	WHERE s.deleted = false
+	GROUP BY t.song_id
+	ON CONFLICT (song_id) DO UPDATE SET norm = excluded.norm WHERE song_norms.norm <> excluded.norm`,
+}
+
+// countTermDocuments counts the document frequencies of the terms again.
+var countTermDocuments = []string{
+	`DELETE FROM term_documents`,
+	`INSERT INTO term_documents (term, songs)
+	SELECT t.term, count(*) FROM song_terms t JOIN songs s ON s.id = t.song_id
+	WHERE s.deleted = false
+	GROUP BY t.term`,
+}
+
+// RelatedSongs returns the songs whose lyrics are like those of the song id,
+// the highest scores first and paged by params. Songs without a term in
+// common are left out.
+func (p *Postgres) RelatedSongs(ctx context.Context, id uuid.UUID, params models.Params) ([]*models.RelatedSong, error) {
+	db := p.reader(ctx)
+
+	if err := songExists(ctx, db, id); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(ctx, relatedQuery, id, params.GroupBoost, params.TagBoost, params.Offset, params.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("getting related songs err: %w", err)
+	}
+
+	related, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.RelatedSong, error) {
+		var related models.RelatedSong
+
+		song, err := scanSong(relatedRow{row: row, related: &related})
+		if err != nil {
+			return nil, err
+		}
+
+		related.Song = *song
+
+		return &related, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading related songs err: %w", err)
+	}
+
+	return related, nil
+}
+
+// setTerms replaces the terms of the song id with those of its lyrics and
+// weighs them. Unless the song is deleted, the document frequencies of the
+// terms it had and has are updated first, in the order of the terms so that
+// concurrent writes do not deadlock.
+func setTerms(ctx context.Context, tx pgx.Tx, id uuid.UUID, text string) error {
+	counts := lyricTerms(text)
+	terms := make([]string, 0, len(counts))
+	frequencies := make([]int, 0, len(counts))
+
+	for term, frequency := range counts {
+		terms = append(terms, term)
+		frequencies = append(frequencies, frequency)
+	}
+
+	_, err := tx.Exec(ctx, `INSERT INTO term_documents (term, songs)
+		SELECT term, sum(change) FROM (
+			SELECT term, -1 AS change FROM song_terms WHERE song_id = $1
+			UNION ALL
+			SELECT unnest($2::varchar[]), 1
+		) changes
+		WHERE exists (SELECT 1 FROM songs WHERE id = $1 and deleted = false)
+		GROUP BY term HAVING sum(change) <> 0
+		ORDER BY term
+		ON CONFLICT (term) DO UPDATE SET songs = term_documents.songs + excluded.songs`, id, terms)
+	if err != nil {
+		return fmt.Errorf("counting term documents err: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM song_terms WHERE song_id = $1`, id); err != nil {
+		return fmt.Errorf("clearing song terms err: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `INSERT INTO song_terms (song_id, term, frequency, weight)
+		SELECT $1, t.term, t.frequency, `+termWeight+`
+		FROM unnest($2::varchar[], $3::int[]) AS t (term, frequency) LEFT JOIN term_documents d ON d.term = t.term`,
+		id, terms, frequencies)
+	if err != nil {
+		return fmt.Errorf("indexing song terms err: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM song_norms WHERE song_id = $1`, id); err != nil {
+		return fmt.Errorf("clearing song norm err: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `INSERT INTO song_norms (song_id, norm)
+		SELECT song_id, sqrt(sum(weight * weight)) FROM song_terms WHERE song_id = $1 GROUP BY song_id`, id)
+	if err != nil {
+		return fmt.Errorf("computing song norm err: %w", err)
+	}
+
+	return nil
+}
+
+// forgetTerms takes the songs ids, just deleted, out of the document
+// frequencies of their terms.
+func forgetTerms(ctx context.Context, tx pgx.Tx, ids []uuid.UUID) error {
+	_, err := tx.Exec(ctx, `INSERT INTO term_documents (term, songs)
+		SELECT term, -count(*) FROM song_terms WHERE song_id = ANY($1)
+		GROUP BY term
+		ORDER BY term
+		ON CONFLICT (term) DO UPDATE SET songs = term_documents.songs + excluded.songs`, ids)
+	if err != nil {
+		return fmt.Errorf("counting term documents err: %w", err)
+	}
+
+	return nil
+}
+
+// relatedRow scans the columns of a song followed by its similarity and score.
+type relatedRow struct {
+	row     rowScanner
+	related *models.RelatedSong
+}
+
+func (r relatedRow) Scan(dest ...any) error {
+	return r.row.Scan(append(dest, &r.related.Similarity, &r.related.Score)...) //nolint:wrapcheck
+}
+
+// lyricTerms counts the terms of lyrics: their words in lower case, split on
+// anything but letters and digits, leaving out single characters.
+func lyricTerms(text string) map[string]int {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := make(map[string]int, len(words))
+
+	for _, word := range words {
+		if len([]rune(word)) > 1 {
+			terms[word]++
+		}
+	}
+
+	return terms
+}
+
+// relatedDoc is a song that is not deleted, as rankRelated compares it.
+type relatedDoc struct {
+	id          uuid.UUID
+	name, group string
+	terms       map[string]int
+	// sharesTag tells whether the song has a tag in common with the song
+	// related songs are looked for.
+	sharesTag bool
+}
+
+// relatedScore is the similarity and score of a related song.
+type relatedScore struct {
+	*relatedDoc
+	similarity, score float64
+}
+
+// rankRelated ranks the songs related to the song id among docs, every song
+// that is not deleted, like relatedQuery does for the memory store, weighing
+// the terms as it goes.
+func rankRelated(id uuid.UUID, docs []relatedDoc, params models.Params) []relatedScore {
+	frequencies := make(map[string]int)
+
+	for _, doc := range docs {
+		for term := range doc.terms {
+			frequencies[term]++
+		}
+	}
+
+	weights := make([]map[string]float64, len(docs))
+	norms := make([]float64, len(docs))
+	target := -1
+
+	for i, doc := range docs {
+		weights[i] = make(map[string]float64, len(doc.terms))
+
+		for term, frequency := range doc.terms {
+			weight := float64(frequency) * (math.Log(float64(1+len(docs))/float64(1+frequencies[term])) + 1)
+			weights[i][term] = weight
+			norms[i] += weight * weight
+		}
+
+		norms[i] = math.Sqrt(norms[i])
+
+		if doc.id == id {
+			target = i
+		}
+	}
+
+	if target < 0 {
+		return []relatedScore{}
+	}
+
+	related := make([]relatedScore, 0, 1)
+
+	for i := range docs {
+		if i == target {
+			continue
+		}
+
+		var dot float64
+
+		for term, weight := range weights[target] {
+			dot += weight * weights[i][term]
+		}
+
+		if dot == 0 {
+			continue
+		}
+
+		scored := relatedScore{relatedDoc: &docs[i], similarity: dot / (norms[target] * norms[i])}
+		scored.score = scored.similarity
+
+		if docs[i].group == docs[target].group {
+			scored.score += params.GroupBoost
+		}
+
+		if docs[i].sharesTag {
+			scored.score += params.TagBoost
+		}
+
+		related = append(related, scored)
+	}
+
+	slices.SortFunc(related, func(a, b relatedScore) int {
+		return cmp.Or(cmp.Compare(b.score, a.score), cmp.Compare(a.name, b.name), cmp.Compare(a.id.String(), b.id.String()))
+	})
+
+	return page(related, params)
+}
+
+// indexMissingTerms indexes the terms of the songs with lyrics but without
+// terms, those stored before terms were. tableExists tells whether the
+// migration adding them was applied, insert adds a term of a song.
+func indexMissingTerms(db *sql.DB, tableExists, insert string) error {
+	var exists bool
+
+	if err := db.QueryRow(tableExists).Scan(&exists); err != nil {
+		return fmt.Errorf("checking song_terms err: %w", err)
+	}
+
+	if !exists {
+		return nil
+	}
+
+	rows, err := db.Query(`SELECT id, text FROM songs
+		WHERE text <> '' and NOT exists (SELECT 1 FROM song_terms t WHERE t.song_id = songs.id)`)
+	if err != nil {
+		return fmt.Errorf("getting songs without terms err: %w", err)
+	}
+	defer rows.Close()
+
+	texts := make(map[string]string)
+
+	for rows.Next() {
+		var id, text string
+
+		if err := rows.Scan(&id, &text); err != nil {
+			return fmt.Errorf("scanning song err: %w", err)
+		}
+
+		texts[id] = text
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("reading songs without terms err: %w", err)
+	}
+
+	if len(texts) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("db.Begin() err: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	indexed := 0
+
+	for id, text := range texts {
+		terms := lyricTerms(text)
+
+		for term, frequency := range terms {
+			if _, err := tx.Exec(insert, id, term, frequency); err != nil {
+				return fmt.Errorf("indexing song terms err: %w", err)
+			}
+		}
+
+		if len(terms) > 0 {
+			indexed++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("tx.Commit() err: %w", err)
+	}
+
+	if indexed > 0 {
+		log.WithField("songs", indexed).Info("Indexed the terms of song lyrics")
+	}
+
+	return nil
+}
+
+// weighMissingTerms counts the document frequencies of the terms again and
+// weighs the terms of every song when some song has terms not weighed yet,
+// those stored before terms were weighed or indexed by indexMissingTerms.
+// tableExists tells whether the migration adding the weights was applied,
+// count and reweigh are countTermDocuments and reweighTerms of the database.
+func weighMissingTerms(db *sql.DB, tableExists string, count, reweigh []string) error {
+	var exists bool
+
+	if err := db.QueryRow(tableExists).Scan(&exists); err != nil {
+		return fmt.Errorf("checking term_documents err: %w", err)
+	}
+
+	if !exists {
+		return nil
+	}
+
+	var missing bool
+
+	err := db.QueryRow(`SELECT exists (
+		SELECT 1 FROM song_terms t JOIN songs s ON s.id = t.song_id WHERE s.deleted = false and t.weight = 0
+	)`).Scan(&missing)
+	if err != nil {
+		return fmt.Errorf("checking unweighed terms err: %w", err)
+	}
+
+	if !missing {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("db.Begin() err: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	for _, statement := range append(append([]string{}, count...), reweigh...) {
+		if _, err := tx.Exec(statement); err != nil {
+			return fmt.Errorf("weighing terms err: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("tx.Commit() err: %w", err)
+	}
+
+	log.Info("Weighed the terms of song lyrics")
+
+	return nil
+}
@@ -0,0 +1,187 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/iurikman/songs/internal/consistency"
+	"github.com/iurikman/songs/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+	log "github.com/sirupsen/logrus"
+)
+
+const replicaCheckTimeout = 2 * time.Second
+
+var (
+	errReplicaLagging      = errors.New("replica lags behind the primary")
+	errReplicaDisconnected = errors.New("replica does not stream from the primary")
+)
+
+type replica struct {
+	pool *pgxpool.Pool
+	name string
+
+	mu     sync.RWMutex
+	status models.ReplicaStatus
+}
+
+func (r *replica) healthy() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.status.Healthy
+}
+
+// replicaLagQuery measures how far a replica is behind and whether it still
+// streams from the primary. A streaming replica that has replayed everything
+// it received is not lagging even if its last replayed transaction is old,
+// which happens when the primary is idle. A replica without a WAL receiver has
+// replayed all it got but no longer gets anything, so its lag is the age of
+// its last replayed transaction. Roles without pg_read_all_stats only see the
+// pid of the receiver, which is enough to tell it is there.
+const replicaLagQuery = `
+	SELECT streaming, CASE
+		WHEN NOT recovering THEN 0
+		WHEN streaming and pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+	END::float8
+	FROM (
+		SELECT recovering, NOT recovering OR EXISTS (
+			SELECT 1 FROM pg_stat_wal_receiver WHERE coalesce(status, 'streaming') = 'streaming'
+		) AS streaming
+		FROM (SELECT pg_is_in_recovery() AS recovering) r
+	) s
+`
+
+// reader picks the pool a read runs on. Healthy replicas take turns; the
+// primary serves the read when there is no healthy replica, when ctx asks for
+// the primary, or when the session of ctx wrote within the sticky window.
+func (p *Postgres) reader(ctx context.Context) *pgxpool.Pool {
+	if len(p.replicas) == 0 || consistency.Primary(ctx) {
+		return p.db
+	}
+
+	if session := consistency.SessionFrom(ctx); session != nil {
+		if lastWrite := session.LastWrite(); !lastWrite.IsZero() && time.Since(lastWrite) < p.stickyWindow {
+			return p.db
+		}
+	}
+
+	start := p.next.Add(1)
+
+	for i := range uint64(len(p.replicas)) {
+		r := p.replicas[(start+i)%uint64(len(p.replicas))]
+		if r.healthy() {
+			return r.pool
+		}
+	}
+
+	return p.db
+}
+
+// wrote tells the session of ctx, if any, that it has just written.
+func wrote(ctx context.Context) {
+	if session := consistency.SessionFrom(ctx); session != nil {
+		session.Wrote(time.Now())
+	}
+}
+
+// Replicas returns the last health check of every replica.
+func (p *Postgres) Replicas() []models.ReplicaStatus {
+	statuses := make([]models.ReplicaStatus, 0, len(p.replicas))
+
+	for _, r := range p.replicas {
+		r.mu.RLock()
+		statuses = append(statuses, r.status)
+		r.mu.RUnlock()
+	}
+
+	return statuses
+}
+
+// MonitorReplicas checks the replicas every interval until ctx is done,
+// ejecting those that are unreachable, no longer stream from the primary or
+// lag more than the allowed maximum, and restoring them once they catch up.
+func (p *Postgres) MonitorReplicas(ctx context.Context, interval time.Duration) error {
+	if len(p.replicas) == 0 {
+		return nil
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			p.CheckReplicas(ctx)
+		}
+	}
+}
+
+// CheckReplicas measures the lag of every replica once.
+func (p *Postgres) CheckReplicas(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	for _, r := range p.replicas {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			p.checkReplica(ctx, r)
+		}()
+	}
+
+	wg.Wait()
+}
+
+func (p *Postgres) checkReplica(ctx context.Context, r *replica) {
+	ctx, cancel := context.WithTimeout(ctx, replicaCheckTimeout)
+	defer cancel()
+
+	var (
+		streaming bool
+		seconds   float64
+	)
+
+	err := r.pool.QueryRow(ctx, replicaLagQuery).Scan(&streaming, &seconds)
+
+	lag := time.Duration(seconds * float64(time.Second)).Round(time.Millisecond)
+
+	if err == nil && !streaming {
+		err = fmt.Errorf("%w: last transaction replayed %s ago", errReplicaDisconnected, lag)
+	}
+
+	if err == nil && p.maxLag > 0 && lag > p.maxLag {
+		err = fmt.Errorf("%w: %s", errReplicaLagging, lag)
+	}
+
+	status := models.ReplicaStatus{
+		Name:    r.name,
+		Healthy: err == nil,
+		Lag:     lag.String(),
+		Checked: time.Now(),
+	}
+	if err != nil {
+		status.Error = err.Error()
+	}
+
+	r.mu.Lock()
+	wasHealthy, first := r.status.Healthy, r.status.Checked.IsZero()
+	r.status = status
+	r.mu.Unlock()
+
+	entry := log.WithField("replica", r.name).WithField("lag", status.Lag)
+
+	switch {
+	case (wasHealthy || first) && !status.Healthy:
+		entry.WithError(err).Warn("replica ejected from reads")
+	case !wasHealthy && status.Healthy:
+		entry.Info("replica serving reads")
+	}
+}
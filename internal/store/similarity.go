@@ -0,0 +1,79 @@
+package store
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/iurikman/songs/internal/models"
+)
+
+// similarity is the similarity function of pg_trgm, for the stores without
+// it: the share of the trigrams of a and b they have in common. Like pg_trgm
+// it ignores case and splits on anything but letters and digits, padding
+// every word with two spaces in front and one behind.
+func similarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+
+	common := 0
+
+	for trigram := range ta {
+		if _, ok := tb[trigram]; ok {
+			common++
+		}
+	}
+
+	return float64(common) / float64(len(ta)+len(tb)-common)
+}
+
+func trigrams(s string) map[string]struct{} {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	result := make(map[string]struct{})
+
+	for _, word := range words {
+		padded := []rune("  " + word + " ")
+
+		for i := range len(padded) - 2 {
+			result[string(padded[i:i+3])] = struct{}{}
+		}
+	}
+
+	return result
+}
+
+// probableDuplicate tells whether songs a and b are probably the same song:
+// their names and groups are at least threshold similar and their release
+// dates, when both are known, overlap. It returns the similarity of the
+// names.
+func probableDuplicate(a, b *models.Song, threshold float64) (float64, bool) {
+	score := similarity(a.Name, b.Name)
+
+	return score, score >= threshold &&
+		similarity(a.Group, b.Group) >= threshold &&
+		releaseDatesOverlap(a.ReleaseDate, b.ReleaseDate)
+}
+
+// releaseDatesOverlap reports whether the periods of a and b overlap, which
+// unknown dates always do.
+func releaseDatesOverlap(a, b models.ReleaseDate) bool {
+	if a.IsZero() || b.IsZero() {
+		return true
+	}
+
+	return !a.Date.After(b.End()) && !b.Date.After(a.End())
+}
+
+// scoredRow scans the columns of a song followed by a similarity score.
+type scoredRow struct {
+	row   rowScanner
+	score *float64
+}
+
+func (r scoredRow) Scan(dest ...any) error {
+	return r.row.Scan(append(dest, r.score)...) //nolint:wrapcheck
+}
@@ -2,49 +2,72 @@ package store
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/logger"
 	"github.com/iurikman/songs/internal/models"
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	log "github.com/sirupsen/logrus"
 )
 
+// queryHashLength is how many hex digits of the hash of a query are logged.
+const queryHashLength = 12
+
 func (p *Postgres) CreateSong(ctx context.Context, song models.Song) (*models.Song, error) {
-	query := `	INSERT INTO songs (id, release_date, name, music_group, text, link, deleted)
+	query := `	INSERT INTO songs (id, release_date, release_precision, name, music_group, text, deleted)
 				VALUES ($1, $2, $3, $4, $5, $6, $7)
-				RETURNING id, release_date, name, music_group, text, link, deleted
+				RETURNING id, release_date, release_precision, name, music_group, text, '', deleted
 				`
 
-	createdSong := new(models.Song)
-
-	err := p.db.QueryRow(
-		ctx,
-		query,
-		song.ID,
-		song.ReleaseDate,
-		song.Name,
-		song.Group,
-		song.Text,
-		song.Link,
-		song.Deleted,
-	).Scan(
-		&createdSong.ID,
-		&createdSong.ReleaseDate,
-		&createdSong.Name,
-		&createdSong.Group,
-		&createdSong.Text,
-		&createdSong.Link,
-		&createdSong.Deleted,
-	)
+	var createdSong *models.Song
+
+	releaseDate, releasePrecision := releaseDateArgs(song.ReleaseDate)
+
+	err := p.inTx(ctx, func(tx pgx.Tx) error {
+		var err error
+
+		createdSong, err = scanSong(tx.QueryRow(
+			ctx,
+			query,
+			song.ID,
+			releaseDate,
+			releasePrecision,
+			song.Name,
+			song.Group,
+			song.Text,
+			song.Deleted,
+		))
+		if err != nil {
+			return err
+		}
+
+		if createdSong.Link, err = setPrimaryLink(ctx, tx, createdSong.ID, song.Link); err != nil {
+			return err
+		}
+
+		if err := setTerms(ctx, tx, createdSong.ID, createdSong.Text); err != nil {
+			return err
+		}
+
+		return recordEvent(ctx, tx, models.EventSongCreated, createdSong)
+	})
 	if err != nil {
 		var pgErr *pgconn.PgError
 
 		switch {
 		case errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation:
+			logger.FromContext(ctx).WithField("constraint", pgErr.ConstraintName).Debug("song violates unique constraint")
+
 			return nil, models.ErrDuplicateSong
 		case err != nil:
 			return nil, fmt.Errorf("creating song err: %w", err)
@@ -54,40 +77,447 @@ func (p *Postgres) CreateSong(ctx context.Context, song models.Song) (*models.So
 	return createdSong, nil
 }
 
-func (p *Postgres) GetSongs(ctx context.Context, params models.Params) ([]*models.Song, error) {
-	songs := make([]*models.Song, 0, 1)
+// sortColumns maps the sorting values accepted by the API, both column and
+// JSON field names, to columns. Anything else is rejected instead of being
+// interpolated into the query.
+var sortColumns = map[string]string{
+	"id":           "id",
+	"name":         "name",
+	"music_group":  "music_group",
+	"musicGroup":   "music_group",
+	"release_date": "release_date",
+	"releaseDate":  "release_date",
+}
 
+func (p *Postgres) GetSongs(ctx context.Context, params models.Params) ([]*models.Song, error) {
 	query := `
-				SELECT id, release_date, name, music_group, text, link
+				SELECT id, release_date, release_precision, name, music_group, text, primary_link(id)
 				FROM songs
 				WHERE deleted=false
 			`
 
-	if params.Filter != "" {
-		query += fmt.Sprintf(" and name LIKE '%%%s%%'", params.Filter)
-	}
+	filter, args := songFilter(params)
+	query += filter
 
 	if params.Sorting != "" {
-		query += " ORDER BY " + params.Sorting
+		column, ok := sortColumns[params.Sorting]
+		if !ok {
+			return nil, models.ErrInvalidSorting
+		}
+
+		query += " ORDER BY " + column
 		if params.Descending {
 			query += " DESC"
 		}
+
+		// Songs with an unknown release date come last either way.
+		query += " NULLS LAST"
 	}
 
 	query += fmt.Sprintf(" OFFSET %d LIMIT %d", params.Offset, params.Limit)
 
-	rows, err := p.db.Query(ctx, query)
+	logger.FromContext(ctx).WithFields(queryFields("GetSongs", query)).Debug("listing songs")
+
+	rows, err := p.reader(ctx).Query(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("getting songs err: %w", err)
 	}
+
+	return scanSongs(rows)
+}
+
+// queryFields identifies a query built at run time for logging by its name
+// and a hash of its SQL, which tells its shapes apart without logging the SQL.
+func queryFields(name, query string) log.Fields {
+	sum := sha256.Sum256([]byte(query))
+
+	return log.Fields{"query": name, "query_hash": hex.EncodeToString(sum[:])[:queryHashLength]}
+}
+
+// songFilter returns the conditions params put on the songs listed, to be
+// ANDed with others, and their arguments.
+func songFilter(params models.Params) (string, []any) {
+	var filter string
+
+	args := make([]any, 0, 1)
+
+	if params.Filter != "" {
+		args = append(args, "%"+escapeLike(params.Filter)+"%")
+		filter += fmt.Sprintf(" and name LIKE $%d", len(args))
+	}
+
+	if !params.ReleasedFrom.IsZero() {
+		args = append(args, params.ReleasedFrom.Date)
+		filter += fmt.Sprintf(" and release_date >= $%d", len(args))
+	}
+
+	if !params.ReleasedTo.IsZero() {
+		args = append(args, params.ReleasedTo.End())
+		filter += fmt.Sprintf(" and release_date <= $%d", len(args))
+	}
+
+	if params.Tag != "" {
+		args = append(args, params.Tag)
+		filter += " and id IN (" + taggedSongs(fmt.Sprintf("$%d", len(args)), params.Descendants) + ")"
+	}
+
+	return filter, args
+}
+
+// GetSongsByIDs returns the songs with the given ids that are not deleted, in
+// no particular order.
+func (p *Postgres) GetSongsByIDs(ctx context.Context, ids []uuid.UUID) ([]*models.Song, error) {
+	query := `
+				SELECT id, release_date, release_precision, name, music_group, text, primary_link(id)
+				FROM songs
+				WHERE deleted=false and id = ANY($1)
+			`
+
+	rows, err := p.reader(ctx).Query(ctx, query, ids)
+	if err != nil {
+		return nil, fmt.Errorf("getting songs by ids err: %w", err)
+	}
+
+	return scanSongs(rows)
+}
+
+// GetSongsByGroups returns every song of the given music groups, ordered by
+// group and name.
+func (p *Postgres) GetSongsByGroups(ctx context.Context, groups []string) ([]*models.Song, error) {
+	query := `
+				SELECT id, release_date, release_precision, name, music_group, text, primary_link(id)
+				FROM songs
+				WHERE deleted=false and music_group = ANY($1)
+				ORDER BY music_group, name
+			`
+
+	rows, err := p.reader(ctx).Query(ctx, query, groups)
+	if err != nil {
+		return nil, fmt.Errorf("getting songs by groups err: %w", err)
+	}
+
+	return scanSongs(rows)
+}
+
+// GetGroups lists the distinct music groups, filtered by name and paged like
+// GetSongs. Sorting is always by name.
+func (p *Postgres) GetGroups(ctx context.Context, params models.Params) ([]string, error) {
+	query := `
+				SELECT DISTINCT music_group
+				FROM songs
+				WHERE deleted=false and music_group LIKE $1
+				ORDER BY music_group
+			`
+
+	if params.Descending {
+		query += " DESC"
+	}
+
+	query += fmt.Sprintf(" OFFSET %d LIMIT %d", params.Offset, params.Limit)
+
+	rows, err := p.reader(ctx).Query(ctx, query, "%"+escapeLike(params.Filter)+"%")
+	if err != nil {
+		return nil, fmt.Errorf("getting groups err: %w", err)
+	}
 	defer rows.Close()
 
+	groups := make([]string, 0, params.Limit)
+
 	for rows.Next() {
-		song := new(models.Song)
+		var group string
+
+		if err := rows.Scan(&group); err != nil {
+			return nil, fmt.Errorf("scanning group err: %w", err)
+		}
+
+		groups = append(groups, group)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading groups err: %w", err)
+	}
+
+	return groups, nil
+}
+
+// MergeSongs replaces the song id with song and merges the sources into it:
+// they are deleted and point at it, as do the songs merged into them before,
+// so that a merged song always points at a song that is not. The links of the
+// sources move to the song, but for those it already has, and so do their
+// tags. Every source is recorded as merged with the merged song as payload.
+func (p *Postgres) MergeSongs(ctx context.Context, id uuid.UUID, song models.Song, sources []uuid.UUID) (*models.Song, error) {
+	tombstone := `
+				UPDATE songs SET deleted = true, merged_into = $1
+				WHERE deleted = false and id = ANY($2)
+				RETURNING id
+			`
+
+	update := `	UPDATE songs SET release_date = $2, release_precision = $3, name = $4, music_group = $5, text = $6
+				WHERE id = $1 and deleted = false
+				RETURNING id, release_date, release_precision, name, music_group, text, '', deleted
+				`
+
+	var mergedSong *models.Song
+
+	releaseDate, releasePrecision := releaseDateArgs(song.ReleaseDate)
+
+	err := p.inTx(ctx, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, tombstone, id, sources)
+		if err != nil {
+			return fmt.Errorf("tombstoning sources err: %w", err)
+		}
+
+		merged, err := pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+		if err != nil {
+			return fmt.Errorf("tombstoning sources err: %w", err)
+		}
+
+		if len(merged) != len(sources) {
+			return pgx.ErrNoRows
+		}
+
+		if err := forgetTerms(ctx, tx, merged); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(ctx, `UPDATE songs SET merged_into = $1 WHERE merged_into = ANY($2)`, id, sources); err != nil {
+			return fmt.Errorf("re-pointing merged songs err: %w", err)
+		}
+
+		if err := moveLinks(ctx, tx, id, sources); err != nil {
+			return err
+		}
+
+		if err := moveTags(ctx, tx, id, sources); err != nil {
+			return err
+		}
+
+		mergedSong, err = scanSong(tx.QueryRow(
+			ctx,
+			update,
+			id,
+			releaseDate,
+			releasePrecision,
+			song.Name,
+			song.Group,
+			song.Text,
+		))
+		if err != nil {
+			return err
+		}
+
+		if mergedSong.Link, err = setPrimaryLink(ctx, tx, id, song.Link); err != nil {
+			return err
+		}
+
+		if err := setTerms(ctx, tx, id, mergedSong.Text); err != nil {
+			return err
+		}
+
+		for _, source := range merged {
+			if err := recordSongEvent(ctx, tx, models.EventSongMerged, source, mergedSong); err != nil {
+				return err
+			}
+		}
+
+		return recordEvent(ctx, tx, models.EventSongUpdated, mergedSong)
+	})
+
+	var pgErr *pgconn.PgError
+
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return nil, models.ErrSongNotFound
+	case errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation:
+		return nil, models.ErrDuplicateSong
+	case err != nil:
+		return nil, fmt.Errorf("merging songs err: %w", err)
+	}
+
+	return mergedSong, nil
+}
+
+// MergedInto returns the song the song id was merged into.
+func (p *Postgres) MergedInto(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	var into uuid.UUID
+
+	err := p.reader(ctx).QueryRow(ctx, `SELECT merged_into FROM songs WHERE id = $1 and merged_into IS NOT NULL`, id).Scan(&into)
+
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return uuid.Nil, models.ErrSongNotFound
+	case err != nil:
+		return uuid.Nil, fmt.Errorf("getting merged song err: %w", err)
+	}
+
+	return into, nil
+}
+
+// SimilarSongs returns up to limit songs that are probably duplicates of
+// song, most similar first. It reads from the primary, so that a song just
+// created is found.
+func (p *Postgres) SimilarSongs(ctx context.Context, song models.Song, threshold float64, limit int) ([]*models.SimilarSong, error) {
+	query := `
+				SELECT id, release_date, release_precision, name, music_group, text, primary_link(id), deleted, similarity(name, $1) AS score
+				FROM songs
+				WHERE deleted=false and id <> $2 and name % $1 and similarity(music_group, $3) >= $4
+					and ($5::date IS NULL or release_date IS NULL
+						or (release_date <= release_end($5, $6) and $5 <= release_end(release_date, release_precision)))
+				ORDER BY score DESC, name
+				LIMIT $7
+			`
+
+	releaseDate, releasePrecision := releaseDateArgs(song.ReleaseDate)
+
+	similar := make([]*models.SimilarSong, 0, 1)
+
+	err := withSimilarityThreshold(ctx, p.db, threshold, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, query, song.Name, song.ID, song.Group, threshold, releaseDate, releasePrecision, limit)
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var score float64
+
+			song, err := scanSong(scoredRow{row: rows, score: &score})
+			if err != nil {
+				return err
+			}
+
+			similar = append(similar, &models.SimilarSong{Song: *song, Similarity: score})
+		}
+
+		return rows.Err() //nolint:wrapcheck
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getting similar songs err: %w", err)
+	}
+
+	return similar, nil
+}
+
+// SimilarSongPairs returns every pair of songs that are probably duplicates.
+func (p *Postgres) SimilarSongPairs(ctx context.Context, threshold float64) ([]models.SongPair, error) {
+	query := `
+				SELECT a.id, b.id, similarity(a.name, b.name)
+				FROM songs a JOIN songs b ON a.id < b.id and a.name % b.name
+				WHERE a.deleted=false and b.deleted=false and similarity(a.music_group, b.music_group) >= $1
+					and (a.release_date IS NULL or b.release_date IS NULL
+						or (a.release_date <= release_end(b.release_date, b.release_precision)
+							and b.release_date <= release_end(a.release_date, a.release_precision)))
+			`
+
+	pairs := make([]models.SongPair, 0)
+
+	err := withSimilarityThreshold(ctx, p.reader(ctx), threshold, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, query, threshold)
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var pair models.SongPair
+
+			if err := rows.Scan(&pair.A, &pair.B, &pair.Similarity); err != nil {
+				return err //nolint:wrapcheck
+			}
+
+			pairs = append(pairs, pair)
+		}
+
+		return rows.Err() //nolint:wrapcheck
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getting similar song pairs err: %w", err)
+	}
+
+	return pairs, nil
+}
+
+// withSimilarityThreshold runs fn in a read transaction on pool where the %
+// operator of pg_trgm, which can use the trigram index, matches strings at
+// least threshold similar.
+func withSimilarityThreshold(ctx context.Context, pool *pgxpool.Pool, threshold float64, fn func(tx pgx.Tx) error) error {
+	return pgx.BeginTxFunc(ctx, pool, pgx.TxOptions{AccessMode: pgx.ReadOnly}, func(tx pgx.Tx) error { //nolint:wrapcheck
+		_, err := tx.Exec(ctx, `SELECT set_config('pg_trgm.similarity_threshold', $1, true)`,
+			strconv.FormatFloat(threshold, 'f', -1, 64))
+		if err != nil {
+			return fmt.Errorf("setting similarity threshold err: %w", err)
+		}
+
+		return fn(tx)
+	})
+}
+
+// releaseDateArgs returns the release_date and release_precision columns of
+// date, NULL when it is unknown.
+func releaseDateArgs(date models.ReleaseDate) (any, any) {
+	if date.IsZero() {
+		return nil, nil
+	}
+
+	return date.Date, date.Precision
+}
+
+// releaseDateColumns receives the release_date and release_precision columns
+// of a row.
+type releaseDateColumns struct {
+	date      *time.Time
+	precision *string
+}
+
+func (c releaseDateColumns) releaseDate() models.ReleaseDate {
+	if c.date == nil || c.precision == nil {
+		return models.ReleaseDate{}
+	}
+
+	return models.NewReleaseDate(*c.date, *c.precision)
+}
+
+// scanSong scans the columns of a song followed by deleted.
+func scanSong(row pgx.Row) (*models.Song, error) {
+	var (
+		song        models.Song
+		releaseDate releaseDateColumns
+	)
+
+	err := row.Scan(
+		&song.ID,
+		&releaseDate.date,
+		&releaseDate.precision,
+		&song.Name,
+		&song.Group,
+		&song.Text,
+		&song.Link,
+		&song.Deleted,
+	)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	song.ReleaseDate = releaseDate.releaseDate()
+
+	return &song, nil
+}
+
+func scanSongs(rows pgx.Rows) ([]*models.Song, error) {
+	defer rows.Close()
+
+	songs := make([]*models.Song, 0, 1)
+
+	for rows.Next() {
+		var (
+			song        models.Song
+			releaseDate releaseDateColumns
+		)
 
 		err := rows.Scan(
 			&song.ID,
-			&song.ReleaseDate,
+			&releaseDate.date,
+			&releaseDate.precision,
 			&song.Name,
 			&song.Group,
 			&song.Text,
@@ -97,12 +527,21 @@ func (p *Postgres) GetSongs(ctx context.Context, params models.Params) ([]*model
 			return nil, fmt.Errorf("scanning song err: %w", err)
 		}
 
-		songs = append(songs, song)
+		song.ReleaseDate = releaseDate.releaseDate()
+		songs = append(songs, &song)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading songs err: %w", err)
 	}
 
 	return songs, nil
 }
 
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
 func (p *Postgres) GetText(ctx context.Context, id uuid.UUID, verse int) (*string, error) {
 	text := ""
 
@@ -112,14 +551,20 @@ func (p *Postgres) GetText(ctx context.Context, id uuid.UUID, verse int) (*strin
 				WHERE id = $1 and deleted=false
 			`
 
-	err := p.db.QueryRow(ctx, query, id).Scan(&text)
-	if err != nil {
-		return nil, fmt.Errorf("p.db.QueryRow(ctx, query, id).Scan(&text) err: %w", err)
+	err := p.reader(ctx).QueryRow(ctx, query, id).Scan(&text)
+
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return nil, models.ErrSongNotFound
+	case err != nil:
+		return nil, fmt.Errorf("getting text err: %w", err)
 	}
 
-	splittedText := strings.Split(text, "\n\n")
+	splittedText := models.SplitVerses(text)
 
 	if verse < 1 || verse > len(splittedText) {
+		logger.FromContext(ctx).Debugf("verse %d out of range, song has %d verses", verse, len(splittedText))
+
 		return nil, models.ErrVerseIsNotValid
 	}
 
@@ -131,12 +576,24 @@ func (p *Postgres) GetText(ctx context.Context, id uuid.UUID, verse int) (*strin
 func (p *Postgres) DeleteSong(ctx context.Context, id uuid.UUID) error {
 	query := `
 				UPDATE songs SET deleted = true WHERE id = $1 and deleted = false
+				RETURNING id, release_date, release_precision, name, music_group, text, primary_link(id), deleted
 			`
 
-	result, err := p.db.Exec(ctx, query, id)
+	err := p.inTx(ctx, func(tx pgx.Tx) error {
+		deletedSong, err := scanSong(tx.QueryRow(ctx, query, id))
+		if err != nil {
+			return err
+		}
+
+		if err := forgetTerms(ctx, tx, []uuid.UUID{id}); err != nil {
+			return err
+		}
+
+		return recordEvent(ctx, tx, models.EventSongDeleted, deletedSong)
+	})
 
 	switch {
-	case result.RowsAffected() == 0:
+	case errors.Is(err, pgx.ErrNoRows):
 		return models.ErrSongNotFound
 	case err != nil:
 		return fmt.Errorf("deleting song error: %w", err)
@@ -146,34 +603,50 @@ func (p *Postgres) DeleteSong(ctx context.Context, id uuid.UUID) error {
 }
 
 func (p *Postgres) UpdateSong(ctx context.Context, id uuid.UUID, song models.Song) (*models.Song, error) {
-	query := `	UPDATE songs SET release_date = $2, name = $3, music_group = $4, text = $5, link = $6
-	            WHERE id = $1
-				RETURNING id, release_date, name, music_group, text, link
+	query := `	UPDATE songs SET release_date = $2, release_precision = $3, name = $4, music_group = $5, text = $6
+	            WHERE id = $1 and deleted = false
+				RETURNING id, release_date, release_precision, name, music_group, text, '', false
 				`
 
-	updatedSong := new(models.Song)
-
-	err := p.db.QueryRow(
-		ctx,
-		query,
-		id,
-		song.ReleaseDate,
-		song.Name,
-		song.Group,
-		song.Text,
-		song.Link,
-	).Scan(
-		&updatedSong.ID,
-		&updatedSong.ReleaseDate,
-		&updatedSong.Name,
-		&updatedSong.Group,
-		&updatedSong.Text,
-		&updatedSong.Link,
-	)
+	var updatedSong *models.Song
+
+	releaseDate, releasePrecision := releaseDateArgs(song.ReleaseDate)
+
+	err := p.inTx(ctx, func(tx pgx.Tx) error {
+		var err error
+
+		updatedSong, err = scanSong(tx.QueryRow(
+			ctx,
+			query,
+			id,
+			releaseDate,
+			releasePrecision,
+			song.Name,
+			song.Group,
+			song.Text,
+		))
+		if err != nil {
+			return err
+		}
+
+		if updatedSong.Link, err = setPrimaryLink(ctx, tx, id, song.Link); err != nil {
+			return err
+		}
+
+		if err := setTerms(ctx, tx, id, updatedSong.Text); err != nil {
+			return err
+		}
+
+		return recordEvent(ctx, tx, models.EventSongUpdated, updatedSong)
+	})
+
+	var pgErr *pgconn.PgError
 
 	switch {
 	case errors.Is(err, pgx.ErrNoRows):
 		return nil, models.ErrSongNotFound
+	case errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation:
+		return nil, models.ErrDuplicateSong
 	case err != nil:
 		return nil, fmt.Errorf("updating song err: %w", err)
 	}
@@ -0,0 +1,807 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"embed"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/logger"
+	"github.com/iurikman/songs/internal/models"
+	migrate "github.com/rubenv/sql-migrate"
+	log "github.com/sirupsen/logrus"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+//go:embed sqlite_migrations
+var sqliteMigrations embed.FS
+
+const sqliteMemory = ":memory:"
+
+// SQLite stores songs in a single file with the semantics of the Postgres
+// store. When the library has FTS5 it also keeps a full-text index of names,
+// groups and lyrics for Search.
+type SQLite struct {
+	db  *sql.DB
+	fts bool
+}
+
+// registerSQLiteFunctions adds the functions of Postgres extensions the
+// queries rely on to every SQLite connection opened afterwards.
+var registerSQLiteFunctions = sync.OnceValue(func() error {
+	return sqlite.RegisterDeterministicScalarFunction("similarity", 2, //nolint:wrapcheck
+		func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+			a, _ := args[0].(string)
+			b, _ := args[1].(string)
+
+			return similarity(a, b), nil
+		})
+})
+
+// NewSQLite opens the database at path, ":memory:" for a private in-memory
+// one.
+func NewSQLite(path string) (*SQLite, error) {
+	if err := registerSQLiteFunctions(); err != nil {
+		return nil, fmt.Errorf("registering SQLite functions err: %w", err)
+	}
+
+	dsn := path + "?_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)"
+	if path != sqliteMemory {
+		dsn += "&_pragma=journal_mode(WAL)"
+	}
+
+	log.WithField("path", path).Info("Opening SQLite database")
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("sql.Open(sqlite) err: %w", err)
+	}
+
+	// Every connection to :memory: is a database of its own.
+	if path == sqliteMemory {
+		db.SetMaxOpenConns(1)
+	}
+
+	return &SQLite{db: db}, nil
+}
+
+func (s *SQLite) Close() error {
+	if err := s.db.Close(); err != nil {
+		return fmt.Errorf("s.db.Close() err: %w", err)
+	}
+
+	return nil
+}
+
+// Migrate applies the SQLite migrations and, going up, sets up the full-text
+// index if the library supports it.
+func (s *SQLite) Migrate(direction migrate.MigrationDirection) error {
+	log.Infof("Running SQLite migrations in direction: %v", direction)
+
+	migrator, _ := s.Migrator()
+
+	if _, err := migrator.Exec(direction, 0); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// PendingMigrations returns the number of embedded migrations that have not
+// been applied, reading the migrations table without creating it.
+func (s *SQLite) PendingMigrations(ctx context.Context) (int, error) {
+	var exists bool
+
+	err := s.db.QueryRowContext(ctx, `SELECT count(*) > 0 FROM sqlite_master WHERE type = 'table' and name = ?`,
+		migrationsTable).Scan(&exists)
+	if err != nil {
+		return 0, fmt.Errorf("checking %s err: %w", migrationsTable, err)
+	}
+
+	applied := make(map[string]bool)
+
+	if exists {
+		rows, err := s.db.QueryContext(ctx, `SELECT id FROM `+migrationsTable)
+		if err != nil {
+			return 0, fmt.Errorf("getting applied migrations err: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var id string
+
+			if err := rows.Scan(&id); err != nil {
+				return 0, fmt.Errorf("scanning applied migration err: %w", err)
+			}
+
+			applied[id] = true
+		}
+
+		if err := rows.Err(); err != nil {
+			return 0, fmt.Errorf("reading applied migrations err: %w", err)
+		}
+	}
+
+	return countPending(sqliteMigrationSource(), applied)
+}
+
+func (s *SQLite) Name() string {
+	return "sqlite"
+}
+
+func (s *SQLite) Ping(ctx context.Context) error {
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("s.db.PingContext(ctx) err: %w", err)
+	}
+
+	return nil
+}
+
+func sqliteMigrationSource() migrate.AssetMigrationSource {
+	return assetSource(sqliteMigrations, "sqlite_migrations")
+}
+
+// setupSearch creates the FTS5 index kept in sync with songs by triggers.
+// It is not a migration because FTS5 is optional.
+func (s *SQLite) setupSearch(ctx context.Context) error {
+	var available bool
+
+	if err := s.db.QueryRowContext(ctx, `SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&available); err != nil {
+		return fmt.Errorf("checking FTS5 err: %w", err)
+	}
+
+	if !available {
+		log.Warn("SQLite has no FTS5, search falls back to substring matching")
+
+		return nil
+	}
+
+	var exists bool
+
+	err := s.db.QueryRowContext(ctx,
+		`SELECT count(*) > 0 FROM sqlite_master WHERE type = 'table' and name = 'songs_fts'`,
+	).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("checking songs_fts err: %w", err)
+	}
+
+	statements := []string{
+		`CREATE VIRTUAL TABLE IF NOT EXISTS songs_fts
+			USING fts5(name, music_group, text, content='songs', content_rowid='seq')`,
+		`CREATE TRIGGER IF NOT EXISTS songs_fts_insert AFTER INSERT ON songs BEGIN
+			INSERT INTO songs_fts (rowid, name, music_group, text)
+			VALUES (new.seq, new.name, new.music_group, new.text);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS songs_fts_delete AFTER DELETE ON songs BEGIN
+			INSERT INTO songs_fts (songs_fts, rowid, name, music_group, text)
+			VALUES ('delete', old.seq, old.name, old.music_group, old.text);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS songs_fts_update AFTER UPDATE ON songs BEGIN
+			INSERT INTO songs_fts (songs_fts, rowid, name, music_group, text)
+			VALUES ('delete', old.seq, old.name, old.music_group, old.text);
+			INSERT INTO songs_fts (rowid, name, music_group, text)
+			VALUES (new.seq, new.name, new.music_group, new.text);
+		END`,
+	}
+
+	if !exists {
+		statements = append(statements, `INSERT INTO songs_fts (songs_fts) VALUES ('rebuild')`)
+	}
+
+	for _, statement := range statements {
+		if _, err := s.db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("setting up songs_fts err: %w", err)
+		}
+	}
+
+	s.fts = true
+
+	return nil
+}
+
+// sqliteSongColumns are the columns of a song, its link being the URL of its
+// primary link.
+var sqliteSongColumns = sqliteSongColumnsOf("songs")
+
+// sqliteWrittenColumns are the columns of a song written by a statement, which
+// cannot read its primary link yet.
+const sqliteWrittenColumns = `id, release_date, release_precision, name, music_group, text, '', deleted`
+
+// sqliteSongColumnsOf returns the columns of the songs in table.
+func sqliteSongColumnsOf(table string) string {
+	return fmt.Sprintf(`%[1]s.id, %[1]s.release_date, %[1]s.release_precision, %[1]s.name, %[1]s.music_group, %[1]s.text,
+		coalesce((SELECT l.url FROM song_links l WHERE l.song_id = %[1]s.id and l.is_primary), ''), %[1]s.deleted`, table)
+}
+
+// sqliteDateLayout is how release_date holds the first day of the release
+// period, so that dates compare and sort as text.
+const sqliteDateLayout = "2006-01-02"
+
+// sqliteReleaseDate returns the release_date and release_precision columns of
+// date, empty when it is unknown.
+func sqliteReleaseDate(date models.ReleaseDate) (string, string) {
+	if date.IsZero() {
+		return "", ""
+	}
+
+	return date.Date.Format(sqliteDateLayout), date.Precision
+}
+
+func (s *SQLite) CreateSong(ctx context.Context, song models.Song) (*models.Song, error) {
+	query := `	INSERT INTO songs (id, release_date, release_precision, name, music_group, text, deleted, created_at)
+				VALUES (?, ?, ?, ?, ?, ?, ?, strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+				RETURNING ` + sqliteWrittenColumns
+
+	releaseDate, releasePrecision := sqliteReleaseDate(song.ReleaseDate)
+
+	var created *models.Song
+
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+
+		created, err = scanSQLiteSong(tx.QueryRowContext(
+			ctx,
+			query,
+			song.ID.String(),
+			releaseDate,
+			releasePrecision,
+			song.Name,
+			song.Group,
+			song.Text,
+			song.Deleted,
+		))
+		if err != nil {
+			return err
+		}
+
+		if created.Link, err = sqliteSetPrimaryLink(ctx, tx, created.ID, song.Link); err != nil {
+			return err
+		}
+
+		return sqliteSetTerms(ctx, tx, created.ID, created.Text)
+	})
+
+	switch {
+	case isSQLiteConflict(err):
+		logger.FromContext(ctx).Debug("song violates unique constraint")
+
+		return nil, models.ErrDuplicateSong
+	case err != nil:
+		return nil, fmt.Errorf("creating song err: %w", err)
+	}
+
+	return created, nil
+}
+
+func (s *SQLite) GetSongs(ctx context.Context, params models.Params) ([]*models.Song, error) {
+	filter, args := sqliteSongFilter(params)
+	query := `SELECT ` + sqliteSongColumns + ` FROM songs WHERE deleted = 0` + filter
+
+	// Without an explicit sorting songs come in insertion order, like Memory.
+	orderBy := "seq"
+
+	if params.Sorting != "" {
+		column, ok := sortColumns[params.Sorting]
+		if !ok {
+			return nil, models.ErrInvalidSorting
+		}
+
+		// Unknown release dates are empty, they come last either way like
+		// NULLs do in Postgres.
+		orderBy = "nullif(" + column + ", '')"
+		if params.Descending {
+			orderBy += " DESC"
+		}
+
+		orderBy += " NULLS LAST"
+	}
+
+	query += " ORDER BY " + orderBy + " LIMIT ? OFFSET ?"
+	args = append(args, params.Limit, params.Offset)
+
+	logger.FromContext(ctx).WithFields(queryFields("GetSongs", query)).Debug("listing songs")
+
+	return s.querySongs(ctx, query, args...)
+}
+
+// sqliteSongFilter is songFilter for SQLite.
+func sqliteSongFilter(params models.Params) (string, []any) {
+	filter := ` and instr(name, ?) > 0`
+	args := []any{params.Filter}
+
+	if !params.ReleasedFrom.IsZero() {
+		filter += ` and release_date <> '' and release_date >= ?`
+		args = append(args, params.ReleasedFrom.Date.Format(sqliteDateLayout))
+	}
+
+	if !params.ReleasedTo.IsZero() {
+		filter += ` and release_date <> '' and release_date <= ?`
+		args = append(args, params.ReleasedTo.End().Format(sqliteDateLayout))
+	}
+
+	if params.Tag != "" {
+		filter += ` and id IN (` + taggedSongs("?", params.Descendants) + `)`
+		args = append(args, params.Tag)
+	}
+
+	return filter, args
+}
+
+func (s *SQLite) GetSongsByIDs(ctx context.Context, ids []uuid.UUID) ([]*models.Song, error) {
+	if len(ids) == 0 {
+		return []*models.Song{}, nil
+	}
+
+	args := make([]any, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id.String())
+	}
+
+	query := `SELECT ` + sqliteSongColumns + ` FROM songs WHERE deleted = 0 and id IN (` + placeholders(len(ids)) + `)`
+
+	return s.querySongs(ctx, query, args...)
+}
+
+func (s *SQLite) GetSongsByGroups(ctx context.Context, groups []string) ([]*models.Song, error) {
+	if len(groups) == 0 {
+		return []*models.Song{}, nil
+	}
+
+	args := make([]any, 0, len(groups))
+	for _, group := range groups {
+		args = append(args, group)
+	}
+
+	query := `SELECT ` + sqliteSongColumns + ` FROM songs
+				WHERE deleted = 0 and music_group IN (` + placeholders(len(groups)) + `)
+				ORDER BY music_group, name`
+
+	return s.querySongs(ctx, query, args...)
+}
+
+func (s *SQLite) GetGroups(ctx context.Context, params models.Params) ([]string, error) {
+	query := `SELECT DISTINCT music_group FROM songs WHERE deleted = 0 and instr(music_group, ?) > 0 ORDER BY music_group`
+
+	if params.Descending {
+		query += " DESC"
+	}
+
+	query += " LIMIT ? OFFSET ?"
+
+	rows, err := s.db.QueryContext(ctx, query, params.Filter, params.Limit, params.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("getting groups err: %w", err)
+	}
+	defer rows.Close()
+
+	groups := make([]string, 0, params.Limit)
+
+	for rows.Next() {
+		var group string
+
+		if err := rows.Scan(&group); err != nil {
+			return nil, fmt.Errorf("scanning group err: %w", err)
+		}
+
+		groups = append(groups, group)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading groups err: %w", err)
+	}
+
+	return groups, nil
+}
+
+func (s *SQLite) GetText(ctx context.Context, id uuid.UUID, verse int) (*string, error) {
+	var text string
+
+	err := s.db.QueryRowContext(ctx, `SELECT text FROM songs WHERE id = ? and deleted = 0`, id.String()).Scan(&text)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, models.ErrSongNotFound
+	case err != nil:
+		return nil, fmt.Errorf("getting text err: %w", err)
+	}
+
+	verses := models.SplitVerses(text)
+
+	if verse < 1 || verse > len(verses) {
+		logger.FromContext(ctx).Debugf("verse %d out of range, song has %d verses", verse, len(verses))
+
+		return nil, models.ErrVerseIsNotValid
+	}
+
+	return &verses[verse-1], nil
+}
+
+func (s *SQLite) DeleteSong(ctx context.Context, id uuid.UUID) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `UPDATE songs SET deleted = 1 WHERE id = ? and deleted = 0`, id.String())
+		if err != nil {
+			return fmt.Errorf("deleting song error: %w", err)
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("result.RowsAffected() err: %w", err)
+		}
+
+		if affected == 0 {
+			return models.ErrSongNotFound
+		}
+
+		return sqliteForgetTerms(ctx, tx, []any{id.String()})
+	})
+}
+
+func (s *SQLite) UpdateSong(ctx context.Context, id uuid.UUID, song models.Song) (*models.Song, error) {
+	query := `	UPDATE songs SET release_date = ?, release_precision = ?, name = ?, music_group = ?, text = ?
+				WHERE id = ? and deleted = 0
+				RETURNING ` + sqliteWrittenColumns
+
+	releaseDate, releasePrecision := sqliteReleaseDate(song.ReleaseDate)
+
+	var updated *models.Song
+
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+
+		updated, err = scanSQLiteSong(tx.QueryRowContext(
+			ctx,
+			query,
+			releaseDate,
+			releasePrecision,
+			song.Name,
+			song.Group,
+			song.Text,
+			id.String(),
+		))
+		if err != nil {
+			return err
+		}
+
+		if updated.Link, err = sqliteSetPrimaryLink(ctx, tx, id, song.Link); err != nil {
+			return err
+		}
+
+		return sqliteSetTerms(ctx, tx, id, updated.Text)
+	})
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, models.ErrSongNotFound
+	case isSQLiteConflict(err):
+		return nil, models.ErrDuplicateSong
+	case err != nil:
+		return nil, fmt.Errorf("updating song err: %w", err)
+	}
+
+	// The Postgres store does not report deleted on update either.
+	updated.Deleted = false
+
+	return updated, nil
+}
+
+// MergeSongs replaces the song id with song and merges the sources into it:
+// they are deleted and point at it, as do the songs merged into them before.
+// The links of the sources move to the song, but for those it already has, and
+// so do their tags.
+func (s *SQLite) MergeSongs(ctx context.Context, id uuid.UUID, song models.Song, sources []uuid.UUID) (*models.Song, error) {
+	args := make([]any, 0, len(sources)+1)
+	args = append(args, id.String())
+
+	for _, source := range sources {
+		args = append(args, source.String())
+	}
+
+	releaseDate, releasePrecision := sqliteReleaseDate(song.ReleaseDate)
+
+	var merged *models.Song
+
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `UPDATE songs SET deleted = 1, merged_into = ?
+			WHERE deleted = 0 and id IN (`+placeholders(len(sources))+`)`, args...)
+		if err != nil {
+			return fmt.Errorf("merging songs err: %w", err)
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("result.RowsAffected() err: %w", err)
+		}
+
+		if affected != int64(len(sources)) {
+			return models.ErrSongNotFound
+		}
+
+		if err := sqliteForgetTerms(ctx, tx, args[1:]); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE songs SET merged_into = ?
+			WHERE merged_into IN (`+placeholders(len(sources))+`)`, args...)
+		if err != nil {
+			return fmt.Errorf("re-pointing merged songs err: %w", err)
+		}
+
+		if err := sqliteMoveLinks(ctx, tx, args); err != nil {
+			return err
+		}
+
+		if err := sqliteMoveTags(ctx, tx, args); err != nil {
+			return err
+		}
+
+		merged, err = scanSQLiteSong(tx.QueryRowContext(ctx, `UPDATE songs
+			SET release_date = ?, release_precision = ?, name = ?, music_group = ?, text = ?
+			WHERE id = ? and deleted = 0
+			RETURNING `+sqliteWrittenColumns,
+			releaseDate,
+			releasePrecision,
+			song.Name,
+			song.Group,
+			song.Text,
+			id.String(),
+		))
+		if err != nil {
+			return err
+		}
+
+		if merged.Link, err = sqliteSetPrimaryLink(ctx, tx, id, song.Link); err != nil {
+			return err
+		}
+
+		return sqliteSetTerms(ctx, tx, id, merged.Text)
+	})
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows), errors.Is(err, models.ErrSongNotFound):
+		return nil, models.ErrSongNotFound
+	case isSQLiteConflict(err):
+		return nil, models.ErrDuplicateSong
+	case err != nil:
+		return nil, fmt.Errorf("updating merged song err: %w", err)
+	}
+
+	return merged, nil
+}
+
+// MergedInto returns the song the song id was merged into.
+func (s *SQLite) MergedInto(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	var into string
+
+	err := s.db.QueryRowContext(ctx, `SELECT merged_into FROM songs WHERE id = ? and merged_into IS NOT NULL`,
+		id.String()).Scan(&into)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return uuid.Nil, models.ErrSongNotFound
+	case err != nil:
+		return uuid.Nil, fmt.Errorf("getting merged song err: %w", err)
+	}
+
+	mergedInto, err := uuid.Parse(into)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("uuid.Parse(merged_into) err: %w", err)
+	}
+
+	return mergedInto, nil
+}
+
+// Search finds songs whose name, group or lyrics contain every word of
+// query, best matches first when FTS5 is available.
+func (s *SQLite) Search(ctx context.Context, query string, params models.Params) ([]*models.Song, error) {
+	terms := strings.Fields(query)
+	if len(terms) == 0 {
+		return []*models.Song{}, nil
+	}
+
+	if !s.fts {
+		where := make([]string, 0, len(terms))
+		args := make([]any, 0, len(terms)+2)
+
+		for _, term := range terms {
+			where = append(where, `instr(lower(name || ' ' || music_group || ' ' || text), lower(?)) > 0`)
+			args = append(args, term)
+		}
+
+		args = append(args, params.Limit, params.Offset)
+
+		return s.querySongs(ctx, `SELECT `+sqliteSongColumns+` FROM songs
+			WHERE deleted = 0 and `+strings.Join(where, " and ")+`
+			ORDER BY seq LIMIT ? OFFSET ?`, args...)
+	}
+
+	// Every term is quoted so that user input is never read as FTS5 syntax,
+	// and matched as a prefix.
+	quoted := make([]string, 0, len(terms))
+	for _, term := range terms {
+		quoted = append(quoted, `"`+strings.ReplaceAll(term, `"`, `""`)+`"*`)
+	}
+
+	return s.querySongs(ctx, `SELECT `+sqliteSongColumnsOf("s")+`
+		FROM songs_fts JOIN songs s ON s.seq = songs_fts.rowid
+		WHERE songs_fts MATCH ? and s.deleted = 0
+		ORDER BY songs_fts.rank LIMIT ? OFFSET ?`,
+		strings.Join(quoted, " "), params.Limit, params.Offset)
+}
+
+// sqliteReleaseEnd is the last day of the release period of the songs in
+// table, as YYYY-MM-DD.
+func sqliteReleaseEnd(table string) string {
+	return fmt.Sprintf(`date(%[1]s.release_date, CASE %[1]s.release_precision
+		WHEN 'year' THEN '+1 year' WHEN 'month' THEN '+1 month' ELSE '+1 day' END, '-1 day')`, table)
+}
+
+// SimilarSongs returns up to limit songs that are probably duplicates of
+// song, most similar first.
+func (s *SQLite) SimilarSongs(ctx context.Context, song models.Song, threshold float64, limit int) ([]*models.SimilarSong, error) {
+	releaseDate, _ := sqliteReleaseDate(song.ReleaseDate)
+
+	releaseEnd := ""
+	if releaseDate != "" {
+		releaseEnd = song.ReleaseDate.End().Format(sqliteDateLayout)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+sqliteSongColumnsOf("s")+`, similarity(s.name, ?1) AS score
+		FROM songs s
+		WHERE s.deleted = 0 and s.id <> ?2 and similarity(s.name, ?1) >= ?3 and similarity(s.music_group, ?4) >= ?3
+			and (?5 = '' or s.release_date = '' or (s.release_date <= ?6 and ?5 <= `+sqliteReleaseEnd("s")+`))
+		ORDER BY score DESC, s.name LIMIT ?7`,
+		song.Name, song.ID.String(), threshold, song.Group, releaseDate, releaseEnd, limit)
+	if err != nil {
+		return nil, fmt.Errorf("getting similar songs err: %w", err)
+	}
+	defer rows.Close()
+
+	similar := make([]*models.SimilarSong, 0, 1)
+
+	for rows.Next() {
+		var score float64
+
+		song, err := scanSQLiteSong(scoredRow{row: rows, score: &score})
+		if err != nil {
+			return nil, fmt.Errorf("scanning similar song err: %w", err)
+		}
+
+		similar = append(similar, &models.SimilarSong{Song: *song, Similarity: score})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading similar songs err: %w", err)
+	}
+
+	return similar, nil
+}
+
+// SimilarSongPairs returns every pair of songs that are probably duplicates.
+func (s *SQLite) SimilarSongPairs(ctx context.Context, threshold float64) ([]models.SongPair, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT a.id, b.id, similarity(a.name, b.name)
+		FROM songs a JOIN songs b ON a.id < b.id
+		WHERE a.deleted = 0 and b.deleted = 0
+			and similarity(a.name, b.name) >= ?1 and similarity(a.music_group, b.music_group) >= ?1
+			and (a.release_date = '' or b.release_date = ''
+				or (a.release_date <= `+sqliteReleaseEnd("b")+` and b.release_date <= `+sqliteReleaseEnd("a")+`))`,
+		threshold)
+	if err != nil {
+		return nil, fmt.Errorf("getting similar song pairs err: %w", err)
+	}
+	defer rows.Close()
+
+	pairs := make([]models.SongPair, 0)
+
+	for rows.Next() {
+		var (
+			pair models.SongPair
+			a, b string
+		)
+
+		if err := rows.Scan(&a, &b, &pair.Similarity); err != nil {
+			return nil, fmt.Errorf("scanning similar song pair err: %w", err)
+		}
+
+		if pair.A, err = uuid.Parse(a); err != nil {
+			return nil, fmt.Errorf("uuid.Parse(%q) err: %w", a, err)
+		}
+
+		if pair.B, err = uuid.Parse(b); err != nil {
+			return nil, fmt.Errorf("uuid.Parse(%q) err: %w", b, err)
+		}
+
+		pairs = append(pairs, pair)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading similar song pairs err: %w", err)
+	}
+
+	return pairs, nil
+}
+
+func (s *SQLite) querySongs(ctx context.Context, query string, args ...any) ([]*models.Song, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("getting songs err: %w", err)
+	}
+	defer rows.Close()
+
+	songs := make([]*models.Song, 0, 1)
+
+	for rows.Next() {
+		song, err := scanSQLiteSong(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning song err: %w", err)
+		}
+
+		songs = append(songs, song)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading songs err: %w", err)
+	}
+
+	return songs, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSQLiteSong(row rowScanner) (*models.Song, error) {
+	var (
+		song                              models.Song
+		id, releaseDate, releasePrecision string
+	)
+
+	err := row.Scan(&id, &releaseDate, &releasePrecision, &song.Name, &song.Group, &song.Text, &song.Link, &song.Deleted)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	song.ID, err = uuid.Parse(id)
+	if err != nil {
+		return nil, fmt.Errorf("uuid.Parse(%q) err: %w", id, err)
+	}
+
+	if releaseDate != "" {
+		date, err := time.Parse(sqliteDateLayout, releaseDate)
+		if err != nil {
+			return nil, fmt.Errorf("time.Parse(%q) err: %w", releaseDate, err)
+		}
+
+		song.ReleaseDate = models.NewReleaseDate(date, releasePrecision)
+	}
+
+	return &song, nil
+}
+
+func isSQLiteConflict(err error) bool {
+	var sqliteErr *sqlite.Error
+
+	return errors.As(err, &sqliteErr) &&
+		(sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY)
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// numberedPlaceholders returns the placeholders ?from to ?to, which can be
+// used along with others numbered in the same statement.
+func numberedPlaceholders(from, to int) string {
+	numbered := make([]string, 0, to-from+1)
+	for i := from; i <= to; i++ {
+		numbered = append(numbered, fmt.Sprintf("?%d", i))
+	}
+
+	return strings.Join(numbered, ", ")
+}
@@ -0,0 +1,128 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/models"
+)
+
+// ClaimLinks is Postgres.ClaimLinks for SQLite, which has a single writer.
+func (s *SQLite) ClaimLinks(ctx context.Context, limit int, interval time.Duration) ([]*models.SongLink, error) {
+	now := time.Now().UTC()
+
+	rows, err := s.db.QueryContext(ctx, `
+		UPDATE song_links SET next_check_at = ?
+		WHERE id IN (
+			SELECT l.id FROM song_links l JOIN songs s ON s.id = l.song_id
+			WHERE s.deleted = 0 and l.next_check_at <= ?
+			ORDER BY l.next_check_at
+			LIMIT ?
+		)
+		RETURNING `+sqliteLinkColumns,
+		now.Add(interval).Format(sqliteTimeLayout), now.Format(sqliteTimeLayout), limit)
+	if err != nil {
+		return nil, fmt.Errorf("claiming links err: %w", err)
+	}
+	defer rows.Close()
+
+	links := make([]*models.SongLink, 0, limit)
+
+	for rows.Next() {
+		link, err := scanSQLiteLink(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning link err: %w", err)
+		}
+
+		links = append(links, link)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading claimed links err: %w", err)
+	}
+
+	return links, nil
+}
+
+// RecordLinkCheck is Postgres.RecordLinkCheck for SQLite.
+func (s *SQLite) RecordLinkCheck(
+	ctx context.Context,
+	id uuid.UUID,
+	check models.LinkCheck,
+	maxFailures int,
+) (*models.LinkCheck, error) {
+	query := `	UPDATE song_links
+				SET check_status = ?2, redirect_url = ?3, check_error = ?4, checked_at = ?5,
+					check_failures = CASE WHEN ?6 THEN 0 ELSE check_failures + 1 END,
+					broken = NOT ?6 and check_failures + 1 >= ?7
+				WHERE id = ?1
+				RETURNING check_status, redirect_url, check_error, checked_at, check_failures, broken`
+
+	var (
+		recorded  models.LinkCheck
+		checkedAt string
+	)
+
+	err := s.db.QueryRowContext(ctx, query, id.String(), check.Status, check.RedirectURL, check.Error,
+		check.CheckedAt.UTC().Format(sqliteTimeLayout), check.OK(), maxFailures).Scan(
+		&recorded.Status,
+		&recorded.RedirectURL,
+		&recorded.Error,
+		&checkedAt,
+		&recorded.Failures,
+		&recorded.Broken,
+	)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, models.ErrLinkNotFound
+	case err != nil:
+		return nil, fmt.Errorf("recording link check err: %w", err)
+	}
+
+	if recorded.CheckedAt, err = time.Parse(time.RFC3339Nano, checkedAt); err != nil {
+		return nil, fmt.Errorf("time.Parse(%q) err: %w", checkedAt, err)
+	}
+
+	return &recorded, nil
+}
+
+// BrokenLinks is Postgres.BrokenLinks for SQLite.
+func (s *SQLite) BrokenLinks(ctx context.Context, params models.Params) ([]*models.BrokenLink, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT l.id, l.song_id, l.platform, l.url, l.external_id, l.region, l.is_primary, l.created_at,
+			l.check_status, l.redirect_url, l.check_error, l.checked_at, l.check_failures, l.broken,
+			s.name, s.music_group
+		FROM song_links l JOIN songs s ON s.id = l.song_id
+		WHERE l.broken and s.deleted = 0
+		ORDER BY l.checked_at DESC, l.seq
+		LIMIT ? OFFSET ?`, params.Limit, params.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("getting broken links err: %w", err)
+	}
+	defer rows.Close()
+
+	links := make([]*models.BrokenLink, 0, 1)
+
+	for rows.Next() {
+		var broken models.BrokenLink
+
+		link, err := scanSQLiteLink(rows, &broken.Name, &broken.Group)
+		if err != nil {
+			return nil, fmt.Errorf("scanning broken link err: %w", err)
+		}
+
+		broken.SongLink = *link
+		links = append(links, &broken)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading broken links err: %w", err)
+	}
+
+	return links, nil
+}
@@ -0,0 +1,296 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/models"
+)
+
+const sqliteLinkColumns = `id, song_id, platform, url, external_id, region, is_primary, created_at,
+	check_status, redirect_url, check_error, checked_at, check_failures, broken`
+
+// sqliteTimeLayout is the layout of the times SQLite stores, the one of
+// strftime('%Y-%m-%dT%H:%M:%fZ'), which sorts as text.
+const sqliteTimeLayout = "2006-01-02T15:04:05.000Z"
+
+func (s *SQLite) GetLinks(ctx context.Context, songID uuid.UUID) ([]*models.SongLink, error) {
+	var links []*models.SongLink
+
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		if err := sqliteSongExists(ctx, tx, songID); err != nil {
+			return err
+		}
+
+		rows, err := tx.QueryContext(ctx, `SELECT `+sqliteLinkColumns+` FROM song_links
+			WHERE song_id = ? ORDER BY is_primary DESC, seq`, songID.String())
+		if err != nil {
+			return fmt.Errorf("getting links err: %w", err)
+		}
+		defer rows.Close()
+
+		links = make([]*models.SongLink, 0, 1)
+
+		for rows.Next() {
+			link, err := scanSQLiteLink(rows)
+			if err != nil {
+				return fmt.Errorf("scanning link err: %w", err)
+			}
+
+			links = append(links, link)
+		}
+
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("reading links err: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, sqliteLinkError(err, "getting links")
+	}
+
+	return links, nil
+}
+
+func (s *SQLite) CreateLink(ctx context.Context, link models.SongLink) (*models.SongLink, error) {
+	query := `	INSERT INTO song_links (id, song_id, platform, url, external_id, region, is_primary)
+				VALUES (?, ?, ?, ?, ?, ?, ?)
+				RETURNING ` + sqliteLinkColumns
+
+	var created *models.SongLink
+
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		if err := sqliteSongExists(ctx, tx, link.SongID); err != nil {
+			return err
+		}
+
+		if err := sqliteClearPrimaryLink(ctx, tx, link); err != nil {
+			return err
+		}
+
+		var err error
+
+		created, err = scanSQLiteLink(tx.QueryRowContext(ctx, query, link.ID.String(), link.SongID.String(),
+			link.Platform, link.URL, link.ExternalID, link.Region, link.Primary))
+
+		return err
+	})
+	if err != nil {
+		return nil, sqliteLinkError(err, "creating link")
+	}
+
+	return created, nil
+}
+
+func (s *SQLite) UpdateLink(ctx context.Context, link models.SongLink) (*models.SongLink, error) {
+	query := `	UPDATE song_links SET platform = ?, url = ?, external_id = ?, region = ?, is_primary = ?
+				WHERE id = ? and song_id = ?
+				RETURNING ` + sqliteLinkColumns
+
+	var updated *models.SongLink
+
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		if err := sqliteSongExists(ctx, tx, link.SongID); err != nil {
+			return err
+		}
+
+		if err := sqliteClearPrimaryLink(ctx, tx, link); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, `
+			UPDATE song_links
+			SET check_status = 0, redirect_url = '', check_error = '', checked_at = NULL, check_failures = 0,
+				broken = 0, next_check_at = ''
+			WHERE id = ? and song_id = ? and url <> ?`, link.ID.String(), link.SongID.String(), link.URL)
+		if err != nil {
+			return fmt.Errorf("resetting link check err: %w", err)
+		}
+
+		updated, err = scanSQLiteLink(tx.QueryRowContext(ctx, query, link.Platform, link.URL, link.ExternalID,
+			link.Region, link.Primary, link.ID.String(), link.SongID.String()))
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ErrLinkNotFound
+		}
+
+		return err
+	})
+	if err != nil {
+		return nil, sqliteLinkError(err, "updating link")
+	}
+
+	return updated, nil
+}
+
+func (s *SQLite) DeleteLink(ctx context.Context, songID, id uuid.UUID) error {
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		if err := sqliteSongExists(ctx, tx, songID); err != nil {
+			return err
+		}
+
+		result, err := tx.ExecContext(ctx, `DELETE FROM song_links WHERE id = ? and song_id = ?`,
+			id.String(), songID.String())
+		if err != nil {
+			return fmt.Errorf("deleting link err: %w", err)
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("result.RowsAffected() err: %w", err)
+		}
+
+		if affected == 0 {
+			return models.ErrLinkNotFound
+		}
+
+		return nil
+	})
+	if err != nil {
+		return sqliteLinkError(err, "deleting link")
+	}
+
+	return nil
+}
+
+func sqliteSongExists(ctx context.Context, tx *sql.Tx, id uuid.UUID) error {
+	var exists bool
+
+	err := tx.QueryRowContext(ctx, `SELECT exists (SELECT 1 FROM songs WHERE id = ? and deleted = 0)`,
+		id.String()).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("checking song err: %w", err)
+	}
+
+	if !exists {
+		return models.ErrSongNotFound
+	}
+
+	return nil
+}
+
+func sqliteClearPrimaryLink(ctx context.Context, tx *sql.Tx, link models.SongLink) error {
+	if !link.Primary {
+		return nil
+	}
+
+	_, err := tx.ExecContext(ctx, `UPDATE song_links SET is_primary = 0 WHERE song_id = ? and is_primary and id <> ?`,
+		link.SongID.String(), link.ID.String())
+	if err != nil {
+		return fmt.Errorf("clearing primary link err: %w", err)
+	}
+
+	return nil
+}
+
+// sqliteSetPrimaryLink is setPrimaryLink for SQLite.
+func sqliteSetPrimaryLink(ctx context.Context, tx *sql.Tx, songID uuid.UUID, raw string) (string, error) {
+	if raw == "" {
+		_, err := tx.ExecContext(ctx, `UPDATE song_links SET is_primary = 0 WHERE song_id = ? and is_primary`,
+			songID.String())
+		if err != nil {
+			return "", fmt.Errorf("clearing primary link err: %w", err)
+		}
+
+		return "", nil
+	}
+
+	link, ok := primaryLink(ctx, raw)
+	if !ok {
+		return sqliteSetPrimaryLink(ctx, tx, songID, "")
+	}
+
+	_, err := tx.ExecContext(ctx, `UPDATE song_links SET is_primary = 0 WHERE song_id = ? and is_primary and url <> ?`,
+		songID.String(), link.URL)
+	if err != nil {
+		return "", fmt.Errorf("clearing primary link err: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `	INSERT INTO song_links (id, song_id, platform, url, external_id, region, is_primary)
+									VALUES (?, ?, ?, ?, ?, ?, 1)
+									ON CONFLICT (song_id, url) DO UPDATE SET is_primary = 1`,
+		uuid.New().String(), songID.String(), link.Platform, link.URL, link.ExternalID, link.Region)
+	if err != nil {
+		return "", fmt.Errorf("setting primary link err: %w", err)
+	}
+
+	return link.URL, nil
+}
+
+// sqliteMoveLinks is moveLinks for SQLite, args being the song and then the
+// sources.
+func sqliteMoveLinks(ctx context.Context, tx *sql.Tx, args []any) error {
+	sources := numberedPlaceholders(2, len(args))
+
+	_, err := tx.ExecContext(ctx, `
+		DELETE FROM song_links AS l
+		WHERE l.song_id IN (`+sources+`) and exists (
+			SELECT 1 FROM song_links other
+			WHERE other.url = l.url and (other.song_id = ?1 or (other.song_id IN (`+sources+`) and other.seq < l.seq))
+		)`, args...)
+	if err != nil {
+		return fmt.Errorf("dropping duplicate links err: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE song_links SET song_id = ?1, is_primary = 0
+		WHERE song_id IN (`+sources+`)`, args...)
+	if err != nil {
+		return fmt.Errorf("moving links err: %w", err)
+	}
+
+	return nil
+}
+
+func sqliteLinkError(err error, action string) error {
+	switch {
+	case errors.Is(err, models.ErrSongNotFound), errors.Is(err, models.ErrLinkNotFound):
+		return err
+	case isSQLiteConflict(err):
+		return models.ErrDuplicateLink
+	default:
+		return fmt.Errorf("%s err: %w", action, err)
+	}
+}
+
+func scanSQLiteLink(row rowScanner, extra ...any) (*models.SongLink, error) {
+	var (
+		link                  models.SongLink
+		check                 models.LinkCheck
+		id, songID, createdAt string
+		checkedAt             sql.NullString
+	)
+
+	err := row.Scan(append([]any{
+		&id, &songID, &link.Platform, &link.URL, &link.ExternalID, &link.Region, &link.Primary, &createdAt,
+		&check.Status, &check.RedirectURL, &check.Error, &checkedAt, &check.Failures, &check.Broken,
+	}, extra...)...)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	if link.ID, err = uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("uuid.Parse(%q) err: %w", id, err)
+	}
+
+	if link.SongID, err = uuid.Parse(songID); err != nil {
+		return nil, fmt.Errorf("uuid.Parse(%q) err: %w", songID, err)
+	}
+
+	if link.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+		return nil, fmt.Errorf("time.Parse(%q) err: %w", createdAt, err)
+	}
+
+	if checkedAt.Valid {
+		if check.CheckedAt, err = time.Parse(time.RFC3339Nano, checkedAt.String); err != nil {
+			return nil, fmt.Errorf("time.Parse(%q) err: %w", checkedAt.String, err)
+		}
+
+		link.Check = &check
+	}
+
+	return &link, nil
+}
@@ -0,0 +1,23 @@
+-- +migrate Up
+
+-- seq keeps insertion order and is the stable rowid of the full-text index.
+CREATE TABLE songs (
+    seq integer primary key,
+    id text not null unique,
+    release_date text not null default '',
+    name text not null,
+    music_group text not null,
+    text text not null default '',
+    link text not null default '',
+    deleted integer not null default 0,
+
+    CONSTRAINT unique_song UNIQUE (release_date, name, music_group)
+);
+
+CREATE INDEX songs_music_group_idx ON songs (music_group);
+
+-- +migrate Down
+
+-- The full-text index is created outside migrations but lives on songs.
+DROP TABLE IF EXISTS songs_fts;
+DROP TABLE songs;
@@ -0,0 +1,61 @@
+-- +migrate Up
+
+-- Release dates that differ as text but not as dates, like "16.07.2006" and
+-- "2006-07-16", or that are both unknown, like "" and one that cannot be
+-- parsed, make duplicates of songs with the same name and group once the
+-- release dates migration parses them. They are kept here, to be merged or
+-- fixed by hand: the first of them, songs that are not deleted first, keeps
+-- the song and the others are deleted and renamed after their ids, out of the
+-- way of the unique key until the merge of conflicts migration merges them.
+-- The table does not reference songs, which the merges migration rebuilds.
+CREATE TABLE release_date_conflicts (
+    song_id text primary key,
+    duplicate_of text,
+    release_date text not null,
+    name text not null,
+    deleted integer not null
+);
+
+-- Release dates are parsed as the release dates migration does. Databases
+-- that applied it already, and so have release_precision, have no conflicts,
+-- it would have failed otherwise.
+INSERT INTO release_date_conflicts (song_id, duplicate_of, release_date, name, deleted)
+SELECT id, CASE WHEN rank > 1 THEN kept END, release_date, name, deleted
+FROM (
+    SELECT id, release_date, name, deleted,
+        first_value(id) OVER keys AS kept, row_number() OVER keys AS rank, count(*) OVER same AS songs
+    FROM (
+        SELECT *, CASE WHEN iso IS NOT NULL and date(iso) IS iso THEN iso ELSE '' END AS parsed
+        FROM (
+            SELECT id, seq, release_date, name, music_group, deleted,
+                CASE
+                    WHEN raw GLOB '[0-9][0-9].[0-9][0-9].[0-9][0-9][0-9][0-9]'
+                        THEN substr(raw, 7, 4) || '-' || substr(raw, 4, 2) || '-' || substr(raw, 1, 2)
+                    WHEN raw GLOB '[0-9][0-9][0-9][0-9]-[0-9][0-9]-[0-9][0-9]' THEN raw
+                    WHEN raw GLOB '[0-9][0-9].[0-9][0-9][0-9][0-9]' THEN substr(raw, 4, 4) || '-' || substr(raw, 1, 2) || '-01'
+                    WHEN raw GLOB '[0-9][0-9][0-9][0-9]-[0-9][0-9]' THEN raw || '-01'
+                    WHEN raw GLOB '[0-9][0-9][0-9][0-9]' THEN raw || '-01-01'
+                END AS iso
+            FROM (SELECT *, trim(release_date) AS raw FROM songs)
+            WHERE NOT EXISTS (SELECT 1 FROM pragma_table_info('songs') WHERE name = 'release_precision')
+        )
+    )
+    WINDOW same AS (PARTITION BY parsed, name, music_group),
+        keys AS (same ORDER BY deleted, seq)
+)
+WHERE songs > 1;
+
+UPDATE songs SET deleted = 1, name = name || ' (' || id || ')'
+WHERE id IN (SELECT song_id FROM release_date_conflicts WHERE duplicate_of IS NOT NULL);
+
+-- +migrate Down
+
+-- The release dates migration formats the dates it parsed when it goes down,
+-- which would make the songs in conflict duplicates again.
+UPDATE songs SET
+    release_date = (SELECT c.release_date FROM release_date_conflicts c WHERE c.song_id = songs.id),
+    name = (SELECT c.name FROM release_date_conflicts c WHERE c.song_id = songs.id),
+    deleted = (SELECT c.deleted FROM release_date_conflicts c WHERE c.song_id = songs.id)
+WHERE id IN (SELECT song_id FROM release_date_conflicts);
+
+DROP TABLE release_date_conflicts;
@@ -0,0 +1,59 @@
+-- +migrate Up
+
+-- release_date holds the first day of the release period as YYYY-MM-DD, ''
+-- when it is unknown, and release_precision tells the period. Release dates
+-- that could not be parsed are kept here, and left unknown on the songs, so
+-- that they can be fixed by hand.
+CREATE TABLE unparsed_release_dates (
+    song_id text primary key references songs (id) ON DELETE CASCADE,
+    release_date text not null
+);
+
+ALTER TABLE songs ADD COLUMN release_precision text not null default '';
+
+CREATE TABLE parsed_release_dates AS
+SELECT id, raw,
+    CASE
+        WHEN raw GLOB '[0-9][0-9].[0-9][0-9].[0-9][0-9][0-9][0-9]'
+            THEN substr(raw, 7, 4) || '-' || substr(raw, 4, 2) || '-' || substr(raw, 1, 2)
+        WHEN raw GLOB '[0-9][0-9][0-9][0-9]-[0-9][0-9]-[0-9][0-9]' THEN raw
+        WHEN raw GLOB '[0-9][0-9].[0-9][0-9][0-9][0-9]' THEN substr(raw, 4, 4) || '-' || substr(raw, 1, 2) || '-01'
+        WHEN raw GLOB '[0-9][0-9][0-9][0-9]-[0-9][0-9]' THEN raw || '-01'
+        WHEN raw GLOB '[0-9][0-9][0-9][0-9]' THEN raw || '-01-01'
+    END AS iso,
+    CASE
+        WHEN raw GLOB '[0-9][0-9].[0-9][0-9].[0-9][0-9][0-9][0-9]' THEN 'day'
+        WHEN raw GLOB '[0-9][0-9][0-9][0-9]-[0-9][0-9]-[0-9][0-9]' THEN 'day'
+        WHEN raw GLOB '[0-9][0-9].[0-9][0-9][0-9][0-9]' THEN 'month'
+        WHEN raw GLOB '[0-9][0-9][0-9][0-9]-[0-9][0-9]' THEN 'month'
+        WHEN raw GLOB '[0-9][0-9][0-9][0-9]' THEN 'year'
+    END AS precision
+FROM (SELECT id, trim(release_date) AS raw FROM songs WHERE trim(release_date) <> '');
+
+-- date() normalizes days out of range instead of rejecting them.
+INSERT INTO unparsed_release_dates (song_id, release_date)
+SELECT id, raw FROM parsed_release_dates WHERE iso IS NULL OR date(iso) IS NOT iso;
+
+DELETE FROM parsed_release_dates WHERE iso IS NULL OR date(iso) IS NOT iso;
+
+UPDATE songs SET
+    release_date = coalesce((SELECT iso FROM parsed_release_dates p WHERE p.id = songs.id), ''),
+    release_precision = coalesce((SELECT precision FROM parsed_release_dates p WHERE p.id = songs.id), '');
+
+DROP TABLE parsed_release_dates;
+
+-- +migrate Down
+
+UPDATE songs SET release_date = CASE release_precision
+    WHEN 'year' THEN substr(release_date, 1, 4)
+    WHEN 'month' THEN substr(release_date, 6, 2) || '.' || substr(release_date, 1, 4)
+    WHEN 'day' THEN substr(release_date, 9, 2) || '.' || substr(release_date, 6, 2) || '.' || substr(release_date, 1, 4)
+    ELSE ''
+END;
+
+UPDATE songs SET release_date = (SELECT u.release_date FROM unparsed_release_dates u WHERE u.song_id = songs.id)
+WHERE id IN (SELECT song_id FROM unparsed_release_dates);
+
+DROP TABLE unparsed_release_dates;
+
+ALTER TABLE songs DROP COLUMN release_precision;
@@ -0,0 +1,43 @@
+-- +migrate Up
+
+-- Songs merged into another one are deleted and point at it. They no longer
+-- hold their unique key, so that the merged song can take their values. The
+-- constraint of the table cannot be dropped, the table is rebuilt instead;
+-- seq is kept so the full-text index stays valid, and the unparsed release
+-- dates are kept aside from the cascade of dropping the table.
+CREATE TEMPORARY TABLE kept_unparsed_release_dates AS SELECT * FROM unparsed_release_dates;
+
+CREATE TABLE songs_rebuilt (
+    seq integer primary key,
+    id text not null unique,
+    release_date text not null default '',
+    name text not null,
+    music_group text not null,
+    text text not null default '',
+    link text not null default '',
+    deleted integer not null default 0,
+    release_precision text not null default '',
+    merged_into text
+);
+
+INSERT INTO songs_rebuilt (seq, id, release_date, name, music_group, text, link, deleted, release_precision)
+SELECT seq, id, release_date, name, music_group, text, link, deleted, release_precision FROM songs;
+
+DROP TABLE songs;
+ALTER TABLE songs_rebuilt RENAME TO songs;
+
+CREATE UNIQUE INDEX unique_song ON songs (release_date, name, music_group) WHERE merged_into IS NULL;
+CREATE INDEX songs_music_group_idx ON songs (music_group);
+CREATE INDEX songs_merged_into_idx ON songs (merged_into) WHERE merged_into IS NOT NULL;
+
+INSERT INTO unparsed_release_dates SELECT * FROM kept_unparsed_release_dates;
+DROP TABLE kept_unparsed_release_dates;
+
+-- +migrate Down
+
+-- Fails when a merged song shares its key with another song.
+DROP INDEX songs_merged_into_idx;
+DROP INDEX unique_song;
+CREATE UNIQUE INDEX unique_song ON songs (release_date, name, music_group);
+
+ALTER TABLE songs DROP COLUMN merged_into;
@@ -0,0 +1,13 @@
+-- +migrate Up
+
+-- Songs that duplicated others once their release dates were parsed are
+-- merged into them and take their names back, which merged songs can share.
+UPDATE songs SET
+    name = (SELECT c.name FROM release_date_conflicts c WHERE c.song_id = songs.id),
+    merged_into = (SELECT c.duplicate_of FROM release_date_conflicts c WHERE c.song_id = songs.id)
+WHERE id IN (SELECT song_id FROM release_date_conflicts WHERE duplicate_of IS NOT NULL);
+
+-- +migrate Down
+
+UPDATE songs SET name = name || ' (' || id || ')', merged_into = NULL
+WHERE id IN (SELECT song_id FROM release_date_conflicts WHERE duplicate_of IS NOT NULL);
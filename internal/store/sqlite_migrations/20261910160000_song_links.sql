@@ -0,0 +1,49 @@
+-- +migrate Up
+
+-- A song has at most one primary link, which the API shows as its link. seq
+-- keeps the order links were added in.
+CREATE TABLE song_links (
+    seq integer primary key,
+    id text not null unique,
+    song_id text not null references songs (id) ON DELETE CASCADE,
+    platform text not null CHECK (platform IN ('youtube', 'spotify', 'apple_music', 'lyrics', 'other')),
+    url text not null,
+    external_id text not null default '',
+    region text not null default '',
+    is_primary integer not null default 0,
+    created_at text not null default (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+
+    CONSTRAINT unique_song_link UNIQUE (song_id, url)
+);
+
+CREATE UNIQUE INDEX song_links_primary_idx ON song_links (song_id) WHERE is_primary;
+CREATE INDEX song_links_external_id_idx ON song_links (platform, external_id) WHERE external_id <> '';
+
+-- Links are moved as they are, the platform is told by the host only. They
+-- are normalized when they are written again. Ids are random version 4 UUIDs.
+INSERT INTO song_links (id, song_id, platform, url, is_primary)
+SELECT lower(hex(randomblob(4))) || '-' || lower(hex(randomblob(2))) || '-4' || substr(lower(hex(randomblob(2))), 2)
+        || '-' || substr('89ab', 1 + abs(random()) % 4, 1) || substr(lower(hex(randomblob(2))), 2)
+        || '-' || lower(hex(randomblob(6))),
+    id,
+    CASE
+        WHEN lower(link) GLOB 'http*://*youtube.com/*' OR lower(link) GLOB 'http*://youtu.be/*' THEN 'youtube'
+        WHEN lower(link) GLOB 'http*://open.spotify.com/*' THEN 'spotify'
+        WHEN lower(link) GLOB 'http*://music.apple.com/*' THEN 'apple_music'
+        WHEN lower(link) GLOB 'http*://*genius.com/*' OR lower(link) GLOB 'http*://*musixmatch.com/*' THEN 'lyrics'
+        ELSE 'other'
+    END,
+    trim(link),
+    1
+FROM songs
+WHERE trim(link) <> '';
+
+ALTER TABLE songs DROP COLUMN link;
+
+-- +migrate Down
+
+ALTER TABLE songs ADD COLUMN link text not null default '';
+
+UPDATE songs SET link = coalesce((SELECT l.url FROM song_links l WHERE l.song_id = songs.id and l.is_primary), '');
+
+DROP TABLE song_links;
@@ -0,0 +1,27 @@
+-- +migrate Up
+
+-- See the Postgres migration. Times are UTC text, an empty next_check_at
+-- makes a link due right away.
+ALTER TABLE song_links ADD COLUMN check_status integer not null default 0;
+ALTER TABLE song_links ADD COLUMN redirect_url text not null default '';
+ALTER TABLE song_links ADD COLUMN check_error text not null default '';
+ALTER TABLE song_links ADD COLUMN checked_at text;
+ALTER TABLE song_links ADD COLUMN check_failures integer not null default 0;
+ALTER TABLE song_links ADD COLUMN broken integer not null default 0;
+ALTER TABLE song_links ADD COLUMN next_check_at text not null default '';
+
+CREATE INDEX song_links_next_check_at_idx ON song_links (next_check_at);
+CREATE INDEX song_links_broken_idx ON song_links (checked_at DESC) WHERE broken;
+
+-- +migrate Down
+
+DROP INDEX song_links_broken_idx;
+DROP INDEX song_links_next_check_at_idx;
+
+ALTER TABLE song_links DROP COLUMN check_status;
+ALTER TABLE song_links DROP COLUMN redirect_url;
+ALTER TABLE song_links DROP COLUMN check_error;
+ALTER TABLE song_links DROP COLUMN checked_at;
+ALTER TABLE song_links DROP COLUMN check_failures;
+ALTER TABLE song_links DROP COLUMN broken;
+ALTER TABLE song_links DROP COLUMN next_check_at;
@@ -0,0 +1,26 @@
+-- +migrate Up
+
+-- See the Postgres migration.
+CREATE TABLE tags (
+    id text primary key,
+    slug text not null unique,
+    name text not null,
+    parent_id text references tags (id),
+    created_at text not null default (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+CREATE INDEX tags_parent_id_idx ON tags (parent_id);
+
+CREATE TABLE song_tags (
+    song_id text not null references songs (id) ON DELETE CASCADE,
+    tag_id text not null references tags (id) ON DELETE CASCADE,
+
+    primary key (song_id, tag_id)
+);
+
+CREATE INDEX song_tags_tag_id_idx ON song_tags (tag_id);
+
+-- +migrate Down
+
+DROP TABLE song_tags;
+DROP TABLE tags;
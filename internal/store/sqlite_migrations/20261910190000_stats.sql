@@ -0,0 +1,9 @@
+-- +migrate Up
+
+-- See the Postgres migration. SQLite cannot add a column defaulting to the
+-- current time, songs are given it when they are created.
+ALTER TABLE songs ADD COLUMN created_at text;
+
+-- +migrate Down
+
+ALTER TABLE songs DROP COLUMN created_at;
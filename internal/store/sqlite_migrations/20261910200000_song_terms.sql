@@ -0,0 +1,16 @@
+-- +migrate Up
+
+-- See the Postgres migration.
+CREATE TABLE song_terms (
+    song_id text not null references songs (id) ON DELETE CASCADE,
+    term text not null,
+    frequency integer not null,
+
+    primary key (song_id, term)
+);
+
+CREATE INDEX song_terms_term_idx ON song_terms (term);
+
+-- +migrate Down
+
+DROP TABLE song_terms;
@@ -0,0 +1,20 @@
+-- +migrate Up
+
+-- See the Postgres migration.
+ALTER TABLE song_terms ADD COLUMN weight real not null default 0;
+
+CREATE TABLE term_documents (
+    term text primary key,
+    songs integer not null
+);
+
+CREATE TABLE song_norms (
+    song_id text primary key references songs (id) ON DELETE CASCADE,
+    norm real not null
+);
+
+-- +migrate Down
+
+DROP TABLE song_norms;
+DROP TABLE term_documents;
+ALTER TABLE song_terms DROP COLUMN weight;
@@ -0,0 +1,228 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/models"
+)
+
+// sqliteTermWeight is termWeight for SQLite.
+const sqliteTermWeight = `t.frequency * (ln((1 + (SELECT count(*) FROM songs WHERE deleted = 0)) * 1.0
+	/ (1 + coalesce(d.songs, 0))) + 1)`
+
+// sqliteReweighTerms is reweighTerms for SQLite.
+var sqliteReweighTerms = []string{
+	`UPDATE song_terms AS t SET weight = ` + sqliteTermWeight + `
+	FROM term_documents d, songs s
+	WHERE d.term = t.term and s.id = t.song_id and s.deleted = 0 and t.weight <> ` + sqliteTermWeight,
+	`INSERT INTO song_norms (song_id, norm)
+	SELECT t.song_id, sqrt(sum(t.weight * t.weight)) FROM song_terms t JOIN songs s ON s.id = t.song_id
+	WHERE s.deleted = 0
+	GROUP BY t.song_id
+	ON CONFLICT (song_id) DO UPDATE SET norm = excluded.norm WHERE song_norms.norm <> excluded.norm`,
+}
+
+// sqliteCountTermDocuments is countTermDocuments for SQLite.
+var sqliteCountTermDocuments = []string{
+	`DELETE FROM term_documents`,
+	`INSERT INTO term_documents (term, songs)
+	SELECT t.term, count(*) FROM song_terms t JOIN songs s ON s.id = t.song_id
+	WHERE s.deleted = 0
+	GROUP BY t.term`,
+}
+
+// RelatedSongs returns the songs whose lyrics are like those of the song id,
+// the highest scores first and paged by params. Songs without a term in
+// common are left out.
+func (s *SQLite) RelatedSongs(ctx context.Context, id uuid.UUID, params models.Params) ([]*models.RelatedSong, error) {
+	var ranked []*models.RelatedSong
+
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		if err := sqliteSongExists(ctx, tx, id); err != nil {
+			return err
+		}
+
+		var err error
+
+		ranked, err = sqliteRankRelated(ctx, tx, id, params)
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uuid.UUID, 0, len(ranked))
+	for _, scored := range ranked {
+		ids = append(ids, scored.ID)
+	}
+
+	songs, err := s.GetSongsByIDs(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("getting related songs err: %w", err)
+	}
+
+	byID := make(map[uuid.UUID]*models.Song, len(songs))
+	for _, song := range songs {
+		byID[song.ID] = song
+	}
+
+	related := make([]*models.RelatedSong, 0, len(ranked))
+
+	for _, scored := range ranked {
+		// A song deleted meanwhile is left out.
+		if song, ok := byID[scored.ID]; ok {
+			scored.Song = *song
+			related = append(related, scored)
+		}
+	}
+
+	return related, nil
+}
+
+// sqliteRankRelated scores the songs sharing a term with the song id like
+// relatedQuery does, returning their ids, similarities and scores.
+func sqliteRankRelated(ctx context.Context, tx *sql.Tx, id uuid.UUID, params models.Params) ([]*models.RelatedSong, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT r.song_id, r.similarity,
+			r.similarity
+				+ CASE WHEN s.music_group = (SELECT music_group FROM songs WHERE id = ?) THEN ? ELSE 0 END
+				+ CASE WHEN exists (
+					SELECT 1 FROM song_tags a JOIN song_tags b ON b.tag_id = a.tag_id
+					WHERE a.song_id = ? and b.song_id = s.id
+				) THEN ? ELSE 0 END AS score
+		FROM (
+			SELECT d.song_id, d.dot / (n.norm * t.norm) AS similarity
+			FROM (
+				SELECT b.song_id, sum(a.weight * b.weight) AS dot
+				FROM song_terms a JOIN song_terms b ON b.term = a.term
+				WHERE a.song_id = ? and b.song_id <> a.song_id
+				GROUP BY b.song_id
+			) d
+			JOIN song_norms n ON n.song_id = d.song_id JOIN song_norms t ON t.song_id = ?
+		) r JOIN songs s ON s.id = r.song_id
+		WHERE s.deleted = 0
+		ORDER BY score DESC, s.name, s.id
+		LIMIT ? OFFSET ?`,
+		id.String(), params.GroupBoost, id.String(), params.TagBoost, id.String(), id.String(),
+		params.Limit, params.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("getting related songs err: %w", err)
+	}
+	defer rows.Close()
+
+	related := make([]*models.RelatedSong, 0, 1)
+
+	for rows.Next() {
+		var (
+			scored models.RelatedSong
+			songID string
+		)
+
+		if err := rows.Scan(&songID, &scored.Similarity, &scored.Score); err != nil {
+			return nil, fmt.Errorf("scanning related song err: %w", err)
+		}
+
+		if scored.ID, err = uuid.Parse(songID); err != nil {
+			return nil, fmt.Errorf("uuid.Parse(%q) err: %w", songID, err)
+		}
+
+		related = append(related, &scored)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading related songs err: %w", err)
+	}
+
+	return related, nil
+}
+
+// sqliteSetTerms is setTerms for SQLite, where writes do not run concurrently.
+func sqliteSetTerms(ctx context.Context, tx *sql.Tx, id uuid.UUID, text string) error {
+	_, err := tx.ExecContext(ctx, `UPDATE term_documents SET songs = songs - 1
+		WHERE term IN (SELECT term FROM song_terms WHERE song_id = ?1)
+			and exists (SELECT 1 FROM songs WHERE id = ?1 and deleted = 0)`, id.String())
+	if err != nil {
+		return fmt.Errorf("counting term documents err: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM song_terms WHERE song_id = ?`, id.String()); err != nil {
+		return fmt.Errorf("clearing song terms err: %w", err)
+	}
+
+	for term, frequency := range lyricTerms(text) {
+		_, err := tx.ExecContext(ctx, `INSERT INTO song_terms (song_id, term, frequency) VALUES (?, ?, ?)`,
+			id.String(), term, frequency)
+		if err != nil {
+			return fmt.Errorf("indexing song terms err: %w", err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO term_documents (term, songs)
+		SELECT term, 1 FROM song_terms
+		WHERE song_id = ?1 and exists (SELECT 1 FROM songs WHERE id = ?1 and deleted = 0)
+		ON CONFLICT (term) DO UPDATE SET songs = songs + 1`, id.String())
+	if err != nil {
+		return fmt.Errorf("counting term documents err: %w", err)
+	}
+
+	// The terms of a deleted song are not counted and stay unweighed.
+	_, err = tx.ExecContext(ctx, `UPDATE song_terms AS t SET weight = `+sqliteTermWeight+`
+		FROM term_documents d
+		WHERE t.song_id = ? and d.term = t.term`, id.String())
+	if err != nil {
+		return fmt.Errorf("weighing song terms err: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM song_norms WHERE song_id = ?`, id.String()); err != nil {
+		return fmt.Errorf("clearing song norm err: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO song_norms (song_id, norm)
+		SELECT song_id, sqrt(sum(weight * weight)) FROM song_terms WHERE song_id = ? GROUP BY song_id`, id.String())
+	if err != nil {
+		return fmt.Errorf("computing song norm err: %w", err)
+	}
+
+	return nil
+}
+
+// sqliteForgetTerms is forgetTerms for SQLite, args being the ids of the songs.
+func sqliteForgetTerms(ctx context.Context, tx *sql.Tx, args []any) error {
+	_, err := tx.ExecContext(ctx, `UPDATE term_documents SET songs = term_documents.songs - c.songs
+		FROM (
+			SELECT term, count(*) AS songs FROM song_terms WHERE song_id IN (`+placeholders(len(args))+`)
+			GROUP BY term
+		) c
+		WHERE c.term = term_documents.term`, args...)
+	if err != nil {
+		return fmt.Errorf("counting term documents err: %w", err)
+	}
+
+	return nil
+}
+
+// RefreshStats weighs the terms of every song with the document frequencies as
+// they are now, the other statistics being computed when they are read.
+func (s *SQLite) RefreshStats(ctx context.Context) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		for _, statement := range sqliteReweighTerms {
+			if _, err := tx.ExecContext(ctx, statement); err != nil {
+				return fmt.Errorf("reweighing terms err: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// RefreshStatsEvery refreshes the statistics right away and then every
+// interval until ctx is done. Failed refreshes are logged and retried at the
+// next tick.
+func (s *SQLite) RefreshStatsEvery(ctx context.Context, interval time.Duration) error {
+	return refreshEvery(ctx, interval, s.RefreshStats)
+}
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/iurikman/songs/internal/models"
+)
+
+// GetStats computes the statistics of the songs, ByGroup paged by params.
+func (s *SQLite) GetStats(ctx context.Context, params models.Params) (*models.Stats, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT s.music_group, s.release_date, s.release_precision, s.created_at, s.text,
+			exists (SELECT 1 FROM song_links l WHERE l.song_id = s.id AND l.is_primary),
+			exists (SELECT 1 FROM song_tags t WHERE t.song_id = s.id)
+		FROM songs s
+		WHERE s.deleted = 0`)
+	if err != nil {
+		return nil, fmt.Errorf("getting stats err: %w", err)
+	}
+	defer rows.Close()
+
+	builder := newStatsBuilder()
+
+	for rows.Next() {
+		var (
+			song                          songStats
+			releaseDate, releasePrecision string
+			createdAt                     sql.NullString
+		)
+
+		err := rows.Scan(&song.group, &releaseDate, &releasePrecision, &createdAt, &song.text, &song.hasLink, &song.hasTags)
+		if err != nil {
+			return nil, fmt.Errorf("scanning stats err: %w", err)
+		}
+
+		if releaseDate != "" {
+			date, err := time.Parse(sqliteDateLayout, releaseDate)
+			if err != nil {
+				return nil, fmt.Errorf("time.Parse(%q) err: %w", releaseDate, err)
+			}
+
+			song.releaseDate = models.NewReleaseDate(date, releasePrecision)
+		}
+
+		if createdAt.Valid {
+			if song.createdAt, err = time.Parse(sqliteTimeLayout, createdAt.String); err != nil {
+				return nil, fmt.Errorf("time.Parse(%q) err: %w", createdAt.String, err)
+			}
+		}
+
+		builder.add(song)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading stats err: %w", err)
+	}
+
+	return builder.build(params), nil
+}
@@ -0,0 +1,319 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/models"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+func (s *SQLite) GetTags(ctx context.Context) ([]*models.Tag, error) {
+	return sqliteQueryTags(ctx, s.db, `SELECT `+tagColumns+` FROM tags ORDER BY slug`)
+}
+
+func (s *SQLite) CreateTag(ctx context.Context, tag models.Tag) (*models.Tag, error) {
+	created, err := scanSQLiteTag(s.db.QueryRowContext(ctx, `INSERT INTO tags (id, slug, name, parent_id)
+		VALUES (?, ?, ?, ?) RETURNING `+tagColumns, tag.ID.String(), tag.Slug, tag.Name, sqliteTagID(tag.ParentID)))
+	if err != nil {
+		return nil, sqliteTagError(err, "creating tag")
+	}
+
+	return created, nil
+}
+
+// UpdateTag replaces the slug, name and parent of a tag. A tag cannot move
+// under itself or one of its descendants.
+func (s *SQLite) UpdateTag(ctx context.Context, tag models.Tag) (*models.Tag, error) {
+	var updated *models.Tag
+
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var exists bool
+
+		err := tx.QueryRowContext(ctx, `SELECT exists (SELECT 1 FROM tags WHERE id = ?)`, tag.ID.String()).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("checking tag err: %w", err)
+		}
+
+		if !exists {
+			return models.ErrTagNotFound
+		}
+
+		if tag.ParentID != nil {
+			var cycle bool
+
+			err := tx.QueryRowContext(ctx, `
+				WITH RECURSIVE subtree (id) AS (
+					SELECT ?1
+					UNION ALL
+					SELECT t.id FROM tags t JOIN subtree s ON t.parent_id = s.id
+				)
+				SELECT exists (SELECT 1 FROM subtree WHERE id = ?2)`, tag.ID.String(), tag.ParentID.String()).Scan(&cycle)
+			if err != nil {
+				return fmt.Errorf("checking tag cycle err: %w", err)
+			}
+
+			if cycle {
+				return models.ErrTagCycle
+			}
+		}
+
+		updated, err = scanSQLiteTag(tx.QueryRowContext(ctx, `UPDATE tags SET slug = ?, name = ?, parent_id = ? WHERE id = ?
+			RETURNING `+tagColumns, tag.Slug, tag.Name, sqliteTagID(tag.ParentID), tag.ID.String()))
+
+		return err
+	})
+	if err != nil {
+		return nil, sqliteTagError(err, "updating tag")
+	}
+
+	return updated, nil
+}
+
+// DeleteTag removes a tag from the songs that have it. Its children move up
+// to its parent.
+func (s *SQLite) DeleteTag(ctx context.Context, id uuid.UUID) error {
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `UPDATE tags SET parent_id = (SELECT parent_id FROM tags WHERE id = ?1)
+			WHERE parent_id = ?1`, id.String())
+		if err != nil {
+			return fmt.Errorf("moving children up err: %w", err)
+		}
+
+		result, err := tx.ExecContext(ctx, `DELETE FROM tags WHERE id = ?`, id.String())
+		if err != nil {
+			return fmt.Errorf("deleting tag err: %w", err)
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("result.RowsAffected() err: %w", err)
+		}
+
+		if affected == 0 {
+			return models.ErrTagNotFound
+		}
+
+		return nil
+	})
+	if err != nil {
+		return sqliteTagError(err, "deleting tag")
+	}
+
+	return nil
+}
+
+func (s *SQLite) GetSongTags(ctx context.Context, songID uuid.UUID) ([]*models.Tag, error) {
+	var tags []*models.Tag
+
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		if err := sqliteSongExists(ctx, tx, songID); err != nil {
+			return err
+		}
+
+		var err error
+
+		tags, err = sqliteSongTags(ctx, tx, songID)
+
+		return err
+	})
+	if err != nil {
+		return nil, sqliteTagError(err, "getting song tags")
+	}
+
+	return tags, nil
+}
+
+// SetSongTags replaces the tags of a song that is not deleted with those of
+// the slugs, models.ErrUnknownTag when one of them is not a tag.
+func (s *SQLite) SetSongTags(ctx context.Context, songID uuid.UUID, slugs []string) ([]*models.Tag, error) {
+	var tags []*models.Tag
+
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		if err := sqliteSongExists(ctx, tx, songID); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM song_tags WHERE song_id = ?`, songID.String()); err != nil {
+			return fmt.Errorf("clearing song tags err: %w", err)
+		}
+
+		set := distinct(slugs)
+
+		args := make([]any, 0, len(set)+1)
+		args = append(args, songID.String())
+
+		for slug := range set {
+			args = append(args, slug)
+		}
+
+		result, err := tx.ExecContext(ctx, `INSERT INTO song_tags (song_id, tag_id)
+			SELECT ?, id FROM tags WHERE slug IN (`+placeholders(len(set))+`)`, args...)
+		if err != nil {
+			return fmt.Errorf("tagging song err: %w", err)
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("result.RowsAffected() err: %w", err)
+		}
+
+		if affected != int64(len(set)) {
+			return models.ErrUnknownTag
+		}
+
+		tags, err = sqliteSongTags(ctx, tx, songID)
+
+		return err
+	})
+	if err != nil {
+		return nil, sqliteTagError(err, "setting song tags")
+	}
+
+	return tags, nil
+}
+
+// TagFacets counts per tag the songs params list, ignoring paging and sorting.
+func (s *SQLite) TagFacets(ctx context.Context, params models.Params) ([]*models.TagFacet, error) {
+	filter, args := sqliteSongFilter(params)
+
+	rows, err := s.db.QueryContext(ctx, tagFacets(`SELECT id FROM songs WHERE deleted = 0`+filter), args...)
+	if err != nil {
+		return nil, fmt.Errorf("getting tag facets err: %w", err)
+	}
+	defer rows.Close()
+
+	facets := make([]*models.TagFacet, 0, 1)
+
+	for rows.Next() {
+		var facet models.TagFacet
+
+		tag, err := scanSQLiteTag(rows, &facet.Count)
+		if err != nil {
+			return nil, fmt.Errorf("scanning tag facet err: %w", err)
+		}
+
+		facet.Tag = *tag
+		facets = append(facets, &facet)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading tag facets err: %w", err)
+	}
+
+	return facets, nil
+}
+
+// sqliteQuerier is a database or a transaction.
+type sqliteQuerier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func sqliteSongTags(ctx context.Context, q sqliteQuerier, songID uuid.UUID) ([]*models.Tag, error) {
+	return sqliteQueryTags(ctx, q, `SELECT `+tagColumns+` FROM tags
+		WHERE id IN (SELECT tag_id FROM song_tags WHERE song_id = ?) ORDER BY slug`, songID.String())
+}
+
+func sqliteQueryTags(ctx context.Context, q sqliteQuerier, query string, args ...any) ([]*models.Tag, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("getting tags err: %w", err)
+	}
+	defer rows.Close()
+
+	tags := make([]*models.Tag, 0, 1)
+
+	for rows.Next() {
+		tag, err := scanSQLiteTag(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning tag err: %w", err)
+		}
+
+		tags = append(tags, tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading tags err: %w", err)
+	}
+
+	return tags, nil
+}
+
+// sqliteMoveTags is moveTags for SQLite, args being the song and then the
+// sources.
+func sqliteMoveTags(ctx context.Context, tx *sql.Tx, args []any) error {
+	sources := numberedPlaceholders(2, len(args))
+
+	_, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO song_tags (song_id, tag_id)
+		SELECT DISTINCT ?1, tag_id FROM song_tags WHERE song_id IN (`+sources+`)`, args...)
+	if err != nil {
+		return fmt.Errorf("moving tags err: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM song_tags WHERE song_id IN (`+placeholders(len(args)-1)+`)`, args[1:]...)
+	if err != nil {
+		return fmt.Errorf("dropping moved tags err: %w", err)
+	}
+
+	return nil
+}
+
+// sqliteTagID is the column of a tag id that may be missing.
+func sqliteTagID(id *uuid.UUID) sql.NullString {
+	if id == nil {
+		return sql.NullString{}
+	}
+
+	return sql.NullString{String: id.String(), Valid: true}
+}
+
+func sqliteTagError(err error, action string) error {
+	var sqliteErr *sqlite.Error
+
+	switch {
+	case errors.Is(err, models.ErrSongNotFound), errors.Is(err, models.ErrTagNotFound),
+		errors.Is(err, models.ErrUnknownTag), errors.Is(err, models.ErrTagCycle):
+		return err
+	case errors.Is(err, sql.ErrNoRows):
+		return models.ErrTagNotFound
+	case isSQLiteConflict(err):
+		return models.ErrDuplicateTag
+	case errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+		return models.ErrUnknownTag
+	default:
+		return fmt.Errorf("%s err: %w", action, err)
+	}
+}
+
+func scanSQLiteTag(row rowScanner, extra ...any) (*models.Tag, error) {
+	var (
+		tag      models.Tag
+		id       string
+		parentID sql.NullString
+	)
+
+	if err := row.Scan(append([]any{&id, &tag.Slug, &tag.Name, &parentID}, extra...)...); err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	var err error
+
+	if tag.ID, err = uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("uuid.Parse(%q) err: %w", id, err)
+	}
+
+	if parentID.Valid {
+		parent, err := uuid.Parse(parentID.String)
+		if err != nil {
+			return nil, fmt.Errorf("uuid.Parse(%q) err: %w", parentID.String, err)
+		}
+
+		tag.ParentID = &parent
+	}
+
+	return &tag, nil
+}
@@ -0,0 +1,262 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iurikman/songs/internal/logger"
+	"github.com/iurikman/songs/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// statsRefreshLock is the advisory lock of the statistics refresh, so that
+// instances sharing the database do not refresh the views all at once.
+const statsRefreshLock = 4901
+
+// statsViews are the materialized views of the statistics.
+var statsViews = []string{"stats_summary", "stats_by_group", "stats_by_release_year", "stats_by_creation_month"}
+
+// GetStats reads the statistics from the materialized views, ByGroup paged by
+// params.
+func (p *Postgres) GetStats(ctx context.Context, params models.Params) (*models.Stats, error) {
+	db := p.reader(ctx)
+
+	var (
+		stats                              models.Stats
+		refreshedAt                        time.Time
+		withReleaseDate, withLink, withTag int
+	)
+
+	err := db.QueryRow(ctx, `
+		SELECT songs, groups, lyrics, average_verses, max_verses, average_words, total_words,
+			with_release_date, with_link, with_tags, refreshed_at
+		FROM stats_summary`).Scan(
+		&stats.Songs, &stats.Groups, &stats.Lyrics.Songs, &stats.Lyrics.AverageVerses, &stats.Lyrics.MaxVerses,
+		&stats.Lyrics.AverageWords, &stats.Lyrics.TotalWords, &withReleaseDate, &withLink, &withTag, &refreshedAt)
+	if err != nil {
+		return nil, fmt.Errorf("getting stats summary err: %w", err)
+	}
+
+	stats.RefreshedAt = &refreshedAt
+	stats.Completeness = models.Completeness{
+		ReleaseDate: share(withReleaseDate, stats.Songs),
+		Text:        share(stats.Lyrics.Songs, stats.Songs),
+		Link:        share(withLink, stats.Songs),
+		Tags:        share(withTag, stats.Songs),
+	}
+
+	if stats.ByGroup, err = statsCounts(ctx, db, `SELECT music_group, songs FROM stats_by_group
+		ORDER BY songs DESC, music_group OFFSET $1 LIMIT $2`, params.Offset, params.Limit); err != nil {
+		return nil, err
+	}
+
+	if stats.ByReleaseYear, err = statsCounts(ctx, db, `SELECT year::text, songs FROM stats_by_release_year
+		ORDER BY year`); err != nil {
+		return nil, err
+	}
+
+	if stats.ByCreationMonth, err = statsCounts(ctx, db, `SELECT month, songs FROM stats_by_creation_month
+		ORDER BY month`); err != nil {
+		return nil, err
+	}
+
+	return &stats, nil
+}
+
+// RefreshStats refreshes the materialized views of the statistics and the
+// weights of the terms of the lyrics, unless another instance is refreshing
+// them already. Reads are not blocked.
+func (p *Postgres) RefreshStats(ctx context.Context) error {
+	return p.inTx(ctx, func(tx pgx.Tx) error {
+		var locked bool
+
+		if err := tx.QueryRow(ctx, `SELECT pg_try_advisory_xact_lock($1)`, statsRefreshLock).Scan(&locked); err != nil {
+			return fmt.Errorf("locking stats refresh err: %w", err)
+		}
+
+		if !locked {
+			return nil
+		}
+
+		for _, view := range statsViews {
+			if _, err := tx.Exec(ctx, `REFRESH MATERIALIZED VIEW CONCURRENTLY `+view); err != nil {
+				return fmt.Errorf("refreshing %s err: %w", view, err)
+			}
+		}
+
+		for _, statement := range reweighTerms {
+			if _, err := tx.Exec(ctx, statement); err != nil {
+				return fmt.Errorf("reweighing terms err: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// RefreshStatsEvery refreshes the statistics right away and then every
+// interval until ctx is done. Failed refreshes are logged and retried at the
+// next tick.
+func (p *Postgres) RefreshStatsEvery(ctx context.Context, interval time.Duration) error {
+	return refreshEvery(ctx, interval, p.RefreshStats)
+}
+
+// refreshEvery calls refresh right away and then every interval until ctx is
+// done, logging its failures.
+func refreshEvery(ctx context.Context, interval time.Duration, refresh func(ctx context.Context) error) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := refresh(ctx); err != nil && ctx.Err() == nil {
+			logger.FromContext(ctx).Warnf("refreshing stats err: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func statsCounts(ctx context.Context, q querier, query string, args ...any) ([]models.StatsCount, error) {
+	rows, err := q.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("getting stats err: %w", err)
+	}
+
+	counts, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.StatsCount, error) {
+		var count models.StatsCount
+
+		err := row.Scan(&count.Key, &count.Songs)
+
+		return count, err //nolint:wrapcheck
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading stats err: %w", err)
+	}
+
+	return counts, nil
+}
+
+// songStats is what the statistics take from a song.
+type songStats struct {
+	group       string
+	releaseDate models.ReleaseDate
+	// createdAt is zero when the creation time is unknown.
+	createdAt time.Time
+	text      string
+	hasLink   bool
+	hasTags   bool
+}
+
+// statsBuilder computes the statistics of the stores without materialized
+// views song by song. It must agree with the views of the Postgres store.
+type statsBuilder struct {
+	songs, lyrics                           int
+	verses, maxVerses, words                int
+	withReleaseDate, withLink, withTags     int
+	byGroup, byReleaseYear, byCreationMonth map[string]int
+}
+
+func newStatsBuilder() *statsBuilder {
+	return &statsBuilder{
+		byGroup:         make(map[string]int),
+		byReleaseYear:   make(map[string]int),
+		byCreationMonth: make(map[string]int),
+	}
+}
+
+func (b *statsBuilder) add(song songStats) {
+	b.songs++
+	b.byGroup[song.group]++
+
+	if !song.releaseDate.IsZero() {
+		b.withReleaseDate++
+		b.byReleaseYear[strconv.Itoa(song.releaseDate.Date.Year())]++
+	}
+
+	if !song.createdAt.IsZero() {
+		b.byCreationMonth[song.createdAt.UTC().Format("2006-01")]++
+	}
+
+	if strings.TrimSpace(song.text) != "" {
+		verses := len(models.SplitVerses(song.text))
+
+		b.lyrics++
+		b.verses += verses
+		b.maxVerses = max(b.maxVerses, verses)
+		b.words += len(strings.Fields(song.text))
+	}
+
+	if song.hasLink {
+		b.withLink++
+	}
+
+	if song.hasTags {
+		b.withTags++
+	}
+}
+
+// build returns the statistics of the songs added, ByGroup paged by params.
+func (b *statsBuilder) build(params models.Params) *models.Stats {
+	stats := &models.Stats{
+		Songs:  b.songs,
+		Groups: len(b.byGroup),
+		ByGroup: page(sortedCounts(b.byGroup, func(x, y models.StatsCount) int {
+			return cmp.Or(cmp.Compare(y.Songs, x.Songs), cmp.Compare(x.Key, y.Key))
+		}), params),
+		ByReleaseYear: sortedCounts(b.byReleaseYear, func(x, y models.StatsCount) int {
+			// Years sort as numbers.
+			return cmp.Or(cmp.Compare(len(x.Key), len(y.Key)), cmp.Compare(x.Key, y.Key))
+		}),
+		ByCreationMonth: sortedCounts(b.byCreationMonth, func(x, y models.StatsCount) int {
+			return cmp.Compare(x.Key, y.Key)
+		}),
+		Lyrics: models.LyricStats{
+			Songs:      b.lyrics,
+			MaxVerses:  b.maxVerses,
+			TotalWords: b.words,
+		},
+		Completeness: models.Completeness{
+			ReleaseDate: share(b.withReleaseDate, b.songs),
+			Text:        share(b.lyrics, b.songs),
+			Link:        share(b.withLink, b.songs),
+			Tags:        share(b.withTags, b.songs),
+		},
+	}
+
+	if b.lyrics > 0 {
+		stats.Lyrics.AverageVerses = float64(b.verses) / float64(b.lyrics)
+		stats.Lyrics.AverageWords = float64(b.words) / float64(b.lyrics)
+	}
+
+	return stats
+}
+
+func sortedCounts(counts map[string]int, compare func(a, b models.StatsCount) int) []models.StatsCount {
+	sorted := make([]models.StatsCount, 0, len(counts))
+
+	for key, songs := range counts {
+		sorted = append(sorted, models.StatsCount{Key: key, Songs: songs})
+	}
+
+	slices.SortFunc(sorted, compare)
+
+	return sorted
+}
+
+// share is n of total as a fraction, 0 when total is.
+func share(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+
+	return float64(n) / float64(total)
+}
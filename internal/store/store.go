@@ -2,11 +2,14 @@ package store
 
 import (
 	"context"
-	"database/sql"
 	"embed"
 	"fmt"
 	"net/url"
+	"sync/atomic"
+	"time"
 
+	"github.com/iurikman/songs/internal/logger"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	migrate "github.com/rubenv/sql-migrate"
 	log "github.com/sirupsen/logrus"
@@ -15,17 +18,39 @@ import (
 type Postgres struct {
 	db  *pgxpool.Pool
 	dsn string
+
+	replicas     []*replica
+	next         atomic.Uint64
+	maxLag       time.Duration
+	stickyWindow time.Duration
 }
 
 //go:embed migrations
 var migrations embed.FS
 
 type Config struct {
+	// DSN, when set, is used as is instead of the PG* fields.
+	DSN string
+
 	PGUser     string
 	PGPassword string
 	PGHost     string
 	PGPort     string
 	PGDatabase string
+
+	MaxConns       int32
+	MinConns       int32
+	ConnectTimeout time.Duration
+
+	// ReplicaDSNs are read replicas of the database. Reads of songs are
+	// spread over the healthy ones, writes always go to the primary.
+	ReplicaDSNs []string
+	// ReplicaMaxLag is how far behind the primary a replica may fall before
+	// reads stop being routed to it.
+	ReplicaMaxLag time.Duration
+	// StickyWindow is how long after a write reads made with the same
+	// consistency.Session keep going to the primary.
+	StickyWindow time.Duration
 }
 
 func New(ctx context.Context, cfg Config) (*Postgres, error) {
@@ -38,68 +63,171 @@ func New(ctx context.Context, cfg Config) (*Postgres, error) {
 	}
 
 	dsn := urlScheme.String()
+	if cfg.DSN != "" {
+		dsn = cfg.DSN
+	}
 
-	log.Infof("Connecting to database with DSN: %s", dsn)
+	log.WithField("url", logger.RedactDSN(dsn)).Info("Connecting to database")
 
-	db, err := pgxpool.New(ctx, dsn)
+	db, err := newPool(ctx, dsn, cfg)
 	if err != nil {
-		return nil, fmt.Errorf("pgxpool.New(ctx, dsn) err: %w", err)
+		return nil, err
 	}
 
 	log.Info("Successfully connected to database")
 
-	return &Postgres{
-		db:  db,
-		dsn: dsn,
-	}, nil
+	p := &Postgres{
+		db:           db,
+		dsn:          dsn,
+		maxLag:       cfg.ReplicaMaxLag,
+		stickyWindow: cfg.StickyWindow,
+	}
+
+	for _, replicaDSN := range cfg.ReplicaDSNs {
+		pool, err := newPool(ctx, replicaDSN, cfg)
+		if err != nil {
+			p.Close()
+
+			return nil, fmt.Errorf("replica %s: %w", logger.RedactDSN(replicaDSN), err)
+		}
+
+		p.replicas = append(p.replicas, &replica{pool: pool, name: logger.RedactDSN(replicaDSN)})
+	}
+
+	if len(p.replicas) > 0 {
+		p.CheckReplicas(ctx)
+	}
+
+	return p, nil
+}
+
+// newPool opens a pool to dsn with the pool settings of cfg. Connections are
+// established lazily, so an unreachable server is only noticed on use.
+func newPool(ctx context.Context, dsn string, cfg Config) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("pgxpool.ParseConfig(dsn) err: %w", err)
+	}
+
+	poolConfig.ConnConfig.Tracer = queryTracer{}
+
+	if cfg.MaxConns > 0 {
+		poolConfig.MaxConns = cfg.MaxConns
+	}
+
+	if cfg.MinConns > 0 {
+		poolConfig.MinConns = cfg.MinConns
+	}
+
+	if cfg.ConnectTimeout > 0 {
+		poolConfig.ConnConfig.ConnectTimeout = cfg.ConnectTimeout
+	}
+
+	db, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("pgxpool.NewWithConfig(ctx, poolConfig) err: %w", err)
+	}
+
+	return db, nil
 }
 
 func (p *Postgres) Migrate(direction migrate.MigrationDirection) error {
 	log.Infof("Running migrations in direction: %v", direction)
 
-	conn, err := sql.Open("pgx", p.dsn)
+	migrator, err := p.Migrator()
 	if err != nil {
-		return fmt.Errorf("sql.Open: %w", err)
+		return err
 	}
 
-	defer func() {
-		err := conn.Close()
+	defer closeMigrator(migrator)
+
+	if _, err := migrator.Exec(direction, 0); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// PendingMigrations returns the number of embedded migrations that have not
+// been applied. It reads the migrations table over the pool of the store, so
+// that ctx bounds it and it leaves the schema alone.
+func (p *Postgres) PendingMigrations(ctx context.Context) (int, error) {
+	var exists bool
+
+	err := p.db.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, migrationsTable).Scan(&exists)
+	if err != nil {
+		return 0, fmt.Errorf("checking %s err: %w", migrationsTable, err)
+	}
+
+	applied := make(map[string]bool)
+
+	if exists {
+		rows, err := p.db.Query(ctx, `SELECT id FROM `+migrationsTable)
 		if err != nil {
-			log.Warnf("conn.Close: %v", err)
+			return 0, fmt.Errorf("getting applied migrations err: %w", err)
 		}
-	}()
 
-	assetDir := func() func(string) ([]string, error) {
-		return func(path string) ([]string, error) {
-			dirEntry, err := migrations.ReadDir(path)
-			if err != nil {
-				return nil, fmt.Errorf("migrations.ReadDir: %w", err)
-			}
+		ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			return 0, fmt.Errorf("reading applied migrations err: %w", err)
+		}
 
-			entries := make([]string, 0)
+		for _, id := range ids {
+			applied[id] = true
+		}
+	}
 
-			for _, e := range dirEntry {
-				entries = append(entries, e.Name())
-			}
+	return countPending(migrationSource(), applied)
+}
 
-			return entries, nil
-		}
-	}()
+// Close releases every connection of the primary and replica pools.
+func (p *Postgres) Close() {
+	p.db.Close()
 
-	asset := migrate.AssetMigrationSource{
-		Asset:    migrations.ReadFile,
-		AssetDir: assetDir,
-		Dir:      "migrations",
+	for _, r := range p.replicas {
+		r.pool.Close()
 	}
+}
 
-	_, err = migrate.Exec(conn, "postgres", asset, direction)
-	if err != nil {
-		return fmt.Errorf("migrate.Exec: %w", err)
+func (p *Postgres) Name() string {
+	return "postgres"
+}
+
+func (p *Postgres) Ping(ctx context.Context) error {
+	if err := p.db.Ping(ctx); err != nil {
+		return fmt.Errorf("p.db.Ping(ctx) err: %w", err)
 	}
 
 	return nil
 }
 
+func migrationSource() migrate.AssetMigrationSource {
+	return assetSource(migrations, "migrations")
+}
+
+func assetSource(fsys embed.FS, dir string) migrate.AssetMigrationSource {
+	assetDir := func(path string) ([]string, error) {
+		dirEntry, err := fsys.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("migrations.ReadDir: %w", err)
+		}
+
+		entries := make([]string, 0)
+
+		for _, e := range dirEntry {
+			entries = append(entries, e.Name())
+		}
+
+		return entries, nil
+	}
+
+	return migrate.AssetMigrationSource{
+		Asset:    fsys.ReadFile,
+		AssetDir: assetDir,
+		Dir:      dir,
+	}
+}
+
 func (p *Postgres) Truncate(ctx context.Context, tables ...string) error {
 	for _, table := range tables {
 		_, err := p.db.Exec(ctx, "DELETE FROM"+" "+table)
@@ -0,0 +1,320 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/models"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const tagColumns = `id, slug, name, parent_id`
+
+// taggedSongs selects the ids of the songs with the tag of the slug bound to
+// param, or with one of its descendants as well. Postgres and SQLite share it.
+func taggedSongs(param string, descendants bool) string {
+	if !descendants {
+		return `SELECT st.song_id FROM song_tags st JOIN tags t ON t.id = st.tag_id WHERE t.slug = ` + param
+	}
+
+	return `WITH RECURSIVE subtree (id) AS (
+			SELECT id FROM tags WHERE slug = ` + param + `
+			UNION ALL
+			SELECT t.id FROM tags t JOIN subtree s ON t.parent_id = s.id
+		)
+		SELECT song_id FROM song_tags WHERE tag_id IN (SELECT id FROM subtree)`
+}
+
+// tagFacets counts per tag the songs selected by listed that have the tag or
+// one of its descendants, the largest counts first. Tags no such song has are
+// left out. Postgres and SQLite share it.
+func tagFacets(listed string) string {
+	return `WITH RECURSIVE closure (ancestor, descendant) AS (
+			SELECT id, id FROM tags
+			UNION ALL
+			SELECT c.ancestor, t.id FROM tags t JOIN closure c ON t.parent_id = c.descendant
+		)
+		SELECT t.id, t.slug, t.name, t.parent_id, count(DISTINCT st.song_id) AS songs
+		FROM tags t
+		JOIN closure c ON c.ancestor = t.id
+		JOIN song_tags st ON st.tag_id = c.descendant
+		WHERE st.song_id IN (` + listed + `)
+		GROUP BY t.id, t.slug, t.name, t.parent_id
+		ORDER BY songs DESC, t.slug`
+}
+
+// GetTags returns every tag, ordered by slug.
+func (p *Postgres) GetTags(ctx context.Context) ([]*models.Tag, error) {
+	rows, err := p.reader(ctx).Query(ctx, `SELECT `+tagColumns+` FROM tags ORDER BY slug`)
+	if err != nil {
+		return nil, fmt.Errorf("getting tags err: %w", err)
+	}
+
+	return collectTags(rows)
+}
+
+// CreateTag adds a tag under its parent, models.ErrUnknownTag when there is no
+// such tag.
+func (p *Postgres) CreateTag(ctx context.Context, tag models.Tag) (*models.Tag, error) {
+	var created *models.Tag
+
+	err := p.inTx(ctx, func(tx pgx.Tx) error {
+		var err error
+
+		created, err = scanTag(tx.QueryRow(ctx, `INSERT INTO tags (id, slug, name, parent_id) VALUES ($1, $2, $3, $4)
+			RETURNING `+tagColumns, tag.ID, tag.Slug, tag.Name, tag.ParentID))
+
+		return err
+	})
+	if err != nil {
+		return nil, tagError(err, "creating tag")
+	}
+
+	return created, nil
+}
+
+// UpdateTag replaces the slug, name and parent of a tag. A tag cannot move
+// under itself or one of its descendants.
+func (p *Postgres) UpdateTag(ctx context.Context, tag models.Tag) (*models.Tag, error) {
+	var updated *models.Tag
+
+	err := p.inTx(ctx, func(tx pgx.Tx) error {
+		// Two tags moving under each other at once would make a cycle, so
+		// writes to tags wait for each other.
+		if _, err := tx.Exec(ctx, `LOCK TABLE tags IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+			return fmt.Errorf("locking tags err: %w", err)
+		}
+
+		var exists bool
+
+		err := tx.QueryRow(ctx, `SELECT exists (SELECT 1 FROM tags WHERE id = $1)`, tag.ID).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("checking tag err: %w", err)
+		}
+
+		if !exists {
+			return models.ErrTagNotFound
+		}
+
+		if tag.ParentID != nil {
+			var cycle bool
+
+			err := tx.QueryRow(ctx, `
+				WITH RECURSIVE subtree (id) AS (
+					SELECT $1::uuid
+					UNION ALL
+					SELECT t.id FROM tags t JOIN subtree s ON t.parent_id = s.id
+				)
+				SELECT exists (SELECT 1 FROM subtree WHERE id = $2)`, tag.ID, *tag.ParentID).Scan(&cycle)
+			if err != nil {
+				return fmt.Errorf("checking tag cycle err: %w", err)
+			}
+
+			if cycle {
+				return models.ErrTagCycle
+			}
+		}
+
+		updated, err = scanTag(tx.QueryRow(ctx, `UPDATE tags SET slug = $2, name = $3, parent_id = $4 WHERE id = $1
+			RETURNING `+tagColumns, tag.ID, tag.Slug, tag.Name, tag.ParentID))
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		return notifyChange(ctx, tx, models.Change{Type: models.ChangeTags})
+	})
+	if err != nil {
+		return nil, tagError(err, "updating tag")
+	}
+
+	return updated, nil
+}
+
+// DeleteTag removes a tag from the songs that have it. Its children move up
+// to its parent.
+func (p *Postgres) DeleteTag(ctx context.Context, id uuid.UUID) error {
+	err := p.inTx(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `UPDATE tags SET parent_id = (SELECT parent_id FROM tags WHERE id = $1) WHERE parent_id = $1`, id)
+		if err != nil {
+			return fmt.Errorf("moving children up err: %w", err)
+		}
+
+		tag, err := tx.Exec(ctx, `DELETE FROM tags WHERE id = $1`, id)
+		if err != nil {
+			return fmt.Errorf("deleting tag err: %w", err)
+		}
+
+		if tag.RowsAffected() == 0 {
+			return models.ErrTagNotFound
+		}
+
+		return notifyChange(ctx, tx, models.Change{Type: models.ChangeTags})
+	})
+	if err != nil {
+		return tagError(err, "deleting tag")
+	}
+
+	return nil
+}
+
+// GetSongTags returns the tags of a song that is not deleted, ordered by slug.
+func (p *Postgres) GetSongTags(ctx context.Context, songID uuid.UUID) ([]*models.Tag, error) {
+	if err := songExists(ctx, p.reader(ctx), songID); err != nil {
+		return nil, err
+	}
+
+	return songTags(ctx, p.reader(ctx), songID)
+}
+
+// SetSongTags replaces the tags of a song that is not deleted with those of
+// the slugs, models.ErrUnknownTag when one of them is not a tag.
+func (p *Postgres) SetSongTags(ctx context.Context, songID uuid.UUID, slugs []string) ([]*models.Tag, error) {
+	var tags []*models.Tag
+
+	err := p.inTx(ctx, func(tx pgx.Tx) error {
+		if err := songExists(ctx, tx, songID); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(ctx, `DELETE FROM song_tags WHERE song_id = $1`, songID); err != nil {
+			return fmt.Errorf("clearing song tags err: %w", err)
+		}
+
+		tag, err := tx.Exec(ctx, `INSERT INTO song_tags (song_id, tag_id) SELECT $1, id FROM tags WHERE slug = ANY($2)`,
+			songID, slugs)
+		if err != nil {
+			return fmt.Errorf("tagging song err: %w", err)
+		}
+
+		if tag.RowsAffected() != int64(len(distinct(slugs))) {
+			return models.ErrUnknownTag
+		}
+
+		if err := notifyChange(ctx, tx, models.Change{Type: models.ChangeTags, SongID: songID}); err != nil {
+			return err
+		}
+
+		tags, err = songTags(ctx, tx, songID)
+
+		return err
+	})
+	if err != nil {
+		return nil, tagError(err, "setting song tags")
+	}
+
+	return tags, nil
+}
+
+// TagFacets counts per tag the songs params list, ignoring paging and sorting.
+func (p *Postgres) TagFacets(ctx context.Context, params models.Params) ([]*models.TagFacet, error) {
+	filter, args := songFilter(params)
+
+	rows, err := p.reader(ctx).Query(ctx, tagFacets(`SELECT id FROM songs WHERE deleted = false`+filter), args...)
+	if err != nil {
+		return nil, fmt.Errorf("getting tag facets err: %w", err)
+	}
+
+	facets, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.TagFacet, error) {
+		var facet models.TagFacet
+
+		tag, err := scanTag(row, &facet.Count)
+		if err != nil {
+			return nil, err
+		}
+
+		facet.Tag = *tag
+
+		return &facet, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading tag facets err: %w", err)
+	}
+
+	return facets, nil
+}
+
+// querier is a pool or a transaction.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+func songTags(ctx context.Context, q querier, songID uuid.UUID) ([]*models.Tag, error) {
+	rows, err := q.Query(ctx, `SELECT `+tagColumns+` FROM tags
+		WHERE id IN (SELECT tag_id FROM song_tags WHERE song_id = $1) ORDER BY slug`, songID)
+	if err != nil {
+		return nil, fmt.Errorf("getting song tags err: %w", err)
+	}
+
+	return collectTags(rows)
+}
+
+// moveTags gives the song id the tags of the sources, which lose them.
+func moveTags(ctx context.Context, tx pgx.Tx, id uuid.UUID, sources []uuid.UUID) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO song_tags (song_id, tag_id)
+		SELECT DISTINCT $1::uuid, tag_id FROM song_tags WHERE song_id = ANY($2)
+		ON CONFLICT DO NOTHING`, id, sources)
+	if err != nil {
+		return fmt.Errorf("moving tags err: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM song_tags WHERE song_id = ANY($1)`, sources); err != nil {
+		return fmt.Errorf("dropping moved tags err: %w", err)
+	}
+
+	return nil
+}
+
+// distinct returns values without repetitions.
+func distinct(values []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(values))
+	for _, value := range values {
+		set[value] = struct{}{}
+	}
+
+	return set
+}
+
+// tagError maps the errors of tag writes.
+func tagError(err error, action string) error {
+	var pgErr *pgconn.PgError
+
+	switch {
+	case errors.Is(err, models.ErrSongNotFound), errors.Is(err, models.ErrTagNotFound),
+		errors.Is(err, models.ErrUnknownTag), errors.Is(err, models.ErrTagCycle):
+		return err
+	case errors.Is(err, pgx.ErrNoRows):
+		return models.ErrTagNotFound
+	case errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation:
+		return models.ErrDuplicateTag
+	case errors.As(err, &pgErr) && pgErr.Code == pgerrcode.ForeignKeyViolation:
+		return models.ErrUnknownTag
+	default:
+		return fmt.Errorf("%s err: %w", action, err)
+	}
+}
+
+func collectTags(rows pgx.Rows) ([]*models.Tag, error) {
+	tags, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.Tag, error) {
+		return scanTag(row)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading tags err: %w", err)
+	}
+
+	return tags, nil
+}
+
+func scanTag(row pgx.Row, extra ...any) (*models.Tag, error) {
+	var tag models.Tag
+
+	if err := row.Scan(append([]any{&tag.ID, &tag.Slug, &tag.Name, &tag.ParentID}, extra...)...); err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	return &tag, nil
+}
@@ -0,0 +1,63 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/iurikman/songs/internal/logger"
+	"github.com/jackc/pgx/v5"
+)
+
+// inTx runs fn in a transaction, committing when fn succeeds and rolling back
+// otherwise. The error of fn is returned unwrapped so callers can match it.
+// Transactions always run on the primary.
+func (p *Postgres) inTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("p.db.Begin(ctx) err: %w", err)
+	}
+
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			logger.FromContext(ctx).Warnf("tx.Rollback(ctx) err: %v", err)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("tx.Commit(ctx) err: %w", err)
+	}
+
+	wrote(ctx)
+
+	return nil
+}
+
+// inTx runs fn in a transaction like Postgres.inTx does.
+func (s *SQLite) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("s.db.BeginTx(ctx) err: %w", err)
+	}
+
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			logger.FromContext(ctx).Warnf("tx.Rollback() err: %v", err)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("tx.Commit() err: %w", err)
+	}
+
+	return nil
+}
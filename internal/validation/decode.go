@@ -0,0 +1,119 @@
+package validation
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/iurikman/songs/internal/models"
+)
+
+var (
+	ErrMalformed     = errors.New("malformed JSON")
+	errTrailingValue = errors.New("body must hold a single JSON value")
+)
+
+// DecodeJSON decodes the single JSON value read from r into v, rejecting
+// fields v does not have. Unknown fields and values of the wrong type are
+// reported as Errors, anything else that is not JSON of the right shape wraps
+// ErrMalformed; errors of r are returned as they are.
+func DecodeJSON(r io.Reader, v any) error {
+	body := &recordingReader{r: r}
+
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(v); err != nil {
+		if body.err != nil && !errors.Is(body.err, io.EOF) {
+			return body.err
+		}
+
+		return decodeError(err)
+	}
+
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: %w", ErrMalformed, errTrailingValue)
+	}
+
+	return nil
+}
+
+func decodeError(err error) error {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+
+	switch {
+	case errors.As(err, &syntaxErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return fmt.Errorf("%w: %w", ErrMalformed, err)
+	case errors.As(err, &typeErr) && typeErr.Field == "":
+		return fmt.Errorf("%w: %w", ErrMalformed, err)
+	case errors.As(err, &typeErr):
+		return Errors{{
+			Field:   typeErr.Field,
+			Code:    CodeInvalidType,
+			Message: "must be " + jsonKind(typeErr.Type),
+		}}
+	case errors.Is(err, models.ErrInvalidReleaseDate):
+		return Errors{{
+			Field:   "releaseDate",
+			Code:    CodeInvalidValue,
+			Message: "must be a day, month or year like 16.07.2006, 07.2006 or 2006",
+		}}
+	}
+
+	// The json package has no error type for unknown fields.
+	if field, ok := strings.CutPrefix(err.Error(), `json: unknown field "`); ok {
+		return Errors{{
+			Field:   strings.TrimSuffix(field, `"`),
+			Code:    CodeUnknownField,
+			Message: "is not a known field",
+		}}
+	}
+
+	return fmt.Errorf("%w: %w", ErrMalformed, err)
+}
+
+// recordingReader keeps the error of r, telling it apart from decoding errors.
+type recordingReader struct {
+	r   io.Reader
+	err error
+}
+
+func (r *recordingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err != nil {
+		r.err = err
+	}
+
+	return n, err //nolint:wrapcheck
+}
+
+// jsonKind names the JSON values that decode into t.
+func jsonKind(t reflect.Type) string {
+	if t.Implements(textUnmarshaler) || reflect.PointerTo(t).Implements(textUnmarshaler) {
+		return "a string"
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	default:
+		return "an object"
+	}
+}
+
+var textUnmarshaler = reflect.TypeFor[encoding.TextUnmarshaler]()
@@ -0,0 +1,284 @@
+// Package validation checks what clients send before it reaches a store. Every
+// violation is reported with the JSON name of its field, so that all of them
+// can be fixed at once.
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/models"
+)
+
+// Codes of field errors.
+const (
+	CodeRequired     = "required"
+	CodeTooLong      = "too_long"
+	CodeInvalidURL   = "invalid_url"
+	CodeInvalidValue = "invalid_value"
+	CodeInvalidType  = "invalid_type"
+	CodeUnknownField = "unknown_field"
+)
+
+// Limits of song fields; names and groups are counted in characters, lyrics
+// in bytes.
+const (
+	MaxNameLength  = 255
+	MaxGroupLength = 255
+	MaxTextBytes   = 64 << 10
+	MaxLinkLength  = 2048
+	MaxSlugLength  = 64
+	// MaxSongTags bounds the tags of a song.
+	MaxSongTags = 50
+)
+
+// ErrInvalid matches every Errors with errors.Is.
+var ErrInvalid = errors.New("validation failed")
+
+// FieldError is one violation.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Errors are the violations found in a value; validators return them as an
+// error only when there is at least one.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	violations := make([]string, 0, len(e))
+	for _, violation := range e {
+		violations = append(violations, violation.Field+": "+violation.Message)
+	}
+
+	return ErrInvalid.Error() + ": " + strings.Join(violations, "; ")
+}
+
+func (e Errors) Is(target error) bool {
+	return target == ErrInvalid //nolint:errorlint
+}
+
+func (e *Errors) add(field, code, message string) {
+	*e = append(*e, FieldError{Field: field, Code: code, Message: message})
+}
+
+func (e Errors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+
+	return e
+}
+
+// Song checks a song to be created or to replace another one.
+func Song(song models.Song) error {
+	var errs Errors
+
+	required(&errs, "name", song.Name)
+	maxLength(&errs, "name", song.Name, MaxNameLength)
+	required(&errs, "musicGroup", song.Group)
+	maxLength(&errs, "musicGroup", song.Group, MaxGroupLength)
+
+	if len(song.Text) > MaxTextBytes {
+		errs.add("text", CodeTooLong, fmt.Sprintf("must be at most %d bytes", MaxTextBytes))
+	}
+
+	if song.Link != "" {
+		link(&errs, "link", song.Link)
+	}
+
+	return errs.err()
+}
+
+// regionCode is an ISO 3166-1 alpha-2 code.
+var regionCode = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// Link checks a link of a song. The platform, when given, must be the one of
+// the URL.
+func Link(songLink models.SongLink) error {
+	var errs Errors
+
+	if required(&errs, "url", songLink.URL) && link(&errs, "url", songLink.URL) {
+		normalized, _ := models.NormalizeLink(songLink.URL)
+
+		if songLink.Platform != "" && songLink.Platform != normalized.Platform {
+			errs.add("platform", CodeInvalidValue, "must be "+normalized.Platform+" for this URL")
+		}
+	}
+
+	if songLink.Platform != "" && !slices.Contains(models.Platforms, songLink.Platform) {
+		errs.add("platform", CodeInvalidValue, "must be one of "+strings.Join(models.Platforms, ", "))
+	}
+
+	if songLink.Region != "" && !regionCode.MatchString(songLink.Region) {
+		errs.add("region", CodeInvalidValue, "must be an ISO 3166-1 alpha-2 code")
+	}
+
+	return errs.err()
+}
+
+// slug is lowercase words of letters and digits joined by hyphens.
+var slug = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Tag checks a tag to be created or to replace another one.
+func Tag(tag models.Tag) error {
+	var errs Errors
+
+	if required(&errs, "slug", tag.Slug) {
+		tagSlug(&errs, "slug", tag.Slug)
+	}
+
+	required(&errs, "name", tag.Name)
+	maxLength(&errs, "name", tag.Name, MaxNameLength)
+
+	if tag.ParentID != nil && *tag.ParentID == tag.ID {
+		errs.add("parentId", CodeInvalidValue, "must not be the tag itself")
+	}
+
+	return errs.err()
+}
+
+// SongTags checks the tags of a song, by slug.
+func SongTags(tags models.SongTags) error {
+	var errs Errors
+
+	if len(tags.Tags) > MaxSongTags {
+		errs.add("tags", CodeTooLong, fmt.Sprintf("must have at most %d tags", MaxSongTags))
+	}
+
+	for i, value := range tags.Tags {
+		tagSlug(&errs, fmt.Sprintf("tags[%d]", i), value)
+	}
+
+	return errs.err()
+}
+
+// Webhook checks a webhook to be registered for some of eventTypes.
+func Webhook(webhook models.Webhook, eventTypes []string) error {
+	var errs Errors
+
+	if required(&errs, "url", webhook.URL) {
+		httpURL(&errs, "url", webhook.URL)
+	}
+
+	for i, eventType := range webhook.EventTypes {
+		if !slices.Contains(eventTypes, eventType) {
+			errs.add(fmt.Sprintf("eventTypes[%d]", i), CodeInvalidValue,
+				"must be one of "+strings.Join(eventTypes, ", "))
+		}
+	}
+
+	return errs.err()
+}
+
+// MergeFields lists the song fields a merge can pick, by JSON name.
+var MergeFields = []string{"releaseDate", "name", "musicGroup", "text", "link"}
+
+// MaxMergeSources bounds the songs merged at once.
+const MaxMergeSources = 100
+
+// Merge checks a merge of songs into the song id.
+func Merge(id uuid.UUID, merge models.Merge) error {
+	var errs Errors
+
+	switch {
+	case len(merge.Sources) == 0:
+		errs.add("sources", CodeRequired, "is required")
+	case len(merge.Sources) > MaxMergeSources:
+		errs.add("sources", CodeTooLong, fmt.Sprintf("must have at most %d songs", MaxMergeSources))
+	}
+
+	for i, source := range merge.Sources {
+		switch {
+		case source == id:
+			errs.add(fmt.Sprintf("sources[%d]", i), CodeInvalidValue, "must not be the merged song")
+		case slices.Contains(merge.Sources[:i], source):
+			errs.add(fmt.Sprintf("sources[%d]", i), CodeInvalidValue, "must not be repeated")
+		}
+	}
+
+	fields := make([]string, 0, len(merge.Fields))
+	for field := range merge.Fields {
+		fields = append(fields, field)
+	}
+
+	slices.Sort(fields)
+
+	for _, field := range fields {
+		winner := merge.Fields[field]
+
+		switch {
+		case !slices.Contains(MergeFields, field):
+			errs.add("fields."+field, CodeUnknownField, "must be one of "+strings.Join(MergeFields, ", "))
+		case winner != id && !slices.Contains(merge.Sources, winner):
+			errs.add("fields."+field, CodeInvalidValue, "must be the merged song or one of the sources")
+		}
+	}
+
+	return errs.err()
+}
+
+func required(errs *Errors, field, value string) bool {
+	if strings.TrimSpace(value) == "" {
+		errs.add(field, CodeRequired, "is required")
+
+		return false
+	}
+
+	return true
+}
+
+func maxLength(errs *Errors, field, value string, limit int) {
+	if utf8.RuneCountInString(value) > limit {
+		errs.add(field, CodeTooLong, fmt.Sprintf("must be at most %d characters", limit))
+	}
+}
+
+func tagSlug(errs *Errors, field, value string) {
+	switch {
+	case len(value) > MaxSlugLength:
+		errs.add(field, CodeTooLong, fmt.Sprintf("must be at most %d characters", MaxSlugLength))
+	case !slug.MatchString(value):
+		errs.add(field, CodeInvalidValue, "must be lowercase letters and digits joined by hyphens")
+	}
+}
+
+// link checks a link URL, which must be a valid link to a track when it is on
+// a known platform.
+func link(errs *Errors, field, value string) bool {
+	if len(value) > MaxLinkLength {
+		errs.add(field, CodeTooLong, fmt.Sprintf("must be at most %d characters", MaxLinkLength))
+
+		return false
+	}
+
+	var linkErr *models.LinkError
+
+	if _, err := models.NormalizeLink(value); errors.As(err, &linkErr) {
+		errs.add(field, CodeInvalidURL, linkErr.Reason)
+
+		return false
+	}
+
+	return true
+}
+
+func httpURL(errs *Errors, field, value string) {
+	if len(value) > MaxLinkLength {
+		errs.add(field, CodeTooLong, fmt.Sprintf("must be at most %d characters", MaxLinkLength))
+
+		return
+	}
+
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs.add(field, CodeInvalidURL, "must be an absolute http or https URL")
+	}
+}
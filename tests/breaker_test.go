@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/models"
+	"github.com/iurikman/songs/internal/songdetails"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSongDetailsCircuitBreaker(t *testing.T) {
+	const openTimeout = 100 * time.Millisecond
+
+	var (
+		failing  atomic.Bool
+		requests atomic.Int32
+	)
+
+	// A request held here keeps the trial of a half-open circuit in flight.
+	held := make(chan struct{})
+	release := make(chan struct{})
+
+	var holding atomic.Bool
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+
+		if holding.CompareAndSwap(true, false) {
+			held <- struct{}{}
+			<-release
+		}
+
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(models.SongDetails{ReleaseDate: "16.07.2006", Text: "Ooh baby"})
+	}))
+	defer upstream.Close()
+
+	details := songdetails.NewSongDetails(songdetails.Config{
+		Host:             upstream.URL,
+		FailureThreshold: 2,
+		OpenTimeout:      openTimeout,
+	})
+
+	get := func() error {
+		_, err := details.Get(context.Background(), models.Song{ID: uuid.New(), Name: "Hysteria", Group: "Muse"})
+
+		return err //nolint:wrapcheck
+	}
+
+	require.Equal(t, songdetails.StateClosed, details.CircuitState())
+
+	failing.Store(true)
+
+	require.ErrorIs(t, get(), songdetails.ErrUnexpectedStatus)
+	require.Equal(t, songdetails.StateClosed, details.CircuitState(), "a single failure keeps the circuit closed")
+
+	require.ErrorIs(t, get(), songdetails.ErrUnexpectedStatus)
+	require.Equal(t, songdetails.StateOpen, details.CircuitState(), "failures in a row open the circuit")
+
+	sent := requests.Load()
+
+	require.ErrorIs(t, get(), songdetails.ErrCircuitOpen)
+	require.Equal(t, sent, requests.Load(), "an open circuit does not call the API")
+
+	require.Eventually(t, func() bool {
+		return details.CircuitState() == songdetails.StateHalfOpen
+	}, time.Second, 10*time.Millisecond, "the circuit half-opens after the open timeout")
+
+	require.ErrorIs(t, get(), songdetails.ErrUnexpectedStatus, "a half-open circuit lets a trial through")
+	require.Equal(t, songdetails.StateOpen, details.CircuitState(), "a failed trial opens the circuit again")
+
+	require.Eventually(t, func() bool {
+		return details.CircuitState() == songdetails.StateHalfOpen
+	}, time.Second, 10*time.Millisecond)
+
+	failing.Store(false)
+	holding.Store(true)
+
+	trial := make(chan error, 1)
+
+	go func() {
+		trial <- get()
+	}()
+
+	<-held
+
+	require.ErrorIs(t, get(), songdetails.ErrCircuitOpen, "only one trial runs at a time")
+
+	close(release)
+
+	require.NoError(t, <-trial)
+	require.Equal(t, songdetails.StateClosed, details.CircuitState(), "a successful trial closes the circuit")
+	require.NoError(t, get())
+}
@@ -0,0 +1,407 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/cache"
+	"github.com/iurikman/songs/internal/models"
+	"github.com/iurikman/songs/internal/service"
+	"github.com/stretchr/testify/require"
+)
+
+// songRepo is an in-process store that counts reads and can hold them back
+// so the tests can observe coalescing.
+type songRepo struct {
+	mu      sync.Mutex
+	songs   map[uuid.UUID]*models.Song
+	release chan struct{}
+
+	listCalls atomic.Int32
+	byIDCalls atomic.Int32
+}
+
+func newSongRepo(songs ...*models.Song) *songRepo {
+	repo := &songRepo{songs: make(map[uuid.UUID]*models.Song)}
+
+	for _, song := range songs {
+		repo.songs[song.ID] = song
+	}
+
+	return repo
+}
+
+func (r *songRepo) wait() {
+	if r.release != nil {
+		<-r.release
+	}
+}
+
+func (r *songRepo) CreateSong(_ context.Context, song models.Song) (*models.Song, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.songs[song.ID] = &song
+
+	return &song, nil
+}
+
+func (r *songRepo) GetSongs(_ context.Context, _ models.Params) ([]*models.Song, error) {
+	r.listCalls.Add(1)
+	r.wait()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	songs := make([]*models.Song, 0, len(r.songs))
+	for _, song := range r.songs {
+		songs = append(songs, song)
+	}
+
+	return songs, nil
+}
+
+func (r *songRepo) GetText(context.Context, uuid.UUID, int) (*string, error) {
+	return nil, errors.New("GetText must be served from the cache") //nolint:err113
+}
+
+func (r *songRepo) DeleteSong(_ context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.songs, id)
+
+	return nil
+}
+
+func (r *songRepo) UpdateSong(_ context.Context, id uuid.UUID, song models.Song) (*models.Song, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	song.ID = id
+	r.songs[id] = &song
+
+	return &song, nil
+}
+
+func (r *songRepo) GetSongsByIDs(_ context.Context, ids []uuid.UUID) ([]*models.Song, error) {
+	r.byIDCalls.Add(1)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var songs []*models.Song
+
+	for _, id := range ids {
+		if song, ok := r.songs[id]; ok {
+			songs = append(songs, song)
+		}
+	}
+
+	return songs, nil
+}
+
+func (r *songRepo) GetSongsByGroups(context.Context, []string) ([]*models.Song, error) {
+	return nil, nil
+}
+
+func (r *songRepo) GetGroups(context.Context, models.Params) ([]string, error) {
+	return nil, nil
+}
+
+func (r *songRepo) SimilarSongs(context.Context, models.Song, float64, int) ([]*models.SimilarSong, error) {
+	return nil, nil
+}
+
+func (r *songRepo) SimilarSongPairs(context.Context, float64) ([]models.SongPair, error) {
+	return nil, nil
+}
+
+func (r *songRepo) MergeSongs(context.Context, uuid.UUID, models.Song, []uuid.UUID) (*models.Song, error) {
+	return nil, models.ErrSongNotFound
+}
+
+func (r *songRepo) MergedInto(context.Context, uuid.UUID) (uuid.UUID, error) {
+	return uuid.Nil, models.ErrSongNotFound
+}
+
+func (r *songRepo) GetLinks(context.Context, uuid.UUID) ([]*models.SongLink, error) {
+	return nil, models.ErrSongNotFound
+}
+
+func (r *songRepo) CreateLink(context.Context, models.SongLink) (*models.SongLink, error) {
+	return nil, models.ErrSongNotFound
+}
+
+func (r *songRepo) UpdateLink(context.Context, models.SongLink) (*models.SongLink, error) {
+	return nil, models.ErrSongNotFound
+}
+
+func (r *songRepo) DeleteLink(context.Context, uuid.UUID, uuid.UUID) error {
+	return models.ErrSongNotFound
+}
+
+func (r *songRepo) BrokenLinks(context.Context, models.Params) ([]*models.BrokenLink, error) {
+	return nil, nil
+}
+
+func (r *songRepo) GetTags(context.Context) ([]*models.Tag, error) {
+	return nil, nil
+}
+
+func (r *songRepo) CreateTag(context.Context, models.Tag) (*models.Tag, error) {
+	return nil, nil
+}
+
+func (r *songRepo) UpdateTag(context.Context, models.Tag) (*models.Tag, error) {
+	return nil, nil
+}
+
+func (r *songRepo) DeleteTag(context.Context, uuid.UUID) error {
+	return nil
+}
+
+func (r *songRepo) GetSongTags(context.Context, uuid.UUID) ([]*models.Tag, error) {
+	return nil, nil
+}
+
+func (r *songRepo) SetSongTags(context.Context, uuid.UUID, []string) ([]*models.Tag, error) {
+	return nil, nil
+}
+
+func (r *songRepo) TagFacets(context.Context, models.Params) ([]*models.TagFacet, error) {
+	return nil, nil
+}
+
+func (r *songRepo) RelatedSongs(context.Context, uuid.UUID, models.Params) ([]*models.RelatedSong, error) {
+	return nil, nil
+}
+
+func (r *songRepo) GetStats(context.Context, models.Params) (*models.Stats, error) {
+	return &models.Stats{}, nil
+}
+
+func TestMemoryCacheEvictsAndExpires(t *testing.T) {
+	ctx := context.Background()
+	memory := cache.NewMemory(2)
+
+	require.NoError(t, memory.Set(ctx, "a", []byte("1"), 0))
+	require.NoError(t, memory.Set(ctx, "b", []byte("2"), 0))
+
+	_, ok, _ := memory.Get(ctx, "a")
+	require.True(t, ok)
+
+	// "b" is now the least recently used entry.
+	require.NoError(t, memory.Set(ctx, "c", []byte("3"), 0))
+
+	_, ok, _ = memory.Get(ctx, "b")
+	require.False(t, ok)
+	require.Equal(t, 2, memory.Len())
+
+	require.NoError(t, memory.Set(ctx, "short", []byte("4"), 10*time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+
+	_, ok, _ = memory.Get(ctx, "short")
+	require.False(t, ok)
+
+	require.NoError(t, memory.Set(ctx, "songs:list:1", []byte("x"), 0))
+	require.NoError(t, memory.DeletePrefix(ctx, "songs:list:"))
+
+	_, ok, _ = memory.Get(ctx, "songs:list:1")
+	require.False(t, ok)
+}
+
+func TestRedisCacheBackend(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+
+	require.NoError(t, server.Set("unrelated", "keep"))
+
+	backend := cache.NewRedis(cache.RedisConfig{Addr: server.Addr()})
+	defer backend.Close()
+
+	_, ok, err := backend.Get(ctx, "songs:list:1")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	require.NoError(t, backend.Set(ctx, "songs:list:1", []byte("one"), time.Minute))
+	require.NoError(t, backend.Set(ctx, "songs:verses:1", []byte("two"), time.Minute))
+
+	value, ok, err := backend.Get(ctx, "songs:list:1")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, []byte("one"), value)
+
+	server.FastForward(2 * time.Minute)
+
+	_, ok, err = backend.Get(ctx, "songs:list:1")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	require.NoError(t, backend.Set(ctx, "songs:list:2", []byte("three"), 0))
+	require.NoError(t, backend.DeletePrefix(ctx, "songs:list:"))
+	require.NoError(t, backend.Set(ctx, "songs:verses:1", []byte("two"), 0))
+	require.NoError(t, backend.Purge(ctx))
+
+	require.Equal(t, []string{"unrelated"}, server.Keys())
+}
+
+func TestCachedDB(t *testing.T) {
+	ctx := context.Background()
+	song := &models.Song{ID: uuid.New(), Name: "Cached", Group: "Layer", Text: "first\n\nsecond"}
+	repo := newSongRepo(song)
+	cached := service.NewCachedDB(repo, cache.New("memory", cache.NewMemory(100), time.Minute))
+
+	t.Run("coalesces concurrent misses", func(t *testing.T) {
+		repo.release = make(chan struct{})
+
+		var wg sync.WaitGroup
+
+		for range 10 {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				songs, err := cached.GetSongs(ctx, models.Params{Limit: 10})
+				require.NoError(t, err)
+				require.Len(t, songs, 1)
+			}()
+		}
+
+		require.Eventually(t, func() bool { return repo.listCalls.Load() == 1 }, time.Second, time.Millisecond)
+		time.Sleep(10 * time.Millisecond)
+		close(repo.release)
+		wg.Wait()
+
+		repo.release = nil
+
+		require.EqualValues(t, 1, repo.listCalls.Load())
+		require.Positive(t, cached.Stats().Coalesced)
+	})
+
+	t.Run("serves verses from one lyric lookup", func(t *testing.T) {
+		for verse, want := range map[int]string{1: "first", 2: "second"} {
+			text, err := cached.GetText(ctx, song.ID, verse)
+			require.NoError(t, err)
+			require.Equal(t, want, *text)
+		}
+
+		_, err := cached.GetText(ctx, song.ID, 3)
+		require.ErrorIs(t, err, models.ErrVerseIsNotValid)
+
+		_, err = cached.GetText(ctx, uuid.New(), 1)
+		require.ErrorIs(t, err, models.ErrSongNotFound)
+
+		require.EqualValues(t, 2, repo.byIDCalls.Load())
+	})
+
+	t.Run("writes invalidate", func(t *testing.T) {
+		_, err := cached.UpdateSong(ctx, song.ID, models.Song{Name: "Cached", Group: "Layer", Text: "changed"})
+		require.NoError(t, err)
+
+		text, err := cached.GetText(ctx, song.ID, 1)
+		require.NoError(t, err)
+		require.Equal(t, "changed", *text)
+
+		_, err = cached.CreateSong(ctx, models.Song{ID: uuid.New(), Name: "New"})
+		require.NoError(t, err)
+
+		songs, err := cached.GetSongs(ctx, models.Params{Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, songs, 2)
+		require.EqualValues(t, 2, repo.listCalls.Load())
+	})
+
+	t.Run("notifications from other instances invalidate", func(t *testing.T) {
+		_, err := repo.UpdateSong(ctx, song.ID, models.Song{Text: "from elsewhere"})
+		require.NoError(t, err)
+
+		cached.Invalidate(models.Change{Type: models.EventSongUpdated, SongID: song.ID})
+
+		text, err := cached.GetText(ctx, song.ID, 1)
+		require.NoError(t, err)
+		require.Equal(t, "from elsewhere", *text)
+	})
+
+	stats := cached.Stats()
+	require.Positive(t, stats.Hits)
+	require.Positive(t, stats.Misses)
+	require.Zero(t, stats.Errors)
+}
+
+func TestCacheDropsLoadsOverlappingWrites(t *testing.T) {
+	ctx := context.Background()
+	c := cache.New("memory", cache.NewMemory(100), time.Minute)
+
+	var (
+		mu      sync.Mutex
+		current = "old"
+		loads   atomic.Int32
+	)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	load := func(context.Context) (string, error) {
+		mu.Lock()
+		value := current
+		mu.Unlock()
+
+		if loads.Add(1) == 1 {
+			close(started)
+			<-release
+		}
+
+		return value, nil
+	}
+
+	slow := make(chan string)
+
+	go func() {
+		value, err := cache.Fetch(ctx, c, "songs:list:1", load)
+		require.NoError(t, err)
+
+		slow <- value
+	}()
+
+	<-started
+
+	// A write lands while the first load is still running with what it read
+	// before.
+	mu.Lock()
+	current = "new"
+	mu.Unlock()
+
+	c.DeletePrefix(ctx, "songs:list:")
+
+	fresh := make(chan string, 1)
+
+	go func() {
+		value, err := cache.Fetch(ctx, c, "songs:list:1", load)
+		require.NoError(t, err)
+
+		fresh <- value
+	}()
+
+	select {
+	case value := <-fresh:
+		require.Equal(t, "new", value)
+	case <-time.After(time.Second):
+		t.Error("a read after the write joined the load before it")
+	}
+
+	close(release)
+	require.Equal(t, "old", <-slow)
+
+	value, err := cache.Fetch(ctx, c, "songs:list:1", load)
+	require.NoError(t, err)
+	require.Equal(t, "new", value, "the load before the write is not cached")
+	require.EqualValues(t, 2, loads.Load())
+}
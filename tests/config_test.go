@@ -0,0 +1,136 @@
+package tests
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/iurikman/songs/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigLayers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "songs.yaml")
+	err := os.WriteFile(path, []byte("bind_address: \":7000\"\nlog_level: debug\napi_timeout: 3s\n"), 0o600)
+	require.NoError(t, err)
+
+	t.Setenv("LOG_LEVEL", "warn")
+
+	cfg, err := config.Load([]string{"--config", path, "--bind-address", ":9000"})
+	require.NoError(t, err)
+
+	require.Equal(t, ":9000", cfg.BindAddress, "flag overrides file")
+	require.Equal(t, "warn", cfg.LogLevel, "env overrides file")
+	require.Equal(t, "3s", cfg.APITimeout.String(), "file overrides default")
+	require.Equal(t, config.Default().MaxPageSize, cfg.MaxPageSize, "default is kept")
+}
+
+func TestConfigTOML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "songs.toml")
+	err := os.WriteFile(path, []byte("max_page_size = 50\napi_open_timeout = \"1m\"\n"), 0o600)
+	require.NoError(t, err)
+
+	cfg, err := config.Load([]string{"--config", path})
+	require.NoError(t, err)
+
+	require.Equal(t, 50, cfg.MaxPageSize)
+	require.Equal(t, "1m0s", cfg.APIOpenTimeout.String())
+}
+
+func TestConfigValidationAggregatesErrors(t *testing.T) {
+	t.Setenv("POSTGRES_PORT", "not-a-port")
+
+	_, err := config.Load([]string{"--default-page-size", "0", "--api-url", "localhost"})
+	require.ErrorIs(t, err, config.ErrInvalid)
+	require.ErrorContains(t, err, "POSTGRES_PORT")
+	require.ErrorContains(t, err, "DEFAULT_PAGE_SIZE")
+	require.ErrorContains(t, err, "API_URL")
+}
+
+func TestConfigPrintRedactsSecrets(t *testing.T) {
+	cfg, err := config.Load([]string{"--postgres-password", "s3cr3t"})
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+
+	require.NoError(t, cfg.Print(&out))
+	require.NotContains(t, out.String(), "s3cr3t")
+	require.Contains(t, out.String(), "postgres_password: '[REDACTED]'")
+}
+
+func TestConfigSongDetailsURL(t *testing.T) {
+	cfg := config.Config{APIUrl: "http://localhost/", APIPort: ":8081"}
+	require.Equal(t, "http://localhost:8081", cfg.SongDetailsURL())
+
+	cfg.APIPort = "8081"
+	require.Equal(t, "http://localhost:8081", cfg.SongDetailsURL())
+
+	cfg.APIPort = ""
+	require.Equal(t, "http://localhost", cfg.SongDetailsURL())
+}
+
+func TestConfigReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "songs.yaml")
+	err := os.WriteFile(path, []byte("max_page_size: 50\npostgres_max_conns: 10\n"), 0o600)
+	require.NoError(t, err)
+
+	args := []string{"--config", path}
+
+	cfg, err := config.Load(args)
+	require.NoError(t, err)
+
+	reloader := config.NewReloader(cfg, args)
+	initialHash := reloader.Hash()
+
+	var notified config.Config
+
+	reloader.Subscribe(func(cfg config.Config) { notified = cfg })
+
+	err = os.WriteFile(path, []byte("max_page_size: 70\npostgres_max_conns: 20\n"), 0o600)
+	require.NoError(t, err)
+
+	require.NoError(t, reloader.Reload())
+	require.Equal(t, int64(1), reloader.Reloads())
+	require.NotEqual(t, initialHash, reloader.Hash())
+	require.Equal(t, 70, notified.MaxPageSize, "safe change is applied")
+	require.Equal(t, 10, notified.PostgresMaxConns, "unsafe change is rejected")
+
+	err = os.WriteFile(path, []byte("max_page_size: -1\n"), 0o600)
+	require.NoError(t, err)
+
+	require.Error(t, reloader.Reload())
+	require.Equal(t, int64(1), reloader.Failures())
+	require.Equal(t, 70, reloader.Current().MaxPageSize, "invalid config keeps running values")
+}
+
+func TestConfigMemoryStoreNeedsNoPostgres(t *testing.T) {
+	cfg := config.Default()
+	cfg.APIUrl = "http://localhost"
+	cfg.PostgresHost = ""
+	cfg.PostgresMaxConns = 0
+
+	require.ErrorContains(t, cfg.Validate(), "POSTGRES_USER is required")
+
+	cfg.Store = "memory"
+	require.NoError(t, cfg.Validate())
+}
+
+func TestConfigStoreDSNNeedsNoPostgresSettings(t *testing.T) {
+	cfg := config.Default()
+	cfg.APIUrl = "http://localhost"
+	cfg.PostgresHost = ""
+	cfg.StoreDSN = "sqlite:///tmp/songs.db"
+
+	require.NoError(t, cfg.Validate())
+
+	backend, target := cfg.StoreBackend()
+	require.Equal(t, "sqlite", backend)
+	require.Equal(t, "/tmp/songs.db", target)
+
+	cfg.StoreDSN = "postgres://admin@localhost/songs"
+	require.NoError(t, cfg.Validate(), "the DSN locates the server")
+
+	cfg.PostgresMaxConns = 0
+	require.ErrorContains(t, cfg.Validate(), "POSTGRES_MAX_CONNS", "pool settings still apply")
+}
@@ -0,0 +1,899 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/models"
+	"github.com/iurikman/songs/internal/store"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// songStore is the contract every store backend implements for the service.
+type songStore interface {
+	CreateSong(ctx context.Context, song models.Song) (*models.Song, error)
+	GetSongs(ctx context.Context, params models.Params) ([]*models.Song, error)
+	GetText(ctx context.Context, id uuid.UUID, verse int) (*string, error)
+	DeleteSong(ctx context.Context, id uuid.UUID) error
+	UpdateSong(ctx context.Context, id uuid.UUID, song models.Song) (*models.Song, error)
+	GetSongsByIDs(ctx context.Context, ids []uuid.UUID) ([]*models.Song, error)
+	GetSongsByGroups(ctx context.Context, groups []string) ([]*models.Song, error)
+	GetGroups(ctx context.Context, params models.Params) ([]string, error)
+	SimilarSongs(ctx context.Context, song models.Song, threshold float64, limit int) ([]*models.SimilarSong, error)
+	SimilarSongPairs(ctx context.Context, threshold float64) ([]models.SongPair, error)
+	MergeSongs(ctx context.Context, id uuid.UUID, song models.Song, sources []uuid.UUID) (*models.Song, error)
+	MergedInto(ctx context.Context, id uuid.UUID) (uuid.UUID, error)
+	GetLinks(ctx context.Context, songID uuid.UUID) ([]*models.SongLink, error)
+	CreateLink(ctx context.Context, link models.SongLink) (*models.SongLink, error)
+	UpdateLink(ctx context.Context, link models.SongLink) (*models.SongLink, error)
+	DeleteLink(ctx context.Context, songID, id uuid.UUID) error
+	ClaimLinks(ctx context.Context, limit int, interval time.Duration) ([]*models.SongLink, error)
+	RecordLinkCheck(ctx context.Context, id uuid.UUID, check models.LinkCheck, maxFailures int) (*models.LinkCheck, error)
+	BrokenLinks(ctx context.Context, params models.Params) ([]*models.BrokenLink, error)
+	GetTags(ctx context.Context) ([]*models.Tag, error)
+	CreateTag(ctx context.Context, tag models.Tag) (*models.Tag, error)
+	UpdateTag(ctx context.Context, tag models.Tag) (*models.Tag, error)
+	DeleteTag(ctx context.Context, id uuid.UUID) error
+	GetSongTags(ctx context.Context, songID uuid.UUID) ([]*models.Tag, error)
+	SetSongTags(ctx context.Context, songID uuid.UUID, slugs []string) ([]*models.Tag, error)
+	TagFacets(ctx context.Context, params models.Params) ([]*models.TagFacet, error)
+	RelatedSongs(ctx context.Context, id uuid.UUID, params models.Params) ([]*models.RelatedSong, error)
+	GetStats(ctx context.Context, params models.Params) (*models.Stats, error)
+}
+
+// StoreConformanceSuite checks that a store backend behaves like the others.
+// newStore must return an empty store for every test.
+type StoreConformanceSuite struct {
+	suite.Suite
+	newStore func() songStore
+	store    songStore
+}
+
+func TestMemoryStoreConformance(t *testing.T) {
+	suite.Run(t, &StoreConformanceSuite{newStore: func() songStore { return store.NewMemory() }})
+}
+
+func (s *IntegrationTestSuite) TestPostgresStoreConformance() {
+	suite.Run(s.T(), &StoreConformanceSuite{newStore: func() songStore {
+		s.Require().NoError(s.store.Truncate(context.Background(), "songs", "tags"))
+
+		return s.store
+	}})
+}
+
+func (s *StoreConformanceSuite) SetupTest() {
+	s.store = s.newStore()
+}
+
+func (s *StoreConformanceSuite) create(name, group, releaseDate string) *models.Song {
+	s.T().Helper()
+
+	song, err := s.store.CreateSong(context.Background(), models.Song{
+		ID:          uuid.New(),
+		Name:        name,
+		Group:       group,
+		ReleaseDate: parseReleaseDate(s.T(), releaseDate),
+		Text:        name + " first\n\n" + name + " second",
+		Link:        "https://example.com/" + url.PathEscape(name),
+	})
+	s.Require().NoError(err)
+
+	return song
+}
+
+// parseReleaseDate parses a release date of a test song.
+func parseReleaseDate(t *testing.T, s string) models.ReleaseDate {
+	t.Helper()
+
+	date, err := models.ParseReleaseDate(s)
+	require.NoError(t, err)
+
+	return date
+}
+
+func names(songs []*models.Song) []string {
+	result := make([]string, 0, len(songs))
+	for _, song := range songs {
+		result = append(result, song.Name)
+	}
+
+	return result
+}
+
+func (s *StoreConformanceSuite) TestCreateEnforcesUniqueness() {
+	ctx := context.Background()
+	song := s.create("alpha", "band", "01.01.2001")
+
+	s.Require().Equal("alpha", song.Name)
+	s.Require().Equal("https://example.com/alpha", song.Link)
+	s.Require().False(song.Deleted)
+
+	_, err := s.store.CreateSong(ctx, models.Song{ID: uuid.New(), Name: "alpha", Group: "band", ReleaseDate: parseReleaseDate(s.T(), "01.01.2001")})
+	s.Require().ErrorIs(err, models.ErrDuplicateSong)
+
+	_, err = s.store.CreateSong(ctx, models.Song{ID: song.ID, Name: "other", Group: "band"})
+	s.Require().ErrorIs(err, models.ErrDuplicateSong)
+
+	s.Require().NoError(s.store.DeleteSong(ctx, song.ID))
+
+	_, err = s.store.CreateSong(ctx, models.Song{ID: uuid.New(), Name: "alpha", Group: "band", ReleaseDate: parseReleaseDate(s.T(), "01.01.2001")})
+	s.Require().ErrorIs(err, models.ErrDuplicateSong, "deleted songs keep their key")
+
+	s.create("alpha", "band", "02.02.2002")
+}
+
+func (s *StoreConformanceSuite) TestSoftDelete() {
+	ctx := context.Background()
+	kept := s.create("kept", "band", "")
+	deleted := s.create("deleted", "band", "")
+
+	s.Require().NoError(s.store.DeleteSong(ctx, deleted.ID))
+	s.Require().ErrorIs(s.store.DeleteSong(ctx, deleted.ID), models.ErrSongNotFound)
+	s.Require().ErrorIs(s.store.DeleteSong(ctx, uuid.New()), models.ErrSongNotFound)
+
+	_, err := s.store.GetText(ctx, deleted.ID, 1)
+	s.Require().ErrorIs(err, models.ErrSongNotFound)
+
+	songs, err := s.store.GetSongs(ctx, models.Params{Limit: 10})
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"kept"}, names(songs))
+
+	byID, err := s.store.GetSongsByIDs(ctx, []uuid.UUID{kept.ID, deleted.ID})
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"kept"}, names(byID))
+}
+
+func (s *StoreConformanceSuite) TestListFiltersSortsAndPages() {
+	ctx := context.Background()
+
+	s.create("charlie", "x", "03.03.2003")
+	s.create("alpha", "y", "01.01.2001")
+	s.create("delta", "x", "04.04.2004")
+	s.create("bravo", "y", "02.02.2002")
+	s.create("100%_pure", "z", "05.05.2005")
+
+	songs, err := s.store.GetSongs(ctx, models.Params{Limit: 10, Filter: "a"})
+	s.Require().NoError(err)
+	s.Require().ElementsMatch([]string{"charlie", "alpha", "delta", "bravo"}, names(songs))
+
+	songs, err = s.store.GetSongs(ctx, models.Params{Limit: 10, Filter: "%_"})
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"100%_pure"}, names(songs), "LIKE wildcards are matched literally")
+
+	songs, err = s.store.GetSongs(ctx, models.Params{Limit: 10, Filter: "a", Sorting: "name"})
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"alpha", "bravo", "charlie", "delta"}, names(songs))
+
+	songs, err = s.store.GetSongs(ctx, models.Params{Limit: 10, Filter: "a", Sorting: "releaseDate", Descending: true})
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"delta", "charlie", "bravo", "alpha"}, names(songs))
+
+	songs, err = s.store.GetSongs(ctx, models.Params{Offset: 1, Limit: 2, Sorting: "release_date"})
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"bravo", "charlie"}, names(songs))
+
+	songs, err = s.store.GetSongs(ctx, models.Params{Offset: 10, Limit: 2})
+	s.Require().NoError(err)
+	s.Require().Empty(songs)
+
+	_, err = s.store.GetSongs(ctx, models.Params{Limit: 10, Sorting: "name; DROP TABLE songs"})
+	s.Require().ErrorIs(err, models.ErrInvalidSorting)
+}
+
+func (s *StoreConformanceSuite) TestReleaseDates() {
+	ctx := context.Background()
+
+	s.create("eighties", "x", "05.01.1989")
+	s.create("nineties", "x", "01.02.1990")
+	s.create("late", "x", "12.1999")
+	s.create("year", "x", "1995")
+	s.create("unknown", "x", "")
+
+	songs, err := s.store.GetSongs(ctx, models.Params{Limit: 10, Sorting: "releaseDate"})
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"eighties", "nineties", "year", "late", "unknown"}, names(songs),
+		"dates sort chronologically, unknown ones last")
+
+	songs, err = s.store.GetSongs(ctx, models.Params{Limit: 10, Sorting: "releaseDate", Descending: true})
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"late", "year", "nineties", "eighties", "unknown"}, names(songs))
+
+	songs, err = s.store.GetSongs(ctx, models.Params{
+		Limit:        10,
+		Sorting:      "releaseDate",
+		ReleasedFrom: parseReleaseDate(s.T(), "1990"),
+		ReleasedTo:   parseReleaseDate(s.T(), "1999"),
+	})
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"nineties", "year", "late"}, names(songs), "the 1990s")
+
+	songs, err = s.store.GetSongs(ctx, models.Params{Limit: 10, ReleasedTo: parseReleaseDate(s.T(), "01.1990")})
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"eighties"}, names(songs))
+	s.Require().Equal(models.PrecisionDay, songs[0].ReleaseDate.Precision)
+
+	songs, err = s.store.GetSongs(ctx, models.Params{Limit: 10, Filter: "year"})
+	s.Require().NoError(err)
+	s.Require().Len(songs, 1)
+	s.Require().Equal("1995", songs[0].ReleaseDate.String(), "the precision is kept")
+}
+
+func (s *StoreConformanceSuite) TestVerses() {
+	ctx := context.Background()
+	song := s.create("verses", "band", "")
+
+	text, err := s.store.GetText(ctx, song.ID, 1)
+	s.Require().NoError(err)
+	s.Require().Equal("verses first", *text)
+
+	text, err = s.store.GetText(ctx, song.ID, 2)
+	s.Require().NoError(err)
+	s.Require().Equal("verses second", *text)
+
+	for _, verse := range []int{0, 3} {
+		_, err = s.store.GetText(ctx, song.ID, verse)
+		s.Require().ErrorIs(err, models.ErrVerseIsNotValid)
+	}
+
+	_, err = s.store.GetText(ctx, uuid.New(), 1)
+	s.Require().ErrorIs(err, models.ErrSongNotFound)
+}
+
+func (s *StoreConformanceSuite) TestUpdate() {
+	ctx := context.Background()
+	song := s.create("before", "band", "01.01.2001")
+	other := s.create("other", "band", "01.01.2001")
+
+	updated, err := s.store.UpdateSong(ctx, song.ID, models.Song{Name: "after", Group: "band", Text: "new"})
+	s.Require().NoError(err)
+	s.Require().Equal(song.ID, updated.ID)
+	s.Require().Equal("after", updated.Name)
+	s.Require().Empty(updated.ReleaseDate)
+
+	text, err := s.store.GetText(ctx, song.ID, 1)
+	s.Require().NoError(err)
+	s.Require().Equal("new", *text)
+
+	_, err = s.store.UpdateSong(ctx, uuid.New(), models.Song{Name: "missing", Group: "band"})
+	s.Require().ErrorIs(err, models.ErrSongNotFound)
+
+	_, err = s.store.UpdateSong(ctx, other.ID, models.Song{Name: "after", Group: "band"})
+	s.Require().ErrorIs(err, models.ErrDuplicateSong)
+
+	s.Require().NoError(s.store.DeleteSong(ctx, other.ID))
+
+	_, err = s.store.UpdateSong(ctx, other.ID, models.Song{Name: "revived", Group: "band"})
+	s.Require().ErrorIs(err, models.ErrSongNotFound, "deleted songs are not updated")
+
+	songs, err := s.store.GetSongs(ctx, models.Params{Limit: 10, Filter: "revived"})
+	s.Require().NoError(err)
+	s.Require().Empty(songs)
+}
+
+func (s *StoreConformanceSuite) TestGroups() {
+	ctx := context.Background()
+
+	s.create("b-song", "beta", "")
+	s.create("a-song", "beta", "")
+	s.create("solo", "alpha", "")
+	s.create("gone", "gamma", "")
+
+	gone, err := s.store.GetSongs(ctx, models.Params{Limit: 10, Filter: "gone"})
+	s.Require().NoError(err)
+	s.Require().NoError(s.store.DeleteSong(ctx, gone[0].ID))
+
+	songs, err := s.store.GetSongsByGroups(ctx, []string{"beta", "alpha", "gamma"})
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"solo", "a-song", "b-song"}, names(songs))
+
+	groups, err := s.store.GetGroups(ctx, models.Params{Limit: 10})
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"alpha", "beta"}, groups)
+
+	groups, err = s.store.GetGroups(ctx, models.Params{Limit: 1, Descending: true})
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"beta"}, groups)
+
+	groups, err = s.store.GetGroups(ctx, models.Params{Limit: 10, Filter: "lph"})
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"alpha"}, groups)
+}
+
+func (s *StoreConformanceSuite) TestSimilarSongs() {
+	ctx := context.Background()
+
+	yesterday := s.create("Yesterday", "The Beatles", "")
+	letItBe := s.create("Let It Be", "The Beatles", "03.1970")
+	s.create("Yesterday", "Muse", "")
+	s.create("Help!", "The Beatles", "1965")
+	gone := s.create("Yesterday", "The Beatles", "1966")
+	s.Require().NoError(s.store.DeleteSong(ctx, gone.ID))
+
+	similar, err := s.store.SimilarSongs(ctx, models.Song{Name: "yesterday ", Group: "the beatles"}, 0.7, 5)
+	s.Require().NoError(err)
+	s.Require().Len(similar, 1)
+	s.Require().Equal(yesterday.ID, similar[0].ID)
+	s.Require().InDelta(1, similar[0].Similarity, 1e-6)
+
+	similar, err = s.store.SimilarSongs(ctx, models.Song{
+		Name:        "Let it be",
+		Group:       "The Beatles",
+		ReleaseDate: parseReleaseDate(s.T(), "1970"),
+	}, 0.7, 5)
+	s.Require().NoError(err)
+	s.Require().Len(similar, 1, "overlapping release dates")
+	s.Require().Equal(letItBe.ID, similar[0].ID)
+
+	similar, err = s.store.SimilarSongs(ctx, models.Song{
+		Name:        "Let it be",
+		Group:       "The Beatles",
+		ReleaseDate: parseReleaseDate(s.T(), "04.1970"),
+	}, 0.7, 5)
+	s.Require().NoError(err)
+	s.Require().Empty(similar, "disjoint release dates")
+
+	similar, err = s.store.SimilarSongs(ctx, models.Song{Name: "Yesterdays", Group: "Beatles"}, 0.3, 1)
+	s.Require().NoError(err)
+	s.Require().Len(similar, 1)
+	s.Require().Equal(yesterday.ID, similar[0].ID)
+	s.Require().Less(similar[0].Similarity, 1.0)
+
+	remaster := s.create("yesterday ", "the beatles", "14.09.1965")
+
+	pairs, err := s.store.SimilarSongPairs(ctx, 0.7)
+	s.Require().NoError(err)
+	s.Require().Len(pairs, 1)
+	s.Require().ElementsMatch([]uuid.UUID{yesterday.ID, remaster.ID}, []uuid.UUID{pairs[0].A, pairs[0].B})
+	s.Require().InDelta(1, pairs[0].Similarity, 1e-6)
+}
+
+func (s *StoreConformanceSuite) TestMergeSongs() {
+	ctx := context.Background()
+
+	yesterday := s.create("Yesterday", "The Beatles", "1965")
+	typo := s.create("Yesterdy", "The Beatles", "1965")
+	remaster := s.create("yesterday ", "the beatles", "14.09.1965")
+	other := s.create("Help!", "The Beatles", "1965")
+
+	// The merged song takes the key of a source, which then no longer holds it.
+	song := *typo
+	song.Name = yesterday.Name
+
+	merged, err := s.store.MergeSongs(ctx, typo.ID, song, []uuid.UUID{yesterday.ID})
+	s.Require().NoError(err)
+	s.Require().Equal(typo.ID, merged.ID)
+	s.Require().Equal("Yesterday", merged.Name)
+	s.Require().False(merged.Deleted)
+
+	songs, err := s.store.GetSongsByIDs(ctx, []uuid.UUID{yesterday.ID, typo.ID})
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"Yesterday"}, names(songs))
+	s.Require().Equal(typo.ID, songs[0].ID)
+
+	into, err := s.store.MergedInto(ctx, yesterday.ID)
+	s.Require().NoError(err)
+	s.Require().Equal(typo.ID, into)
+
+	_, err = s.store.MergedInto(ctx, typo.ID)
+	s.Require().ErrorIs(err, models.ErrSongNotFound)
+
+	// Songs merged into a source follow it.
+	_, err = s.store.MergeSongs(ctx, remaster.ID, *remaster, []uuid.UUID{typo.ID})
+	s.Require().NoError(err)
+
+	into, err = s.store.MergedInto(ctx, yesterday.ID)
+	s.Require().NoError(err)
+	s.Require().Equal(remaster.ID, into)
+
+	_, err = s.store.MergeSongs(ctx, remaster.ID, *remaster, []uuid.UUID{typo.ID})
+	s.Require().ErrorIs(err, models.ErrSongNotFound, "merged sources are gone")
+
+	_, err = s.store.MergeSongs(ctx, typo.ID, *typo, []uuid.UUID{other.ID})
+	s.Require().ErrorIs(err, models.ErrSongNotFound, "merged songs cannot be merged into")
+
+	song = *remaster
+	song.Name = other.Name
+	song.ReleaseDate = other.ReleaseDate
+	song.Group = other.Group
+
+	_, err = s.store.MergeSongs(ctx, remaster.ID, song, []uuid.UUID{s.create("Girl", "The Beatles", "1965").ID})
+	s.Require().ErrorIs(err, models.ErrDuplicateSong, "the key of a song not merged")
+
+	songs, err = s.store.GetSongs(ctx, models.Params{Limit: 10})
+	s.Require().NoError(err)
+	s.Require().ElementsMatch([]string{"yesterday ", "Help!", "Girl"}, names(songs), "a failed merge changes nothing")
+}
+
+func (s *StoreConformanceSuite) TestLinks() {
+	ctx := context.Background()
+
+	song := s.create("Yesterday", "The Beatles", "1965")
+	other := s.create("Help!", "The Beatles", "1965")
+
+	links, err := s.store.GetLinks(ctx, song.ID)
+	s.Require().NoError(err)
+	s.Require().Len(links, 1)
+	s.Require().Equal(song.Link, links[0].URL)
+	s.Require().True(links[0].Primary)
+
+	spotify, err := s.store.CreateLink(ctx, models.SongLink{
+		ID: uuid.New(), SongID: song.ID, Platform: models.PlatformSpotify,
+		URL: "https://open.spotify.com/track/3BQHpFgAp4l80e1XslIjNI", ExternalID: "3BQHpFgAp4l80e1XslIjNI", Region: "SE",
+	})
+	s.Require().NoError(err)
+	s.Require().Equal("SE", spotify.Region)
+	s.Require().False(spotify.Primary)
+	s.Require().False(spotify.CreatedAt.IsZero())
+
+	_, err = s.store.CreateLink(ctx, models.SongLink{
+		ID: uuid.New(), SongID: song.ID, Platform: models.PlatformSpotify, URL: spotify.URL,
+	})
+	s.Require().ErrorIs(err, models.ErrDuplicateLink)
+
+	_, err = s.store.CreateLink(ctx, models.SongLink{
+		ID: uuid.New(), SongID: other.ID, Platform: models.PlatformSpotify, URL: spotify.URL,
+	})
+	s.Require().NoError(err, "songs may share a link")
+
+	// A primary link becomes the link of the song.
+	spotify.Primary = true
+
+	_, err = s.store.UpdateLink(ctx, *spotify)
+	s.Require().NoError(err)
+
+	songs, err := s.store.GetSongsByIDs(ctx, []uuid.UUID{song.ID})
+	s.Require().NoError(err)
+	s.Require().Equal(spotify.URL, songs[0].Link)
+
+	links, err = s.store.GetLinks(ctx, song.ID)
+	s.Require().NoError(err)
+	s.Require().Equal([]string{spotify.URL, song.Link}, urlsOf(links))
+	s.Require().False(links[1].Primary)
+
+	// Updating the song adds its link or makes it the primary one again.
+	updated, err := s.store.UpdateSong(ctx, song.ID, *song)
+	s.Require().NoError(err)
+	s.Require().Equal(song.Link, updated.Link)
+
+	links, err = s.store.GetLinks(ctx, song.ID)
+	s.Require().NoError(err)
+	s.Require().Equal([]string{song.Link, spotify.URL}, urlsOf(links))
+
+	_, err = s.store.UpdateLink(ctx, models.SongLink{ID: uuid.New(), SongID: song.ID, Platform: models.PlatformOther, URL: "https://example.com"})
+	s.Require().ErrorIs(err, models.ErrLinkNotFound)
+
+	s.Require().ErrorIs(s.store.DeleteLink(ctx, other.ID, spotify.ID), models.ErrLinkNotFound, "links belong to a song")
+	s.Require().NoError(s.store.DeleteLink(ctx, song.ID, links[0].ID))
+
+	songs, err = s.store.GetSongsByIDs(ctx, []uuid.UUID{song.ID})
+	s.Require().NoError(err)
+	s.Require().Empty(songs[0].Link, "deleting the primary link leaves the song without a link")
+
+	// Merging moves the links of the sources but for those already there.
+	merged, err := s.store.MergeSongs(ctx, song.ID, *songs[0], []uuid.UUID{other.ID})
+	s.Require().NoError(err)
+	s.Require().Empty(merged.Link)
+
+	links, err = s.store.GetLinks(ctx, song.ID)
+	s.Require().NoError(err)
+	s.Require().ElementsMatch([]string{spotify.URL, other.Link}, urlsOf(links))
+
+	for _, link := range links {
+		s.Require().Equal(song.ID, link.SongID)
+		s.Require().False(link.Primary)
+	}
+
+	_, err = s.store.GetLinks(ctx, other.ID)
+	s.Require().ErrorIs(err, models.ErrSongNotFound)
+
+	s.Require().NoError(s.store.DeleteSong(ctx, song.ID))
+
+	_, err = s.store.CreateLink(ctx, models.SongLink{
+		ID: uuid.New(), SongID: song.ID, Platform: models.PlatformOther, URL: "https://example.com",
+	})
+	s.Require().ErrorIs(err, models.ErrSongNotFound)
+}
+
+func (s *StoreConformanceSuite) TestUnrecognizedLinks() {
+	ctx := context.Background()
+	song := s.create("alpha", "one", "")
+
+	// Links of songs may come from the details API, which the normalizer
+	// does not vouch for.
+	channel := "https://www.youtube.com/channel/UC0C-w0YjGpqDXGB8IHb662A"
+
+	updated, err := s.store.UpdateSong(ctx, song.ID, models.Song{Name: song.Name, Group: song.Group, Link: channel})
+	s.Require().NoError(err)
+	s.Require().Equal(channel, updated.Link)
+
+	links, err := s.store.GetLinks(ctx, song.ID)
+	s.Require().NoError(err)
+	s.Require().Len(links, 2)
+	s.Require().Equal(models.PlatformOther, links[0].Platform, "a link the normalizer rejects is kept as another platform")
+	s.Require().True(links[0].Primary)
+
+	updated, err = s.store.UpdateSong(ctx, song.ID, models.Song{Name: song.Name, Group: song.Group, Link: "not a link"})
+	s.Require().NoError(err)
+	s.Require().Empty(updated.Link, "what is not an http or https URL is left out")
+
+	created, err := s.store.CreateSong(ctx, models.Song{ID: uuid.New(), Name: "beta", Group: "one", Link: "ftp://example.com/beta"})
+	s.Require().NoError(err)
+	s.Require().Empty(created.Link)
+}
+
+func (s *StoreConformanceSuite) TestLinkChecks() {
+	ctx := context.Background()
+
+	song := s.create("Yesterday", "The Beatles", "1965")
+	deleted := s.create("Help!", "The Beatles", "1965")
+	s.Require().NoError(s.store.DeleteSong(ctx, deleted.ID))
+
+	claimed, err := s.store.ClaimLinks(ctx, 10, time.Hour)
+	s.Require().NoError(err)
+	s.Require().Len(claimed, 1, "links of deleted songs are not checked")
+	s.Require().Equal(song.Link, claimed[0].URL)
+	s.Require().Nil(claimed[0].Check)
+
+	claimed, err = s.store.ClaimLinks(ctx, 10, time.Hour)
+	s.Require().NoError(err)
+	s.Require().Empty(claimed, "claimed links are due after the interval")
+
+	id := s.linkIDs(song.ID)[0]
+	failed := models.LinkCheck{Status: http.StatusNotFound, CheckedAt: time.Now().UTC().Truncate(time.Millisecond)}
+
+	check, err := s.store.RecordLinkCheck(ctx, id, failed, 2)
+	s.Require().NoError(err)
+	s.Require().Equal(1, check.Failures)
+	s.Require().False(check.Broken)
+	s.Require().True(failed.CheckedAt.Equal(check.CheckedAt))
+
+	check, err = s.store.RecordLinkCheck(ctx, id, failed, 2)
+	s.Require().NoError(err)
+	s.Require().Equal(2, check.Failures)
+	s.Require().True(check.Broken)
+
+	broken, err := s.store.BrokenLinks(ctx, models.Params{Limit: 10})
+	s.Require().NoError(err)
+	s.Require().Len(broken, 1)
+	s.Require().Equal(id, broken[0].ID)
+	s.Require().Equal(song.Name, broken[0].Name)
+	s.Require().Equal(song.Group, broken[0].Group)
+	s.Require().Equal(http.StatusNotFound, broken[0].Check.Status)
+
+	check, err = s.store.RecordLinkCheck(ctx, id, models.LinkCheck{
+		Status: http.StatusOK, RedirectURL: "https://example.com/moved", CheckedAt: time.Now(),
+	}, 2)
+	s.Require().NoError(err)
+	s.Require().Zero(check.Failures)
+	s.Require().False(check.Broken, "a link that works again is not broken")
+	s.Require().Equal("https://example.com/moved", check.RedirectURL)
+
+	broken, err = s.store.BrokenLinks(ctx, models.Params{Limit: 10})
+	s.Require().NoError(err)
+	s.Require().Empty(broken)
+
+	_, err = s.store.RecordLinkCheck(ctx, uuid.New(), failed, 2)
+	s.Require().ErrorIs(err, models.ErrLinkNotFound)
+}
+
+// linkIDs returns the ids of the links of a song.
+func (s *StoreConformanceSuite) linkIDs(songID uuid.UUID) []uuid.UUID {
+	s.T().Helper()
+
+	links, err := s.store.GetLinks(context.Background(), songID)
+	s.Require().NoError(err)
+
+	ids := make([]uuid.UUID, 0, len(links))
+	for _, link := range links {
+		ids = append(ids, link.ID)
+	}
+
+	return ids
+}
+
+func (s *StoreConformanceSuite) TestTags() {
+	ctx := context.Background()
+
+	rock := s.createTag("rock", nil)
+	punk := s.createTag("punk", &rock.ID)
+	grunge := s.createTag("grunge", &rock.ID)
+	s.createTag("calm", nil)
+
+	s.Require().Equal(rock.ID, *punk.ParentID)
+	s.Require().Nil(rock.ParentID)
+
+	_, err := s.store.CreateTag(ctx, models.Tag{ID: uuid.New(), Slug: "rock", Name: "Rock again"})
+	s.Require().ErrorIs(err, models.ErrDuplicateTag)
+
+	orphan := uuid.New()
+
+	_, err = s.store.CreateTag(ctx, models.Tag{ID: uuid.New(), Slug: "orphan", Name: "Orphan", ParentID: &orphan})
+	s.Require().ErrorIs(err, models.ErrUnknownTag)
+
+	tags, err := s.store.GetTags(ctx)
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"calm", "grunge", "punk", "rock"}, slugsOf(tags))
+
+	anarchy := s.create("Anarchy in the U.K.", "Sex Pistols", "1976")
+	teen := s.create("Smells Like Teen Spirit", "Nirvana", "1991")
+	yesterday := s.create("Yesterday", "The Beatles", "1965")
+	help := s.create("Help!", "The Beatles", "1965")
+
+	tags, err = s.store.SetSongTags(ctx, anarchy.ID, []string{"punk", "punk"})
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"punk"}, slugsOf(tags))
+
+	s.setTags(teen.ID, "grunge", "rock")
+	s.setTags(yesterday.ID, "calm")
+
+	_, err = s.store.SetSongTags(ctx, yesterday.ID, []string{"calm", "jazz"})
+	s.Require().ErrorIs(err, models.ErrUnknownTag)
+
+	tags, err = s.store.GetSongTags(ctx, yesterday.ID)
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"calm"}, slugsOf(tags), "unknown tags change nothing")
+
+	_, err = s.store.SetSongTags(ctx, uuid.New(), []string{"calm"})
+	s.Require().ErrorIs(err, models.ErrSongNotFound)
+
+	_, err = s.store.GetSongTags(ctx, uuid.New())
+	s.Require().ErrorIs(err, models.ErrSongNotFound)
+
+	list := func(params models.Params) []string {
+		params.Limit = 10
+		params.Sorting = "name"
+
+		songs, err := s.store.GetSongs(ctx, params)
+		s.Require().NoError(err)
+
+		return names(songs)
+	}
+
+	s.Require().Equal([]string{"Smells Like Teen Spirit"}, list(models.Params{Tag: "rock"}))
+	s.Require().Equal([]string{"Anarchy in the U.K.", "Smells Like Teen Spirit"},
+		list(models.Params{Tag: "rock", Descendants: true}))
+	s.Require().Equal([]string{"Anarchy in the U.K."}, list(models.Params{Tag: "punk", Descendants: true}))
+	s.Require().Equal([]string{"Smells Like Teen Spirit"},
+		list(models.Params{Tag: "rock", Descendants: true, Filter: "Spirit"}))
+	s.Require().Empty(list(models.Params{Tag: "jazz", Descendants: true}))
+
+	facets, err := s.store.TagFacets(ctx, models.Params{})
+	s.Require().NoError(err)
+	s.Require().Equal(map[string]int{"rock": 2, "punk": 1, "grunge": 1, "calm": 1}, countsOf(facets))
+	s.Require().Equal("rock", facets[0].Slug, "the largest counts come first")
+
+	facets, err = s.store.TagFacets(ctx, models.Params{Tag: "rock", Descendants: true, ReleasedTo: parseReleaseDate(s.T(), "1980")})
+	s.Require().NoError(err)
+	s.Require().Equal(map[string]int{"rock": 1, "punk": 1}, countsOf(facets), "facets follow the filter")
+
+	// Grunge under punk makes rock > punk > grunge.
+	grunge.ParentID = &punk.ID
+
+	grunge, err = s.store.UpdateTag(ctx, *grunge)
+	s.Require().NoError(err)
+	s.Require().Equal(punk.ID, *grunge.ParentID)
+	s.Require().Equal([]string{"Anarchy in the U.K.", "Smells Like Teen Spirit"},
+		list(models.Params{Tag: "punk", Descendants: true}))
+
+	rock.ParentID = &grunge.ID
+
+	_, err = s.store.UpdateTag(ctx, *rock)
+	s.Require().ErrorIs(err, models.ErrTagCycle)
+
+	rock.ParentID = nil
+	rock.Slug = "calm"
+
+	_, err = s.store.UpdateTag(ctx, *rock)
+	s.Require().ErrorIs(err, models.ErrDuplicateTag)
+
+	_, err = s.store.UpdateTag(ctx, models.Tag{ID: uuid.New(), Slug: "missing", Name: "Missing"})
+	s.Require().ErrorIs(err, models.ErrTagNotFound)
+
+	// Deleting punk moves grunge up to rock and untags the songs.
+	s.Require().NoError(s.store.DeleteTag(ctx, punk.ID))
+	s.Require().ErrorIs(s.store.DeleteTag(ctx, punk.ID), models.ErrTagNotFound)
+
+	tags, err = s.store.GetTags(ctx)
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"calm", "grunge", "rock"}, slugsOf(tags))
+	s.Require().Equal(rock.ID, *tags[1].ParentID)
+
+	tags, err = s.store.GetSongTags(ctx, anarchy.ID)
+	s.Require().NoError(err)
+	s.Require().Empty(tags)
+
+	// Merging moves the tags of the sources.
+	s.setTags(help.ID, "calm", "grunge")
+
+	_, err = s.store.MergeSongs(ctx, yesterday.ID, *yesterday, []uuid.UUID{help.ID})
+	s.Require().NoError(err)
+
+	tags, err = s.store.GetSongTags(ctx, yesterday.ID)
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"calm", "grunge"}, slugsOf(tags))
+
+	facets, err = s.store.TagFacets(ctx, models.Params{Tag: "calm"})
+	s.Require().NoError(err)
+	s.Require().Equal(map[string]int{"calm": 1, "grunge": 1, "rock": 1}, countsOf(facets))
+
+	s.Require().NoError(s.store.DeleteSong(ctx, yesterday.ID))
+	s.Require().Empty(list(models.Params{Tag: "calm"}), "deleted songs are not listed")
+}
+
+func (s *StoreConformanceSuite) createTag(slug string, parentID *uuid.UUID) *models.Tag {
+	s.T().Helper()
+
+	tag, err := s.store.CreateTag(context.Background(), models.Tag{
+		ID: uuid.New(), Slug: slug, Name: slug, ParentID: parentID,
+	})
+	s.Require().NoError(err)
+
+	return tag
+}
+
+func (s *StoreConformanceSuite) setTags(songID uuid.UUID, slugs ...string) {
+	s.T().Helper()
+
+	_, err := s.store.SetSongTags(context.Background(), songID, slugs)
+	s.Require().NoError(err)
+}
+
+func slugsOf(tags []*models.Tag) []string {
+	slugs := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		slugs = append(slugs, tag.Slug)
+	}
+
+	return slugs
+}
+
+func countsOf(facets []*models.TagFacet) map[string]int {
+	counts := make(map[string]int, len(facets))
+	for _, facet := range facets {
+		counts[facet.Slug] = facet.Count
+	}
+
+	return counts
+}
+
+func (s *StoreConformanceSuite) TestStats() {
+	ctx := context.Background()
+
+	yesterday := s.create("Yesterday", "The Beatles", "1965")
+	s.create("Help!", "The Beatles", "1965-07")
+	s.create("Gone", "Gone", "2000")
+
+	for _, song := range []models.Song{
+		{ID: uuid.New(), Name: "Untitled", Group: "Nirvana", Text: "one two\n\nthree\n\nfour five six"},
+		{ID: uuid.New(), Name: "Silence", Group: "Nirvana", ReleaseDate: parseReleaseDate(s.T(), "1991")},
+	} {
+		_, err := s.store.CreateSong(ctx, song)
+		s.Require().NoError(err)
+	}
+
+	gone, err := s.store.GetSongs(ctx, models.Params{Filter: "Gone", Limit: 1})
+	s.Require().NoError(err)
+	s.Require().NoError(s.store.DeleteSong(ctx, gone[0].ID))
+
+	s.createTag("rock", nil)
+	s.setTags(yesterday.ID, "rock")
+
+	stats := s.stats(models.Params{Limit: 10})
+
+	s.Require().Equal(4, stats.Songs, "deleted songs are left out")
+	s.Require().Equal(2, stats.Groups)
+	s.Require().Equal([]models.StatsCount{{Key: "Nirvana", Songs: 2}, {Key: "The Beatles", Songs: 2}}, stats.ByGroup)
+	s.Require().Equal([]models.StatsCount{{Key: "1965", Songs: 2}, {Key: "1991", Songs: 1}}, stats.ByReleaseYear)
+	s.Require().Len(stats.ByCreationMonth, 1)
+	s.Require().Equal(4, stats.ByCreationMonth[0].Songs)
+
+	s.Require().Equal(3, stats.Lyrics.Songs, "songs without lyrics are left out")
+	s.Require().InDelta(7.0/3, stats.Lyrics.AverageVerses, 1e-9)
+	s.Require().Equal(3, stats.Lyrics.MaxVerses)
+	s.Require().InDelta(14.0/3, stats.Lyrics.AverageWords, 1e-9)
+	s.Require().Equal(14, stats.Lyrics.TotalWords)
+
+	s.Require().Equal(models.Completeness{ReleaseDate: 0.75, Text: 0.75, Link: 0.5, Tags: 0.25}, stats.Completeness)
+
+	stats = s.stats(models.Params{Offset: 1, Limit: 1})
+	s.Require().Equal([]models.StatsCount{{Key: "The Beatles", Songs: 2}}, stats.ByGroup, "groups are paged")
+	s.Require().Equal(2, stats.Groups)
+}
+
+// stats returns the statistics of the store, refreshing them first when the
+// store serves them from materialized views.
+func (s *StoreConformanceSuite) stats(params models.Params) *models.Stats {
+	s.T().Helper()
+
+	ctx := context.Background()
+
+	if refresher, ok := s.store.(interface {
+		RefreshStats(ctx context.Context) error
+	}); ok {
+		s.Require().NoError(refresher.RefreshStats(ctx))
+	}
+
+	stats, err := s.store.GetStats(ctx, params)
+	s.Require().NoError(err)
+
+	return stats
+}
+
+func (s *StoreConformanceSuite) TestRelatedSongs() {
+	ctx := context.Background()
+
+	lyrics := func(name, group, text string) *models.Song {
+		song, err := s.store.CreateSong(ctx, models.Song{ID: uuid.New(), Name: name, Group: group, Text: text})
+		s.Require().NoError(err)
+
+		return song
+	}
+
+	loveMeDo := lyrics("Love Me Do", "The Beatles", "Love, love me do\n\nYou know I love you")
+	cover := lyrics("Love Me Do (cover)", "Covers", "Love, love me do\n\nYou know I love you")
+	tender := lyrics("Love Me Tender", "Elvis Presley", "Love me tender, love me sweet")
+	allYouNeed := lyrics("All You Need Is Love", "The Beatles", "All you need is love")
+	highway := lyrics("Highway to Hell", "AC/DC", "Living easy, living free\n\nOn the highway to hell")
+	deleted := lyrics("Love Me Do (demo)", "The Beatles", "Love, love me do")
+	s.Require().NoError(s.store.DeleteSong(ctx, deleted.ID))
+
+	related := func(params models.Params) []*models.RelatedSong {
+		if params.Limit == 0 {
+			params.Limit = 10
+		}
+
+		related, err := s.store.RelatedSongs(ctx, loveMeDo.ID, params)
+		s.Require().NoError(err)
+
+		return related
+	}
+
+	ids := func(related []*models.RelatedSong) []uuid.UUID {
+		result := make([]uuid.UUID, 0, len(related))
+		for _, song := range related {
+			result = append(result, song.ID)
+		}
+
+		return result
+	}
+
+	plain := related(models.Params{})
+	s.Require().Equal([]uuid.UUID{cover.ID, tender.ID, allYouNeed.ID}, ids(plain),
+		"songs without terms in common and deleted songs are left out")
+	s.Require().InDelta(1, plain[0].Similarity, 1e-9, "the same lyrics are the most similar")
+	s.Require().Greater(plain[1].Similarity, plain[2].Similarity)
+	s.Require().Greater(plain[2].Similarity, 0.0)
+
+	for _, song := range plain {
+		s.Require().InDelta(song.Similarity, song.Score, 1e-9, "without boosts the score is the similarity")
+	}
+
+	boosted := related(models.Params{GroupBoost: 1})
+	s.Require().Equal(allYouNeed.ID, boosted[0].ID, "songs by the same group are boosted")
+	s.Require().InDelta(boosted[0].Similarity+1, boosted[0].Score, 1e-9)
+
+	s.createTag("ballad", nil)
+	s.setTags(loveMeDo.ID, "ballad")
+	s.setTags(tender.ID, "ballad")
+
+	boosted = related(models.Params{TagBoost: 1})
+	s.Require().Equal(tender.ID, boosted[0].ID, "songs with a tag in common are boosted")
+
+	s.Require().Equal([]uuid.UUID{tender.ID}, ids(related(models.Params{Offset: 1, Limit: 1})), "related songs are paged")
+
+	// Terms follow the lyrics.
+	_, err := s.store.UpdateSong(ctx, loveMeDo.ID, models.Song{Name: "Love Me Do", Group: "The Beatles", Text: "Down the highway"})
+	s.Require().NoError(err)
+	s.Require().Equal([]uuid.UUID{highway.ID}, ids(related(models.Params{})))
+
+	_, err = s.store.RelatedSongs(ctx, uuid.New(), models.Params{Limit: 10})
+	s.Require().ErrorIs(err, models.ErrSongNotFound)
+
+	_, err = s.store.RelatedSongs(ctx, deleted.ID, models.Params{Limit: 10})
+	s.Require().ErrorIs(err, models.ErrSongNotFound)
+}
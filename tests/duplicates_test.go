@@ -0,0 +1,59 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/models"
+	"github.com/iurikman/songs/internal/service"
+	"github.com/iurikman/songs/internal/store"
+	"github.com/stretchr/testify/require"
+)
+
+// passthroughDetails is a songdetails client without details to add.
+type passthroughDetails struct{}
+
+func (passthroughDetails) Get(_ context.Context, song models.Song) (*models.Song, error) {
+	return &song, nil
+}
+
+func TestCreateSongDuplicates(t *testing.T) {
+	ctx := context.Background()
+	svc := service.NewService(store.NewMemory(), passthroughDetails{})
+
+	original, err := svc.CreateSong(ctx, models.Song{ID: uuid.New(), Name: "Yesterday", Group: "The Beatles"}, false)
+	require.NoError(t, err)
+
+	_, err = svc.CreateSong(ctx, models.Song{ID: uuid.New(), Name: "Starlight", Group: "Muse"}, false)
+	require.NoError(t, err)
+
+	_, err = svc.CreateSong(ctx, models.Song{ID: uuid.New(), Name: "yesterday ", Group: "the beatles"}, false)
+	require.ErrorIs(t, err, models.ErrProbableDuplicate)
+
+	var duplicate *models.ProbableDuplicateError
+
+	require.True(t, errors.As(err, &duplicate))
+	require.Len(t, duplicate.Candidates, 1)
+	require.Equal(t, original.ID, duplicate.Candidates[0].ID)
+	require.InDelta(t, 1, duplicate.Candidates[0].Similarity, 0.001)
+
+	forced, err := svc.CreateSong(ctx, models.Song{ID: uuid.New(), Name: "yesterday ", Group: "the beatles"}, true)
+	require.NoError(t, err)
+
+	clusters, err := svc.DuplicateClusters(ctx, models.Params{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, clusters, 1)
+	require.ElementsMatch(t, []string{original.ID.String(), forced.ID.String()},
+		[]string{clusters[0].Songs[0].ID.String(), clusters[0].Songs[1].ID.String()})
+
+	clusters, err = svc.DuplicateClusters(ctx, models.Params{Offset: 1, Limit: 10})
+	require.NoError(t, err)
+	require.Empty(t, clusters)
+
+	svc.SetDuplicateThreshold(1)
+
+	_, err = svc.CreateSong(ctx, models.Song{ID: uuid.New(), Name: "Yesterdays", Group: "The Beatles"}, false)
+	require.NoError(t, err, "below the threshold")
+}
@@ -0,0 +1,244 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iurikman/songs/internal/events"
+	"github.com/iurikman/songs/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+const webhooksAddress = "http://localhost:8080/api/v1/webhooks"
+
+type receivedHook struct {
+	header http.Header
+	body   []byte
+}
+
+func (s *IntegrationTestSuite) TestChangeFeed() {
+	ctx := context.Background()
+
+	received := make(chan receivedHook, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- receivedHook{header: r.Header, body: body}
+	}))
+	defer receiver.Close()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	var webhook struct {
+		Data models.Webhook `json:"data"`
+	}
+
+	resp := s.sendWebhookRequest(ctx, http.MethodPost, "", models.Webhook{
+		URL:        receiver.URL,
+		EventTypes: []string{models.EventSongCreated},
+	}, &webhook)
+	s.Require().Equal(http.StatusCreated, resp.StatusCode)
+	s.Require().NotEmpty(webhook.Data.Secret)
+
+	resp = s.sendWebhookRequest(ctx, http.MethodPost, "", models.Webhook{URL: failing.URL}, nil)
+	s.Require().Equal(http.StatusCreated, resp.StatusCode)
+
+	s.Run("rejects invalid url", func() {
+		resp := s.sendWebhookRequest(ctx, http.MethodPost, "", models.Webhook{URL: "ftp://example.com"}, nil)
+		s.Require().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+	})
+
+	s.Run("lists webhooks without secrets", func() {
+		var hooks struct {
+			Data []models.Webhook `json:"data"`
+		}
+
+		resp := s.sendWebhookRequest(ctx, http.MethodGet, "", nil, &hooks)
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+		s.Require().Len(hooks.Data, 2)
+
+		for _, h := range hooks.Data {
+			s.Require().Empty(h.Secret)
+		}
+	})
+
+	var created struct {
+		Data models.Song `json:"data"`
+	}
+
+	resp = s.sendRequest(ctx, http.MethodPost, "", models.Song{Name: "Feed", Group: "Outbox"}, &created)
+	s.Require().Equal(http.StatusCreated, resp.StatusCode)
+
+	s.Run("delivers signed webhook", func() {
+		select {
+		case hook := <-received:
+			s.Require().Equal(models.EventSongCreated, hook.header.Get(events.HeaderEvent))
+			s.Require().NoError(events.Verify(
+				webhook.Data.Secret,
+				hook.header.Get(events.HeaderTimestamp),
+				hook.header.Get(events.HeaderSignature),
+				hook.body,
+				time.Minute,
+			))
+
+			var event models.Event
+
+			s.Require().NoError(json.Unmarshal(hook.body, &event))
+			s.Require().Equal(created.Data.ID, event.SongID)
+		case <-time.After(5 * time.Second):
+			s.FailNow("webhook was not delivered")
+		}
+	})
+
+	resp = s.sendRequest(ctx, http.MethodDelete, "/"+created.Data.ID.String(), nil, nil)
+	s.Require().Equal(http.StatusNoContent, resp.StatusCode)
+
+	s.Run("streams events and resumes after last event id", func() {
+		first := s.readEvents(ctx, "", 2)
+		s.Require().Equal(models.EventSongCreated, first[0].Type)
+		s.Require().Equal(models.EventSongDeleted, first[1].Type)
+		s.Require().Equal("Feed", first[1].Song.Name)
+
+		resumed := s.readEvents(ctx, strconv.FormatInt(first[0].ID, 10), 1)
+		s.Require().Equal(first[1].ID, resumed[0].ID)
+	})
+
+	s.Run("dead deliveries can be retried", func() {
+		var dead struct {
+			Data []models.Delivery `json:"data"`
+		}
+
+		s.Require().Eventually(func() bool {
+			s.sendWebhookRequest(ctx, http.MethodGet, "/deliveries/dead", nil, &dead)
+
+			return len(dead.Data) == 2
+		}, 5*time.Second, 100*time.Millisecond)
+
+		s.Require().Equal(http.StatusServiceUnavailable, dead.Data[0].LastStatus)
+
+		endpoint := "/deliveries/" + strconv.FormatInt(dead.Data[0].ID, 10) + "/retry"
+
+		resp := s.sendWebhookRequest(ctx, http.MethodPost, endpoint, nil, nil)
+		s.Require().Equal(http.StatusAccepted, resp.StatusCode)
+
+		resp = s.sendWebhookRequest(ctx, http.MethodPost, "/deliveries/0/retry", nil, nil)
+		s.Require().Equal(http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func (s *IntegrationTestSuite) sendWebhookRequest(
+	ctx context.Context,
+	method, endpoint string,
+	body any,
+	dest any,
+) *http.Response {
+	s.T().Helper()
+
+	var reader io.Reader
+
+	if body != nil {
+		data, err := json.Marshal(body)
+		s.Require().NoError(err)
+
+		reader = strings.NewReader(string(data))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, webhooksAddress+endpoint, reader)
+	s.Require().NoError(err)
+
+	resp, err := http.DefaultClient.Do(req)
+	s.Require().NoError(err)
+
+	defer func() {
+		s.Require().NoError(resp.Body.Close())
+	}()
+
+	if dest != nil {
+		s.Require().NoError(json.NewDecoder(resp.Body).Decode(dest))
+	}
+
+	return resp
+}
+
+// readEvents opens the event stream and returns its first n events.
+func (s *IntegrationTestSuite) readEvents(ctx context.Context, lastEventID string, n int) []models.Event {
+	s.T().Helper()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, bindAddress+"/events", nil)
+	s.Require().NoError(err)
+
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	s.Require().NoError(err)
+
+	defer resp.Body.Close()
+
+	s.Require().Equal("text/event-stream", resp.Header.Get("Content-Type"))
+
+	result := make([]models.Event, 0, n)
+	scanner := bufio.NewScanner(resp.Body)
+
+	for len(result) < n && scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+
+		var event models.Event
+
+		s.Require().NoError(json.Unmarshal([]byte(data), &event))
+
+		result = append(result, event)
+	}
+
+	s.Require().Len(result, n)
+
+	return result
+}
+
+func TestWebhookSignature(t *testing.T) {
+	body := []byte(`{"id":1,"type":"song.created"}`)
+	now := time.Now().Unix()
+	timestamp := strconv.FormatInt(now, 10)
+	signature := events.Sign("secret", now, body)
+
+	require.True(t, strings.HasPrefix(signature, "sha256="))
+	require.NoError(t, events.Verify("secret", timestamp, signature, body, time.Minute))
+
+	require.ErrorIs(t, events.Verify("other", timestamp, signature, body, time.Minute), events.ErrInvalidSignature)
+	require.ErrorIs(t, events.Verify("secret", timestamp, signature, []byte("{}"), time.Minute),
+		events.ErrInvalidSignature)
+
+	old := time.Now().Add(-time.Hour).Unix()
+	require.ErrorIs(t,
+		events.Verify("secret", strconv.FormatInt(old, 10), events.Sign("secret", old, body), body, time.Minute),
+		events.ErrStaleTimestamp)
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	base, maxDelay := time.Second, time.Minute
+
+	for attempt := 1; attempt <= 40; attempt++ {
+		want := min(base<<min(attempt-1, 30), maxDelay)
+		got := events.Backoff(attempt, base, maxDelay)
+
+		require.GreaterOrEqual(t, got, want/2, "attempt %d", attempt)
+		require.LessOrEqual(t, got, want, "attempt %d", attempt)
+	}
+}
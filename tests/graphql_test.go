@@ -0,0 +1,229 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/gql"
+	"github.com/iurikman/songs/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+// catalog is a fixed in-process song list that counts backend calls so the
+// tests can check that GraphQL lookups are batched.
+type catalog struct {
+	songs       []*models.Song
+	groupCalls  atomic.Int32
+	byIDCalls   atomic.Int32
+	listedCalls atomic.Int32
+}
+
+func (c *catalog) GetSongs(_ context.Context, params models.Params) ([]*models.Song, error) {
+	c.listedCalls.Add(1)
+
+	end := min(params.Offset+params.Limit, len(c.songs))
+
+	return c.songs[params.Offset:end], nil
+}
+
+func (c *catalog) GetSongsByIDs(_ context.Context, ids []uuid.UUID) ([]*models.Song, error) {
+	c.byIDCalls.Add(1)
+
+	var songs []*models.Song
+
+	for _, song := range c.songs {
+		for _, id := range ids {
+			if song.ID == id {
+				songs = append(songs, song)
+			}
+		}
+	}
+
+	return songs, nil
+}
+
+func (c *catalog) GetSongsByGroups(_ context.Context, groups []string) ([]*models.Song, error) {
+	c.groupCalls.Add(1)
+
+	var songs []*models.Song
+
+	for _, song := range c.songs {
+		for _, group := range groups {
+			if song.Group == group {
+				songs = append(songs, song)
+			}
+		}
+	}
+
+	return songs, nil
+}
+
+func (c *catalog) GetGroups(_ context.Context, _ models.Params) ([]string, error) {
+	return []string{"Muse", "Queen"}, nil
+}
+
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func newCatalog() *catalog {
+	return &catalog{songs: []*models.Song{
+		{ID: uuid.New(), Name: "Supermassive Black Hole", Group: "Muse", Text: "verse one\n\nverse two\n\nverse three"},
+		{ID: uuid.New(), Name: "Starlight", Group: "Muse", Text: "far away"},
+		{ID: uuid.New(), Name: "Bohemian Rhapsody", Group: "Queen", Text: "is this the real life"},
+	}}
+}
+
+func queryGraphQL(t *testing.T, handler http.Handler, query string, variables map[string]any) (int, graphQLResponse) {
+	t.Helper()
+
+	body, err := json.Marshal(map[string]any{"query": query, "variables": variables})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	var resp graphQLResponse
+
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+
+	return rec.Code, resp
+}
+
+func TestGraphQLNestedQueryIsBatched(t *testing.T) {
+	c := newCatalog()
+
+	handler, err := gql.NewHandler(gql.Config{}, c)
+	require.NoError(t, err)
+
+	code, resp := queryGraphQL(t, handler, `{
+		songs(limit: 10) {
+			name
+			group { name songCount }
+			related { name }
+			verses(offset: 1, limit: 1) { number text }
+		}
+	}`, nil)
+	require.Equal(t, http.StatusOK, code)
+	require.Empty(t, resp.Errors)
+
+	var data struct {
+		Songs []struct {
+			Name  string `json:"name"`
+			Group struct {
+				Name      string `json:"name"`
+				SongCount int    `json:"songCount"`
+			} `json:"group"`
+			Related []struct {
+				Name string `json:"name"`
+			} `json:"related"`
+			Verses []struct {
+				Number int    `json:"number"`
+				Text   string `json:"text"`
+			} `json:"verses"`
+		} `json:"songs"`
+	}
+
+	require.NoError(t, json.Unmarshal(resp.Data, &data))
+	require.Len(t, data.Songs, 3)
+	require.Equal(t, 2, data.Songs[0].Group.SongCount)
+	require.Equal(t, "Starlight", data.Songs[0].Related[0].Name)
+	require.Equal(t, 2, data.Songs[0].Verses[0].Number)
+	require.Equal(t, "verse two", data.Songs[0].Verses[0].Text)
+	require.Equal(t, int32(1), c.groupCalls.Load(), "group lookups of all songs share one batch")
+}
+
+func TestGraphQLSongByID(t *testing.T) {
+	c := newCatalog()
+
+	handler, err := gql.NewHandler(gql.Config{}, c)
+	require.NoError(t, err)
+
+	code, resp := queryGraphQL(t, handler, `query($a: ID!, $b: ID!, $missing: ID!) {
+		a: song(id: $a) { name verse(number: 3) { text } }
+		b: song(id: $b) { name }
+		missing: song(id: $missing) { name }
+	}`, map[string]any{
+		"a":       c.songs[0].ID.String(),
+		"b":       c.songs[2].ID.String(),
+		"missing": uuid.NewString(),
+	})
+	require.Equal(t, http.StatusOK, code)
+	require.Empty(t, resp.Errors)
+	require.JSONEq(t, `{
+		"a": {"name": "Supermassive Black Hole", "verse": {"text": "verse three"}},
+		"b": {"name": "Bohemian Rhapsody"},
+		"missing": null
+	}`, string(resp.Data))
+	require.Equal(t, int32(1), c.byIDCalls.Load())
+}
+
+func TestGraphQLLimits(t *testing.T) {
+	handler, err := gql.NewHandler(gql.Config{Limits: gql.Limits{MaxDepth: 4, MaxComplexity: 100}}, newCatalog())
+	require.NoError(t, err)
+
+	code, resp := queryGraphQL(t, handler, `{ songs { group { songs { group { songs { name } } } } } }`, nil)
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Contains(t, resp.Errors[0].Message, "too deep")
+
+	code, resp = queryGraphQL(t, handler, `query($n: Int) { songs(limit: $n) { related(limit: 50) { name } } }`,
+		map[string]any{"n": 50})
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Contains(t, resp.Errors[0].Message, "too complex")
+
+	code, resp = queryGraphQL(t, handler, `{ songs(limit: 2) { name } }`, nil)
+	require.Equal(t, http.StatusOK, code)
+	require.Empty(t, resp.Errors)
+}
+
+func TestGraphQLPagingIsReloaded(t *testing.T) {
+	handler, err := gql.NewHandler(gql.Config{
+		DefaultPageSize: 1,
+		MaxPageSize:     2,
+		Limits:          gql.Limits{MaxComplexity: 3},
+	}, newCatalog())
+	require.NoError(t, err)
+
+	songs := func(query string) []string {
+		code, resp := queryGraphQL(t, handler, query, nil)
+		require.Equal(t, http.StatusOK, code)
+		require.Empty(t, resp.Errors)
+
+		var data struct {
+			Songs []struct {
+				Name string `json:"name"`
+			} `json:"songs"`
+		}
+
+		require.NoError(t, json.Unmarshal(resp.Data, &data))
+
+		names := make([]string, 0, len(data.Songs))
+		for _, song := range data.Songs {
+			names = append(names, song.Name)
+		}
+
+		return names
+	}
+
+	require.Len(t, songs(`{ songs { name } }`), 1)
+	require.Len(t, songs(`{ songs(limit: 10) { name } }`), 2)
+
+	handler.SetPaging(2, 3)
+
+	require.Len(t, songs(`{ songs { name } }`), 2)
+
+	code, resp := queryGraphQL(t, handler, `{ songs(limit: 10) { name } }`, nil)
+	require.Equal(t, http.StatusBadRequest, code, "the complexity is counted with the new page size")
+	require.Contains(t, resp.Errors[0].Message, "too complex")
+}
@@ -0,0 +1,208 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/google/uuid"
+	songsv1 "github.com/iurikman/songs/internal/gen/songs/v1"
+	"github.com/iurikman/songs/internal/models"
+	"github.com/iurikman/songs/internal/rpc"
+	"github.com/iurikman/songs/internal/service"
+	"github.com/iurikman/songs/internal/store"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+const grpcAddress = "localhost:9090"
+
+func (s *IntegrationTestSuite) TestGRPC() {
+	ctx := context.Background()
+
+	conn, err := grpc.NewClient(grpcAddress, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	s.Require().NoError(err)
+
+	defer func() {
+		s.Require().NoError(conn.Close())
+	}()
+
+	client := songsv1.NewSongServiceClient(conn)
+	id := uuid.New()
+
+	s.Run("health", func() {
+		resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+		s.Require().NoError(err)
+		s.Require().Equal(healthpb.HealthCheckResponse_SERVING, resp.GetStatus())
+	})
+
+	s.Run("create", func() {
+		song, err := client.CreateSong(ctx, &songsv1.CreateSongRequest{Song: &songsv1.Song{
+			Id:         id.String(),
+			Name:       "grpcSong",
+			MusicGroup: "grpcGroup",
+		}})
+		s.Require().NoError(err)
+		s.Require().Equal(id.String(), song.GetId())
+		s.Require().NotEmpty(song.GetText())
+	})
+
+	s.Run("create/duplicate", func() {
+		_, err := client.CreateSong(ctx, &songsv1.CreateSongRequest{Song: &songsv1.Song{
+			Id:         uuid.NewString(),
+			Name:       "grpcSong",
+			MusicGroup: "grpcGroup",
+		}})
+		s.Require().Equal(codes.AlreadyExists, status.Code(err))
+	})
+
+	s.Run("list", func() {
+		stream, err := client.ListSongs(ctx, &songsv1.ListSongsRequest{Filter: "grpcSong"})
+		s.Require().NoError(err)
+
+		var songs []*songsv1.Song
+
+		for {
+			song, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			s.Require().NoError(err)
+
+			songs = append(songs, song)
+		}
+
+		s.Require().Len(songs, 1)
+		s.Require().Equal(id.String(), songs[0].GetId())
+	})
+
+	s.Run("getText", func() {
+		resp, err := client.GetText(ctx, &songsv1.GetTextRequest{Id: id.String(), Verse: 1})
+		s.Require().NoError(err)
+		s.Require().NotEmpty(resp.GetText())
+
+		_, err = client.GetText(ctx, &songsv1.GetTextRequest{Id: id.String(), Verse: 0})
+		s.Require().Equal(codes.OutOfRange, status.Code(err))
+
+		_, err = client.GetText(ctx, &songsv1.GetTextRequest{Id: "not-a-uuid", Verse: 1})
+		s.Require().Equal(codes.InvalidArgument, status.Code(err))
+	})
+
+	s.Run("update", func() {
+		song, err := client.UpdateSong(ctx, &songsv1.UpdateSongRequest{
+			Id:   id.String(),
+			Song: &songsv1.Song{Name: "grpcSongUpdated", MusicGroup: "grpcGroup", Text: "updated"},
+		})
+		s.Require().NoError(err)
+		s.Require().Equal("grpcSongUpdated", song.GetName())
+	})
+
+	s.Run("delete", func() {
+		_, err := client.DeleteSong(ctx, &songsv1.DeleteSongRequest{Id: id.String()})
+		s.Require().NoError(err)
+
+		_, err = client.DeleteSong(ctx, &songsv1.DeleteSongRequest{Id: id.String()})
+		s.Require().Equal(codes.NotFound, status.Code(err))
+	})
+}
+
+// startGRPCServer serves svc over gRPC on a free port until the test ends and
+// returns the server with a client connected to it.
+func startGRPCServer(t *testing.T, cfg rpc.SrvConfig, svc *service.Service) (*rpc.Server, songsv1.SongServiceClient) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	cfg.BindAddr = listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	server, err := rpc.NewServer(cfg, svc)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+
+	go func() {
+		done <- server.Start(ctx)
+	}()
+
+	conn, err := grpc.NewClient(cfg.BindAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		require.NoError(t, conn.Close())
+		cancel()
+		require.NoError(t, <-done)
+	})
+
+	return server, songsv1.NewSongServiceClient(conn)
+}
+
+func TestGRPCPagingIsReloaded(t *testing.T) {
+	ctx := context.Background()
+
+	db := store.NewMemory()
+
+	for _, name := range []string{"Uprising", "Resistance", "Madness"} {
+		_, err := db.CreateSong(ctx, models.Song{ID: uuid.New(), Name: name, Group: "Muse"})
+		require.NoError(t, err)
+	}
+
+	server, client := startGRPCServer(t, rpc.SrvConfig{DefaultPageSize: 2, MaxPageSize: 3},
+		service.NewService(db, newSongDetails(t)))
+
+	list := func(limit int32) int {
+		t.Helper()
+
+		stream, err := client.ListSongs(ctx, &songsv1.ListSongsRequest{Limit: limit}, grpc.WaitForReady(true))
+		require.NoError(t, err)
+
+		songs := 0
+
+		for {
+			_, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				return songs
+			}
+
+			require.NoError(t, err)
+
+			songs++
+		}
+	}
+
+	require.Equal(t, 2, list(0), "the default page size applies without a limit")
+	require.Equal(t, 3, list(10), "limits are capped at the largest page")
+
+	server.SetPaging(1, 2)
+
+	require.Equal(t, 1, list(0), "the reloaded default page size applies")
+	require.Equal(t, 2, list(10), "the reloaded largest page applies")
+}
+
+func TestGRPCForceCreateSong(t *testing.T) {
+	ctx := context.Background()
+
+	_, client := startGRPCServer(t, rpc.SrvConfig{}, service.NewService(store.NewMemory(), passthroughDetails{}))
+
+	create := func(name string, force bool) error {
+		_, err := client.CreateSong(ctx, &songsv1.CreateSongRequest{
+			Song:  &songsv1.Song{Id: uuid.NewString(), Name: name, MusicGroup: "The Beatles"},
+			Force: force,
+		}, grpc.WaitForReady(true))
+
+		return err //nolint:wrapcheck
+	}
+
+	require.NoError(t, create("Yesterday", false))
+	require.Equal(t, codes.AlreadyExists, status.Code(create("yesterday ", false)), "probable duplicates are refused")
+	require.NoError(t, create("yesterday ", true), "forced songs are created anyway")
+}
@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+const hostAddress = "http://localhost:8080"
+
+type healthReport struct {
+	Status string `json:"status"`
+	Ready  bool   `json:"ready"`
+	Checks map[string]struct {
+		Status  string `json:"status"`
+		Latency string `json:"latency"`
+		Pending *int   `json:"pending"`
+		Circuit string `json:"circuit"`
+	} `json:"checks"`
+}
+
+func (s *IntegrationTestSuite) TestHealth() {
+	s.Run("healthz/200", func() {
+		report, resp := s.getHealth("/healthz")
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+		s.Require().Equal("ok", report.Status)
+	})
+
+	s.Run("readyz/200", func() {
+		report, resp := s.getHealth("/readyz")
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+		s.Require().True(report.Ready)
+	})
+
+	s.Run("health/dependencies", func() {
+		report, resp := s.getHealth("/health")
+		s.Require().Equal(http.StatusOK, resp.StatusCode)
+		s.Require().Equal("up", report.Checks["postgres"].Status)
+		s.Require().NotEmpty(report.Checks["postgres"].Latency)
+		s.Require().Equal(0, *report.Checks["migrations"].Pending)
+		s.Require().Equal("closed", report.Checks["songDetails"].Circuit)
+	})
+}
+
+func (s *IntegrationTestSuite) getHealth(endpoint string) (*healthReport, *http.Response) {
+	s.T().Helper()
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, hostAddress+endpoint, nil)
+	s.Require().NoError(err)
+
+	resp, err := http.DefaultClient.Do(req)
+	s.Require().NoError(err)
+
+	defer func() {
+		err = resp.Body.Close()
+		s.Require().NoError(err)
+	}()
+
+	report := new(healthReport)
+
+	err = json.NewDecoder(resp.Body).Decode(report)
+	s.Require().NoError(err)
+
+	return report, resp
+}
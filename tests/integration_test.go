@@ -6,12 +6,14 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
-	"os"
 	"testing"
+	"time"
 
 	"github.com/iurikman/songs/internal/config"
+	"github.com/iurikman/songs/internal/events"
 	"github.com/iurikman/songs/internal/models"
 	"github.com/iurikman/songs/internal/rest"
+	"github.com/iurikman/songs/internal/rpc"
 	"github.com/iurikman/songs/internal/service"
 	"github.com/iurikman/songs/internal/songdetails"
 	"github.com/iurikman/songs/internal/store"
@@ -28,6 +30,7 @@ type IntegrationTestSuite struct {
 	store      *store.Postgres
 	service    *service.Service
 	server     *rest.Server
+	grpcServer *rpc.Server
 	mockserver *httptest.Server
 }
 
@@ -39,7 +42,8 @@ func (s *IntegrationTestSuite) SetupSuite() {
 	ctx, cancel := context.WithCancel(context.Background())
 	s.cancel = cancel
 
-	cfg := config.NewConfig()
+	cfg, err := config.Load(nil)
+	s.Require().NoError(err)
 
 	db, err := store.New(ctx, store.Config{
 		PGUser:     cfg.PostgresUser,
@@ -55,22 +59,46 @@ func (s *IntegrationTestSuite) SetupSuite() {
 	err = s.store.Migrate(migrate.Up)
 	s.Require().NoError(err)
 
-	err = s.store.Truncate(ctx, "songs")
+	err = s.store.Truncate(ctx, "songs", "tags", "webhook_deliveries", "webhooks", "song_events")
 	s.Require().NoError(err)
 
 	s.mockserver = httptest.NewServer(http.HandlerFunc(handler))
 
-	songDetails := songdetails.NewSongDetails(s.mockserver.URL)
+	songDetails := songdetails.NewSongDetails(songdetails.Config{Host: s.mockserver.URL})
 
 	s.service = service.NewService(db, songDetails)
 
-	s.server, err = rest.NewServer(rest.SrvConfig{BindAddr: os.Getenv("BIND_ADDRESS")}, s.service)
+	s.server, err = rest.NewServer(
+		rest.SrvConfig{BindAddr: cfg.BindAddress, SSEPollInterval: 50 * time.Millisecond},
+		s.service,
+		rest.Dependencies{DB: db, SongDetails: songDetails, Events: events.NewService(db)},
+	)
 	s.Require().NoError(err)
 
+	dispatcher := events.NewDispatcher(events.DispatcherConfig{
+		PollInterval: 50 * time.Millisecond,
+		MaxAttempts:  2,
+		BackoffBase:  50 * time.Millisecond,
+		BackoffMax:   100 * time.Millisecond,
+	}, db)
+
+	go func() {
+		err := dispatcher.Run(ctx)
+		s.Require().NoError(err)
+	}()
+
 	go func() {
 		err := s.server.Start(ctx)
 		s.Require().NoError(err)
 	}()
+
+	s.grpcServer, err = rpc.NewServer(rpc.SrvConfig{BindAddr: cfg.GRPCBindAddress}, s.service)
+	s.Require().NoError(err)
+
+	go func() {
+		err := s.grpcServer.Start(ctx)
+		s.Require().NoError(err)
+	}()
 }
 
 func (s *IntegrationTestSuite) TearDownSuite() {
@@ -80,10 +108,17 @@ func (s *IntegrationTestSuite) TearDownSuite() {
 func (s *IntegrationTestSuite) sendRequest(ctx context.Context, method, endpoint string, body interface{}, dest interface{}) *http.Response {
 	s.T().Helper()
 
+	return s.sendRequestTo(ctx, method, bindAddress+endpoint, body, dest)
+}
+
+// sendRequestTo is sendRequest for endpoints outside of the songs.
+func (s *IntegrationTestSuite) sendRequestTo(ctx context.Context, method, url string, body interface{}, dest interface{}) *http.Response {
+	s.T().Helper()
+
 	reqBody, err := json.Marshal(body)
 	s.Require().NoError(err)
 
-	req, err := http.NewRequestWithContext(ctx, method, bindAddress+endpoint, bytes.NewBuffer(reqBody))
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(reqBody))
 	s.Require().NoError(err)
 
 	req.Header.Set("Content-Type", "application/json")
@@ -105,6 +140,8 @@ func (s *IntegrationTestSuite) sendRequest(ctx context.Context, method, endpoint
 }
 
 func handler(w http.ResponseWriter, r *http.Request) {
+	upstreamTraceparents.record(r)
+
 	group := r.URL.Query().Get("group")
 	song := r.URL.Query().Get("song")
 
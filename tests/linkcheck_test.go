@@ -0,0 +1,148 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/linkcheck"
+	"github.com/iurikman/songs/internal/models"
+	"github.com/iurikman/songs/internal/service"
+	"github.com/iurikman/songs/internal/store"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinkChecker(t *testing.T) {
+	ctx := context.Background()
+
+	ok := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer ok.Close()
+
+	gone := httptest.NewServer(http.NotFoundHandler())
+	defer gone.Close()
+
+	moved := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, ok.URL+"/moved", http.StatusMovedPermanently)
+	}))
+	defer moved.Close()
+
+	noHead := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer noHead.Close()
+
+	db := store.NewMemory()
+	svc := service.NewService(db, passthroughDetails{})
+
+	song, err := svc.CreateSong(ctx, models.Song{ID: uuid.New(), Name: "Yesterday", Group: "The Beatles"}, false)
+	require.NoError(t, err)
+
+	links := make(map[string]*models.SongLink)
+
+	for name, url := range map[string]string{
+		"ok": ok.URL + "/ok", "gone": gone.URL + "/gone", "moved": moved.URL + "/old", "noHead": noHead.URL + "/get",
+	} {
+		links[name], err = svc.CreateLink(ctx, song.ID, models.SongLink{URL: url})
+		require.NoError(t, err)
+	}
+
+	checker := linkcheck.NewChecker(linkcheck.Config{Interval: time.Nanosecond, MaxFailures: 2}, db)
+
+	require.Equal(t, 4, checker.CheckDue(ctx))
+
+	checks := checksOf(t, svc, song.ID)
+	require.True(t, checks[links["ok"].ID].OK())
+	require.Equal(t, http.StatusOK, checks[links["noHead"].ID].Status, "GET when HEAD is refused")
+	require.Equal(t, ok.URL+"/moved", checks[links["moved"].ID].RedirectURL)
+	require.Equal(t, http.StatusNotFound, checks[links["gone"].ID].Status)
+	require.Equal(t, 1, checks[links["gone"].ID].Failures)
+	require.False(t, checks[links["gone"].ID].Broken, "broken only after MaxFailures failures")
+
+	broken, err := svc.GetBrokenLinks(ctx, models.Params{Limit: 10})
+	require.NoError(t, err)
+	require.Empty(t, broken)
+
+	time.Sleep(time.Millisecond)
+	require.Equal(t, 4, checker.CheckDue(ctx))
+
+	broken, err = svc.GetBrokenLinks(ctx, models.Params{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, broken, 1)
+	require.Equal(t, links["gone"].ID, broken[0].ID)
+	require.Equal(t, "Yesterday", broken[0].Name)
+	require.Equal(t, 2, broken[0].Check.Failures)
+
+	// A new URL is checked anew.
+	_, err = svc.UpdateLink(ctx, song.ID, links["gone"].ID, models.SongLink{URL: ok.URL + "/back"})
+	require.NoError(t, err)
+
+	broken, err = svc.GetBrokenLinks(ctx, models.Params{Limit: 10})
+	require.NoError(t, err)
+	require.Empty(t, broken)
+}
+
+func TestLinkCheckerHostConcurrency(t *testing.T) {
+	ctx := context.Background()
+
+	var inFlight, maxInFlight, requests atomic.Int32
+
+	slow := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		requests.Add(1)
+
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+
+		for {
+			highest := maxInFlight.Load()
+			if n <= highest || maxInFlight.CompareAndSwap(highest, n) {
+				break
+			}
+		}
+
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer slow.Close()
+
+	db := store.NewMemory()
+	svc := service.NewService(db, passthroughDetails{})
+
+	song, err := svc.CreateSong(ctx, models.Song{ID: uuid.New(), Name: "Yesterday", Group: "The Beatles"}, false)
+	require.NoError(t, err)
+
+	for _, path := range []string{"/a", "/b", "/c", "/d", "/e", "/f"} {
+		_, err := svc.CreateLink(ctx, song.ID, models.SongLink{URL: slow.URL + path})
+		require.NoError(t, err)
+	}
+
+	checker := linkcheck.NewChecker(linkcheck.Config{HostConcurrency: 2}, db)
+
+	require.Equal(t, 6, checker.CheckDue(ctx))
+	require.Equal(t, int32(6), requests.Load())
+	require.Equal(t, int32(2), maxInFlight.Load())
+
+	require.Zero(t, checker.CheckDue(ctx), "checked links are not due until the interval passes")
+}
+
+// checksOf returns the checks of the links of a song by link id.
+func checksOf(t *testing.T, svc *service.Service, songID uuid.UUID) map[uuid.UUID]*models.LinkCheck {
+	t.Helper()
+
+	links, err := svc.GetLinks(context.Background(), songID)
+	require.NoError(t, err)
+
+	checks := make(map[uuid.UUID]*models.LinkCheck, len(links))
+
+	for _, link := range links {
+		require.NotNil(t, link.Check, link.URL)
+
+		checks[link.ID] = link.Check
+	}
+
+	return checks
+}
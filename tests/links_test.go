@@ -0,0 +1,201 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/models"
+	"github.com/iurikman/songs/internal/service"
+	"github.com/iurikman/songs/internal/store"
+	"github.com/iurikman/songs/internal/validation"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeLink(t *testing.T) {
+	tests := []struct {
+		raw, platform, url, id, region string
+	}{
+		{
+			raw: "https://youtu.be/Xsp3_a-PMTw?t=42", platform: models.PlatformYouTube,
+			url: "https://www.youtube.com/watch?v=Xsp3_a-PMTw", id: "Xsp3_a-PMTw",
+		},
+		{
+			raw: "https://m.youtube.com/watch?v=Xsp3_a-PMTw&list=PL1", platform: models.PlatformYouTube,
+			url: "https://www.youtube.com/watch?v=Xsp3_a-PMTw", id: "Xsp3_a-PMTw",
+		},
+		{
+			raw: "https://www.youtube.com/shorts/Xsp3_a-PMTw", platform: models.PlatformYouTube,
+			url: "https://www.youtube.com/watch?v=Xsp3_a-PMTw", id: "Xsp3_a-PMTw",
+		},
+		{
+			raw: "https://open.spotify.com/intl-de/track/3BQHpFgAp4l80e1XslIjNI?si=abc", platform: models.PlatformSpotify,
+			url: "https://open.spotify.com/track/3BQHpFgAp4l80e1XslIjNI", id: "3BQHpFgAp4l80e1XslIjNI",
+		},
+		{
+			raw: "spotify:track:3BQHpFgAp4l80e1XslIjNI", platform: models.PlatformSpotify,
+			url: "https://open.spotify.com/track/3BQHpFgAp4l80e1XslIjNI", id: "3BQHpFgAp4l80e1XslIjNI",
+		},
+		{
+			raw: "https://music.apple.com/gb/album/help/1441164430?i=1441164589", platform: models.PlatformAppleMusic,
+			url: "https://music.apple.com/gb/song/1441164589", id: "1441164589", region: "GB",
+		},
+		{
+			raw: "https://music.apple.com/us/song/yesterday/1441164600", platform: models.PlatformAppleMusic,
+			url: "https://music.apple.com/us/song/1441164600", id: "1441164600", region: "US",
+		},
+		{
+			raw: "https://genius.com/The-beatles-yesterday-lyrics", platform: models.PlatformLyrics,
+			url: "https://genius.com/The-beatles-yesterday-lyrics", id: "The-beatles-yesterday-lyrics",
+		},
+		{
+			raw: "https://www.musixmatch.com/lyrics/The-Beatles/Yesterday", platform: models.PlatformLyrics,
+			url: "https://www.musixmatch.com/lyrics/The-Beatles/Yesterday", id: "The-Beatles/Yesterday",
+		},
+		{
+			raw: "https://Example.COM/songs/yesterday#chorus", platform: models.PlatformOther,
+			url: "https://example.com/songs/yesterday",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			link, err := models.NormalizeLink(tt.raw)
+			require.NoError(t, err)
+			require.Equal(t, tt.platform, link.Platform)
+			require.Equal(t, tt.url, link.URL)
+			require.Equal(t, tt.id, link.ExternalID)
+			require.Equal(t, tt.region, link.Region)
+		})
+	}
+
+	for _, raw := range []string{
+		"https://www.youtube.com/channel/UC1",
+		"https://open.spotify.com/album/3BQHpFgAp4l80e1XslIjNI",
+		"spotify:album:3BQHpFgAp4l80e1XslIjNI",
+		"https://music.apple.com/us/album/help/1441164430",
+		"https://genius.com/artists/The-beatles",
+		"ftp://example.com/yesterday",
+		"yesterday",
+	} {
+		_, err := models.NormalizeLink(raw)
+		require.ErrorIs(t, err, models.ErrInvalidLink, raw)
+	}
+}
+
+func TestSongLinks(t *testing.T) {
+	ctx := context.Background()
+	svc := service.NewService(store.NewMemory(), passthroughDetails{})
+
+	song, err := svc.CreateSong(ctx, models.Song{
+		ID: uuid.New(), Name: "Yesterday", Group: "The Beatles", Link: "https://youtu.be/Xsp3_a-PMTw",
+	}, false)
+	require.NoError(t, err)
+	require.Equal(t, "https://www.youtube.com/watch?v=Xsp3_a-PMTw", song.Link, "the link of a song is normalized")
+
+	spotify, err := svc.CreateLink(ctx, song.ID, models.SongLink{URL: "spotify:track:3BQHpFgAp4l80e1XslIjNI"})
+	require.NoError(t, err)
+	require.Equal(t, song.ID, spotify.SongID)
+	require.Equal(t, models.PlatformSpotify, spotify.Platform)
+	require.Equal(t, "3BQHpFgAp4l80e1XslIjNI", spotify.ExternalID)
+	require.False(t, spotify.Primary)
+
+	apple, err := svc.CreateLink(ctx, song.ID, models.SongLink{
+		URL: "https://music.apple.com/gb/song/yesterday/1441164600", Primary: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "GB", apple.Region, "the region defaults to the storefront")
+
+	songs, err := svc.GetSongsByIDs(ctx, []uuid.UUID{song.ID})
+	require.NoError(t, err)
+	require.Equal(t, apple.URL, songs[0].Link, "a primary link becomes the link of the song")
+
+	links, err := svc.GetLinks(ctx, song.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{apple.URL, song.Link, spotify.URL}, urlsOf(links))
+
+	_, err = svc.CreateLink(ctx, song.ID, models.SongLink{URL: "https://open.spotify.com/track/3BQHpFgAp4l80e1XslIjNI"})
+	require.ErrorIs(t, err, models.ErrDuplicateLink)
+
+	_, err = svc.CreateLink(ctx, song.ID, models.SongLink{URL: "https://youtu.be/Xsp3_a-PMTw", Platform: "spotify", Region: "gb"})
+
+	var violations validation.Errors
+
+	require.True(t, errors.As(err, &violations))
+	require.Equal(t, []string{"platform", "region"}, fieldsOf(violations))
+
+	updated, err := svc.UpdateLink(ctx, song.ID, spotify.ID, models.SongLink{URL: spotify.URL, Region: "DE"})
+	require.NoError(t, err)
+	require.Equal(t, "DE", updated.Region)
+
+	_, err = svc.UpdateLink(ctx, song.ID, uuid.New(), models.SongLink{URL: spotify.URL})
+	require.ErrorIs(t, err, models.ErrLinkNotFound)
+
+	require.NoError(t, svc.DeleteLink(ctx, song.ID, apple.ID))
+
+	songs, err = svc.GetSongsByIDs(ctx, []uuid.UUID{song.ID})
+	require.NoError(t, err)
+	require.Empty(t, songs[0].Link, "deleting the primary link leaves the song without a link")
+
+	_, err = svc.GetLinks(ctx, uuid.New())
+	require.ErrorIs(t, err, models.ErrSongNotFound)
+}
+
+func urlsOf(links []*models.SongLink) []string {
+	urls := make([]string, 0, len(links))
+	for _, link := range links {
+		urls = append(urls, link.URL)
+	}
+
+	return urls
+}
+
+func (s *IntegrationTestSuite) TestLinkEndpoints() {
+	ctx := context.Background()
+
+	var song struct {
+		Data models.Song `json:"data"`
+	}
+
+	resp := s.sendRequest(ctx, http.MethodPost, "/?force=true",
+		models.Song{ID: uuid.New(), Name: "Linked", Group: "Linkers", Link: "https://youtu.be/Xsp3_a-PMTw"}, &song)
+	s.Require().Equal(http.StatusCreated, resp.StatusCode)
+	s.Require().Equal("https://www.youtube.com/watch?v=Xsp3_a-PMTw", song.Data.Link)
+
+	var link struct {
+		Data models.SongLink `json:"data"`
+	}
+
+	linksPath := "/" + song.Data.ID.String() + "/links"
+
+	resp = s.sendRequest(ctx, http.MethodPost, linksPath,
+		models.SongLink{URL: "https://open.spotify.com/track/3BQHpFgAp4l80e1XslIjNI", Primary: true}, &link)
+	s.Require().Equal(http.StatusCreated, resp.StatusCode)
+	s.Require().Equal(models.PlatformSpotify, link.Data.Platform)
+
+	resp = s.sendRequest(ctx, http.MethodPost, linksPath,
+		models.SongLink{URL: "spotify:track:3BQHpFgAp4l80e1XslIjNI"}, nil)
+	s.Require().Equal(http.StatusConflict, resp.StatusCode)
+
+	var links struct {
+		Data []*models.SongLink `json:"data"`
+	}
+
+	resp = s.sendRequest(ctx, http.MethodGet, linksPath, nil, &links)
+	s.Require().Equal(http.StatusOK, resp.StatusCode)
+	s.Require().Equal([]string{link.Data.URL, song.Data.Link}, urlsOf(links.Data))
+
+	resp = s.sendRequest(ctx, http.MethodPatch, linksPath+"/"+link.Data.ID.String(),
+		models.SongLink{URL: link.Data.URL, Region: "SE"}, &link)
+	s.Require().Equal(http.StatusOK, resp.StatusCode)
+	s.Require().Equal("SE", link.Data.Region)
+	s.Require().False(link.Data.Primary)
+
+	resp = s.sendRequest(ctx, http.MethodDelete, linksPath+"/"+link.Data.ID.String(), nil, nil)
+	s.Require().Equal(http.StatusNoContent, resp.StatusCode)
+
+	resp = s.sendRequest(ctx, http.MethodDelete, linksPath+"/"+link.Data.ID.String(), nil, nil)
+	s.Require().Equal(http.StatusNotFound, resp.StatusCode)
+}
@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/iurikman/songs/internal/models"
+)
+
+// invalidations records what a cache subscribed to the listener is told.
+type invalidations struct {
+	changes chan models.Change
+	resets  atomic.Int32
+}
+
+func (i *invalidations) Invalidate(change models.Change) {
+	i.changes <- change
+}
+
+func (i *invalidations) InvalidateAll() {
+	i.resets.Add(1)
+}
+
+func (s *IntegrationTestSuite) TestListenerInvalidates() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	listener, err := s.store.Listener()
+	s.Require().NoError(err)
+
+	recorder := &invalidations{changes: make(chan models.Change, 10)}
+	listener.Subscribe(recorder)
+
+	stopped := make(chan error, 1)
+
+	go func() {
+		stopped <- listener.Run(ctx)
+	}()
+
+	s.Require().Eventually(listener.Connected, 5*time.Second, 10*time.Millisecond)
+	s.Require().EqualValues(1, recorder.resets.Load(), "connecting must drop everything cached so far")
+
+	var created struct {
+		Data models.Song `json:"data"`
+	}
+
+	resp := s.sendRequest(ctx, http.MethodPost, "", models.Song{Name: "Notify", Group: "Listeners"}, &created)
+	s.Require().Equal(http.StatusCreated, resp.StatusCode)
+
+	select {
+	case change := <-recorder.changes:
+		s.Require().Equal(models.Change{Type: models.EventSongCreated, SongID: created.Data.ID}, change)
+	case <-time.After(5 * time.Second):
+		s.FailNow("no notification received")
+	}
+
+	cancel()
+
+	select {
+	case err := <-stopped:
+		s.Require().NoError(err)
+		s.Require().False(listener.Connected())
+	case <-time.After(5 * time.Second):
+		s.FailNow("listener did not stop with its context")
+	}
+}
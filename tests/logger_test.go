@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/iurikman/songs/internal/logger"
+	"github.com/iurikman/songs/internal/models"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+// captureLog has the global logger write JSON at level into the returned
+// buffer until the test ends.
+func captureLog(t *testing.T, level string) *bytes.Buffer {
+	t.Helper()
+
+	std := log.StandardLogger()
+	out, formatter, lvl := std.Out, std.Formatter, std.GetLevel()
+
+	t.Cleanup(func() {
+		log.SetOutput(out)
+		log.SetFormatter(formatter)
+		log.SetLevel(lvl)
+	})
+
+	var buf bytes.Buffer
+
+	log.SetOutput(&buf)
+	require.NoError(t, logger.Setup(level, logger.FormatJSON))
+
+	return &buf
+}
+
+// lastEntry returns the fields of the last entry written to buf.
+func lastEntry(t *testing.T, buf *bytes.Buffer) map[string]any {
+	t.Helper()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+
+	var entry map[string]any
+
+	require.NoError(t, json.Unmarshal([]byte(lines[len(lines)-1]), &entry))
+
+	return entry
+}
+
+func TestLogRedactsSecrets(t *testing.T) {
+	buf := captureLog(t, "info")
+
+	log.WithFields(log.Fields{
+		"password":         "hunter2",
+		"POSTGRES_DSN":     "postgres://songs:hunter2@db/songs",
+		"webhookSecret":    "s3cr3t",
+		"api_token":        "abc",
+		"Authorization":    "Bearer abc",
+		"music_group":      "Muse",
+		"pending_webhooks": 3,
+	}).Info("configured")
+
+	entry := lastEntry(t, buf)
+
+	for _, key := range []string{"password", "POSTGRES_DSN", "webhookSecret", "api_token", "Authorization"} {
+		require.Equal(t, "[REDACTED]", entry[key], key)
+	}
+
+	require.Equal(t, "Muse", entry["music_group"])
+	require.InDelta(t, 3, entry["pending_webhooks"], 0, "values that are not strings are kept")
+	require.NotContains(t, buf.String(), "hunter2")
+}
+
+func TestLogTruncatesLongValues(t *testing.T) {
+	buf := captureLog(t, "info")
+
+	log.WithFields(log.Fields{
+		"short":  strings.Repeat("a", 256),
+		"long":   strings.Repeat("a", 300),
+		"lyrics": strings.Repeat("a", 255) + "ё",
+	}).Info(strings.Repeat("m", 2000))
+
+	entry := lastEntry(t, buf)
+
+	require.Equal(t, strings.Repeat("a", 256), entry["short"], "values of 256 characters are kept")
+	require.Equal(t, strings.Repeat("a", 256)+"...(truncated)", entry["long"])
+	require.Equal(t, strings.Repeat("a", 255)+"...(truncated)", entry["lyrics"],
+		"values are cut before a character they would split")
+	require.Equal(t, strings.Repeat("m", 1024)+"...(truncated)", entry["msg"])
+}
+
+func TestGetSongsLogsNoSQL(t *testing.T) {
+	db := newSQLite(t)
+	buf := captureLog(t, "debug")
+
+	params := models.Params{Filter: "hunter2", Limit: 10}
+
+	_, err := db.GetSongs(context.Background(), params)
+	require.NoError(t, err)
+
+	entry := lastEntry(t, buf)
+	require.Equal(t, "listing songs", entry["msg"])
+	require.Equal(t, "GetSongs", entry["query"])
+	require.Len(t, entry["query_hash"], 12)
+	require.NotContains(t, buf.String(), "SELECT")
+
+	params.Sorting = "name"
+
+	_, err = db.GetSongs(context.Background(), params)
+	require.NoError(t, err)
+	require.NotEqual(t, entry["query_hash"], lastEntry(t, buf)["query_hash"], "queries of other shapes hash apart")
+}
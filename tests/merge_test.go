@@ -0,0 +1,180 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/models"
+	"github.com/iurikman/songs/internal/rest"
+	"github.com/iurikman/songs/internal/service"
+	"github.com/iurikman/songs/internal/store"
+	"github.com/iurikman/songs/internal/validation"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeSongs(t *testing.T) {
+	ctx := context.Background()
+	svc := service.NewService(store.NewMemory(), passthroughDetails{})
+
+	target, err := svc.CreateSong(ctx, models.Song{
+		ID: uuid.New(), Name: "Yesterdy", Group: "The Beatles", Text: "Yesterday\n\nAll my troubles",
+	}, false)
+	require.NoError(t, err)
+
+	source, err := svc.CreateSong(ctx, models.Song{
+		ID: uuid.New(), Name: "Yesterday", Group: "The Beatles", Link: "https://example.com/yesterday",
+	}, true)
+	require.NoError(t, err)
+
+	merged, err := svc.MergeSongs(ctx, target.ID, models.Merge{
+		Sources: []uuid.UUID{source.ID},
+		Fields:  map[string]uuid.UUID{"name": source.ID, "link": source.ID},
+	})
+	require.NoError(t, err)
+	require.Equal(t, target.ID, merged.ID)
+	require.Equal(t, "Yesterday", merged.Name)
+	require.Equal(t, "https://example.com/yesterday", merged.Link)
+	require.Equal(t, target.Text, merged.Text, "fields not picked keep their value")
+
+	_, err = svc.GetText(ctx, source.ID, 1)
+
+	var mergedErr *models.MergedSongError
+
+	require.True(t, errors.As(err, &mergedErr))
+	require.ErrorIs(t, err, models.ErrSongMerged)
+	require.Equal(t, target.ID, mergedErr.Into)
+
+	_, err = svc.GetText(ctx, uuid.New(), 1)
+	require.ErrorIs(t, err, models.ErrSongNotFound)
+
+	_, err = svc.UpdateSong(ctx, source.ID, models.Song{Name: "Yesterday", Group: "The Beatles"})
+	require.True(t, errors.As(err, &mergedErr), "merged songs are not updated")
+	require.Equal(t, target.ID, mergedErr.Into)
+
+	_, err = svc.MergeSongs(ctx, target.ID, models.Merge{Sources: []uuid.UUID{uuid.New()}})
+	require.ErrorIs(t, err, models.ErrSongNotFound)
+
+	other := uuid.New()
+
+	_, err = svc.MergeSongs(ctx, target.ID, models.Merge{
+		Sources: []uuid.UUID{target.ID, other, other},
+		Fields:  map[string]uuid.UUID{"deleted": other, "text": uuid.New()},
+	})
+
+	var violations validation.Errors
+
+	require.True(t, errors.As(err, &violations))
+	require.Equal(t, []string{"sources[0]", "sources[2]", "fields.deleted", "fields.text"}, fieldsOf(violations))
+
+	_, err = svc.MergeSongs(ctx, target.ID, models.Merge{})
+	require.True(t, errors.As(err, &violations))
+	require.Equal(t, []string{"sources"}, fieldsOf(violations))
+}
+
+func TestUpdateMergedSong(t *testing.T) {
+	ctx := context.Background()
+	svc := service.NewService(store.NewMemory(), passthroughDetails{})
+	host := startServer(ctx, t, rest.SrvConfig{}, svc)
+
+	target, err := svc.CreateSong(ctx, models.Song{ID: uuid.New(), Name: "Let It Be", Group: "The Beatles"}, false)
+	require.NoError(t, err)
+
+	source, err := svc.CreateSong(ctx, models.Song{ID: uuid.New(), Name: "Let it be", Group: "The Beatles"}, true)
+	require.NoError(t, err)
+
+	deleted, err := svc.CreateSong(ctx, models.Song{ID: uuid.New(), Name: "Let It Go", Group: "The Beatles"}, true)
+	require.NoError(t, err)
+
+	_, err = svc.MergeSongs(ctx, target.ID, models.Merge{Sources: []uuid.UUID{source.ID}})
+	require.NoError(t, err)
+	require.NoError(t, svc.DeleteSong(ctx, deleted.ID))
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+	patch := func(id uuid.UUID) *http.Response {
+		body, err := json.Marshal(models.Song{Name: "Let It Be (Remastered)", Group: "The Beatles"})
+		require.NoError(t, err)
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPatch,
+			host+"/api/v1/songs/"+id.String(), bytes.NewReader(body))
+		require.NoError(t, err)
+
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+
+		return resp
+	}
+
+	resp := patch(source.ID)
+	require.Equal(t, http.StatusPermanentRedirect, resp.StatusCode)
+	require.Equal(t, "/api/v1/songs/"+target.ID.String(), resp.Header.Get("Location"))
+
+	resp = patch(deleted.ID)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	_, err = svc.GetText(ctx, source.ID, 1)
+
+	var mergedErr *models.MergedSongError
+
+	require.True(t, errors.As(err, &mergedErr), "the tombstone is left alone")
+	require.Equal(t, target.ID, mergedErr.Into)
+}
+
+func fieldsOf(violations validation.Errors) []string {
+	fields := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		fields = append(fields, violation.Field)
+	}
+
+	return fields
+}
+
+func (s *IntegrationTestSuite) TestMergeEndpoint() {
+	ctx := context.Background()
+
+	var target, source struct {
+		Data models.Song `json:"data"`
+	}
+
+	resp := s.sendRequest(ctx, http.MethodPost, "/?force=true",
+		models.Song{ID: uuid.New(), Name: "Merge me", Group: "Mergers"}, &target)
+	s.Require().Equal(http.StatusCreated, resp.StatusCode)
+
+	resp = s.sendRequest(ctx, http.MethodPost, "/?force=true",
+		models.Song{ID: uuid.New(), Name: "Merge me!", Group: "Mergers", Link: "https://example.com/merge"}, &source)
+	s.Require().Equal(http.StatusCreated, resp.StatusCode)
+
+	var merged struct {
+		Data models.Song `json:"data"`
+	}
+
+	resp = s.sendRequest(ctx, http.MethodPost, "/"+target.Data.ID.String()+"/merge", models.Merge{
+		Sources: []uuid.UUID{source.Data.ID},
+		Fields:  map[string]uuid.UUID{"name": source.Data.ID},
+	}, &merged)
+	s.Require().Equal(http.StatusOK, resp.StatusCode)
+	s.Require().Equal(target.Data.ID, merged.Data.ID)
+	s.Require().Equal("Merge me!", merged.Data.Name)
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		bindAddress+"/"+source.Data.ID.String()+"?offset=1", nil)
+	s.Require().NoError(err)
+
+	resp, err = client.Do(req)
+	s.Require().NoError(err)
+	s.Require().NoError(resp.Body.Close())
+	s.Require().Equal(http.StatusMovedPermanently, resp.StatusCode)
+	s.Require().Equal("/api/v1/songs/"+target.Data.ID.String()+"?offset=1", resp.Header.Get("Location"))
+
+	resp = s.sendRequest(ctx, http.MethodPost, "/"+target.Data.ID.String()+"/merge",
+		models.Merge{Sources: []uuid.UUID{source.Data.ID}}, nil)
+	s.Require().Equal(http.StatusNotFound, resp.StatusCode, "merged songs are gone")
+}
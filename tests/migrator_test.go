@@ -0,0 +1,533 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/config"
+	"github.com/iurikman/songs/internal/models"
+	"github.com/iurikman/songs/internal/store"
+	migrate "github.com/rubenv/sql-migrate"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigKeepsCommand(t *testing.T) {
+	cfg, err := config.Load([]string{"--log-level", "debug", "migrate", "down", "-n", "2"})
+	require.NoError(t, err)
+
+	require.Equal(t, "debug", cfg.LogLevel)
+	require.Equal(t, []string{"migrate", "down", "-n", "2"}, cfg.Command)
+}
+
+func TestMigrator(t *testing.T) {
+	db, err := store.NewSQLite(filepath.Join(t.TempDir(), "songs.db"))
+	require.NoError(t, err)
+
+	defer db.Close()
+
+	migrator, err := db.Migrator()
+	require.NoError(t, err)
+
+	defer migrator.Close()
+
+	statuses, err := migrator.Status()
+	require.NoError(t, err)
+	require.NotEmpty(t, statuses)
+
+	for _, status := range statuses {
+		require.Nil(t, status.AppliedAt, status.ID)
+	}
+
+	planned, err := migrator.Plan(migrate.Up, 0)
+	require.NoError(t, err)
+	require.Len(t, planned, len(statuses))
+	require.Contains(t, planned[0].Queries[0], "CREATE TABLE songs")
+
+	pending, err := db.PendingMigrations(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, len(statuses), pending, "planning does not apply anything")
+
+	applied, err := migrator.Exec(migrate.Up, 0)
+	require.NoError(t, err)
+	require.Equal(t, len(statuses), applied)
+
+	statuses, err = migrator.Status()
+	require.NoError(t, err)
+	require.NotNil(t, statuses[0].AppliedAt)
+
+	planned, err = migrator.Plan(migrate.Down, 1)
+	require.NoError(t, err)
+	require.Len(t, planned, 1)
+	require.Equal(t, statuses[len(statuses)-1].ID, planned[0].Id, "down starts with the newest migration")
+
+	applied, err = migrator.Exec(migrate.Down, 1)
+	require.NoError(t, err)
+	require.Equal(t, 1, applied)
+
+	pending, err = db.PendingMigrations(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, pending)
+
+	applied, err = migrator.Exec(migrate.Up, 0)
+	require.NoError(t, err)
+	require.Equal(t, 1, applied)
+}
+
+func TestSQLitePendingMigrationsLeavesSchemaAlone(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "songs.db")
+
+	db, err := store.NewSQLite(path)
+	require.NoError(t, err)
+
+	defer db.Close()
+
+	pending, err := db.PendingMigrations(context.Background())
+	require.NoError(t, err)
+	require.Positive(t, pending)
+
+	conn, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+
+	defer conn.Close()
+
+	var tables int
+
+	require.NoError(t, conn.QueryRow(`SELECT count(*) FROM sqlite_master WHERE type = 'table'`).Scan(&tables))
+	require.Zero(t, tables, "counting pending migrations does not create the migrations table")
+
+	migrator, err := db.Migrator()
+	require.NoError(t, err)
+
+	statuses, err := migrator.Status()
+	require.NoError(t, err)
+	require.Len(t, statuses, pending)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = db.PendingMigrations(ctx)
+	require.ErrorIs(t, err, context.Canceled, "health probes bound it with their context")
+}
+
+func TestSQLiteReleaseDateMigration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "songs.db")
+
+	db, err := store.NewSQLite(path)
+	require.NoError(t, err)
+
+	defer db.Close()
+
+	migrator, err := db.Migrator()
+	require.NoError(t, err)
+
+	_, err = migrator.Exec(migrate.Up, 1)
+	require.NoError(t, err)
+
+	conn, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+
+	defer conn.Close()
+
+	raw := map[string]string{
+		"day":     "16.07.2006",
+		"iso":     "2009-09-07",
+		"month":   "07.2006",
+		"year":    "2006",
+		"empty":   "",
+		"invalid": "31.02.2006",
+		"words":   "summer 2006",
+	}
+
+	for name, releaseDate := range raw {
+		_, err := conn.Exec(`INSERT INTO songs (id, release_date, name, music_group) VALUES (?, ?, ?, 'band')`,
+			uuid.NewString(), releaseDate, name)
+		require.NoError(t, err)
+	}
+
+	_, err = migrator.Exec(migrate.Up, 1)
+	require.NoError(t, err)
+
+	// The store reads the newest schema; going down as many migrations as
+	// came after the release date one reverts it too.
+	later, err := db.PendingMigrations(context.Background())
+	require.NoError(t, err)
+
+	_, err = migrator.Exec(migrate.Up, 0)
+	require.NoError(t, err)
+
+	songs, err := db.GetSongs(context.Background(), models.Params{Limit: 10})
+	require.NoError(t, err)
+
+	dates := make(map[string]string, len(songs))
+	for _, song := range songs {
+		dates[song.Name] = song.ReleaseDate.String()
+	}
+
+	require.Equal(t, map[string]string{
+		"day":     "16.07.2006",
+		"iso":     "07.09.2009",
+		"month":   "07.2006",
+		"year":    "2006",
+		"empty":   "",
+		"invalid": "",
+		"words":   "",
+	}, dates)
+
+	var unparsed []string
+
+	rows, err := conn.Query(`SELECT release_date FROM unparsed_release_dates ORDER BY release_date`)
+	require.NoError(t, err)
+
+	for rows.Next() {
+		var releaseDate string
+
+		require.NoError(t, rows.Scan(&releaseDate))
+
+		unparsed = append(unparsed, releaseDate)
+	}
+
+	require.NoError(t, rows.Err())
+	require.Equal(t, []string{"31.02.2006", "summer 2006"}, unparsed)
+
+	_, err = migrator.Exec(migrate.Down, later+1)
+	require.NoError(t, err)
+
+	var restored string
+
+	require.NoError(t, conn.QueryRow(`SELECT release_date FROM songs WHERE name = 'words'`).Scan(&restored))
+	require.Equal(t, "summer 2006", restored, "going down restores what could not be parsed")
+	require.NoError(t, conn.QueryRow(`SELECT release_date FROM songs WHERE name = 'iso'`).Scan(&restored))
+	require.Equal(t, "07.09.2009", restored)
+}
+
+func TestSQLiteReleaseDateConflicts(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "songs.db")
+
+	db, err := store.NewSQLite(path)
+	require.NoError(t, err)
+
+	defer db.Close()
+
+	migrator, err := db.Migrator()
+	require.NoError(t, err)
+
+	_, err = migrator.Exec(migrate.Up, 1)
+	require.NoError(t, err)
+
+	later, err := db.PendingMigrations(ctx)
+	require.NoError(t, err)
+
+	conn, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+
+	defer conn.Close()
+
+	// Every pair becomes the same song once the release dates are parsed;
+	// the first one of a pair keeps it.
+	pairs := []struct{ name, kept, duplicate string }{
+		{"day", "16.07.2006", "2006-07-16"},
+		{"year", "2006", "01.01.2006"},
+		{"unknown", "", "summer 2006"},
+	}
+
+	ids := make(map[string]uuid.UUID, 2*len(pairs))
+
+	for _, pair := range pairs {
+		for _, releaseDate := range []string{pair.kept, pair.duplicate} {
+			ids[releaseDate] = uuid.New()
+
+			_, err := conn.Exec(`INSERT INTO songs (id, release_date, name, music_group) VALUES (?, ?, ?, 'band')`,
+				ids[releaseDate].String(), releaseDate, pair.name)
+			require.NoError(t, err)
+		}
+	}
+
+	_, err = migrator.Exec(migrate.Up, 0)
+	require.NoError(t, err)
+
+	songs, err := db.GetSongs(ctx, models.Params{Limit: 10})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"day", "year", "unknown"}, names(songs))
+
+	for _, pair := range pairs {
+		merged, err := db.MergedInto(ctx, ids[pair.duplicate])
+		require.NoError(t, err)
+		require.Equal(t, ids[pair.kept], merged, "%s points at the song it duplicates", pair.duplicate)
+	}
+
+	var conflicts int
+
+	require.NoError(t, conn.QueryRow(`SELECT count(*) FROM release_date_conflicts`).Scan(&conflicts))
+	require.Equal(t, 2*len(pairs), conflicts)
+
+	for _, pair := range pairs {
+		var name string
+
+		require.NoError(t, conn.QueryRow(`SELECT name FROM songs WHERE id = ?`, ids[pair.duplicate].String()).Scan(&name))
+		require.Equal(t, pair.name, name, "merged songs take their names back")
+	}
+
+	_, err = migrator.Exec(migrate.Down, later)
+	require.NoError(t, err)
+
+	for _, pair := range pairs {
+		for _, releaseDate := range []string{pair.kept, pair.duplicate} {
+			var (
+				restored, name string
+				deleted        bool
+			)
+
+			require.NoError(t, conn.QueryRow(`SELECT release_date, name, deleted FROM songs WHERE id = ?`,
+				ids[releaseDate].String()).Scan(&restored, &name, &deleted))
+			require.Equal(t, releaseDate, restored, "going down restores the release dates in conflict")
+			require.Equal(t, pair.name, name)
+			require.False(t, deleted)
+		}
+	}
+}
+
+func TestSQLiteReleaseDateConflictsAfterReleaseDates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "songs.db")
+
+	db, err := store.NewSQLite(path)
+	require.NoError(t, err)
+
+	defer db.Close()
+
+	require.NoError(t, db.Migrate(migrate.Up))
+
+	_, err = db.CreateSong(context.Background(), models.Song{ID: uuid.New(), Name: "Hysteria", Group: "Muse"})
+	require.NoError(t, err)
+
+	conn, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+
+	defer conn.Close()
+
+	// A database migrated before the conflicts migrations were added gets
+	// them after the release dates and merges migrations.
+	_, err = conn.Exec(`DELETE FROM gorp_migrations WHERE id IN (
+		'20261910125000_release_date_conflicts.sql', '20261910155000_merge_release_date_conflicts.sql')`)
+	require.NoError(t, err)
+
+	_, err = conn.Exec(`DROP TABLE release_date_conflicts`)
+	require.NoError(t, err)
+
+	migrator, err := db.Migrator()
+	require.NoError(t, err)
+
+	applied, err := migrator.Exec(migrate.Up, 0)
+	require.NoError(t, err)
+	require.Equal(t, 2, applied)
+
+	var conflicts int
+
+	require.NoError(t, conn.QueryRow(`SELECT count(*) FROM release_date_conflicts`).Scan(&conflicts))
+	require.Zero(t, conflicts)
+
+	var name string
+
+	require.NoError(t, conn.QueryRow(`SELECT name FROM songs`).Scan(&name))
+	require.Equal(t, "Hysteria", name)
+}
+
+func TestSQLiteMergesMigration(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "songs.db")
+
+	db, err := store.NewSQLite(path)
+	require.NoError(t, err)
+
+	defer db.Close()
+
+	migrator, err := db.Migrator()
+	require.NoError(t, err)
+
+	// Up to the release date migration, the merges migration rebuilds songs.
+	_, err = migrator.Exec(migrate.Up, 3)
+	require.NoError(t, err)
+
+	// The merges migration and those after it.
+	later, err := db.PendingMigrations(ctx)
+	require.NoError(t, err)
+
+	conn, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+
+	defer conn.Close()
+
+	submarine := uuid.NewString()
+
+	_, err = conn.Exec(`INSERT INTO songs (id, name, music_group) VALUES (?, 'Yellow Submarine', 'The Beatles')`,
+		submarine)
+	require.NoError(t, err)
+
+	_, err = conn.Exec(`INSERT INTO unparsed_release_dates (song_id, release_date) VALUES (?, 'summer 1968')`,
+		submarine)
+	require.NoError(t, err)
+
+	_, err = migrator.Exec(migrate.Up, 0)
+	require.NoError(t, err)
+
+	var unparsed string
+
+	require.NoError(t, conn.QueryRow(`SELECT release_date FROM unparsed_release_dates`).Scan(&unparsed))
+	require.Equal(t, "summer 1968", unparsed, "unparsed release dates survive the rebuild")
+
+	_, err = db.CreateSong(ctx, models.Song{ID: uuid.New(), Name: "Submarines", Group: "Björk"})
+	require.NoError(t, err)
+
+	songs, err := db.Search(ctx, "submarine", models.Params{Limit: 10})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"Yellow Submarine", "Submarines"}, names(songs), "the full-text index follows songs")
+
+	_, err = migrator.Exec(migrate.Down, later)
+	require.NoError(t, err)
+
+	_, err = conn.Exec(`INSERT INTO songs (id, name, music_group) VALUES (?, 'Yellow Submarine', 'The Beatles')`,
+		uuid.NewString())
+	require.ErrorContains(t, err, "UNIQUE constraint failed", "going down keeps songs unique")
+}
+
+func TestSQLiteSongLinksMigration(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "songs.db")
+
+	db, err := store.NewSQLite(path)
+	require.NoError(t, err)
+
+	defer db.Close()
+
+	migrator, err := db.Migrator()
+	require.NoError(t, err)
+
+	// Up to the merges migrations, the links migration moves the link column.
+	_, err = migrator.Exec(migrate.Up, 5)
+	require.NoError(t, err)
+
+	later, err := db.PendingMigrations(ctx)
+	require.NoError(t, err)
+
+	conn, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+
+	defer conn.Close()
+
+	yesterday, help := uuid.New(), uuid.New()
+
+	_, err = conn.Exec(`INSERT INTO songs (id, name, music_group, link) VALUES
+		(?, 'Yesterday', 'The Beatles', 'https://www.youtube.com/watch?v=Xsp3_a-PMTw'),
+		(?, 'Help!', 'The Beatles', '')`, yesterday.String(), help.String())
+	require.NoError(t, err)
+
+	_, err = migrator.Exec(migrate.Up, 0)
+	require.NoError(t, err)
+
+	links, err := db.GetLinks(ctx, yesterday)
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	require.Equal(t, models.PlatformYouTube, links[0].Platform)
+	require.True(t, links[0].Primary)
+
+	links, err = db.GetLinks(ctx, help)
+	require.NoError(t, err)
+	require.Empty(t, links, "empty links are not moved")
+
+	songs, err := db.GetSongsByIDs(ctx, []uuid.UUID{yesterday})
+	require.NoError(t, err)
+	require.Equal(t, "https://www.youtube.com/watch?v=Xsp3_a-PMTw", songs[0].Link)
+
+	_, err = migrator.Exec(migrate.Down, later)
+	require.NoError(t, err)
+
+	var link string
+
+	require.NoError(t, conn.QueryRow(`SELECT link FROM songs WHERE id = ?`, yesterday.String()).Scan(&link))
+	require.Equal(t, "https://www.youtube.com/watch?v=Xsp3_a-PMTw", link, "going down restores the primary link")
+}
+
+func TestSQLiteMigratedLinksAreNormalized(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "songs.db")
+
+	db, err := store.NewSQLite(path)
+	require.NoError(t, err)
+
+	defer db.Close()
+
+	migrator, err := db.Migrator()
+	require.NoError(t, err)
+
+	_, err = migrator.Exec(migrate.Up, 5)
+	require.NoError(t, err)
+
+	conn, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+
+	defer conn.Close()
+
+	yesterday, help := uuid.New(), uuid.New()
+
+	_, err = conn.Exec(`INSERT INTO songs (id, name, music_group, link) VALUES
+		(?, 'Yesterday', 'The Beatles', 'https://youtu.be/Xsp3_a-PMTw'),
+		(?, 'Help!', 'The Beatles', 'https://www.youtube.com/@TheBeatles')`, yesterday.String(), help.String())
+	require.NoError(t, err)
+
+	_, err = migrator.Exec(migrate.Up, 0)
+	require.NoError(t, err)
+
+	links, err := db.GetLinks(ctx, yesterday)
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	require.Equal(t, models.PlatformYouTube, links[0].Platform)
+	require.Equal(t, "https://www.youtube.com/watch?v=Xsp3_a-PMTw", links[0].URL)
+	require.Equal(t, "Xsp3_a-PMTw", links[0].ExternalID)
+	require.True(t, links[0].Primary)
+
+	links, err = db.GetLinks(ctx, help)
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	require.Equal(t, models.PlatformOther, links[0].Platform, "links the normalizer rejects are kept")
+	require.Equal(t, "https://www.youtube.com/@TheBeatles", links[0].URL)
+
+	var queued int
+
+	require.NoError(t, conn.QueryRow(`SELECT count(*) FROM song_links_to_normalize`).Scan(&queued))
+	require.Zero(t, queued)
+}
+
+func TestSQLiteTermWeightsMigration(t *testing.T) {
+	ctx := context.Background()
+	db := newSQLite(t)
+
+	loveMeDo := models.Song{ID: uuid.New(), Name: "Love Me Do", Group: "The Beatles", Text: "Love, love me do"}
+	cover := models.Song{ID: uuid.New(), Name: "Love Me Do", Group: "Covers", Text: "Love, love me do"}
+	tender := models.Song{ID: uuid.New(), Name: "Love Me Tender", Group: "Elvis Presley", Text: "Love me tender"}
+
+	for _, song := range []models.Song{loveMeDo, cover, tender} {
+		_, err := db.CreateSong(ctx, song)
+		require.NoError(t, err)
+	}
+
+	migrator, err := db.Migrator()
+	require.NoError(t, err)
+
+	// Down to the terms as they were stored before they were weighed.
+	_, err = migrator.Exec(migrate.Down, 1)
+	require.NoError(t, err)
+
+	_, err = migrator.Exec(migrate.Up, 0)
+	require.NoError(t, err)
+
+	related, err := db.RelatedSongs(ctx, loveMeDo.ID, models.Params{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, related, 2)
+	require.Equal(t, cover.ID, related[0].ID)
+	require.InDelta(t, 1, related[0].Similarity, 1e-9, "the terms stored before are weighed")
+	require.Equal(t, tender.ID, related[1].ID)
+	require.Greater(t, related[1].Similarity, 0.0)
+}
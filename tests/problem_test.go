@@ -0,0 +1,138 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/rest"
+	"github.com/iurikman/songs/internal/service"
+	"github.com/iurikman/songs/internal/songdetails"
+	"github.com/iurikman/songs/internal/store"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *IntegrationTestSuite) TestProblemDetails() {
+	ctx := context.Background()
+
+	s.Run("problem+json when accepted", func() {
+		path := "/api/v1/songs/" + uuid.NewString()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodDelete, "http://localhost:8080"+path, nil)
+		s.Require().NoError(err)
+		req.Header.Set("Accept", "application/problem+json, application/json;q=0.5")
+		req.Header.Set("X-Request-ID", "problem-request-1")
+
+		var problem rest.Problem
+
+		resp := s.doProblemRequest(req, &problem)
+		s.Require().Equal(http.StatusNotFound, resp.StatusCode)
+		s.Require().Equal("application/problem+json", resp.Header.Get("Content-Type"))
+		s.Require().Equal(rest.Problem{
+			Type:      "urn:songs:error:SONG_NOT_FOUND",
+			Title:     "Song not found",
+			Status:    http.StatusNotFound,
+			Detail:    "song not found",
+			Instance:  path,
+			Code:      "SONG_NOT_FOUND",
+			RequestID: "problem-request-1",
+		}, problem)
+	})
+
+	s.Run("validation errors", func() {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, bindAddress+"/",
+			strings.NewReader(`{"name":"","musicGroup":"Muse","link":"not a url"}`))
+		s.Require().NoError(err)
+		req.Header.Set("Accept", "application/problem+json")
+
+		var problem rest.Problem
+
+		resp := s.doProblemRequest(req, &problem)
+		s.Require().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+		s.Require().Equal("VALIDATION_FAILED", problem.Code)
+		s.Require().Len(problem.Errors, 2)
+		s.Require().Equal("name", problem.Errors[0].Field)
+		s.Require().Equal("link", problem.Errors[1].Field)
+	})
+
+	s.Run("v1 envelope carries the code", func() {
+		var response rest.HTTPResponse
+
+		resp := s.sendRequest(ctx, http.MethodGet, "/"+uuid.NewString()+"?offset=x", nil, &response)
+		s.Require().Equal(http.StatusBadRequest, resp.StatusCode)
+		s.Require().Equal("application/json", resp.Header.Get("Content-Type"))
+		s.Require().Equal("invalid verse", response.Error)
+		s.Require().Equal("INVALID_PARAMETER", response.Code)
+	})
+
+	s.Run("unknown route", func() {
+		var response rest.HTTPResponse
+
+		resp := s.sendRequest(ctx, http.MethodGet, "/"+uuid.NewString()+"/lyrics", nil, &response)
+		s.Require().Equal(http.StatusNotFound, resp.StatusCode)
+		s.Require().Equal("ROUTE_NOT_FOUND", response.Code)
+	})
+}
+
+func (s *IntegrationTestSuite) doProblemRequest(req *http.Request, dest any) *http.Response {
+	s.T().Helper()
+
+	resp, err := http.DefaultClient.Do(req)
+	s.Require().NoError(err)
+
+	defer func() {
+		s.Require().NoError(resp.Body.Close())
+	}()
+
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(dest))
+
+	return resp
+}
+
+func TestProblemUpstreamUnavailable(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	t.Cleanup(upstream.Close)
+
+	details := songdetails.NewSongDetails(songdetails.Config{
+		Host:             upstream.URL,
+		FailureThreshold: 1,
+		OpenTimeout:      time.Minute,
+	})
+	host := startServer(context.Background(), t, rest.SrvConfig{}, service.NewService(store.NewMemory(), details))
+
+	create := func() (*http.Response, rest.Problem) {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, host+"/api/v1/songs/",
+			strings.NewReader(`{"id":"`+uuid.NewString()+`","name":"Uprising","musicGroup":"Muse"}`))
+		require.NoError(t, err)
+		req.Header.Set("Accept", "application/problem+json")
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		var problem rest.Problem
+
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+
+		return resp, problem
+	}
+
+	resp, problem := create()
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	require.Equal(t, "UPSTREAM_UNAVAILABLE", problem.Code)
+	require.Equal(t, "song details are unavailable", problem.Detail)
+	require.Empty(t, resp.Header.Get("Retry-After"), "a failed request does not tell when to retry")
+
+	resp, problem = create()
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	require.Equal(t, "UPSTREAM_UNAVAILABLE", problem.Code)
+	require.Equal(t, "60", resp.Header.Get("Retry-After"), "the open circuit tells when it closes")
+}
@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/models"
+)
+
+func (s *IntegrationTestSuite) TestRelatedSongsEndpoint() {
+	ctx := context.Background()
+
+	// Words no other song has, so that only these songs are related.
+	create := func(name, group, text string) uuid.UUID {
+		var song struct {
+			Data models.Song `json:"data"`
+		}
+
+		resp := s.sendRequest(ctx, http.MethodPost, "/?force=true",
+			models.Song{ID: uuid.New(), Name: name, Group: group, Text: text}, &song)
+		s.Require().Equal(http.StatusCreated, resp.StatusCode)
+
+		return song.Data.ID
+	}
+
+	target := create("Quixotic Zephyr", "Zorblax", "Quixotic zephyr, quixotic zephyr")
+	same := create("Quixotic Zephyr (live)", "Marmalade Lanterns", "Quixotic zephyr, quixotic zephyr")
+	twoTerms := create("Zephyr Marmalade", "Marmalade Lanterns", "Quixotic zephyr marmalade")
+	oneTerm := create("Lantern", "Zorblax", "Quixotic lantern lantern lantern")
+	create("Unrelated", "Zorblax", "Gibberwocky flumph")
+
+	var related struct {
+		Data []*models.RelatedSong `json:"data"`
+	}
+
+	relatedPath := "/" + target.String() + "/related"
+
+	resp := s.sendRequest(ctx, http.MethodGet, relatedPath, nil, &related)
+	s.Require().Equal(http.StatusOK, resp.StatusCode)
+	s.Require().Equal([]uuid.UUID{same, twoTerms, oneTerm}, relatedIDs(related.Data),
+		"the most similar come first, songs without a term in common are left out")
+	s.Require().InDelta(1, related.Data[0].Similarity, 1e-9)
+	s.Require().Greater(related.Data[1].Similarity, related.Data[2].Similarity)
+
+	resp = s.sendRequest(ctx, http.MethodGet, relatedPath+"?limit=1&offset=1", nil, &related)
+	s.Require().Equal(http.StatusOK, resp.StatusCode)
+	s.Require().Equal([]uuid.UUID{twoTerms}, relatedIDs(related.Data))
+
+	resp = s.sendRequest(ctx, http.MethodGet, relatedPath+"?groupBoost=1", nil, &related)
+	s.Require().Equal(http.StatusOK, resp.StatusCode)
+	s.Require().Equal([]uuid.UUID{oneTerm, same, twoTerms}, relatedIDs(related.Data), "songs by the same group come first")
+	s.Require().InDelta(related.Data[0].Similarity+1, related.Data[0].Score, 1e-9)
+
+	resp = s.sendRequest(ctx, http.MethodGet, relatedPath+"?groupBoost=2", nil, nil)
+	s.Require().Equal(http.StatusBadRequest, resp.StatusCode)
+
+	resp = s.sendRequest(ctx, http.MethodGet, "/"+uuid.NewString()+"/related", nil, nil)
+	s.Require().Equal(http.StatusNotFound, resp.StatusCode)
+}
+
+func relatedIDs(related []*models.RelatedSong) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(related))
+	for _, song := range related {
+		ids = append(ids, song.ID)
+	}
+
+	return ids
+}
@@ -0,0 +1,59 @@
+package tests
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/iurikman/songs/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReleaseDate(t *testing.T) {
+	for _, tc := range []struct {
+		in, out, precision string
+		start, end         time.Time
+	}{
+		{"16.07.2006", "16.07.2006", models.PrecisionDay, day(2006, 7, 16), day(2006, 7, 16)},
+		{"2006-07-16", "16.07.2006", models.PrecisionDay, day(2006, 7, 16), day(2006, 7, 16)},
+		{"6.7.2006", "06.07.2006", models.PrecisionDay, day(2006, 7, 6), day(2006, 7, 6)},
+		{"02.2004", "02.2004", models.PrecisionMonth, day(2004, 2, 1), day(2004, 2, 29)},
+		{"2004-02", "02.2004", models.PrecisionMonth, day(2004, 2, 1), day(2004, 2, 29)},
+		{" 2006 ", "2006", models.PrecisionYear, day(2006, 1, 1), day(2006, 12, 31)},
+	} {
+		date, err := models.ParseReleaseDate(tc.in)
+		require.NoError(t, err, tc.in)
+		require.Equal(t, tc.out, date.String(), tc.in)
+		require.Equal(t, tc.precision, date.Precision, tc.in)
+		require.Equal(t, tc.start, date.Date, tc.in)
+		require.Equal(t, tc.end, date.End(), tc.in)
+	}
+
+	date, err := models.ParseReleaseDate("")
+	require.NoError(t, err)
+	require.True(t, date.IsZero())
+	require.Empty(t, date.String())
+
+	for _, invalid := range []string{"31.02.2006", "summer 2006", "16/07/2006", "13.2006"} {
+		_, err := models.ParseReleaseDate(invalid)
+		require.ErrorIs(t, err, models.ErrInvalidReleaseDate, invalid)
+	}
+}
+
+func TestReleaseDateJSON(t *testing.T) {
+	var song models.Song
+
+	require.NoError(t, json.Unmarshal([]byte(`{"name": "Uprising", "releaseDate": "09.2009"}`), &song))
+	require.Equal(t, models.PrecisionMonth, song.ReleaseDate.Precision)
+
+	data, err := json.Marshal(song)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"releaseDate":"09.2009"`)
+
+	err = json.Unmarshal([]byte(`{"releaseDate": "someday"}`), &song)
+	require.ErrorIs(t, err, models.ErrInvalidReleaseDate)
+}
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
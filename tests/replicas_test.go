@@ -0,0 +1,235 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/config"
+	"github.com/iurikman/songs/internal/consistency"
+	"github.com/iurikman/songs/internal/models"
+	"github.com/iurikman/songs/internal/rest"
+	"github.com/iurikman/songs/internal/service"
+	"github.com/iurikman/songs/internal/store"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsistencySession(t *testing.T) {
+	ctx := context.Background()
+
+	require.False(t, consistency.Primary(ctx))
+	require.True(t, consistency.Primary(consistency.WithPrimary(ctx)))
+	require.Nil(t, consistency.SessionFrom(ctx))
+
+	var notified time.Time
+
+	session := consistency.NewSession(time.Time{}, func(at time.Time) { notified = at })
+	require.True(t, session.LastWrite().IsZero())
+
+	at := time.Now()
+	session.Wrote(at)
+
+	require.True(t, at.Equal(session.LastWrite()))
+	require.True(t, at.Equal(notified))
+	require.Same(t, session, consistency.SessionFrom(consistency.WithSession(ctx, session)))
+
+	restored := consistency.NewSession(time.UnixMilli(at.UnixMilli()), nil)
+	require.Equal(t, at.UnixMilli(), restored.LastWrite().UnixMilli())
+}
+
+func (s *IntegrationTestSuite) TestReplicaRouting() {
+	ctx := context.Background()
+
+	cfg, err := config.Load(nil)
+	s.Require().NoError(err)
+
+	// The primary stands in for a healthy replica: it is not in recovery, so
+	// it never lags.
+	primary := (&url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.PostgresUser, cfg.PostgresPassword),
+		Host:     fmt.Sprintf("%s:%s", cfg.PostgresHost, cfg.PostgresPort),
+		Path:     cfg.PostgresDatabase,
+		RawQuery: "sslmode=disable",
+	}).String()
+
+	db, err := store.New(ctx, store.Config{
+		DSN:            primary,
+		ConnectTimeout: time.Second,
+		ReplicaDSNs:    []string{primary, "postgres://nobody@127.0.0.1:1/none?sslmode=disable"},
+		ReplicaMaxLag:  time.Second,
+		StickyWindow:   time.Minute,
+	})
+	s.Require().NoError(err)
+
+	defer db.Close()
+
+	replicas := db.Replicas()
+	s.Require().Len(replicas, 2)
+	s.Require().True(replicas[0].Healthy)
+	s.Require().Empty(replicas[0].Error)
+	s.Require().False(replicas[1].Healthy, "unreachable replicas are ejected")
+	s.Require().NotEmpty(replicas[1].Error)
+	s.Require().NotContains(replicas[0].Name, cfg.PostgresPassword+"@")
+
+	var notified time.Time
+
+	session := consistency.NewSession(time.Time{}, func(at time.Time) { notified = at })
+	sessionCtx := consistency.WithSession(ctx, session)
+
+	song, err := db.CreateSong(sessionCtx, models.Song{ID: uuid.New(), Name: "replicated", Group: "routing"})
+	s.Require().NoError(err)
+	s.Require().False(notified.IsZero(), "writes are recorded in the session")
+
+	for range 4 {
+		songs, err := db.GetSongsByIDs(sessionCtx, []uuid.UUID{song.ID})
+		s.Require().NoError(err)
+		s.Require().Len(songs, 1)
+
+		_, err = db.GetSongs(ctx, models.Params{Limit: 10})
+		s.Require().NoError(err, "reads skip the ejected replica")
+	}
+
+	s.Require().NoError(db.DeleteSong(ctx, song.ID))
+}
+
+// consistencyRecorder records how the reads of songs are to be routed, and
+// tells the session of a write about it as the Postgres store does.
+type consistencyRecorder struct {
+	*store.Memory
+
+	mu        sync.Mutex
+	primary   bool
+	lastWrite time.Time
+}
+
+func (r *consistencyRecorder) CreateSong(ctx context.Context, song models.Song) (*models.Song, error) {
+	if session := consistency.SessionFrom(ctx); session != nil {
+		session.Wrote(time.Now())
+	}
+
+	return r.Memory.CreateSong(ctx, song) //nolint:wrapcheck
+}
+
+func (r *consistencyRecorder) GetSongs(ctx context.Context, params models.Params) ([]*models.Song, error) {
+	r.mu.Lock()
+	r.primary = consistency.Primary(ctx)
+	r.lastWrite = time.Time{}
+
+	if session := consistency.SessionFrom(ctx); session != nil {
+		r.lastWrite = session.LastWrite()
+	}
+	r.mu.Unlock()
+
+	return r.Memory.GetSongs(ctx, params) //nolint:wrapcheck
+}
+
+// routed returns how the last read of songs was to be routed.
+func (r *consistencyRecorder) routed() (bool, time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.primary, r.lastWrite
+}
+
+func TestReadYourWritesHeaders(t *testing.T) {
+	db := &consistencyRecorder{Memory: store.NewMemory()}
+	host := startServer(context.Background(), t, rest.SrvConfig{StickyWindow: time.Minute}, service.NewService(db, newSongDetails(t)))
+
+	send := func(method string, body any, header http.Header) *http.Response {
+		t.Helper()
+
+		var reader io.Reader = http.NoBody
+
+		path := "/api/v1/songs/"
+
+		if body != nil {
+			path += "?force=true"
+
+			encoded, err := json.Marshal(body)
+			require.NoError(t, err)
+
+			reader = bytes.NewReader(encoded)
+		}
+
+		req, err := http.NewRequestWithContext(context.Background(), method, host+path, reader)
+		require.NoError(t, err)
+
+		for name := range header {
+			req.Header.Set(name, header.Get(name))
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+
+		return resp
+	}
+
+	resp := send(http.MethodPost, models.Song{ID: uuid.New(), Name: "Hysteria", Group: "Muse"}, nil)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	lastWrite := resp.Header.Get("X-Last-Write")
+	require.NotEmpty(t, lastWrite, "writes tell the client when they were made")
+
+	var cookie *http.Cookie
+
+	for _, c := range resp.Cookies() {
+		if c.Name == "songs_last_write" {
+			cookie = c
+		}
+	}
+
+	require.NotNil(t, cookie, "browsers get the time of the write in a cookie")
+	require.Equal(t, lastWrite, cookie.Value)
+
+	millis, err := strconv.ParseInt(lastWrite, 10, 64)
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusOK, send(http.MethodGet, nil, nil).StatusCode)
+	primary, wrote := db.routed()
+	require.False(t, primary)
+	require.True(t, wrote.IsZero(), "clients that did not write read from replicas")
+
+	require.Equal(t, http.StatusOK, send(http.MethodGet, nil, http.Header{"X-Last-Write": {lastWrite}}).StatusCode)
+	_, wrote = db.routed()
+	require.Equal(t, millis, wrote.UnixMilli(), "the header carries the session of the client")
+
+	stale := strconv.FormatInt(millis-1000, 10)
+	require.Equal(t, http.StatusOK, send(http.MethodGet, nil, http.Header{
+		"X-Last-Write": {lastWrite},
+		"Cookie":       {"songs_last_write=" + stale},
+	}).StatusCode)
+	_, wrote = db.routed()
+	require.Equal(t, millis, wrote.UnixMilli(), "the header wins over the cookie")
+
+	require.Equal(t, http.StatusOK, send(http.MethodGet, nil, http.Header{"X-Read-Consistency": {"primary"}}).StatusCode)
+	primary, _ = db.routed()
+	require.True(t, primary, "clients can ask for the primary")
+}
+
+func TestReadConsistencyHeaderWithoutStickyWindow(t *testing.T) {
+	db := &consistencyRecorder{Memory: store.NewMemory()}
+	host := startServer(context.Background(), t, rest.SrvConfig{}, service.NewService(db, newSongDetails(t)))
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, host+"/api/v1/songs/", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Read-Consistency", "primary")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	primary, _ := db.routed()
+	require.True(t, primary)
+}
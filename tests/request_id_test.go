@@ -0,0 +1,35 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+func (s *IntegrationTestSuite) TestRequestID() {
+	s.Run("echoes client request id", func() {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, hostAddress+"/healthz", nil)
+		s.Require().NoError(err)
+		req.Header.Set("X-Request-ID", "client-request-42")
+
+		resp, err := http.DefaultClient.Do(req)
+		s.Require().NoError(err)
+		s.Require().NoError(resp.Body.Close())
+
+		s.Require().Equal("client-request-42", resp.Header.Get("X-Request-ID"))
+	})
+
+	s.Run("generates request id when missing or malformed", func() {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, hostAddress+"/healthz", nil)
+		s.Require().NoError(err)
+		req.Header.Set("X-Request-ID", "bad id\twith spaces")
+
+		resp, err := http.DefaultClient.Do(req)
+		s.Require().NoError(err)
+		s.Require().NoError(resp.Body.Close())
+
+		_, err = uuid.Parse(resp.Header.Get("X-Request-ID"))
+		s.Require().NoError(err)
+	})
+}
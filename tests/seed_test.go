@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/iurikman/songs/internal/models"
+	"github.com/iurikman/songs/internal/seed"
+	"github.com/iurikman/songs/internal/store"
+	"github.com/stretchr/testify/require"
+)
+
+const seedJSON = `{
+	"songs": [
+		{"musicGroup": "Muse", "name": "Starlight", "releaseDate": "04.09.2006", "text": "Far away\n\nMy life"}
+	],
+	"groups": [
+		{"name": "Queen", "songs": [
+			{"name": "Bohemian Rhapsody", "releaseDate": "31.10.1975", "translations": {"ru": "Это реальная жизнь?"}}
+		]}
+	]
+}`
+
+func TestSeedLoadFiles(t *testing.T) {
+	ctx := context.Background()
+	db := store.NewMemory()
+
+	path := filepath.Join(t.TempDir(), "songs.json")
+	require.NoError(t, os.WriteFile(path, []byte(seedJSON), 0o600))
+
+	report, err := seed.LoadFiles(ctx, db, path, filepath.Join("..", "fixtures", "songs.yaml"))
+	require.NoError(t, err)
+	require.Equal(t, 4, report.Created)
+	require.Equal(t, 1, report.Existing, "both fixtures have Bohemian Rhapsody")
+	require.Equal(t, 2, report.SkippedTranslations)
+
+	report, err = seed.LoadFiles(ctx, db, path)
+	require.NoError(t, err)
+	require.Zero(t, report.Created)
+	require.Equal(t, 2, report.Existing, "loading the same fixture again changes nothing")
+
+	songs, err := db.GetSongsByGroups(ctx, []string{"Queen"})
+	require.NoError(t, err)
+	require.Len(t, songs, 1, "the song of the fixture file and of the sample fixture are one")
+	require.Equal(t, seed.KeyID(parseReleaseDate(t, "31.10.1975"), "Bohemian Rhapsody", "Queen"), songs[0].ID)
+}
+
+func TestSeedRejectsBadFixtures(t *testing.T) {
+	dir := t.TempDir()
+
+	incomplete := filepath.Join(dir, "songs.yaml")
+	require.NoError(t, os.WriteFile(incomplete, []byte("songs:\n  - name: Nameless\n"), 0o600))
+
+	_, err := seed.LoadFiles(context.Background(), store.NewMemory(), incomplete)
+	require.ErrorIs(t, err, seed.ErrIncompleteSong)
+
+	unsupported := filepath.Join(dir, "songs.csv")
+	require.NoError(t, os.WriteFile(unsupported, nil, 0o600))
+
+	_, err = seed.ReadFixture(unsupported)
+	require.ErrorIs(t, err, seed.ErrUnsupportedFile)
+}
+
+func TestSeedGenerate(t *testing.T) {
+	songs := seed.Generate(500, 42)
+	require.Len(t, songs, 500)
+	require.Equal(t, songs, seed.Generate(500, 42), "the same seed yields the same songs")
+	require.NotEqual(t, songs[0], seed.Generate(500, 43)[0])
+
+	keys := make(map[string]bool, len(songs))
+
+	for _, song := range songs {
+		require.NotEmpty(t, song.Name)
+		require.NotEmpty(t, song.Group)
+		require.GreaterOrEqual(t, len(models.SplitVerses(song.Text)), 4, "verses alternate with a chorus")
+
+		key := strings.Join([]string{song.ReleaseDate.String(), song.Name, song.Group}, "|")
+		require.False(t, keys[key], "duplicate song %s", key)
+		keys[key] = true
+	}
+
+	db := store.NewMemory()
+
+	report, err := seed.Load(context.Background(), db, songs)
+	require.NoError(t, err)
+	require.Equal(t, 500, report.Created)
+
+	report, err = seed.Load(context.Background(), db, seed.Generate(500, 42))
+	require.NoError(t, err)
+	require.Equal(t, 500, report.Existing)
+}
@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/iurikman/songs/internal/models"
+	"github.com/iurikman/songs/internal/rest"
+	"github.com/iurikman/songs/internal/service"
+	"github.com/iurikman/songs/internal/songdetails"
+	"github.com/stretchr/testify/require"
+)
+
+// startServer serves svc over REST on a free local port until ctx is done or
+// the test ends and returns the address of the server.
+func startServer(ctx context.Context, t *testing.T, cfg rest.SrvConfig, svc *service.Service) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	cfg.BindAddr = listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	server, err := rest.NewServer(cfg, svc, rest.Dependencies{})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan error, 1)
+
+	go func() {
+		done <- server.Start(ctx)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, <-done)
+	})
+
+	host := "http://" + cfg.BindAddr
+
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(host + "/healthz") //nolint:noctx
+		if err != nil {
+			return false
+		}
+
+		return resp.Body.Close() == nil && resp.StatusCode == http.StatusOK
+	}, 5*time.Second, 10*time.Millisecond, "the server starts")
+
+	return host
+}
+
+// newSongDetails returns a song details client of an API serving the same
+// details for every song until the test ends.
+func newSongDetails(t *testing.T) *songdetails.SongDetails {
+	t.Helper()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(models.SongDetails{ReleaseDate: "16.07.2006", Text: "Ooh baby"})
+	}))
+	t.Cleanup(upstream.Close)
+
+	return songdetails.NewSongDetails(songdetails.Config{Host: upstream.URL})
+}
+
+func TestReadinessFailsWhileDraining(t *testing.T) {
+	const drainDelay = 500 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	host := startServer(ctx, t, rest.SrvConfig{ReadinessDrainDelay: drainDelay}, nil)
+
+	status := func(endpoint string) int {
+		resp, err := http.Get(host + endpoint) //nolint:noctx
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+
+		return resp.StatusCode
+	}
+
+	require.Equal(t, http.StatusOK, status("/readyz"))
+
+	cancel()
+	shutdownAt := time.Now()
+
+	require.Eventually(t, func() bool {
+		return status("/readyz") == http.StatusServiceUnavailable
+	}, drainDelay/2, 10*time.Millisecond, "readiness fails as soon as shutdown begins")
+
+	require.Equal(t, http.StatusOK, status("/healthz"), "the server keeps serving while it drains")
+	require.Less(t, time.Since(shutdownAt), drainDelay, "the checks ran within the drain window")
+}
@@ -14,12 +14,11 @@ func (s *IntegrationTestSuite) TestSongs() {
 	testID1 := uuid.New()
 
 	testSong1 := models.Song{
-		ID:          testID1,
-		ReleaseDate: "",
-		Name:        "song1",
-		Group:       "group1",
-		Text:        "",
-		Link:        "",
+		ID:    testID1,
+		Name:  "song1",
+		Group: "group1",
+		Text:  "",
+		Link:  "",
 	}
 
 	s.Run("POST", func() {
@@ -184,11 +183,11 @@ func (s *IntegrationTestSuite) TestSongs() {
 			var updatedSong models.Song
 
 			song := models.Song{
-				ReleaseDate: "01.01.2000",
+				ReleaseDate: parseReleaseDate(s.T(), "01.01.2000"),
 				Name:        "newName",
 				Group:       "newGroup",
 				Text:        "newText",
-				Link:        "newLink",
+				Link:        "https://example.com/newLink",
 			}
 
 			resp := s.sendRequest(
@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/models"
+	"github.com/iurikman/songs/internal/store"
+	migrate "github.com/rubenv/sql-migrate"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+func newSQLite(t *testing.T) *store.SQLite {
+	t.Helper()
+
+	db, err := store.NewSQLite(filepath.Join(t.TempDir(), "songs.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	require.NoError(t, db.Migrate(migrate.Up))
+
+	return db
+}
+
+func TestSQLiteStoreConformance(t *testing.T) {
+	suite.Run(t, &StoreConformanceSuite{newStore: func() songStore { return newSQLite(t) }})
+}
+
+func TestSQLiteSearch(t *testing.T) {
+	ctx := context.Background()
+	db := newSQLite(t)
+
+	for _, song := range []models.Song{
+		{Name: "Yellow Submarine", Group: "The Beatles", Text: "In the town where I was born"},
+		{Name: "Submarines", Group: "Björk", Text: "Deep under water"},
+		{Name: "Town Called Malice", Group: "The Jam", Text: "Better stop dreaming"},
+	} {
+		song.ID = uuid.New()
+		_, err := db.CreateSong(ctx, song)
+		require.NoError(t, err)
+	}
+
+	songs, err := db.Search(ctx, "submarine", models.Params{Limit: 10})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"Yellow Submarine", "Submarines"}, names(songs))
+
+	songs, err = db.Search(ctx, "town beatles", models.Params{Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, []string{"Yellow Submarine"}, names(songs))
+
+	songs, err = db.Search(ctx, `"malice OR`, models.Params{Limit: 10})
+	require.NoError(t, err)
+	require.Empty(t, songs, "search syntax is matched literally")
+
+	require.NoError(t, db.DeleteSong(ctx, songIDByName(t, db, "Town")))
+
+	songs, err = db.Search(ctx, "town", models.Params{Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, []string{"Yellow Submarine"}, names(songs))
+}
+
+func songIDByName(t *testing.T, db *store.SQLite, filter string) uuid.UUID {
+	t.Helper()
+
+	songs, err := db.GetSongs(context.Background(), models.Params{Limit: 1, Filter: filter})
+	require.NoError(t, err)
+	require.Len(t, songs, 1)
+
+	return songs[0].ID
+}
+
+func TestSQLiteRelatedSongsAreReweighed(t *testing.T) {
+	ctx := context.Background()
+	db, exact := newSQLite(t), store.NewMemory()
+
+	lyrics := []models.Song{
+		{Name: "Love Me Do", Group: "The Beatles", Text: "Love, love me do\n\nYou know I love you"},
+		{Name: "Love Me Tender", Group: "Elvis Presley", Text: "Love me tender, love me sweet"},
+		{Name: "All You Need Is Love", Group: "The Beatles", Text: "All you need is love"},
+		{Name: "Can't Buy Me Love", Group: "The Beatles", Text: "Can't buy me love, love, can't buy me love"},
+		{Name: "Love Hurts", Group: "Nazareth", Text: "Love hurts, love scars"},
+	}
+
+	for i := range lyrics {
+		lyrics[i].ID = uuid.New()
+
+		for _, s := range []songStore{db, exact} {
+			_, err := s.CreateSong(ctx, lyrics[i])
+			require.NoError(t, err)
+		}
+	}
+
+	for _, s := range []songStore{db, exact} {
+		require.NoError(t, s.DeleteSong(ctx, lyrics[4].ID))
+
+		_, err := s.UpdateSong(ctx, lyrics[2].ID, models.Song{Name: "All You Need Is Love", Group: "The Beatles",
+			Text: "All you need is love, love is all you need"})
+		require.NoError(t, err)
+	}
+
+	related := func(s songStore) []*models.RelatedSong {
+		related, err := s.RelatedSongs(ctx, lyrics[0].ID, models.Params{Limit: 10})
+		require.NoError(t, err)
+
+		return related
+	}
+
+	// The songs written first were weighed with fewer songs around.
+	stale, want := related(db), related(exact)
+	require.Len(t, stale, len(want))
+	require.NotEqual(t, want[len(want)-1].Similarity, stale[len(stale)-1].Similarity)
+
+	require.NoError(t, db.RefreshStats(ctx))
+	require.NoError(t, db.RefreshStats(ctx), "refreshing weighed terms changes nothing")
+
+	got := related(db)
+	require.Len(t, got, len(want))
+
+	for i := range want {
+		require.Equal(t, want[i].ID, got[i].ID)
+		require.InDelta(t, want[i].Similarity, got[i].Similarity, 1e-9, "refreshed weights are those of the memory store")
+	}
+}
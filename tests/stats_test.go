@@ -0,0 +1,97 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/models"
+)
+
+const statsAddress = "http://localhost:8080/api/v1/stats"
+
+// getStats returns the statistics served by the REST API.
+func (s *IntegrationTestSuite) getStats(query string) models.Stats {
+	s.T().Helper()
+
+	var stats struct {
+		Data models.Stats `json:"data"`
+	}
+
+	resp := s.sendRequestTo(context.Background(), http.MethodGet, statsAddress+query, nil, &stats)
+	s.Require().Equal(http.StatusOK, resp.StatusCode)
+
+	return stats.Data
+}
+
+func (s *IntegrationTestSuite) TestStatsEndpoint() {
+	ctx := context.Background()
+
+	for _, song := range []models.Song{
+		{Name: "Uprising", Group: "Stats Group A"},
+		{Name: "Resistance", Group: "Stats Group A"},
+		{Name: "Madness", Group: "Stats Group B"},
+	} {
+		song.ID = uuid.New()
+		resp := s.sendRequest(ctx, http.MethodPost, "/?force=true", song, nil)
+		s.Require().Equal(http.StatusCreated, resp.StatusCode)
+	}
+
+	s.Require().NoError(s.store.RefreshStats(ctx))
+
+	stats := s.getStats("?limit=100")
+	s.Require().NotNil(stats.RefreshedAt)
+	s.Require().WithinDuration(time.Now(), *stats.RefreshedAt, time.Minute)
+	s.Require().GreaterOrEqual(stats.Songs, 3)
+	s.Require().GreaterOrEqual(stats.Groups, 2)
+	s.Require().Subset(stats.ByGroup, []models.StatsCount{
+		{Key: "Stats Group A", Songs: 2},
+		{Key: "Stats Group B", Songs: 1},
+	})
+	s.Require().NotEmpty(stats.ByCreationMonth)
+	s.Require().Equal(time.Now().UTC().Format("2006-01"), stats.ByCreationMonth[len(stats.ByCreationMonth)-1].Key)
+
+	// The song details API gives the songs lyrics and a release date.
+	s.Require().Positive(stats.Lyrics.Songs)
+	s.Require().Positive(stats.Lyrics.MaxVerses)
+	s.Require().Positive(stats.Lyrics.TotalWords)
+	s.Require().Positive(stats.Completeness.Text)
+	s.Require().Positive(stats.Completeness.ReleaseDate)
+	s.Require().NotEmpty(stats.ByReleaseYear)
+
+	s.Require().Len(s.getStats("?limit=1").ByGroup, 1, "groups are paged")
+
+	resp := s.sendRequestTo(ctx, http.MethodGet, statsAddress+"?limit=-1", nil, nil)
+	s.Require().Equal(http.StatusBadRequest, resp.StatusCode)
+}
+
+func (s *IntegrationTestSuite) TestStatsRefresher() {
+	ctx := context.Background()
+
+	s.Require().NoError(s.store.RefreshStats(ctx))
+
+	before := s.getStats("")
+
+	resp := s.sendRequest(ctx, http.MethodPost, "/?force=true",
+		models.Song{ID: uuid.New(), Name: "Hysteria", Group: "Stats Refresher"}, nil)
+	s.Require().Equal(http.StatusCreated, resp.StatusCode)
+
+	s.Require().Equal(before.Songs, s.getStats("").Songs, "the statistics are only refreshed by the refresher")
+
+	refreshCtx, cancel := context.WithCancel(ctx)
+	done := make(chan error, 1)
+
+	go func() {
+		done <- s.store.RefreshStatsEvery(refreshCtx, 50*time.Millisecond)
+	}()
+
+	s.Require().Eventually(func() bool {
+		stats := s.getStats("")
+
+		return stats.Songs == before.Songs+1 && stats.RefreshedAt.After(*before.RefreshedAt)
+	}, 5*time.Second, 50*time.Millisecond, "the refresher picks up the new song")
+
+	cancel()
+	s.Require().NoError(<-done)
+}
@@ -0,0 +1,87 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/models"
+)
+
+const tagsAddress = "http://localhost:8080/api/v1/tags"
+
+func (s *IntegrationTestSuite) TestTagEndpoints() {
+	ctx := context.Background()
+
+	var rock, punk struct {
+		Data models.Tag `json:"data"`
+	}
+
+	resp := s.sendRequestTo(ctx, http.MethodPost, tagsAddress, models.Tag{Slug: "rock", Name: "Rock"}, &rock)
+	s.Require().Equal(http.StatusCreated, resp.StatusCode)
+
+	resp = s.sendRequestTo(ctx, http.MethodPost, tagsAddress,
+		models.Tag{Slug: "punk", Name: "Punk", ParentID: &rock.Data.ID}, &punk)
+	s.Require().Equal(http.StatusCreated, resp.StatusCode)
+	s.Require().Equal(rock.Data.ID, *punk.Data.ParentID)
+
+	resp = s.sendRequestTo(ctx, http.MethodPost, tagsAddress, models.Tag{Slug: "rock", Name: "Rock"}, nil)
+	s.Require().Equal(http.StatusConflict, resp.StatusCode)
+
+	resp = s.sendRequestTo(ctx, http.MethodPost, tagsAddress, models.Tag{Slug: "Rock & Roll", Name: "Rock"}, nil)
+	s.Require().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+
+	resp = s.sendRequestTo(ctx, http.MethodPatch, tagsAddress+"/"+rock.Data.ID.String(),
+		models.Tag{Slug: "rock", Name: "Rock", ParentID: &punk.Data.ID}, nil)
+	s.Require().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+
+	var song struct {
+		Data models.Song `json:"data"`
+	}
+
+	resp = s.sendRequest(ctx, http.MethodPost, "/?force=true",
+		models.Song{ID: uuid.New(), Name: "Anarchy in the U.K.", Group: "Sex Pistols"}, &song)
+	s.Require().Equal(http.StatusCreated, resp.StatusCode)
+
+	var tags struct {
+		Data []*models.Tag `json:"data"`
+	}
+
+	tagsPath := "/" + song.Data.ID.String() + "/tags"
+
+	resp = s.sendRequest(ctx, http.MethodPut, tagsPath, models.SongTags{Tags: []string{"punk"}}, &tags)
+	s.Require().Equal(http.StatusOK, resp.StatusCode)
+	s.Require().Equal([]string{"punk"}, slugsOf(tags.Data))
+
+	resp = s.sendRequest(ctx, http.MethodPut, tagsPath, models.SongTags{Tags: []string{"jazz"}}, nil)
+	s.Require().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+
+	resp = s.sendRequest(ctx, http.MethodGet, tagsPath, nil, &tags)
+	s.Require().Equal(http.StatusOK, resp.StatusCode)
+	s.Require().Equal([]string{"punk"}, slugsOf(tags.Data))
+
+	var list struct {
+		Data   []*models.Song     `json:"data"`
+		Facets []*models.TagFacet `json:"facets"`
+	}
+
+	resp = s.sendRequest(ctx, http.MethodGet, "?tag=rock", nil, &list)
+	s.Require().Equal(http.StatusOK, resp.StatusCode)
+	s.Require().Empty(list.Data)
+	s.Require().Empty(list.Facets, "facets are only given when asked for")
+
+	resp = s.sendRequest(ctx, http.MethodGet, "?tag=rock&descendants=true&facets=true", nil, &list)
+	s.Require().Equal(http.StatusOK, resp.StatusCode)
+	s.Require().Equal([]string{"Anarchy in the U.K."}, names(list.Data))
+	s.Require().Equal(map[string]int{"rock": 1, "punk": 1}, countsOf(list.Facets))
+
+	resp = s.sendRequestTo(ctx, http.MethodDelete, tagsAddress+"/"+punk.Data.ID.String(), nil, nil)
+	s.Require().Equal(http.StatusNoContent, resp.StatusCode)
+
+	resp = s.sendRequestTo(ctx, http.MethodDelete, tagsAddress+"/"+punk.Data.ID.String(), nil, nil)
+	s.Require().Equal(http.StatusNotFound, resp.StatusCode)
+
+	resp = s.sendRequestTo(ctx, http.MethodGet, tagsAddress, nil, &tags)
+	s.Require().Equal(http.StatusOK, resp.StatusCode)
+	s.Require().Equal([]string{"rock"}, slugsOf(tags.Data))
+}
@@ -0,0 +1,226 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/models"
+	"github.com/iurikman/songs/internal/service"
+	"github.com/iurikman/songs/internal/songdetails"
+	"github.com/iurikman/songs/internal/store"
+	"github.com/iurikman/songs/internal/telemetry"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	recordingSpans sync.Once
+	recordedSpans  *tracetest.InMemoryExporter
+)
+
+// recordSpans has the spans of the service recorded in memory and forgets those
+// recorded before. The global tracer provider is only installed once, as the
+// tracers taken before it was are bound to the first one installed.
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+
+	recordingSpans.Do(func() {
+		recordedSpans = tracetest.NewInMemoryExporter()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(recordedSpans)))
+	})
+
+	// Without an exporter Setup only installs the W3C propagator.
+	_, err := telemetry.Setup(context.Background(), telemetry.Config{Exporter: telemetry.ExporterNone})
+	require.NoError(t, err)
+
+	recordedSpans.Reset()
+
+	return recordedSpans
+}
+
+// traceSpans returns the spans recorded in the trace of root.
+func traceSpans(exporter *tracetest.InMemoryExporter, root trace.SpanContext) tracetest.SpanStubs {
+	var traced tracetest.SpanStubs
+
+	for _, span := range exporter.GetSpans() {
+		if span.SpanContext.TraceID() == root.TraceID() {
+			traced = append(traced, span)
+		}
+	}
+
+	return traced
+}
+
+// spanNamed returns the span named name among spans.
+func spanNamed(t *testing.T, spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+	t.Helper()
+
+	for _, span := range spans {
+		if span.Name == name {
+			return span
+		}
+	}
+
+	require.Failf(t, "span not recorded", "no span named %q", name)
+
+	return tracetest.SpanStub{}
+}
+
+// clientSpanOf returns the HTTP client span started under parent.
+func clientSpanOf(t *testing.T, spans tracetest.SpanStubs, parent tracetest.SpanStub) tracetest.SpanStub {
+	t.Helper()
+
+	for _, span := range spans {
+		if span.SpanKind == trace.SpanKindClient && span.Parent.SpanID() == parent.SpanContext.SpanID() {
+			return span
+		}
+	}
+
+	require.Failf(t, "span not recorded", "no client span under %q", parent.Name)
+
+	return tracetest.SpanStub{}
+}
+
+// traceparents records the traceparent headers a song details API gets.
+type traceparents struct {
+	mu      sync.Mutex
+	headers []http.Header
+}
+
+func (p *traceparents) record(r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.headers = append(p.headers, r.Header.Clone())
+}
+
+// joined returns the span context the song details API got for the trace of
+// root, which is invalid when it got none.
+func (p *traceparents) joined(root trace.SpanContext) trace.SpanContext {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, header := range p.headers {
+		remote := trace.SpanContextFromContext(
+			propagation.TraceContext{}.Extract(context.Background(), propagation.HeaderCarrier(header)))
+		if remote.TraceID() == root.TraceID() {
+			return remote
+		}
+	}
+
+	return trace.SpanContext{}
+}
+
+// upstreamTraceparents are those the song details API of the integration
+// suite got.
+var upstreamTraceparents traceparents
+
+func TestTracingJoinsSongDetails(t *testing.T) {
+	exporter := recordSpans(t)
+
+	var got traceparents
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.record(r)
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(models.SongDetails{ReleaseDate: "16.07.2006", Text: "Ooh baby"})
+	}))
+	defer upstream.Close()
+
+	svc := service.NewService(store.NewMemory(), songdetails.NewSongDetails(songdetails.Config{Host: upstream.URL}))
+
+	ctx, root := telemetry.Tracer().Start(context.Background(), "test")
+	_, err := svc.CreateSong(ctx, models.Song{ID: uuid.New(), Name: "Supermassive Black Hole", Group: "Muse"}, true)
+	root.End()
+	require.NoError(t, err)
+
+	traced := traceSpans(exporter, root.SpanContext())
+
+	create := spanNamed(t, traced, "Service.CreateSong")
+	require.Equal(t, root.SpanContext().SpanID(), create.Parent.SpanID())
+
+	details := spanNamed(t, traced, "SongDetails.Get")
+	require.Equal(t, create.SpanContext.SpanID(), details.Parent.SpanID())
+
+	request := clientSpanOf(t, traced, details)
+
+	remote := got.joined(root.SpanContext())
+	require.True(t, remote.IsValid(), "the traceparent is sent upstream")
+	require.Equal(t, request.SpanContext.SpanID(), remote.SpanID(), "the upstream joins the trace under the request")
+}
+
+func (s *IntegrationTestSuite) TestTracing() {
+	exporter := recordSpans(s.T())
+
+	ctx, root := telemetry.Tracer().Start(context.Background(), "client")
+
+	body, err := json.Marshal(models.Song{ID: uuid.New(), Name: "Starlight", Group: "Muse"})
+	s.Require().NoError(err)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, bindAddress+"/?force=true", bytes.NewReader(body))
+	s.Require().NoError(err)
+
+	req.Header.Set("Content-Type", "application/json")
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := http.DefaultClient.Do(req)
+	s.Require().NoError(err)
+	s.Require().NoError(resp.Body.Close())
+	s.Require().Equal(http.StatusCreated, resp.StatusCode)
+
+	root.End()
+
+	// The server span ends once the response is written.
+	var server tracetest.SpanStub
+
+	s.Require().Eventually(func() bool {
+		for _, span := range traceSpans(exporter, root.SpanContext()) {
+			if span.SpanKind == trace.SpanKindServer {
+				server = span
+
+				return true
+			}
+		}
+
+		return false
+	}, time.Second, 10*time.Millisecond, "the handler span joins the trace of the client")
+
+	s.Require().Equal(root.SpanContext().SpanID(), server.Parent.SpanID())
+	s.Require().Equal("POST /api/v1/songs/", server.Name)
+
+	traced := traceSpans(exporter, root.SpanContext())
+
+	create := spanNamed(s.T(), traced, "Service.CreateSong")
+	s.Require().Equal(server.SpanContext.SpanID(), create.Parent.SpanID())
+
+	details := spanNamed(s.T(), traced, "SongDetails.Get")
+	s.Require().Equal(create.SpanContext.SpanID(), details.Parent.SpanID())
+
+	remote := upstreamTraceparents.joined(root.SpanContext())
+	s.Require().True(remote.IsValid(), "the traceparent is sent upstream")
+	s.Require().Equal(clientSpanOf(s.T(), traced, details).SpanContext.SpanID(), remote.SpanID())
+
+	queries := 0
+
+	for _, span := range traced {
+		if span.Name == "pgx.query" {
+			s.Require().Equal(create.SpanContext.SpanID(), span.Parent.SpanID(), "queries run under the service span")
+
+			queries++
+		}
+	}
+
+	s.Require().Positive(queries, "the queries of the store join the trace")
+}
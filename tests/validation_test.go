@@ -0,0 +1,166 @@
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/iurikman/songs/internal/models"
+	"github.com/iurikman/songs/internal/validation"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateSong(t *testing.T) {
+	valid := models.Song{
+		Name:  "Hysteria",
+		Group: "Muse",
+		Text:  "It's bugging me",
+		Link:  "https://www.youtube.com/watch?v=3dm_5qWWDV8",
+	}
+	require.NoError(t, validation.Song(valid))
+
+	withoutLink := valid
+	withoutLink.Link = ""
+	require.NoError(t, validation.Song(withoutLink))
+
+	for _, tc := range []struct {
+		name   string
+		song   models.Song
+		fields []string
+		codes  []string
+	}{
+		{
+			name:   "missing name and group",
+			song:   models.Song{Name: " ", Link: valid.Link},
+			fields: []string{"name", "musicGroup"},
+			codes:  []string{validation.CodeRequired, validation.CodeRequired},
+		},
+		{
+			name: "too long",
+			song: models.Song{
+				Name:  strings.Repeat("я", validation.MaxNameLength+1),
+				Group: strings.Repeat("g", validation.MaxGroupLength+1),
+				Text:  strings.Repeat("t", validation.MaxTextBytes+1),
+			},
+			fields: []string{"name", "musicGroup", "text"},
+			codes:  []string{validation.CodeTooLong, validation.CodeTooLong, validation.CodeTooLong},
+		},
+		{
+			name:   "relative link",
+			song:   models.Song{Name: "Hysteria", Group: "Muse", Link: "newLink"},
+			fields: []string{"link"},
+			codes:  []string{validation.CodeInvalidURL},
+		},
+		{
+			name:   "not http link",
+			song:   models.Song{Name: "Hysteria", Group: "Muse", Link: "ftp://example.com/hysteria"},
+			fields: []string{"link"},
+			codes:  []string{validation.CodeInvalidURL},
+		},
+		{
+			name:   "too long link",
+			song:   models.Song{Name: "Hysteria", Group: "Muse", Link: "https://example.com/" + strings.Repeat("a", validation.MaxLinkLength)},
+			fields: []string{"link"},
+			codes:  []string{validation.CodeTooLong},
+		},
+	} {
+		err := validation.Song(tc.song)
+		require.ErrorIs(t, err, validation.ErrInvalid, tc.name)
+
+		var violations validation.Errors
+		require.True(t, errors.As(err, &violations), tc.name)
+
+		fields := make([]string, 0, len(violations))
+		codes := make([]string, 0, len(violations))
+
+		for _, violation := range violations {
+			fields = append(fields, violation.Field)
+			codes = append(codes, violation.Code)
+			require.NotEmpty(t, violation.Message, tc.name)
+		}
+
+		require.Equal(t, tc.fields, fields, tc.name)
+		require.Equal(t, tc.codes, codes, tc.name)
+	}
+}
+
+func TestValidateWebhook(t *testing.T) {
+	eventTypes := []string{models.EventSongCreated, models.EventSongDeleted}
+
+	require.NoError(t, validation.Webhook(models.Webhook{
+		URL:        "https://example.com/hook",
+		EventTypes: []string{models.EventSongCreated},
+	}, eventTypes))
+
+	err := validation.Webhook(models.Webhook{
+		URL:        "example.com/hook",
+		EventTypes: []string{models.EventSongCreated, "song.played"},
+	}, eventTypes)
+
+	var violations validation.Errors
+	require.ErrorAs(t, err, &violations)
+	require.Equal(t, validation.Errors{
+		{Field: "url", Code: validation.CodeInvalidURL, Message: "must be an absolute http or https URL"},
+		{Field: "eventTypes[1]", Code: validation.CodeInvalidValue, Message: "must be one of song.created, song.deleted"},
+	}, violations)
+
+	err = validation.Webhook(models.Webhook{}, eventTypes)
+	require.ErrorAs(t, err, &violations)
+	require.Equal(t, "url", violations[0].Field)
+	require.Equal(t, validation.CodeRequired, violations[0].Code)
+}
+
+func TestValidateTag(t *testing.T) {
+	id := uuid.New()
+
+	require.NoError(t, validation.Tag(models.Tag{ID: id, Slug: "punk-rock", Name: "Punk rock"}))
+
+	err := validation.Tag(models.Tag{ID: id, Slug: "Punk Rock", Name: " ", ParentID: &id})
+
+	var violations validation.Errors
+	require.ErrorAs(t, err, &violations)
+	require.Equal(t, validation.Errors{
+		{Field: "slug", Code: validation.CodeInvalidValue, Message: "must be lowercase letters and digits joined by hyphens"},
+		{Field: "name", Code: validation.CodeRequired, Message: "is required"},
+		{Field: "parentId", Code: validation.CodeInvalidValue, Message: "must not be the tag itself"},
+	}, violations)
+
+	require.NoError(t, validation.SongTags(models.SongTags{}))
+
+	err = validation.SongTags(models.SongTags{Tags: []string{"rock", "-rock", strings.Repeat("a", validation.MaxSlugLength+1)}})
+	require.ErrorAs(t, err, &violations)
+	require.Equal(t, []string{"tags[1]", "tags[2]"}, []string{violations[0].Field, violations[1].Field})
+	require.Equal(t, validation.CodeTooLong, violations[1].Code)
+}
+
+func TestDecodeJSON(t *testing.T) {
+	var song models.Song
+
+	err := validation.DecodeJSON(strings.NewReader(`{"name":"Hysteria","musicGroup":"Muse","releaseDate":"2003"}`), &song)
+	require.NoError(t, err)
+	require.Equal(t, "Muse", song.Group)
+	require.Equal(t, "2003", song.ReleaseDate.String())
+
+	for _, tc := range []struct {
+		body, field, code string
+	}{
+		{`{"name":"Hysteria","album":"Absolution"}`, "album", validation.CodeUnknownField},
+		{`{"name":42}`, "name", validation.CodeInvalidType},
+		{`{"releaseDate":"summer 2003"}`, "releaseDate", validation.CodeInvalidValue},
+	} {
+		var violations validation.Errors
+
+		err := validation.DecodeJSON(strings.NewReader(tc.body), &models.Song{})
+		require.ErrorAs(t, err, &violations, tc.body)
+		require.Len(t, violations, 1, tc.body)
+		require.Equal(t, tc.field, violations[0].Field, tc.body)
+		require.Equal(t, tc.code, violations[0].Code, tc.body)
+	}
+
+	for _, body := range []string{``, `{"name":`, `[]`, `{"name":"Hysteria"} {}`} {
+		err := validation.DecodeJSON(strings.NewReader(body), &models.Song{})
+		require.ErrorIs(t, err, validation.ErrMalformed, body)
+		require.NotErrorIs(t, err, validation.ErrInvalid, body)
+	}
+}